@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"quota-manager/internal/services"
+	"strings"
+	"time"
+)
+
+const configAPIPath = "/quota-manager/api/v1/config"
+
+// runConfigCommand implements `quota-manager config <validate|plan|apply|export>`.
+// plan/apply/export talk to a running server so that cron registrations and
+// gateway permissions stay in sync with the database.
+// It returns the process exit code.
+func runConfigCommand(args []string) int {
+	if len(args) == 0 {
+		printConfigUsage()
+		return 1
+	}
+
+	sub := args[0]
+	fs := flag.NewFlagSet("config "+sub, flag.ContinueOnError)
+	var file, server string
+	var detailedExitCode bool
+	fs.StringVar(&file, "f", "", "Path to the desired state file (YAML or JSON)")
+	fs.StringVar(&server, "server", "http://localhost:8099", "Base URL of the running quota-manager server")
+	fs.BoolVar(&detailedExitCode, "detailed-exitcode", false, "plan: exit 0 when there are no changes, 2 when there are changes, 1 on error")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 1
+	}
+
+	switch sub {
+	case "validate":
+		if _, err := readDesiredStateFile(file); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid desired state: %v\n", err)
+			return 1
+		}
+		fmt.Println("Desired state is valid")
+		return 0
+	case "plan", "apply":
+		if _, err := readDesiredStateFile(file); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid desired state: %v\n", err)
+			return 1
+		}
+		body, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read %s: %v\n", file, err)
+			return 1
+		}
+
+		var plan services.ConfigPlan
+		if err := callConfigAPI(http.MethodPost, server+configAPIPath+"/"+sub, body, &plan); err != nil {
+			fmt.Fprintf(os.Stderr, "%s failed: %v\n", sub, err)
+			return 1
+		}
+		printPlan(&plan, sub == "apply")
+
+		if sub == "plan" && detailedExitCode && plan.HasChanges() {
+			return 2
+		}
+		return 0
+	case "export":
+		resp, err := configHTTPClient().Get(server + configAPIPath + "/export?format=yaml")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+			return 1
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			fmt.Fprintf(os.Stderr, "export failed: %s\n", readAPIError(resp.Body))
+			return 1
+		}
+		io.Copy(os.Stdout, resp.Body)
+		return 0
+	default:
+		printConfigUsage()
+		return 1
+	}
+}
+
+func printConfigUsage() {
+	fmt.Println("Usage:")
+	fmt.Printf("  %s config validate -f <file>\n", os.Args[0])
+	fmt.Printf("  %s config plan -f <file> [-server <url>] [-detailed-exitcode]\n", os.Args[0])
+	fmt.Printf("  %s config apply -f <file> [-server <url>]\n", os.Args[0])
+	fmt.Printf("  %s config export [-server <url>]\n", os.Args[0])
+}
+
+func readDesiredStateFile(file string) (*services.DesiredState, error) {
+	if file == "" {
+		return nil, fmt.Errorf("-f is required")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return services.ParseDesiredState(data)
+}
+
+func configHTTPClient() *http.Client {
+	return &http.Client{Timeout: 60 * time.Second}
+}
+
+// callConfigAPI sends body to url and decodes the data field of the response envelope into out
+func callConfigAPI(method, url string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/yaml")
+
+	resp, err := configHTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", readAPIError(resp.Body))
+	}
+
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
+
+func readAPIError(r io.Reader) string {
+	var envelope struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	data, _ := io.ReadAll(r)
+	if err := json.Unmarshal(data, &envelope); err != nil || envelope.Message == "" {
+		return strings.TrimSpace(string(data))
+	}
+	return fmt.Sprintf("%s (%s)", envelope.Message, envelope.Code)
+}
+
+func printPlan(plan *services.ConfigPlan, applied bool) {
+	if !plan.HasChanges() {
+		fmt.Println("No changes. Live configuration matches the desired state.")
+		return
+	}
+
+	for _, change := range plan.Changes {
+		symbol := map[services.PlanAction]string{
+			services.PlanActionCreate: "+",
+			services.PlanActionUpdate: "~",
+			services.PlanActionDelete: "-",
+		}[change.Action]
+		fmt.Printf("%s %s %s\n", symbol, change.Kind, change.Key)
+		for _, field := range change.Fields {
+			fmt.Printf("    %s\n", field)
+		}
+	}
+
+	verb := "Plan"
+	if applied {
+		verb = "Applied"
+	}
+	fmt.Printf("\n%s: %d to create, %d to update, %d to delete.\n",
+		verb, plan.Summary.Create, plan.Summary.Update, plan.Summary.Delete)
+}
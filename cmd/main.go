@@ -38,6 +38,11 @@ func setTimezone(timezone string) {
 }
 
 func main() {
+	// Subcommands are dispatched before the server flags are parsed
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
+	}
+
 	// Parse command line flags FIRST - before any other initialization
 	var configFile string
 	var showHelp bool
@@ -55,6 +60,7 @@ func main() {
 		fmt.Println()
 		fmt.Println("Usage:")
 		fmt.Printf("  %s [options]\n", os.Args[0])
+		fmt.Printf("  %s config <validate|plan|apply|export> [options]\n", os.Args[0])
 		fmt.Println()
 		fmt.Println("Options:")
 		flag.PrintDefaults()
@@ -126,6 +132,8 @@ func main() {
 	voucherService := services.NewVoucherService(cfg.Voucher.SigningKey)
 	quotaService := services.NewQuotaService(db, configManager, gateway, voucherService)
 	strategyService := services.NewStrategyService(db, gateway, quotaService, &cfg.EmployeeSync)
+	// Low balances seen by quota queries trigger topup strategies
+	quotaService.SetBalanceObserver(strategyService.CheckTopupForUser)
 
 	// Initialize permission management services
 	permissionService := services.NewPermissionService(db, &cfg.AiGateway, &cfg.EmployeeSync, gateway)
@@ -154,6 +162,17 @@ func main() {
 
 	// Initialize HTTP handlers
 	strategyHandler := handlers.NewStrategyHandler(strategyService)
+	strategyApprovalHandler := handlers.NewStrategyApprovalHandler(strategyService, &cfg.Server)
+	dripHandler := handlers.NewDripHandler(strategyService)
+	outboxHandler := handlers.NewOutboxHandler(quotaService)
+	reconciliationHandler := handlers.NewReconciliationHandler(quotaService)
+	quotaAuditHandler := handlers.NewQuotaAuditHandler(quotaService)
+	quotaLedgerHandler := handlers.NewQuotaLedgerHandler(quotaService)
+	usageAnalyticsHandler := handlers.NewUsageAnalyticsHandler(quotaService)
+	modelCatalogHandler := handlers.NewModelCatalogHandler(quotaService, &cfg.Server)
+	creditLimitHandler := handlers.NewCreditLimitHandler(quotaService, &cfg.Server)
+	departmentPoolHandler := handlers.NewDepartmentPoolHandler(quotaService, &cfg.Server)
+	balanceCapHandler := handlers.NewBalanceCapHandler(quotaService, &cfg.Server)
 	quotaHandler := handlers.NewQuotaHandler(quotaService, &cfg.Server)
 	modelPermissionHandler := handlers.NewModelPermissionHandler(permissionService)
 	starCheckPermissionHandler := handlers.NewStarCheckPermissionHandler(starCheckPermissionService)
@@ -163,6 +182,8 @@ func main() {
 	aigatewayAdminService := services.NewAiGatewayAdminService(gateway)
 	aigatewayAdminHandler := handlers.NewAiGatewayAdminHandler(aigatewayAdminService)
 	scanHandler := handlers.NewScanHandler(strategyService, unifiedPermissionService, schedulerService, quotaService)
+	declarativeConfigService := services.NewDeclarativeConfigService(db, strategyService, permissionService, starCheckPermissionService, quotaCheckPermissionService)
+	declarativeConfigHandler := handlers.NewDeclarativeConfigHandler(declarativeConfigService)
 
 	// Set Gin mode
 	gin.SetMode(cfg.Server.Mode)
@@ -219,11 +240,112 @@ func main() {
 
 				// Strategy execution records
 				strategies.GET("/:id/executions", strategyHandler.GetStrategyExecuteRecords)
+				strategies.GET("/:id/topups", strategyHandler.GetTopupExecuteRecords)
+
+				// Approval workflow for strategies above the configured thresholds
+				strategies.POST("/:id/submit", strategyApprovalHandler.SubmitStrategy)
+				strategies.POST("/:id/approve", strategyApprovalHandler.ApproveStrategy)
+				strategies.POST("/:id/reject", strategyApprovalHandler.RejectStrategy)
+				strategies.GET("/:id/approvals", strategyApprovalHandler.GetStrategyApprovals)
+
+				// Shadow mode: would-be grants and comparison with a live strategy
+				strategies.GET("/:id/shadow-results", strategyHandler.GetShadowResults)
+				strategies.GET("/:id/shadow-compare", strategyHandler.CompareShadowStrategy)
+			}
+
+			// Drip grant plans: outstanding installments per user
+			dripPlans := v1.Group("/drip-plans")
+			{
+				dripPlans.GET("", dripHandler.GetUserDripPlans)
+				dripPlans.POST("/cancel", dripHandler.CancelUserDripPlans)
 			}
 
 			// Quota management API
 			handlers.RegisterQuotaRoutes(v1, quotaHandler)
 
+			// AiGateway outbox: inspect and retry undelivered gateway mutations
+			gatewayOutbox := v1.Group("/gateway-outbox")
+			{
+				gatewayOutbox.GET("", outboxHandler.GetOutboxEntries)
+				gatewayOutbox.POST("/:id/retry", outboxHandler.RetryOutboxEntry)
+			}
+
+			// Quota audit search across all users, with totals, accounting exports and hash chain verification
+			quotaAudit := v1.Group("/quota-audit")
+			{
+				quotaAudit.GET("", quotaAuditHandler.SearchQuotaAudit)
+				quotaAudit.GET("/summary", quotaAuditHandler.SummarizeQuotaAudit)
+				quotaAudit.GET("/export", quotaAuditHandler.ExportQuotaAudit)
+				quotaAudit.GET("/verify", quotaAuditHandler.VerifyAuditChain)
+				quotaAudit.POST("/verify", quotaAuditHandler.StartAuditChainVerification)
+				quotaAudit.GET("/chain-breaks", quotaAuditHandler.GetAuditChainBreaks)
+			}
+
+			// Quota ledger rebuilt from the audit log: point-in-time balances and replay
+			quotaLedger := v1.Group("/quota-ledger")
+			{
+				quotaLedger.GET("/balance", quotaLedgerHandler.GetBalanceAt)
+				quotaLedger.POST("/replay", quotaLedgerHandler.ReplayQuotaLedger)
+			}
+
+			// Usage analytics over the daily usage snapshots
+			usageAnalytics := v1.Group("/usage-analytics")
+			{
+				usageAnalytics.GET("/users/:user_id", usageAnalyticsHandler.GetUserUsageSeries)
+				usageAnalytics.GET("/top-consumers", usageAnalyticsHandler.GetTopConsumers)
+				usageAnalytics.GET("/departments", usageAnalyticsHandler.GetDepartmentUsage)
+				usageAnalytics.GET("/trends", usageAnalyticsHandler.GetUsageTrends)
+			}
+
+			// Reconciliation of the quota ledger, audit log and AiGateway balances
+			reconciliation := v1.Group("/reconciliation/reports")
+			{
+				reconciliation.POST("", reconciliationHandler.StartReconciliation)
+				reconciliation.GET("", reconciliationHandler.GetReconciliationReports)
+				reconciliation.GET("/:id", reconciliationHandler.GetReconciliationReport)
+				reconciliation.GET("/:id/export", reconciliationHandler.ExportReconciliationReport)
+			}
+
+			// Model cost catalog: versioned multipliers weighting usage of premium models
+			modelCatalog := v1.Group("/model-catalog")
+			{
+				modelCatalog.GET("", modelCatalogHandler.GetModelCatalog)
+				modelCatalog.PUT("", modelCatalogHandler.SetModelCost)
+				modelCatalog.DELETE("", modelCatalogHandler.RemoveModelCost)
+				modelCatalog.GET("/history", modelCatalogHandler.GetModelCostHistory)
+				modelCatalog.POST("/push", modelCatalogHandler.PushModelCatalog)
+			}
+
+			// Credit limits: how far below zero a user's available balance may go
+			creditLimits := v1.Group("/credit-limits")
+			{
+				creditLimits.GET("", creditLimitHandler.GetCreditLimits)
+				creditLimits.POST("/user", creditLimitHandler.SetUserCreditLimit)
+				creditLimits.POST("/department", creditLimitHandler.SetDepartmentCreditLimit)
+				creditLimits.GET("/user/:user_id", creditLimitHandler.GetUserCredit)
+			}
+
+			// Balance caps: the maximum balance strategy grants and transfers stop at
+			balanceCaps := v1.Group("/balance-caps")
+			{
+				balanceCaps.GET("", balanceCapHandler.GetBalanceCaps)
+				balanceCaps.POST("/user", balanceCapHandler.SetUserBalanceCap)
+				balanceCaps.POST("/department", balanceCapHandler.SetDepartmentBalanceCap)
+				balanceCaps.DELETE("/:id", balanceCapHandler.DeleteBalanceCap)
+				balanceCaps.GET("/user/:user_id", balanceCapHandler.GetUserBalanceCap)
+			}
+
+			// Department pools: shared department budgets members draw from once their own quota runs out
+			departmentPools := v1.Group("/department-pools")
+			{
+				departmentPools.GET("", departmentPoolHandler.GetPools)
+				departmentPools.POST("", departmentPoolHandler.SavePool)
+				departmentPools.GET("/:id", departmentPoolHandler.GetPool)
+				departmentPools.POST("/:id/fund", departmentPoolHandler.FundPool)
+				departmentPools.GET("/:id/ledger", departmentPoolHandler.GetPoolLedger)
+				departmentPools.GET("/:id/usage", departmentPoolHandler.GetPoolUsage)
+			}
+
 			// Model permissions management
 			modelPermissions := v1.Group("/model-permissions")
 			{
@@ -254,9 +376,20 @@ func main() {
 			// Unified query and sync interfaces
 			v1.GET("/effective-permissions", unifiedPermissionHandler.GetEffectivePermissions)
 
+			// Cron expression preview for periodic strategies
+			v1.GET("/cron/preview", strategyHandler.PreviewCron)
+
 			// Unified scan interface
 			v1.POST("/scan", scanHandler.TriggerScan)
 
+			// Declarative configuration (desired state kept in git)
+			configGroup := v1.Group("/config")
+			{
+				configGroup.POST("/plan", declarativeConfigHandler.Plan)
+				configGroup.POST("/apply", declarativeConfigHandler.Apply)
+				configGroup.GET("/export", declarativeConfigHandler.Export)
+			}
+
 			// AiGateway passthrough admin APIs
 			aigw := v1.Group("/aigateway")
 			{
@@ -1,10 +1,14 @@
 package condition
 
-import "quota-manager/pkg/aigateway"
+import (
+	"quota-manager/pkg/aigateway"
+	"quota-manager/pkg/decimal"
+)
 
 // AiGatewayQuotaQuerier adapts aigateway.Client to implement QuotaQuerier interface
 type AiGatewayQuotaQuerier struct {
-	client *aigateway.Client
+	client    *aigateway.Client
+	modelPool func(model string) string // resolves a model to its quota pool, nil = no pools
 }
 
 // NewAiGatewayQuotaQuerier creates a new adapter for aigateway.Client
@@ -12,7 +16,22 @@ func NewAiGatewayQuotaQuerier(client *aigateway.Client) QuotaQuerier {
 	return &AiGatewayQuotaQuerier{client: client}
 }
 
+// NewAiGatewayModelQuotaQuerier creates an adapter that also reads the quota of model pools,
+// modelPool resolving a model to its pool ("" for the "any model" pool)
+func NewAiGatewayModelQuotaQuerier(client *aigateway.Client, modelPool func(model string) string) QuotaQuerier {
+	return &AiGatewayQuotaQuerier{client: client, modelPool: modelPool}
+}
+
 // QueryQuota implements QuotaQuerier interface
-func (a *AiGatewayQuotaQuerier) QueryQuota(userID string) (float64, error) {
+func (a *AiGatewayQuotaQuerier) QueryQuota(userID string) (decimal.Decimal, error) {
 	return a.client.QueryQuotaValue(userID)
 }
+
+// QueryModelQuota implements ModelQuotaQuerier interface. Models outside every pool
+// read the "any model" pool.
+func (a *AiGatewayQuotaQuerier) QueryModelQuota(userID, model string) (decimal.Decimal, error) {
+	if a.modelPool == nil {
+		return a.QueryQuota(userID)
+	}
+	return a.client.QueryQuotaValueForModel(userID, a.modelPool(model))
+}
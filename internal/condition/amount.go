@@ -0,0 +1,469 @@
+package condition
+
+import (
+	"fmt"
+	"math"
+	"quota-manager/internal/models"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// UsageQuerier interface for querying recorded monthly usage
+type UsageQuerier interface {
+	QueryMonthlyUsedQuota(userID, yearMonth string) (float64, error)
+}
+
+// AmountVariables are the per-user inputs available to amount expressions
+var AmountVariables = []string{"vip", "used_last_month", "quota"}
+
+// AmountFunctions are the functions available to amount expressions
+var AmountFunctions = []string{"min", "max", "round", "floor", "ceil", "department_lookup"}
+
+// AmountExpr is a parsed amount expression
+type AmountExpr interface {
+	Eval(user *models.UserInfo, ctx *EvaluationContext, inputs map[string]interface{}) (float64, error)
+}
+
+// numberNode is a numeric literal
+type numberNode struct {
+	Value float64
+}
+
+func (n *numberNode) Eval(user *models.UserInfo, ctx *EvaluationContext, inputs map[string]interface{}) (float64, error) {
+	return n.Value, nil
+}
+
+// variableNode is a per-user input; its value is recorded in inputs
+type variableNode struct {
+	Name string
+}
+
+func (v *variableNode) Eval(user *models.UserInfo, ctx *EvaluationContext, inputs map[string]interface{}) (float64, error) {
+	if value, ok := inputs[v.Name].(float64); ok {
+		return value, nil
+	}
+
+	var value float64
+	switch v.Name {
+	case "vip":
+		value = float64(user.VIP)
+	case "used_last_month":
+		if ctx == nil || ctx.UsageQuerier == nil {
+			return 0, fmt.Errorf("usage querier not available")
+		}
+		now := time.Now()
+		lastMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, 0, -1)
+		used, err := ctx.UsageQuerier.QueryMonthlyUsedQuota(user.ID, lastMonth.Format("2006-01"))
+		if err != nil {
+			return 0, err
+		}
+		value = used
+	case "quota":
+		if ctx == nil || ctx.QuotaQuerier == nil {
+			return 0, fmt.Errorf("quota querier not available")
+		}
+		quota, err := ctx.QuotaQuerier.QueryQuota(user.ID)
+		if err != nil {
+			return 0, err
+		}
+		value = quota.Float64()
+	default:
+		return 0, fmt.Errorf("unknown variable '%s'", v.Name)
+	}
+	inputs[v.Name] = value
+	return value, nil
+}
+
+// binaryNode is an arithmetic operation
+type binaryNode struct {
+	Op          byte
+	Left, Right AmountExpr
+}
+
+func (b *binaryNode) Eval(user *models.UserInfo, ctx *EvaluationContext, inputs map[string]interface{}) (float64, error) {
+	left, err := b.Left.Eval(user, ctx, inputs)
+	if err != nil {
+		return 0, err
+	}
+	right, err := b.Right.Eval(user, ctx, inputs)
+	if err != nil {
+		return 0, err
+	}
+	switch b.Op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	case '/':
+		if right == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return left / right, nil
+	}
+	return 0, fmt.Errorf("unknown operator '%c'", b.Op)
+}
+
+// negateNode is a unary minus
+type negateNode struct {
+	Expr AmountExpr
+}
+
+func (n *negateNode) Eval(user *models.UserInfo, ctx *EvaluationContext, inputs map[string]interface{}) (float64, error) {
+	value, err := n.Expr.Eval(user, ctx, inputs)
+	return -value, err
+}
+
+// callNode is a numeric function call
+type callNode struct {
+	Name string
+	Args []AmountExpr
+}
+
+func (c *callNode) Eval(user *models.UserInfo, ctx *EvaluationContext, inputs map[string]interface{}) (float64, error) {
+	values := make([]float64, len(c.Args))
+	for i, arg := range c.Args {
+		value, err := arg.Eval(user, ctx, inputs)
+		if err != nil {
+			return 0, err
+		}
+		values[i] = value
+	}
+
+	switch c.Name {
+	case "min":
+		result := values[0]
+		for _, value := range values[1:] {
+			result = math.Min(result, value)
+		}
+		return result, nil
+	case "max":
+		result := values[0]
+		for _, value := range values[1:] {
+			result = math.Max(result, value)
+		}
+		return result, nil
+	case "round":
+		return math.Round(values[0]), nil
+	case "floor":
+		return math.Floor(values[0]), nil
+	case "ceil":
+		return math.Ceil(values[0]), nil
+	}
+	return 0, fmt.Errorf("unknown function '%s'", c.Name)
+}
+
+// departmentLookupNode picks a value by the user's most specific matching department
+type departmentLookupNode struct {
+	Table   map[string]AmountExpr
+	Default AmountExpr // nil means 0
+}
+
+func (d *departmentLookupNode) Eval(user *models.UserInfo, ctx *EvaluationContext, inputs map[string]interface{}) (float64, error) {
+	departments := []string{user.Company}
+	if ctx != nil && ctx.ConfigQuerier != nil && ctx.ConfigQuerier.IsEmployeeSyncEnabled() &&
+		ctx.DatabaseQuerier != nil && user.EmployeeNumber != "" {
+		if synced, err := ctx.DatabaseQuerier.QueryEmployeeDepartment(user.EmployeeNumber); err == nil && len(synced) > 0 {
+			departments = synced
+		}
+	}
+
+	// Departments are ordered from the top level down, prefer the deepest match
+	for i := len(departments) - 1; i >= 0; i-- {
+		if expr, ok := d.Table[departments[i]]; ok {
+			inputs["department"] = departments[i]
+			return expr.Eval(user, ctx, inputs)
+		}
+	}
+
+	inputs["department"] = ""
+	if d.Default == nil {
+		return 0, nil
+	}
+	return d.Default.Eval(user, ctx, inputs)
+}
+
+// amountParser is a recursive descent parser for amount expressions:
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/") unary }
+//	unary   = "-" unary | primary
+//	primary = number | variable | call | "(" expr ")"
+type amountParser struct {
+	tokens []string
+	pos    int
+}
+
+// ParseAmountExpr parses and validates an amount expression
+func ParseAmountExpr(expr string) (AmountExpr, error) {
+	tokens, err := tokenizeAmount(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty amount expression")
+	}
+
+	p := &amountParser{tokens: tokens}
+	node, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token '%s'", p.tokens[p.pos])
+	}
+	return node, nil
+}
+
+// CalcAmount evaluates an amount expression for a user and returns the amount
+// together with the inputs it used
+func CalcAmount(user *models.UserInfo, expr string, ctx *EvaluationContext) (float64, map[string]interface{}, error) {
+	node, err := ParseAmountExpr(expr)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to parse amount expression: %w", err)
+	}
+
+	inputs := make(map[string]interface{})
+	amount, err := node.Eval(user, ctx, inputs)
+	if err != nil {
+		return 0, inputs, err
+	}
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return 0, inputs, fmt.Errorf("amount expression produced an invalid number")
+	}
+	return amount, inputs, nil
+}
+
+func tokenizeAmount(expr string) ([]string, error) {
+	var tokens []string
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("+-*/(),{}:", r):
+			tokens = append(tokens, string(r))
+			i++
+		case r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				j++
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, string(runes[i:j+1]))
+			i = j + 1
+		case unicode.IsDigit(r) || r == '.':
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character '%c'", r)
+		}
+	}
+	return tokens, nil
+}
+
+func (p *amountParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *amountParser) expect(token string) error {
+	if p.peek() != token {
+		if p.pos >= len(p.tokens) {
+			return fmt.Errorf("expected '%s' at end of expression", token)
+		}
+		return fmt.Errorf("expected '%s', got '%s'", token, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *amountParser) parseExpr() (AmountExpr, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "+" || p.peek() == "-" {
+		op := p.peek()[0]
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *amountParser) parseTerm() (AmountExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "*" || p.peek() == "/" {
+		op := p.peek()[0]
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *amountParser) parseUnary() (AmountExpr, error) {
+	if p.peek() == "-" {
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negateNode{Expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *amountParser) parsePrimary() (AmountExpr, error) {
+	token := p.peek()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case token == "(":
+		p.pos++
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case unicode.IsDigit(rune(token[0])) || token[0] == '.':
+		value, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s'", token)
+		}
+		p.pos++
+		return &numberNode{Value: value}, nil
+	case unicode.IsLetter(rune(token[0])) || token[0] == '_':
+		p.pos++
+		if p.peek() == "(" {
+			return p.parseCall(token)
+		}
+		for _, name := range AmountVariables {
+			if name == token {
+				return &variableNode{Name: token}, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown variable '%s', available: %s", token, strings.Join(AmountVariables, ", "))
+	}
+	return nil, fmt.Errorf("unexpected token '%s'", token)
+}
+
+func (p *amountParser) parseCall(name string) (AmountExpr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	if name == "department_lookup" {
+		return p.parseDepartmentLookup()
+	}
+
+	var args []AmountExpr
+	if p.peek() != ")" {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.peek() != "," {
+				break
+			}
+			p.pos++
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "min", "max":
+		if len(args) < 1 {
+			return nil, fmt.Errorf("%s expects at least 1 argument", name)
+		}
+	case "round", "floor", "ceil":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s expects 1 argument, got %d", name, len(args))
+		}
+	default:
+		return nil, fmt.Errorf("unknown function '%s', available: %s", name, strings.Join(AmountFunctions, ", "))
+	}
+	return &callNode{Name: name, Args: args}, nil
+}
+
+// parseDepartmentLookup parses department_lookup({"R&D": 100, "Sales": 50 * vip}, default)
+func (p *amountParser) parseDepartmentLookup() (AmountExpr, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, fmt.Errorf("department_lookup expects a table: %w", err)
+	}
+
+	node := &departmentLookupNode{Table: make(map[string]AmountExpr)}
+	for p.peek() != "}" {
+		key := p.peek()
+		if len(key) < 2 || key[0] != '"' {
+			return nil, fmt.Errorf("department_lookup keys must be quoted department names, got '%s'", key)
+		}
+		p.pos++
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		node.Table[strings.Trim(key, `"`)] = value
+		if p.peek() != "," {
+			break
+		}
+		p.pos++
+	}
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	if len(node.Table) == 0 {
+		return nil, fmt.Errorf("department_lookup table is empty")
+	}
+
+	if p.peek() == "," {
+		p.pos++
+		def, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		node.Default = def
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
@@ -3,6 +3,7 @@ package condition
 import (
 	"fmt"
 	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
 	"strconv"
 	"strings"
 	"time"
@@ -10,7 +11,13 @@ import (
 
 // QuotaQuerier interface for querying quota information
 type QuotaQuerier interface {
-	QueryQuota(userID string) (float64, error)
+	QueryQuota(userID string) (decimal.Decimal, error)
+}
+
+// ModelQuotaQuerier is implemented by quota queriers that can read the quota of a model's
+// pool; quota-le falls back to QueryQuota for queriers without it
+type ModelQuotaQuerier interface {
+	QueryModelQuota(userID, model string) (decimal.Decimal, error)
 }
 
 // DatabaseQuerier interface for querying database information
@@ -28,12 +35,14 @@ type EvaluationContext struct {
 	QuotaQuerier    QuotaQuerier
 	DatabaseQuerier DatabaseQuerier
 	ConfigQuerier   ConfigQuerier
+	UsageQuerier    UsageQuerier // used by amount expressions
 	// Can add more dependencies here in the future (e.g., cache, etc.)
 }
 
 type Parser struct {
 	tokens []string
 	pos    int
+	trace  *[]string // when set, leaf functions record their results here
 }
 
 type Evaluator interface {
@@ -132,7 +141,7 @@ func (g *GithubStarExpr) Evaluate(user *models.UserInfo, ctx *EvaluationContext)
 // QuotaLEExpr quota less than or equal expression
 type QuotaLEExpr struct {
 	Model  string
-	Amount float64
+	Amount decimal.Decimal
 }
 
 func (q *QuotaLEExpr) Evaluate(user *models.UserInfo, ctx *EvaluationContext) (bool, error) {
@@ -140,11 +149,17 @@ func (q *QuotaLEExpr) Evaluate(user *models.UserInfo, ctx *EvaluationContext) (b
 		return false, fmt.Errorf("quota querier not available")
 	}
 
-	quota, err := ctx.QuotaQuerier.QueryQuota(user.ID)
+	var quota decimal.Decimal
+	var err error
+	if modelQuerier, ok := ctx.QuotaQuerier.(ModelQuotaQuerier); ok && q.Model != "" {
+		quota, err = modelQuerier.QueryModelQuota(user.ID, q.Model)
+	} else {
+		quota, err = ctx.QuotaQuerier.QueryQuota(user.ID)
+	}
 	if err != nil {
 		return false, err
 	}
-	return quota <= q.Amount, nil
+	return quota.LessThanOrEqual(q.Amount), nil
 }
 
 // IsVipExpr VIP level expression
@@ -229,6 +244,23 @@ func (r *RechargeExpr) Evaluate(user *models.UserInfo, ctx *EvaluationContext) (
 	return err == nil, nil
 }
 
+// TracedExpr records the result of a leaf function call for condition traces
+type TracedExpr struct {
+	Call  string
+	Expr  Evaluator
+	trace *[]string
+}
+
+func (t *TracedExpr) Evaluate(user *models.UserInfo, ctx *EvaluationContext) (bool, error) {
+	result, err := t.Expr.Evaluate(user, ctx)
+	if err != nil {
+		*t.trace = append(*t.trace, fmt.Sprintf("%s => error: %v", t.Call, err))
+	} else {
+		*t.trace = append(*t.trace, fmt.Sprintf("%s => %t", t.Call, result))
+	}
+	return result, err
+}
+
 func NewParser(condition string) *Parser {
 	if condition == "" {
 		return &Parser{tokens: []string{}, pos: 0}
@@ -443,7 +475,11 @@ func (p *Parser) parseFunction() (Evaluator, error) {
 	}
 	p.pos++ // consume ')'
 
-	return p.buildFunction(funcName, args)
+	expr, err := p.buildFunction(funcName, args)
+	if err != nil || p.trace == nil {
+		return expr, err
+	}
+	return &TracedExpr{Call: funcName + "(" + strings.Join(args, ", ") + ")", Expr: expr, trace: p.trace}, nil
 }
 
 func (p *Parser) buildFunction(funcName string, args []string) (Evaluator, error) {
@@ -485,7 +521,7 @@ func (p *Parser) buildFunction(funcName string, args []string) (Evaluator, error
 		if len(args) != 2 {
 			return nil, fmt.Errorf("quota-le expects 2 arguments, got %d", len(args))
 		}
-		amount, err := strconv.ParseFloat(args[1], 64)
+		amount, err := decimal.NewFromString(args[1])
 		if err != nil {
 			return nil, fmt.Errorf("invalid amount: %w", err)
 		}
@@ -547,3 +583,22 @@ func CalcCondition(user *models.UserInfo, condition string, ctx *EvaluationConte
 
 	return evaluator.Evaluate(user, ctx)
 }
+
+// CalcConditionWithTrace calculates a condition expression and returns the results of
+// the function calls that were evaluated, in evaluation order. Short-circuited calls are omitted.
+func CalcConditionWithTrace(user *models.UserInfo, condition string, ctx *EvaluationContext) (bool, []string, error) {
+	if condition == "" {
+		return false, nil, fmt.Errorf("empty condition is not allowed, use true() for always-true condition")
+	}
+
+	var trace []string
+	parser := NewParser(condition)
+	parser.trace = &trace
+	evaluator, err := parser.Parse()
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to parse condition: %w", err)
+	}
+
+	result, err := evaluator.Evaluate(user, ctx)
+	return result, trace, err
+}
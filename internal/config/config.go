@@ -2,21 +2,28 @@ package config
 
 import (
 	"fmt"
+	"quota-manager/pkg/decimal"
+	"reflect"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Database        DatabaseConfig        `mapstructure:"database"`
-	AuthDatabase    DatabaseConfig        `mapstructure:"auth_database"`
-	AiGateway       AiGatewayConfig       `mapstructure:"aigateway"`
-	Server          ServerConfig          `mapstructure:"server"`
-	Scheduler       SchedulerConfig       `mapstructure:"scheduler"`
-	Voucher         VoucherConfig         `mapstructure:"voucher"`
-	Log             LogConfig             `mapstructure:"log"`
-	EmployeeSync    EmployeeSyncConfig    `mapstructure:"employee_sync"`
-	GithubStarCheck GithubStarCheckConfig `mapstructure:"github_star_check"`
-	Timezone        string                `mapstructure:"timezone"`
+	Database         DatabaseConfig         `mapstructure:"database"`
+	AuthDatabase     DatabaseConfig         `mapstructure:"auth_database"`
+	AiGateway        AiGatewayConfig        `mapstructure:"aigateway"`
+	Server           ServerConfig           `mapstructure:"server"`
+	Scheduler        SchedulerConfig        `mapstructure:"scheduler"`
+	Voucher          VoucherConfig          `mapstructure:"voucher"`
+	Log              LogConfig              `mapstructure:"log"`
+	EmployeeSync     EmployeeSyncConfig     `mapstructure:"employee_sync"`
+	GithubStarCheck  GithubStarCheckConfig  `mapstructure:"github_star_check"`
+	StrategyApproval StrategyApprovalConfig `mapstructure:"strategy_approval"`
+	QuotaExpiry      QuotaExpiryConfig      `mapstructure:"quota_expiry"`
+	ModelQuota       ModelQuotaConfig       `mapstructure:"model_quota"`
+	BalanceCap       BalanceCapConfig       `mapstructure:"balance_cap"`
+	Timezone         string                 `mapstructure:"timezone"`
 }
 
 type DatabaseConfig struct {
@@ -34,6 +41,9 @@ type AiGatewayConfig struct {
 	AdminPath  string `mapstructure:"admin_path"`
 	AuthHeader string `mapstructure:"auth_header"`
 	AuthValue  string `mapstructure:"auth_value"`
+	// ModelCostPush pushes the model cost catalog to {admin_path}/model-cost/refresh, only
+	// for gateways serving that endpoint
+	ModelCostPush bool `mapstructure:"model_cost_push"`
 }
 
 type ServerConfig struct {
@@ -43,7 +53,21 @@ type ServerConfig struct {
 }
 
 type SchedulerConfig struct {
-	ScanInterval string `mapstructure:"scan_interval"`
+	ScanInterval               string `mapstructure:"scan_interval"`
+	TopupSweepInterval         string `mapstructure:"topup_sweep_interval"`         // balance sweep for topup strategies, default every 15 minutes
+	TopupSweepConcurrency      int    `mapstructure:"topup_sweep_concurrency"`      // concurrent AiGateway balance queries, default 10
+	DripReleaseInterval        string `mapstructure:"drip_release_interval"`        // drip installment release job, default every 5 minutes
+	IdempotencyPurgeInterval   string `mapstructure:"idempotency_purge_interval"`   // purge of idempotency keys past their TTL, default daily at 03:30
+	OutboxDispatchInterval     string `mapstructure:"outbox_dispatch_interval"`     // AiGateway outbox dispatcher, default every minute
+	ReconcileInterval          string `mapstructure:"reconcile_interval"`           // dry-run reconciliation report, default daily at 02:00
+	ExpirySweepInterval        string `mapstructure:"expiry_sweep_interval"`        // expiry of buckets past their expiry date, default every 5 minutes
+	ExpiryWarningInterval      string `mapstructure:"expiry_warning_interval"`      // upcoming expiry notices, default hourly
+	ExpiryWarningDays          []int  `mapstructure:"expiry_warning_days"`          // days ahead of expiry a notice is raised, default 7 and 1
+	ReservationReleaseInterval string `mapstructure:"reservation_release_interval"` // release of reservations past their TTL, default every minute
+	PoolDrawSweepInterval      string `mapstructure:"pool_draw_sweep_interval"`     // department pool draws for exhausted members, default every 15 minutes
+	AuditVerifyInterval        string `mapstructure:"audit_verify_interval"`        // audit hash chain verification, default daily at 02:30
+	UsageSnapshotInterval      string `mapstructure:"usage_snapshot_interval"`      // daily usage snapshot, default daily at 00:30
+	UsageSnapshotConcurrency   int    `mapstructure:"usage_snapshot_concurrency"`   // concurrent AiGateway queries during the snapshot, default 10
 }
 
 type VoucherConfig struct {
@@ -67,6 +91,35 @@ type GithubStarCheckConfig struct {
 	RequiredRepo string `mapstructure:"required_repo"`
 }
 
+// StrategyApprovalConfig controls when a strategy needs a second admin's approval.
+// A zero threshold disables that check.
+type StrategyApprovalConfig struct {
+	AmountThreshold   decimal.Decimal `mapstructure:"amount_threshold"`   // per-user grant amount above which approval is required
+	AudienceThreshold int             `mapstructure:"audience_threshold"` // matching user count above which approval is required
+}
+
+// QuotaExpiryConfig is the global expiry policy, applied to buckets whose originating
+// strategy has no expiry policy of its own. Zero values expire quota hard.
+type QuotaExpiryConfig struct {
+	RolloverPercent   float64         `mapstructure:"rollover_percent"`    // share of the unused amount carried into a new bucket
+	RolloverMaxAmount decimal.Decimal `mapstructure:"rollover_max_amount"` // cap on the carried amount, 0 = no cap
+	RolloverMonths    int             `mapstructure:"rollover_months"`     // months the rollover bucket stays valid, 0 = 1
+	GracePeriod       string          `mapstructure:"grace_period"`        // time quota stays usable past its expiry date, e.g. 72h
+}
+
+// ModelQuotaConfig defines the model pools that keep a balance of their own. Quota for
+// models outside every pool goes to the "any model" pool.
+type ModelQuotaConfig struct {
+	Pools map[string][]string `mapstructure:"pools"` // pool name -> models, a trailing * matches a prefix
+}
+
+// BalanceCapConfig is the global maximum balance of a user, overridable per user or
+// department. Strategy grants and received transfers stop at the cap.
+type BalanceCapConfig struct {
+	MaxBalance decimal.Decimal `mapstructure:"max_balance"` // 0 = no cap
+	Policy     string          `mapstructure:"policy"`      // truncate (default) or reject the excess
+}
+
 func (d *DatabaseConfig) DSN() string {
 	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		d.Host, d.Port, d.User, d.Password, d.DBName, d.SSLMode)
@@ -88,9 +141,31 @@ func LoadConfig(path string) (*Config, error) {
 	}
 
 	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
+	if err := viper.Unmarshal(&config, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		decimalHookFunc,
+	))); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
 	return &config, nil
 }
+
+// decimalHookFunc decodes numbers and numeric strings into decimal amounts
+func decimalHookFunc(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != reflect.TypeOf(decimal.Decimal{}) {
+		return data, nil
+	}
+	switch v := data.(type) {
+	case int:
+		return decimal.New(int64(v)), nil
+	case int64:
+		return decimal.New(v), nil
+	case float64:
+		return decimal.NewFromFloat(v), nil
+	case string:
+		return decimal.NewFromString(v)
+	}
+	return data, nil
+}
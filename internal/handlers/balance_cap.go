@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"net/http"
+	"quota-manager/internal/config"
+	"quota-manager/internal/models"
+	"quota-manager/internal/response"
+	"quota-manager/internal/services"
+	"quota-manager/internal/validation"
+	"quota-manager/pkg/decimal"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BalanceCapHandler handles maximum balance HTTP requests
+type BalanceCapHandler struct {
+	quotaService *services.QuotaService
+	serverConfig *config.ServerConfig
+}
+
+// NewBalanceCapHandler creates a new balance cap handler
+func NewBalanceCapHandler(quotaService *services.QuotaService, serverConfig *config.ServerConfig) *BalanceCapHandler {
+	return &BalanceCapHandler{
+		quotaService: quotaService,
+		serverConfig: serverConfig,
+	}
+}
+
+// SetUserBalanceCapRequest represents the request body to set a user's balance cap
+type SetUserBalanceCapRequest struct {
+	UserID     string          `json:"user_id" validate:"required,uuid"`
+	MaxBalance decimal.Decimal `json:"max_balance" validate:"min=0"`
+	Policy     string          `json:"policy" validate:"omitempty,oneof=truncate reject"`
+}
+
+// SetDepartmentBalanceCapRequest represents the request body to set a department's balance cap
+type SetDepartmentBalanceCapRequest struct {
+	Department string          `json:"department" validate:"required,max=500"`
+	MaxBalance decimal.Decimal `json:"max_balance" validate:"min=0"`
+	Policy     string          `json:"policy" validate:"omitempty,oneof=truncate reject"`
+}
+
+// BalanceCapListQuery represents the balance cap settings query
+type BalanceCapListQuery struct {
+	TargetType string `form:"target_type" validate:"omitempty,oneof=user department"`
+	Page       int    `form:"page"`
+	PageSize   int    `form:"page_size"`
+}
+
+// SetUserBalanceCap sets a user's balance cap, 0 exempting the user
+func (h *BalanceCapHandler) SetUserBalanceCap(c *gin.Context) {
+	operator, err := getOperatorFromToken(c, h.serverConfig)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.NewErrorResponse(response.TokenInvalidCode,
+			"Failed to extract user from token: "+err.Error()))
+		return
+	}
+
+	var req SetUserBalanceCapRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid request body: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	setting, err := h.quotaService.SetBalanceCap(models.TargetTypeUser, req.UserID, req.MaxBalance, req.Policy, operator)
+	if err != nil {
+		respondBalanceCapError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(setting, "User balance cap set successfully"))
+}
+
+// SetDepartmentBalanceCap sets the balance cap of a department's users without a cap of their own
+func (h *BalanceCapHandler) SetDepartmentBalanceCap(c *gin.Context) {
+	operator, err := getOperatorFromToken(c, h.serverConfig)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.NewErrorResponse(response.TokenInvalidCode,
+			"Failed to extract user from token: "+err.Error()))
+		return
+	}
+
+	var req SetDepartmentBalanceCapRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid request body: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	setting, err := h.quotaService.SetBalanceCap(models.TargetTypeDepartment, req.Department, req.MaxBalance, req.Policy, operator)
+	if err != nil {
+		respondBalanceCapError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(setting, "Department balance cap set successfully"))
+}
+
+// DeleteBalanceCap removes a balance cap setting
+func (h *BalanceCapHandler) DeleteBalanceCap(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid balance cap ID"))
+		return
+	}
+
+	if err := h.quotaService.DeleteBalanceCap(id); err != nil {
+		respondBalanceCapError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(nil, "Balance cap removed successfully"))
+}
+
+// GetBalanceCaps lists balance cap settings
+func (h *BalanceCapHandler) GetBalanceCaps(c *gin.Context) {
+	var req BalanceCapListQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+	page, pageSize, err := validation.ValidatePageParams(req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	settings, total, err := h.quotaService.GetBalanceCapSettings(req.TargetType, page, pageSize)
+	if err != nil {
+		respondBalanceCapError(c, err)
+		return
+	}
+
+	data := gin.H{
+		"total":   total,
+		"records": settings,
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Balance caps retrieved successfully"))
+}
+
+// GetUserBalanceCap gets a user's effective balance cap and current balance
+func (h *BalanceCapHandler) GetUserBalanceCap(c *gin.Context) {
+	var uriReq UserIDUri
+	if err := c.ShouldBindUri(&uriReq); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid user_id: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&uriReq); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	info, err := h.quotaService.GetBalanceCapInfo(uriReq.UserID)
+	if err != nil {
+		respondBalanceCapError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(info, "User balance cap retrieved successfully"))
+}
+
+// respondBalanceCapError maps balance cap errors to HTTP responses
+func respondBalanceCapError(c *gin.Context, err error) {
+	if serviceErr, ok := err.(*services.ServiceError); ok {
+		switch serviceErr.Code {
+		case services.ErrorValidationFailed:
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, serviceErr.Message))
+			return
+		case services.ErrorResourceNotFound:
+			c.JSON(http.StatusNotFound, response.NewErrorResponse(response.NotFoundCode, serviceErr.Message))
+			return
+		}
+	}
+
+	c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, err.Error()))
+}
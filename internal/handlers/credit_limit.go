@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"net/http"
+	"quota-manager/internal/config"
+	"quota-manager/internal/response"
+	"quota-manager/internal/services"
+	"quota-manager/internal/validation"
+	"quota-manager/pkg/decimal"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreditLimitHandler handles credit limit HTTP requests
+type CreditLimitHandler struct {
+	quotaService *services.QuotaService
+	serverConfig *config.ServerConfig
+}
+
+// NewCreditLimitHandler creates a new credit limit handler
+func NewCreditLimitHandler(quotaService *services.QuotaService, serverConfig *config.ServerConfig) *CreditLimitHandler {
+	return &CreditLimitHandler{
+		quotaService: quotaService,
+		serverConfig: serverConfig,
+	}
+}
+
+// SetUserCreditLimitRequest represents the request body to set a user's credit limit
+type SetUserCreditLimitRequest struct {
+	UserID      string          `json:"user_id" validate:"required,uuid"`
+	CreditLimit decimal.Decimal `json:"credit_limit" validate:"min=0"`
+}
+
+// SetDepartmentCreditLimitRequest represents the request body to set a department's credit limit
+type SetDepartmentCreditLimitRequest struct {
+	Department  string          `json:"department" validate:"required,max=500"`
+	CreditLimit decimal.Decimal `json:"credit_limit" validate:"min=0"`
+}
+
+// CreditLimitListQuery represents the credit limit settings query
+type CreditLimitListQuery struct {
+	TargetType string `form:"target_type" validate:"omitempty,oneof=user department"`
+	Page       int    `form:"page"`
+	PageSize   int    `form:"page_size"`
+}
+
+// SetUserCreditLimit sets a user's credit limit, 0 removing it
+func (h *CreditLimitHandler) SetUserCreditLimit(c *gin.Context) {
+	operator, err := getOperatorFromToken(c, h.serverConfig)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.NewErrorResponse(response.TokenInvalidCode,
+			"Failed to extract user from token: "+err.Error()))
+		return
+	}
+
+	var req SetUserCreditLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid request body: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	info, err := h.quotaService.SetUserCreditLimit(req.UserID, req.CreditLimit, operator)
+	if err != nil {
+		respondCreditLimitError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(info, "User credit limit set successfully"))
+}
+
+// SetDepartmentCreditLimit sets a department's credit limit, 0 removing it
+func (h *CreditLimitHandler) SetDepartmentCreditLimit(c *gin.Context) {
+	operator, err := getOperatorFromToken(c, h.serverConfig)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.NewErrorResponse(response.TokenInvalidCode,
+			"Failed to extract user from token: "+err.Error()))
+		return
+	}
+
+	var req SetDepartmentCreditLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid request body: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	applied, err := h.quotaService.SetDepartmentCreditLimit(req.Department, req.CreditLimit, operator)
+	if err != nil {
+		respondCreditLimitError(c, err)
+		return
+	}
+
+	data := gin.H{
+		"department":    req.Department,
+		"credit_limit":  req.CreditLimit,
+		"applied_users": applied,
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Department credit limit set successfully"))
+}
+
+// GetCreditLimits lists credit limit settings
+func (h *CreditLimitHandler) GetCreditLimits(c *gin.Context) {
+	var req CreditLimitListQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+	page, pageSize, err := validation.ValidatePageParams(req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	settings, total, err := h.quotaService.GetCreditLimitSettings(req.TargetType, page, pageSize)
+	if err != nil {
+		respondCreditLimitError(c, err)
+		return
+	}
+
+	data := gin.H{
+		"total":   total,
+		"records": settings,
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Credit limits retrieved successfully"))
+}
+
+// GetUserCredit gets a user's effective credit limit and outstanding debt
+func (h *CreditLimitHandler) GetUserCredit(c *gin.Context) {
+	var uriReq UserIDUri
+	if err := c.ShouldBindUri(&uriReq); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid user_id: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&uriReq); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	info, err := h.quotaService.GetCreditInfo(uriReq.UserID)
+	if err != nil {
+		respondCreditLimitError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(info, "User credit retrieved successfully"))
+}
+
+// respondCreditLimitError maps credit limit errors to HTTP responses
+func respondCreditLimitError(c *gin.Context, err error) {
+	if serviceErr, ok := err.(*services.ServiceError); ok {
+		switch serviceErr.Code {
+		case services.ErrorValidationFailed:
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, serviceErr.Message))
+			return
+		case services.ErrorResourceNotFound:
+			c.JSON(http.StatusNotFound, response.NewErrorResponse(response.NotFoundCode, serviceErr.Message))
+			return
+		}
+	}
+
+	c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, err.Error()))
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"quota-manager/internal/response"
+	"quota-manager/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// DeclarativeConfigHandler handles desired-state plan/apply HTTP requests
+type DeclarativeConfigHandler struct {
+	service *services.DeclarativeConfigService
+}
+
+// NewDeclarativeConfigHandler creates a new declarative config handler
+func NewDeclarativeConfigHandler(service *services.DeclarativeConfigService) *DeclarativeConfigHandler {
+	return &DeclarativeConfigHandler{service: service}
+}
+
+// readDesiredState parses the YAML or JSON desired state from the request body
+func (h *DeclarativeConfigHandler) readDesiredState(c *gin.Context) (*services.DesiredState, bool) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Failed to read request body: "+err.Error()))
+		return nil, false
+	}
+
+	state, err := services.ParseDesiredState(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return nil, false
+	}
+	return state, true
+}
+
+// Plan handles POST /quota-manager/api/v1/config/plan
+func (h *DeclarativeConfigHandler) Plan(c *gin.Context) {
+	state, ok := h.readDesiredState(c)
+	if !ok {
+		return
+	}
+
+	plan, err := h.service.Plan(state)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, "Failed to compute plan: "+err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewSuccessResponse(plan, "Plan computed successfully"))
+}
+
+// Apply handles POST /quota-manager/api/v1/config/apply
+func (h *DeclarativeConfigHandler) Apply(c *gin.Context) {
+	state, ok := h.readDesiredState(c)
+	if !ok {
+		return
+	}
+
+	plan, err := h.service.Apply(state)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.ConfigApplyFailedCode, "Failed to apply desired state: "+err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewSuccessResponse(plan, "Desired state applied successfully"))
+}
+
+// Export handles GET /quota-manager/api/v1/config/export
+func (h *DeclarativeConfigHandler) Export(c *gin.Context) {
+	state, err := h.service.Export()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, "Failed to export current state: "+err.Error()))
+		return
+	}
+
+	// format=yaml returns a document that can be committed as-is
+	if c.Query("format") == "yaml" {
+		out, err := yaml.Marshal(state)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.InternalErrorCode, "Failed to encode current state: "+err.Error()))
+			return
+		}
+		c.Data(http.StatusOK, "application/yaml; charset=utf-8", out)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewSuccessResponse(state, "Current state exported successfully"))
+}
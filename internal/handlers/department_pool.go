@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"net/http"
+	"quota-manager/internal/config"
+	"quota-manager/internal/response"
+	"quota-manager/internal/services"
+	"quota-manager/internal/validation"
+	"quota-manager/pkg/decimal"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DepartmentPoolHandler handles department quota pool HTTP requests
+type DepartmentPoolHandler struct {
+	quotaService *services.QuotaService
+	serverConfig *config.ServerConfig
+}
+
+// NewDepartmentPoolHandler creates a new department pool handler
+func NewDepartmentPoolHandler(quotaService *services.QuotaService, serverConfig *config.ServerConfig) *DepartmentPoolHandler {
+	return &DepartmentPoolHandler{
+		quotaService: quotaService,
+		serverConfig: serverConfig,
+	}
+}
+
+// SaveDepartmentPoolRequest represents the request body to create or update a department pool
+type SaveDepartmentPoolRequest struct {
+	Department string          `json:"department" validate:"required,max=500"`
+	MemberCap  decimal.Decimal `json:"member_cap" validate:"min=0"`
+	DrawAmount decimal.Decimal `json:"draw_amount" validate:"min=0"`
+}
+
+// FundDepartmentPoolRequest represents the request body to fund a department pool
+type FundDepartmentPoolRequest struct {
+	Amount decimal.Decimal `json:"amount" validate:"required,gt=0"`
+}
+
+// DepartmentPoolListQuery represents the department pool list query
+type DepartmentPoolListQuery struct {
+	Page     int `form:"page"`
+	PageSize int `form:"page_size"`
+}
+
+// DepartmentPoolLedgerQuery represents the pool ledger query
+type DepartmentPoolLedgerQuery struct {
+	Operation string `form:"operation" validate:"omitempty,oneof=POOL_FUND POOL_DRAW"`
+	UserID    string `form:"user_id" validate:"omitempty,uuid"`
+	Page      int    `form:"page"`
+	PageSize  int    `form:"page_size"`
+}
+
+// DepartmentPoolUsageQuery represents the pool usage query, the current month when empty
+type DepartmentPoolUsageQuery struct {
+	Month string `form:"month" validate:"omitempty,datetime=2006-01"`
+}
+
+// PoolDrawRequest represents a member's draw request, a zero amount drawing the pool's draw amount
+type PoolDrawRequest struct {
+	Amount decimal.Decimal `json:"amount" validate:"min=0"`
+}
+
+// SavePool creates a department pool or updates its member cap and draw amount
+func (h *DepartmentPoolHandler) SavePool(c *gin.Context) {
+	operator, err := getOperatorFromToken(c, h.serverConfig)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.NewErrorResponse(response.TokenInvalidCode,
+			"Failed to extract user from token: "+err.Error()))
+		return
+	}
+
+	var req SaveDepartmentPoolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid request body: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	pool, err := h.quotaService.SaveDepartmentPool(req.Department, req.MemberCap, req.DrawAmount, operator)
+	if err != nil {
+		respondDepartmentPoolError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(pool, "Department pool saved successfully"))
+}
+
+// GetPools lists department pools and their balances
+func (h *DepartmentPoolHandler) GetPools(c *gin.Context) {
+	var req DepartmentPoolListQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	page, pageSize, err := validation.ValidatePageParams(req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	pools, total, err := h.quotaService.GetDepartmentPools(page, pageSize)
+	if err != nil {
+		respondDepartmentPoolError(c, err)
+		return
+	}
+
+	data := gin.H{
+		"total":   total,
+		"records": pools,
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Department pools retrieved successfully"))
+}
+
+// GetPool gets a department pool and its balance
+func (h *DepartmentPoolHandler) GetPool(c *gin.Context) {
+	id, ok := poolIDParam(c)
+	if !ok {
+		return
+	}
+
+	pool, err := h.quotaService.GetDepartmentPool(id)
+	if err != nil {
+		respondDepartmentPoolError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(pool, "Department pool retrieved successfully"))
+}
+
+// FundPool adds quota to a department pool
+func (h *DepartmentPoolHandler) FundPool(c *gin.Context) {
+	operator, err := getOperatorFromToken(c, h.serverConfig)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.NewErrorResponse(response.TokenInvalidCode,
+			"Failed to extract user from token: "+err.Error()))
+		return
+	}
+	id, ok := poolIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req FundDepartmentPoolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid request body: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	pool, err := h.quotaService.FundDepartmentPool(id, req.Amount, operator)
+	if err != nil {
+		respondDepartmentPoolError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(pool, "Department pool funded successfully"))
+}
+
+// GetPoolLedger lists a department pool's funding and draws, newest first
+func (h *DepartmentPoolHandler) GetPoolLedger(c *gin.Context) {
+	id, ok := poolIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req DepartmentPoolLedgerQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+	page, pageSize, err := validation.ValidatePageParams(req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	entries, total, err := h.quotaService.GetDepartmentPoolLedger(id, req.Operation, req.UserID, page, pageSize)
+	if err != nil {
+		respondDepartmentPoolError(c, err)
+		return
+	}
+
+	data := gin.H{
+		"total":   total,
+		"records": entries,
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Department pool ledger retrieved successfully"))
+}
+
+// GetPoolUsage summarizes a department pool's funding and per-member draws over a month
+func (h *DepartmentPoolHandler) GetPoolUsage(c *gin.Context) {
+	id, ok := poolIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req DepartmentPoolUsageQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	usage, err := h.quotaService.GetDepartmentPoolUsage(id, req.Month)
+	if err != nil {
+		respondDepartmentPoolError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(usage, "Department pool usage retrieved successfully"))
+}
+
+// GetMemberPools handles GET /quota-manager/api/v1/quota/pools
+func (h *QuotaHandler) GetMemberPools(c *gin.Context) {
+	userID, err := h.getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.NewErrorResponse(response.TokenInvalidCode,
+			"Failed to extract user from token: "+err.Error()))
+		return
+	}
+
+	pools, err := h.quotaService.GetMemberPools(userID)
+	if err != nil {
+		respondDepartmentPoolError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(pools, "Department pools retrieved successfully"))
+}
+
+// DrawFromPool handles POST /quota-manager/api/v1/quota/pools/draw
+func (h *QuotaHandler) DrawFromPool(c *gin.Context) {
+	userID, err := h.getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.NewErrorResponse(response.TokenInvalidCode,
+			"Failed to extract user from token: "+err.Error()))
+		return
+	}
+
+	var req PoolDrawRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid request body: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	result, err := h.quotaService.DrawFromDepartmentPool(userID, req.Amount)
+	if err != nil {
+		respondDepartmentPoolError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(result, "Quota drawn from department pool successfully"))
+}
+
+// poolIDParam reads the pool ID path parameter, answering 400 when it is invalid
+func poolIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid pool ID"))
+		return 0, false
+	}
+	return id, true
+}
+
+// respondDepartmentPoolError maps department pool errors to HTTP responses
+func respondDepartmentPoolError(c *gin.Context, err error) {
+	if serviceErr, ok := err.(*services.ServiceError); ok {
+		switch serviceErr.Code {
+		case services.ErrorValidationFailed:
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, serviceErr.Message))
+			return
+		case services.ErrorResourceNotFound:
+			c.JSON(http.StatusNotFound, response.NewErrorResponse(response.NotFoundCode, serviceErr.Message))
+			return
+		case services.ErrorConflict:
+			c.JSON(http.StatusConflict, response.NewErrorResponse(response.BadRequestCode, serviceErr.Message))
+			return
+		}
+	}
+
+	c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.InternalErrorCode, err.Error()))
+}
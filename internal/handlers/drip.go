@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"quota-manager/internal/models"
+	"quota-manager/internal/response"
+	"quota-manager/internal/services"
+	"quota-manager/internal/validation"
+	"quota-manager/pkg/decimal"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DripHandler handles drip grant plan HTTP requests
+type DripHandler struct {
+	service *services.StrategyService
+}
+
+// NewDripHandler creates a new drip handler
+func NewDripHandler(service *services.StrategyService) *DripHandler {
+	return &DripHandler{service: service}
+}
+
+// DripPlanQuery represents the drip plan list query
+type DripPlanQuery struct {
+	UserID string `form:"user_id" validate:"required"`
+	Status string `form:"status" validate:"omitempty,oneof=active completed cancelled"`
+}
+
+// CancelDripRequest represents the drip cancel request body
+type CancelDripRequest struct {
+	UserID string `json:"user_id" validate:"required"`
+	PlanID int    `json:"plan_id" validate:"omitempty,gt=0"` // omitted cancels all active plans of the user
+}
+
+// GetUserDripPlans lists a user's drip plans with their installments
+func (h *DripHandler) GetUserDripPlans(c *gin.Context) {
+	var req DripPlanQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	plans, err := h.service.GetUserDripPlans(req.UserID, req.Status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, "Failed to retrieve drip plans: "+err.Error()))
+		return
+	}
+
+	outstanding := decimal.Zero
+	for _, plan := range plans {
+		for _, installment := range plan.Items {
+			if installment.Status == models.DripInstallmentPending {
+				outstanding = outstanding.Add(installment.Amount)
+			}
+		}
+	}
+
+	data := gin.H{
+		"user_id":            req.UserID,
+		"outstanding_amount": outstanding,
+		"plans":              plans,
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Drip plans retrieved successfully"))
+}
+
+// CancelUserDripPlans cancels the outstanding installments of a user's drip plans
+func (h *DripHandler) CancelUserDripPlans(c *gin.Context) {
+	var req CancelDripRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid request body: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	result, err := h.service.CancelUserDripPlans(req.UserID, req.PlanID)
+	if err != nil {
+		if serviceErr, ok := err.(*services.ServiceError); ok && serviceErr.Code == services.ErrorResourceNotFound {
+			c.JSON(http.StatusNotFound, response.NewErrorResponse(response.NotFoundCode, serviceErr.Message))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, "Failed to cancel drip plans: "+err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewSuccessResponse(result, "Drip plans cancelled successfully"))
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"quota-manager/internal/response"
+	"quota-manager/internal/services"
+	"quota-manager/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// IdempotencyKeyHeader lets clients retry a mutating request safely
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotencyReplayedHeader marks responses replayed from an earlier request
+	IdempotencyReplayedHeader = "Idempotency-Replayed"
+)
+
+// respondIdempotent runs handle and writes its result. When the request carries an
+// Idempotency-Key header, the first result is stored and returned again for retries
+// of the same request; server-side failures are not stored so they can be retried.
+func (h *QuotaHandler) respondIdempotent(c *gin.Context, userID, endpoint string, req interface{}, handle func() (int, response.ResponseData)) {
+	key := c.GetHeader(IdempotencyKeyHeader)
+	if key == "" {
+		status, body := handle()
+		c.JSON(status, body)
+		return
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid request body: "+err.Error()))
+		return
+	}
+
+	record, replay, err := h.quotaService.BeginIdempotentRequest(userID, endpoint, key, services.HashIdempotentRequest(payload))
+	if err != nil {
+		if serviceErr, ok := err.(*services.ServiceError); ok {
+			switch serviceErr.Code {
+			case services.ErrorValidationFailed:
+				c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, serviceErr.Message))
+				return
+			case services.ErrorConflict:
+				c.JSON(http.StatusConflict, response.NewErrorResponse(response.IdempotencyConflictCode, serviceErr.Message))
+				return
+			}
+		}
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, err.Error()))
+		return
+	}
+	if replay {
+		c.Header(IdempotencyReplayedHeader, "true")
+		c.Data(record.ResponseCode, "application/json; charset=utf-8", []byte(record.ResponseBody))
+		return
+	}
+
+	status, body := handle()
+	if status >= http.StatusInternalServerError {
+		if err := h.quotaService.ReleaseIdempotentRequest(record); err != nil {
+			logger.Error("Failed to release idempotency key", zap.String("key", key), zap.Error(err))
+		}
+		c.JSON(status, body)
+		return
+	}
+
+	encoded, err := json.Marshal(body)
+	if err == nil {
+		err = h.quotaService.CompleteIdempotentRequest(record, status, encoded)
+	}
+	if err != nil {
+		logger.Error("Failed to store idempotent response", zap.String("key", key), zap.Error(err))
+	}
+	c.JSON(status, body)
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"net/http"
+	"quota-manager/internal/config"
+	"quota-manager/internal/response"
+	"quota-manager/internal/services"
+	"quota-manager/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModelCatalogHandler handles model cost catalog HTTP requests
+type ModelCatalogHandler struct {
+	quotaService *services.QuotaService
+	serverConfig *config.ServerConfig
+}
+
+// NewModelCatalogHandler creates a new model catalog handler
+func NewModelCatalogHandler(quotaService *services.QuotaService, serverConfig *config.ServerConfig) *ModelCatalogHandler {
+	return &ModelCatalogHandler{
+		quotaService: quotaService,
+		serverConfig: serverConfig,
+	}
+}
+
+// ModelCatalogQuery represents the catalog query, revision 0 being the current catalog
+type ModelCatalogQuery struct {
+	Revision int `form:"revision" validate:"min=0"`
+}
+
+// SetModelCostRequest represents the request body to set a model's cost multiplier
+type SetModelCostRequest struct {
+	Model      string  `json:"model" validate:"required,max=100"`
+	Multiplier float64 `json:"multiplier" validate:"required,gt=0"`
+	Reason     string  `json:"reason" validate:"omitempty,max=500"`
+}
+
+// RemoveModelCostQuery represents the query to take a model out of the catalog
+type RemoveModelCostQuery struct {
+	Model  string `form:"model" validate:"required,max=100"`
+	Reason string `form:"reason" validate:"omitempty,max=500"`
+}
+
+// ModelCostHistoryQuery represents the catalog history query
+type ModelCostHistoryQuery struct {
+	Model    string `form:"model" validate:"omitempty,max=100"`
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+}
+
+// GetModelCatalog gets the model cost catalog at a revision
+func (h *ModelCatalogHandler) GetModelCatalog(c *gin.Context) {
+	var req ModelCatalogQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	catalog, err := h.quotaService.GetModelCatalog(req.Revision)
+	if err != nil {
+		respondReconciliationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(catalog, "Model catalog retrieved successfully"))
+}
+
+// SetModelCost sets a model's cost multiplier
+func (h *ModelCatalogHandler) SetModelCost(c *gin.Context) {
+	operator, err := getOperatorFromToken(c, h.serverConfig)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.NewErrorResponse(response.TokenInvalidCode,
+			"Failed to extract user from token: "+err.Error()))
+		return
+	}
+
+	var req SetModelCostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid request body: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	change, err := h.quotaService.SetModelCostMultiplier(req.Model, req.Multiplier, operator, req.Reason)
+	if err != nil {
+		respondReconciliationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(change, "Model cost multiplier set successfully"))
+}
+
+// RemoveModelCost takes a model out of the catalog so it costs 1x again
+func (h *ModelCatalogHandler) RemoveModelCost(c *gin.Context) {
+	operator, err := getOperatorFromToken(c, h.serverConfig)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.NewErrorResponse(response.TokenInvalidCode,
+			"Failed to extract user from token: "+err.Error()))
+		return
+	}
+
+	var req RemoveModelCostQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	change, err := h.quotaService.RemoveModelCostMultiplier(req.Model, operator, req.Reason)
+	if err != nil {
+		respondReconciliationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(change, "Model cost multiplier removed successfully"))
+}
+
+// GetModelCostHistory lists catalog changes, newest first
+func (h *ModelCatalogHandler) GetModelCostHistory(c *gin.Context) {
+	var req ModelCostHistoryQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+	page, pageSize, err := validation.ValidatePageParams(req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	changes, total, err := h.quotaService.GetModelCostHistory(req.Model, page, pageSize)
+	if err != nil {
+		respondReconciliationError(c, err)
+		return
+	}
+
+	data := gin.H{
+		"total":   total,
+		"records": changes,
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Model cost history retrieved successfully"))
+}
+
+// PushModelCatalog pushes the current catalog to AiGateway now
+func (h *ModelCatalogHandler) PushModelCatalog(c *gin.Context) {
+	if err := h.quotaService.PushModelCatalogNow(); err != nil {
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+			return
+		}
+		c.JSON(http.StatusBadGateway, response.NewErrorResponse(response.DatabaseErrorCode, "Failed to push model catalog: "+err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(nil, "Model catalog pushed successfully"))
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"fmt"
+	"quota-manager/internal/config"
+	"quota-manager/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getOperatorFromToken extracts the acting admin's ID from the token in request header
+func getOperatorFromToken(c *gin.Context, serverConfig *config.ServerConfig) (string, error) {
+	tokenHeader := serverConfig.TokenHeader
+	if tokenHeader == "" {
+		tokenHeader = "authorization"
+	}
+
+	token := c.GetHeader(tokenHeader)
+	if token == "" {
+		return "", fmt.Errorf("missing token in header: %s", tokenHeader)
+	}
+
+	authUser, err := models.ParseUserInfoFromToken(token)
+	if err != nil {
+		return "", err
+	}
+	return authUser.ID, nil
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"quota-manager/internal/response"
+	"quota-manager/internal/services"
+	"quota-manager/internal/validation"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OutboxHandler handles the admin API of the AiGateway outbox
+type OutboxHandler struct {
+	quotaService *services.QuotaService
+}
+
+// NewOutboxHandler creates a new outbox handler
+func NewOutboxHandler(quotaService *services.QuotaService) *OutboxHandler {
+	return &OutboxHandler{quotaService: quotaService}
+}
+
+// OutboxQuery represents the outbox list query
+type OutboxQuery struct {
+	Status   string `form:"status" validate:"omitempty,oneof=pending delivering delivered failed stuck"`
+	UserID   string `form:"user_id" validate:"omitempty,max=255"`
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+}
+
+// GetOutboxEntries lists gateway mutations with a per-status summary
+func (h *OutboxHandler) GetOutboxEntries(c *gin.Context) {
+	var req OutboxQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+	page, pageSize, err := validation.ValidatePageParams(req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	entries, total, err := h.quotaService.GetGatewayOutboxEntries(req.Status, req.UserID, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, "Failed to retrieve outbox entries: "+err.Error()))
+		return
+	}
+	summary, err := h.quotaService.GetGatewayOutboxSummary()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, "Failed to retrieve outbox summary: "+err.Error()))
+		return
+	}
+
+	data := gin.H{
+		"summary": summary,
+		"total":   total,
+		"records": entries,
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Outbox entries retrieved successfully"))
+}
+
+// RetryOutboxEntry requeues a failed or stuck gateway mutation and delivers it
+func (h *OutboxHandler) RetryOutboxEntry(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid outbox entry ID"))
+		return
+	}
+
+	entry, err := h.quotaService.RetryGatewayOutboxEntry(id)
+	if err != nil {
+		if serviceErr, ok := err.(*services.ServiceError); ok {
+			switch serviceErr.Code {
+			case services.ErrorResourceNotFound:
+				c.JSON(http.StatusNotFound, response.NewErrorResponse(response.NotFoundCode, serviceErr.Message))
+				return
+			case services.ErrorConflict:
+				c.JSON(http.StatusConflict, response.NewErrorResponse(response.BadRequestCode, serviceErr.Message))
+				return
+			}
+		}
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewSuccessResponse(entry, "Outbox entry requeued"))
+}
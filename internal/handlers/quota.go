@@ -120,6 +120,64 @@ func (h *QuotaHandler) GetQuotaAuditRecords(c *gin.Context) {
 	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Quota audit records retrieved successfully"))
 }
 
+// GetExpiringQuotas handles GET /quota-manager/api/v1/quota/expiring
+func (h *QuotaHandler) GetExpiringQuotas(c *gin.Context) {
+	userID, err := h.getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.NewErrorResponse(response.TokenInvalidCode,
+			"Failed to extract user from token: "+err.Error()))
+		return
+	}
+
+	var req PaginationQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode,
+			"Invalid query parameters: "+err.Error()))
+		return
+	}
+	page, pageSize, err := validation.ValidatePageParams(req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	records, total, err := h.quotaService.GetExpiringQuotas(userID, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode,
+			"Failed to retrieve expiring quotas: "+err.Error()))
+		return
+	}
+
+	data := gin.H{
+		"total":   total,
+		"records": records,
+	}
+
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Expiring quotas retrieved successfully"))
+}
+
+// GetUsageHistory handles GET /quota-manager/api/v1/quota/usage, the caller's daily usage snapshots
+func (h *QuotaHandler) GetUsageHistory(c *gin.Context) {
+	userID, err := h.getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.NewErrorResponse(response.TokenInvalidCode,
+			"Failed to extract user from token: "+err.Error()))
+		return
+	}
+
+	var req UsageDateRangeQuery
+	if !bindUsageQuery(c, &req) {
+		return
+	}
+
+	series, err := h.quotaService.GetUsageSeries(userID, req.StartDate, req.EndDate)
+	if err != nil {
+		respondUsageAnalyticsError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(series, "Usage history retrieved successfully"))
+}
+
 // TransferOut handles POST /quota-manager/api/v1/quota/transfer-out
 func (h *QuotaHandler) TransferOut(c *gin.Context) {
 	giver, err := h.getUserFromToken(c)
@@ -156,24 +214,24 @@ func (h *QuotaHandler) TransferOut(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.quotaService.TransferOut(giver, &req)
-	if err != nil {
-		// Business logic errors (insufficient quota, etc.) should return 400
-		errMsg := err.Error()
-		if strings.Contains(errMsg, "receiver_id cannot be empty") ||
-			strings.Contains(errMsg, "insufficient") ||
-			strings.Contains(errMsg, "quota not found") {
-			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.QuotaTransferFailedCode,
-				"Transfer validation failed: "+err.Error()))
-			return
+	h.respondIdempotent(c, giver.ID, "transfer-out", &req, func() (int, response.ResponseData) {
+		resp, err := h.quotaService.TransferOut(giver, &req)
+		if err != nil {
+			// Business logic errors (insufficient quota, etc.) should return 400
+			errMsg := err.Error()
+			if strings.Contains(errMsg, "receiver_id cannot be empty") ||
+				strings.Contains(errMsg, "insufficient") ||
+				strings.Contains(errMsg, "quota not found") {
+				return http.StatusBadRequest, response.NewErrorResponse(response.QuotaTransferFailedCode,
+					"Transfer validation failed: "+err.Error())
+			}
+			// Otherwise it's a server-side error
+			return http.StatusInternalServerError, response.NewErrorResponse(response.QuotaTransferFailedCode,
+				"Failed to transfer out quota: "+err.Error())
 		}
-		// Otherwise it's a server-side error
-		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.QuotaTransferFailedCode,
-			"Failed to transfer out quota: "+err.Error()))
-		return
-	}
 
-	c.JSON(http.StatusOK, response.NewSuccessResponse(resp, "Quota transferred out successfully"))
+		return http.StatusOK, response.NewSuccessResponse(resp, "Quota transferred out successfully")
+	})
 }
 
 // TransferIn handles POST /quota-manager/api/v1/quota/transfer-in
@@ -196,24 +254,24 @@ func (h *QuotaHandler) TransferIn(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.quotaService.TransferIn(receiver, &req)
-	if err != nil {
-		// For TransferIn, service layer returns TransferInResponse even for business logic errors
-		// Only database/system errors return actual errors
-		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.QuotaTransferFailedCode,
-			"Failed to transfer in quota: "+err.Error()))
-		return
-	}
+	h.respondIdempotent(c, receiver.ID, "transfer-in", &req, func() (int, response.ResponseData) {
+		resp, err := h.quotaService.TransferIn(receiver, &req)
+		if err != nil {
+			// For TransferIn, service layer returns TransferInResponse even for business logic errors
+			// Only database/system errors return actual errors
+			return http.StatusInternalServerError, response.NewErrorResponse(response.QuotaTransferFailedCode,
+				"Failed to transfer in quota: "+err.Error())
+		}
 
-	// Check if the transfer had business logic issues (voucher validation, etc.)
-	if resp.Status == services.TransferStatusFailed {
-		// These are business logic failures, should return 400
-		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.QuotaTransferFailedCode,
-			resp.Message))
-		return
-	}
+		// Check if the transfer had business logic issues (voucher validation, etc.)
+		if resp.Status == services.TransferStatusFailed {
+			// These are business logic failures, should return 400
+			return http.StatusBadRequest, response.NewErrorResponse(response.QuotaTransferFailedCode,
+				resp.Message)
+		}
 
-	c.JSON(http.StatusOK, response.NewSuccessResponse(resp, "Quota transferred in successfully"))
+		return http.StatusOK, response.NewSuccessResponse(resp, "Quota transferred in successfully")
+	})
 }
 
 // GetUserQuotaAuditRecordsAdminEmptyID handles the case when user_id is empty
@@ -265,16 +323,63 @@ func (h *QuotaHandler) GetUserQuotaAuditRecordsAdmin(c *gin.Context) {
 	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "User quota audit records retrieved successfully"))
 }
 
+// GetMonthlyUsageReport gets a user's recorded monthly usage with the model cost multipliers
+// it was weighted with (admin function)
+func (h *QuotaHandler) GetMonthlyUsageReport(c *gin.Context) {
+	var uriReq UserIDUri
+	if err := c.ShouldBindUri(&uriReq); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid user_id: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&uriReq); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	var queryReq PaginationQuery
+	if err := c.ShouldBindQuery(&queryReq); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	page, pageSize, err := validation.ValidatePageParams(queryReq.Page, queryReq.PageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	records, total, err := h.quotaService.GetMonthlyUsageReport(uriReq.UserID, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, "Failed to retrieve monthly usage: "+err.Error()))
+		return
+	}
+
+	data := gin.H{
+		"total":   total,
+		"records": records,
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Monthly usage retrieved successfully"))
+}
+
 // RegisterQuotaRoutes registers quota-related routes
 func RegisterQuotaRoutes(r *gin.RouterGroup, quotaHandler *QuotaHandler) {
 	quota := r.Group("/quota")
 	{
 		quota.GET("", quotaHandler.GetUserQuota)
 		quota.GET("/audit", quotaHandler.GetQuotaAuditRecords)
+		quota.GET("/expiring", quotaHandler.GetExpiringQuotas)
+		quota.GET("/usage", quotaHandler.GetUsageHistory)
 		quota.POST("/transfer-out", quotaHandler.TransferOut)
 		quota.POST("/transfer-in", quotaHandler.TransferIn)
+		quota.POST("/reservations", quotaHandler.ReserveQuota)
+		quota.GET("/reservations", quotaHandler.GetReservations)
+		quota.GET("/reservations/:id", quotaHandler.GetReservation)
+		quota.POST("/reservations/:id/commit", quotaHandler.CommitReservation)
+		quota.POST("/reservations/:id/release", quotaHandler.ReleaseReservation)
+		quota.GET("/pools", quotaHandler.GetMemberPools)
+		quota.POST("/pools/draw", quotaHandler.DrawFromPool)
 		// Handle empty user_id case (must be before parameterized route)
 		quota.GET("/audit/", quotaHandler.GetUserQuotaAuditRecordsAdminEmptyID)
 		quota.GET("/audit/:user_id", quotaHandler.GetUserQuotaAuditRecordsAdmin)
+		quota.GET("/monthly-usage/:user_id", quotaHandler.GetMonthlyUsageReport)
 	}
 }
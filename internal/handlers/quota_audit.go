@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"net/http"
+	"quota-manager/internal/response"
+	"quota-manager/internal/services"
+	"quota-manager/internal/validation"
+	"quota-manager/pkg/decimal"
+	"quota-manager/pkg/logger"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// QuotaAuditHandler handles the admin quota audit search and chain verification HTTP requests
+type QuotaAuditHandler struct {
+	quotaService *services.QuotaService
+}
+
+// NewQuotaAuditHandler creates a new quota audit handler
+func NewQuotaAuditHandler(quotaService *services.QuotaService) *QuotaAuditHandler {
+	return &QuotaAuditHandler{quotaService: quotaService}
+}
+
+// AuditSearchQuery represents the audit search filters shared by search, summary and export
+type AuditSearchQuery struct {
+	UserID       string `form:"user_id" validate:"omitempty,max=255"`
+	Operation    string `form:"operation" validate:"omitempty,max=500"` // comma-separated operations
+	StrategyID   int    `form:"strategy_id" validate:"omitempty,min=1"`
+	StrategyName string `form:"strategy_name" validate:"omitempty,max=100"`
+	RelatedUser  string `form:"related_user" validate:"omitempty,max=255"`
+	MinAmount    string `form:"min_amount"`
+	MaxAmount    string `form:"max_amount"`
+	StartTime    string `form:"start_time"` // RFC3339, inclusive
+	EndTime      string `form:"end_time"`   // RFC3339, exclusive
+}
+
+// AuditSearchPageQuery represents the keyset pagination of an audit search
+type AuditSearchPageQuery struct {
+	Cursor int `form:"cursor" validate:"omitempty,min=1"`
+	Limit  int `form:"limit" validate:"omitempty,min=1,max=1000"`
+}
+
+// AuditExportQuery represents the export format
+type AuditExportQuery struct {
+	Format string `form:"format" validate:"omitempty,oneof=csv ndjson"`
+}
+
+// AuditChainVerifyQuery represents the user whose audit hash chain is verified
+type AuditChainVerifyQuery struct {
+	UserID string `form:"user_id" validate:"required,max=255"`
+}
+
+// AuditChainBreakQuery represents the recorded audit chain break listing
+type AuditChainBreakQuery struct {
+	UserID   string `form:"user_id" validate:"omitempty,max=255"`
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+}
+
+// SearchQuotaAudit returns a keyset page of audit records across all users
+func (h *QuotaAuditHandler) SearchQuotaAudit(c *gin.Context) {
+	filter, ok := bindAuditSearchFilter(c)
+	if !ok {
+		return
+	}
+	var pageReq AuditSearchPageQuery
+	if err := c.ShouldBindQuery(&pageReq); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&pageReq); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	page, err := h.quotaService.SearchQuotaAudit(filter, pageReq.Cursor, pageReq.Limit)
+	if err != nil {
+		respondQuotaAuditError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(page, "Quota audit records retrieved successfully"))
+}
+
+// SummarizeQuotaAudit returns aggregate totals of the filtered audit records
+func (h *QuotaAuditHandler) SummarizeQuotaAudit(c *gin.Context) {
+	filter, ok := bindAuditSearchFilter(c)
+	if !ok {
+		return
+	}
+
+	summary, err := h.quotaService.SummarizeQuotaAudit(filter)
+	if err != nil {
+		respondQuotaAuditError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(summary, "Quota audit summary retrieved successfully"))
+}
+
+// ExportQuotaAudit streams the filtered audit records as CSV or NDJSON
+func (h *QuotaAuditHandler) ExportQuotaAudit(c *gin.Context) {
+	filter, ok := bindAuditSearchFilter(c)
+	if !ok {
+		return
+	}
+	var exportReq AuditExportQuery
+	if err := c.ShouldBindQuery(&exportReq); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&exportReq); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+	// Filter errors are answered before the headers go out
+	if err := services.ValidateAuditSearchFilter(filter); err != nil {
+		respondQuotaAuditError(c, err)
+		return
+	}
+
+	var err error
+	if exportReq.Format == "ndjson" {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", "attachment; filename=quota-audit.ndjson")
+		c.Status(http.StatusOK)
+		err = h.quotaService.ExportQuotaAuditNDJSON(filter, c.Writer)
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", "attachment; filename=quota-audit.csv")
+		c.Status(http.StatusOK)
+		err = h.quotaService.ExportQuotaAuditCSV(filter, c.Writer)
+	}
+	if err != nil {
+		// Headers are already sent, the truncated file is the only signal left
+		logger.Error("Failed to export quota audit", zap.String("format", exportReq.Format), zap.Error(err))
+	}
+}
+
+// VerifyAuditChain verifies one user's audit hash chain now
+func (h *QuotaAuditHandler) VerifyAuditChain(c *gin.Context) {
+	var req AuditChainVerifyQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	result, err := h.quotaService.VerifyAuditChain(req.UserID)
+	if err != nil {
+		respondQuotaAuditError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(result, "Audit chain verified"))
+}
+
+// StartAuditChainVerification verifies every user's audit hash chain in the background
+func (h *QuotaAuditHandler) StartAuditChainVerification(c *gin.Context) {
+	if err := h.quotaService.StartAuditChainVerification(); err != nil {
+		respondQuotaAuditError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, response.NewSuccessResponse(nil, "Audit chain verification started"))
+}
+
+// GetAuditChainBreaks lists the audit chain breaks recorded by verification runs
+func (h *QuotaAuditHandler) GetAuditChainBreaks(c *gin.Context) {
+	var req AuditChainBreakQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+	page, pageSize, err := validation.ValidatePageParams(req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	breaks, total, err := h.quotaService.GetAuditChainBreaks(req.UserID, page, pageSize)
+	if err != nil {
+		respondQuotaAuditError(c, err)
+		return
+	}
+
+	data := gin.H{
+		"total":   total,
+		"records": breaks,
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Audit chain breaks retrieved successfully"))
+}
+
+// bindAuditSearchFilter reads the audit search filters, answering 400 when they are invalid
+func bindAuditSearchFilter(c *gin.Context) (*services.AuditSearchFilter, bool) {
+	var req AuditSearchQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return nil, false
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return nil, false
+	}
+
+	filter := &services.AuditSearchFilter{
+		UserID:       req.UserID,
+		StrategyID:   req.StrategyID,
+		StrategyName: req.StrategyName,
+		RelatedUser:  req.RelatedUser,
+	}
+	for _, operation := range strings.Split(req.Operation, ",") {
+		if operation = strings.ToUpper(strings.TrimSpace(operation)); operation != "" {
+			filter.Operations = append(filter.Operations, operation)
+		}
+	}
+	if req.MinAmount != "" {
+		amount, err := decimal.NewFromString(req.MinAmount)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid min_amount: "+err.Error()))
+			return nil, false
+		}
+		filter.MinAmount = &amount
+	}
+	if req.MaxAmount != "" {
+		amount, err := decimal.NewFromString(req.MaxAmount)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid max_amount: "+err.Error()))
+			return nil, false
+		}
+		filter.MaxAmount = &amount
+	}
+	if req.StartTime != "" {
+		startTime, err := time.Parse(time.RFC3339, req.StartTime)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid start_time, expected RFC3339: "+err.Error()))
+			return nil, false
+		}
+		filter.StartTime = &startTime
+	}
+	if req.EndTime != "" {
+		endTime, err := time.Parse(time.RFC3339, req.EndTime)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid end_time, expected RFC3339: "+err.Error()))
+			return nil, false
+		}
+		filter.EndTime = &endTime
+	}
+	return filter, true
+}
+
+// respondQuotaAuditError maps audit search and chain errors to HTTP responses
+func respondQuotaAuditError(c *gin.Context, err error) {
+	if serviceErr, ok := err.(*services.ServiceError); ok {
+		switch serviceErr.Code {
+		case services.ErrorValidationFailed:
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, serviceErr.Message))
+			return
+		case services.ErrorResourceNotFound:
+			c.JSON(http.StatusNotFound, response.NewErrorResponse(response.NotFoundCode, serviceErr.Message))
+			return
+		case services.ErrorConflict:
+			c.JSON(http.StatusConflict, response.NewErrorResponse(response.BadRequestCode, serviceErr.Message))
+			return
+		}
+	}
+
+	c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, err.Error()))
+}
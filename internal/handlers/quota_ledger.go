@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"quota-manager/internal/response"
+	"quota-manager/internal/services"
+	"quota-manager/internal/validation"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuotaLedgerHandler handles the admin point-in-time balance and ledger replay HTTP requests
+type QuotaLedgerHandler struct {
+	quotaService *services.QuotaService
+}
+
+// NewQuotaLedgerHandler creates a new quota ledger handler
+func NewQuotaLedgerHandler(quotaService *services.QuotaService) *QuotaLedgerHandler {
+	return &QuotaLedgerHandler{quotaService: quotaService}
+}
+
+// BalanceAtQuery represents the point-in-time balance query
+type BalanceAtQuery struct {
+	UserID string `form:"user_id" validate:"required,max=255"`
+	At     string `form:"at" validate:"required"` // RFC3339
+}
+
+// LedgerReplayRequest represents the ledger replay request body
+type LedgerReplayRequest struct {
+	UserID string `json:"user_id" validate:"omitempty,max=255"` // empty replays every user
+	Mode   string `json:"mode" validate:"required,oneof=dry_run apply"`
+}
+
+// GetBalanceAt returns a user's balance and buckets at a point in time, rebuilt from the audit log
+func (h *QuotaLedgerHandler) GetBalanceAt(c *gin.Context) {
+	var req BalanceAtQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+	at, err := time.Parse(time.RFC3339, req.At)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid at, expected RFC3339: "+err.Error()))
+		return
+	}
+
+	balance, err := h.quotaService.GetBalanceAt(req.UserID, at)
+	if err != nil {
+		respondQuotaLedgerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(balance, "Balance retrieved successfully"))
+}
+
+// ReplayQuotaLedger rebuilds quota rows from the audit log, in dry-run or apply mode
+func (h *QuotaLedgerHandler) ReplayQuotaLedger(c *gin.Context) {
+	var req LedgerReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid request body: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	report, err := h.quotaService.ReplayQuotaLedger(req.UserID, req.Mode)
+	if err != nil {
+		respondQuotaLedgerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(report, "Quota ledger replayed successfully"))
+}
+
+// respondQuotaLedgerError maps ledger errors to HTTP responses
+func respondQuotaLedgerError(c *gin.Context, err error) {
+	if serviceErr, ok := err.(*services.ServiceError); ok {
+		switch serviceErr.Code {
+		case services.ErrorValidationFailed:
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, serviceErr.Message))
+			return
+		case services.ErrorResourceNotFound:
+			c.JSON(http.StatusNotFound, response.NewErrorResponse(response.NotFoundCode, serviceErr.Message))
+			return
+		}
+	}
+
+	c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, err.Error()))
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"net/http"
+	"quota-manager/internal/response"
+	"quota-manager/internal/services"
+	"quota-manager/internal/validation"
+	"quota-manager/pkg/decimal"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReservationListQuery represents the reservation list query
+type ReservationListQuery struct {
+	Status   string `form:"status" validate:"omitempty,oneof=HELD COMMITTED RELEASED EXPIRED"`
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+}
+
+// CommitReservationRequest represents the reservation commit body
+type CommitReservationRequest struct {
+	ActualAmount decimal.Decimal `json:"actual_amount" validate:"min=0"`
+}
+
+// ReserveQuota handles POST /quota-manager/api/v1/quota/reservations
+func (h *QuotaHandler) ReserveQuota(c *gin.Context) {
+	userID, err := h.getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.NewErrorResponse(response.TokenInvalidCode,
+			"Failed to extract user from token: "+err.Error()))
+		return
+	}
+
+	var req services.ReserveQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid request body: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	reservation, err := h.quotaService.ReserveQuota(userID, &req)
+	if err != nil {
+		respondReservationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(reservation, "Quota reserved successfully"))
+}
+
+// GetReservations handles GET /quota-manager/api/v1/quota/reservations
+func (h *QuotaHandler) GetReservations(c *gin.Context) {
+	userID, err := h.getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.NewErrorResponse(response.TokenInvalidCode,
+			"Failed to extract user from token: "+err.Error()))
+		return
+	}
+
+	var req ReservationListQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+	page, pageSize, err := validation.ValidatePageParams(req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	reservations, total, err := h.quotaService.GetReservations(userID, req.Status, page, pageSize)
+	if err != nil {
+		respondReservationError(c, err)
+		return
+	}
+
+	data := gin.H{
+		"total":   total,
+		"records": reservations,
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Reservations retrieved successfully"))
+}
+
+// GetReservation handles GET /quota-manager/api/v1/quota/reservations/:id
+func (h *QuotaHandler) GetReservation(c *gin.Context) {
+	userID, id, ok := h.reservationTarget(c)
+	if !ok {
+		return
+	}
+
+	reservation, err := h.quotaService.GetReservation(userID, id)
+	if err != nil {
+		respondReservationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(reservation, "Reservation retrieved successfully"))
+}
+
+// CommitReservation handles POST /quota-manager/api/v1/quota/reservations/:id/commit
+func (h *QuotaHandler) CommitReservation(c *gin.Context) {
+	userID, id, ok := h.reservationTarget(c)
+	if !ok {
+		return
+	}
+
+	var req CommitReservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid request body: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	reservation, err := h.quotaService.CommitReservation(userID, id, req.ActualAmount)
+	if err != nil {
+		respondReservationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(reservation, "Reservation committed successfully"))
+}
+
+// ReleaseReservation handles POST /quota-manager/api/v1/quota/reservations/:id/release
+func (h *QuotaHandler) ReleaseReservation(c *gin.Context) {
+	userID, id, ok := h.reservationTarget(c)
+	if !ok {
+		return
+	}
+
+	reservation, err := h.quotaService.ReleaseReservation(userID, id)
+	if err != nil {
+		respondReservationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(reservation, "Reservation released successfully"))
+}
+
+// reservationTarget reads the calling user and the reservation ID path parameter,
+// answering 401 or 400 when either is invalid
+func (h *QuotaHandler) reservationTarget(c *gin.Context) (string, int, bool) {
+	userID, err := h.getUserIDFromToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.NewErrorResponse(response.TokenInvalidCode,
+			"Failed to extract user from token: "+err.Error()))
+		return "", 0, false
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid reservation ID"))
+		return "", 0, false
+	}
+	return userID, id, true
+}
+
+// respondReservationError maps reservation errors to HTTP responses
+func respondReservationError(c *gin.Context, err error) {
+	if serviceErr, ok := err.(*services.ServiceError); ok {
+		switch serviceErr.Code {
+		case services.ErrorValidationFailed:
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, serviceErr.Message))
+			return
+		case services.ErrorResourceNotFound:
+			c.JSON(http.StatusNotFound, response.NewErrorResponse(response.NotFoundCode, serviceErr.Message))
+			return
+		case services.ErrorConflict:
+			c.JSON(http.StatusConflict, response.NewErrorResponse(response.BadRequestCode, serviceErr.Message))
+			return
+		}
+	}
+
+	c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.InternalErrorCode, err.Error()))
+}
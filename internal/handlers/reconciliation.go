@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"quota-manager/internal/response"
+	"quota-manager/internal/services"
+	"quota-manager/internal/validation"
+	"quota-manager/pkg/logger"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ReconciliationHandler handles quota reconciliation HTTP requests
+type ReconciliationHandler struct {
+	quotaService *services.QuotaService
+}
+
+// NewReconciliationHandler creates a new reconciliation handler
+func NewReconciliationHandler(quotaService *services.QuotaService) *ReconciliationHandler {
+	return &ReconciliationHandler{quotaService: quotaService}
+}
+
+// StartReconciliationRequest represents the reconciliation run request body
+type StartReconciliationRequest struct {
+	Mode string `json:"mode" validate:"required,oneof=dry_run fix"`
+}
+
+// ReconciliationItemQuery represents the report detail query
+type ReconciliationItemQuery struct {
+	Classification string `form:"classification" validate:"omitempty,oneof=ledger_audit_mismatch gateway_total_mismatch pending_delivery gateway_overused read_error"`
+	Page           int    `form:"page"`
+	PageSize       int    `form:"page_size"`
+}
+
+// StartReconciliation starts a reconciliation run in the background
+func (h *ReconciliationHandler) StartReconciliation(c *gin.Context) {
+	var req StartReconciliationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid request body: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	report, err := h.quotaService.StartReconciliation(req.Mode, "api")
+	if err != nil {
+		respondReconciliationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, response.NewSuccessResponse(report, "Reconciliation started"))
+}
+
+// GetReconciliationReports lists reconciliation reports
+func (h *ReconciliationHandler) GetReconciliationReports(c *gin.Context) {
+	var req PaginationQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	page, pageSize, err := validation.ValidatePageParams(req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	reports, total, err := h.quotaService.GetReconciliationReports(page, pageSize)
+	if err != nil {
+		respondReconciliationError(c, err)
+		return
+	}
+
+	data := gin.H{
+		"total":   total,
+		"records": reports,
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Reconciliation reports retrieved successfully"))
+}
+
+// GetReconciliationReport gets a report with its discrepancies
+func (h *ReconciliationHandler) GetReconciliationReport(c *gin.Context) {
+	id, ok := parseReportID(c)
+	if !ok {
+		return
+	}
+
+	var req ReconciliationItemQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+	page, pageSize, err := validation.ValidatePageParams(req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	report, err := h.quotaService.GetReconciliationReport(id)
+	if err != nil {
+		respondReconciliationError(c, err)
+		return
+	}
+	items, total, err := h.quotaService.GetReconciliationItems(id, req.Classification, page, pageSize)
+	if err != nil {
+		respondReconciliationError(c, err)
+		return
+	}
+
+	data := gin.H{
+		"report":  report,
+		"total":   total,
+		"records": items,
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Reconciliation report retrieved successfully"))
+}
+
+// ExportReconciliationReport streams the discrepancies of a report as CSV
+func (h *ReconciliationHandler) ExportReconciliationReport(c *gin.Context) {
+	id, ok := parseReportID(c)
+	if !ok {
+		return
+	}
+	if _, err := h.quotaService.GetReconciliationReport(id); err != nil {
+		respondReconciliationError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=reconciliation-%d.csv", id))
+	c.Status(http.StatusOK)
+	if err := h.quotaService.ExportReconciliationCSV(id, c.Writer); err != nil {
+		// Headers are already sent, the truncated file is the only signal left
+		logger.Error("Failed to export reconciliation report", zap.Int("report_id", id), zap.Error(err))
+	}
+}
+
+// parseReportID reads the report ID path parameter, answering 400 when it is invalid
+func parseReportID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid report ID"))
+		return 0, false
+	}
+	return id, true
+}
+
+// respondReconciliationError maps reconciliation errors to HTTP responses
+func respondReconciliationError(c *gin.Context, err error) {
+	if serviceErr, ok := err.(*services.ServiceError); ok {
+		switch serviceErr.Code {
+		case services.ErrorValidationFailed:
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, serviceErr.Message))
+			return
+		case services.ErrorResourceNotFound:
+			c.JSON(http.StatusNotFound, response.NewErrorResponse(response.NotFoundCode, serviceErr.Message))
+			return
+		case services.ErrorConflict:
+			c.JSON(http.StatusConflict, response.NewErrorResponse(response.BadRequestCode, serviceErr.Message))
+			return
+		}
+	}
+
+	c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, err.Error()))
+}
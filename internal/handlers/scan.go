@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"quota-manager/internal/models"
 	"quota-manager/internal/response"
 	"quota-manager/internal/services"
 
@@ -28,7 +29,7 @@ func NewScanHandler(strategyService *services.StrategyService, unifiedPermission
 
 // ScanRequest represents the scan request body
 type ScanRequest struct {
-	Type string `json:"type" validate:"required,oneof=strategy employee-sync expire-quotas sync-quotas"`
+	Type string `json:"type" validate:"required,oneof=strategy employee-sync expire-quotas sync-quotas topup drip-release reconcile usage-snapshot"`
 }
 
 // TriggerScan handles unified scan triggering
@@ -55,6 +56,22 @@ func (h *ScanHandler) TriggerScan(c *gin.Context) {
 	case "sync-quotas":
 		go h.quotaService.SyncQuotasWithAiGateway()
 		c.JSON(http.StatusOK, response.NewSuccessResponse(nil, "Quota sync task triggered successfully"))
+	case "topup":
+		go h.strategyService.SweepTopupStrategies()
+		c.JSON(http.StatusOK, response.NewSuccessResponse(nil, "Topup sweep triggered successfully"))
+	case "drip-release":
+		go h.strategyService.ReleaseDueDripInstallments()
+		c.JSON(http.StatusOK, response.NewSuccessResponse(nil, "Drip installment release triggered successfully"))
+	case "reconcile":
+		report, err := h.quotaService.StartReconciliation(models.ReconcileModeDryRun, "scan")
+		if err != nil {
+			respondReconciliationError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, response.NewSuccessResponse(report, "Reconciliation triggered successfully"))
+	case "usage-snapshot":
+		go h.quotaService.SnapshotDailyUsage()
+		c.JSON(http.StatusOK, response.NewSuccessResponse(nil, "Usage snapshot triggered successfully"))
 	default:
 		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid scan type: "+req.Type))
 	}
@@ -7,7 +7,9 @@ import (
 	"quota-manager/internal/response"
 	"quota-manager/internal/services"
 	"quota-manager/internal/validation"
+	"quota-manager/pkg/decimal"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,6 +18,12 @@ type StrategyHandler struct {
 	service *services.StrategyService
 }
 
+// strategyResponse adds the schedule preview of periodic strategies to the strategy fields
+type strategyResponse struct {
+	models.QuotaStrategy
+	Schedule *services.CronPreview `json:"schedule,omitempty"`
+}
+
 func NewStrategyHandler(service *services.StrategyService) *StrategyHandler {
 	return &StrategyHandler{service: service}
 }
@@ -44,12 +52,52 @@ func (h *StrategyHandler) CreateStrategy(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid periodic expression: "+err.Error()))
 			return
 		}
+		if err := services.ValidateStrategyTimezone(strategy.Timezone, strategy.PeriodicExpr); err != nil {
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+			return
+		}
 		if strategy.MaxExecPerUser < 0 {
 			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "max_exec_per_user must be >= 0"))
 			return
 		}
 	}
 
+	// For topup type, the threshold and period cap must be consistent
+	if err := services.ValidateTopupStrategy(&strategy); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	// Drip grants need an installment interval
+	if err := services.ValidateDripSettings(&strategy); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	// amount expression
+	if err := services.ValidateAmountExpr(strategy.AmountExpr); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	// exclusion group
+	if err := services.ValidateExclusionGroup(&strategy); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	// rollover and grace period at expiry
+	if err := services.ValidateExpiryPolicy(&strategy); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	// department pool funding
+	if err := services.ValidateFundPool(&strategy); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
 	// condition expression
 	if strategy.Condition != "" {
 		parser := condition.NewParser(strategy.Condition)
@@ -65,7 +113,13 @@ func (h *StrategyHandler) CreateStrategy(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, response.NewSuccessResponse(strategy, "Strategy created successfully"))
+	data := strategyResponse{QuotaStrategy: strategy, Schedule: h.service.PreviewStrategySchedule(&strategy)}
+	if !strategy.IsApproved() {
+		c.JSON(http.StatusCreated, response.NewSuccessResponse(data, "Strategy created as a disabled draft, approval is required before it can be enabled"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.NewSuccessResponse(data, "Strategy created successfully"))
 }
 
 // GetStrategies gets the strategy list
@@ -76,10 +130,12 @@ func (h *StrategyHandler) GetStrategies(c *gin.Context) {
 	var strategies []models.QuotaStrategy
 	var err error
 
-	switch status {
-	case "enabled", "true":
+	switch {
+	case c.Query("approval_status") != "":
+		strategies, err = h.service.GetStrategiesByApprovalStatus(c.Query("approval_status"))
+	case status == "enabled" || status == "true":
 		strategies, err = h.service.GetEnabledStrategies()
-	case "disabled", "false":
+	case status == "disabled" || status == "false":
 		strategies, err = h.service.GetDisabledStrategies()
 	default:
 		strategies, err = h.service.GetStrategies()
@@ -90,9 +146,17 @@ func (h *StrategyHandler) GetStrategies(c *gin.Context) {
 		return
 	}
 
+	// Exclusion groups span all strategies, so conflicts are reported regardless of the filter
+	groups, err := h.service.GetExclusionGroups()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, "Failed to retrieve exclusion groups: "+err.Error()))
+		return
+	}
+
 	data := gin.H{
-		"strategies": strategies,
-		"total":      len(strategies),
+		"strategies":       strategies,
+		"total":            len(strategies),
+		"exclusion_groups": groups,
 	}
 
 	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Strategies retrieved successfully"))
@@ -113,7 +177,8 @@ func (h *StrategyHandler) GetStrategy(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, response.NewSuccessResponse(strategy, "Strategy retrieved successfully"))
+	data := strategyResponse{QuotaStrategy: *strategy, Schedule: h.service.PreviewStrategySchedule(strategy)}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Strategy retrieved successfully"))
 }
 
 // UpdateStrategy updates a strategy
@@ -126,15 +191,31 @@ func (h *StrategyHandler) UpdateStrategy(c *gin.Context) {
 	}
 
 	type UpdateStrategyRequest struct {
-		Name           *string  `json:"name" validate:"omitempty,min=1,max=100"`
-		Title          *string  `json:"title" validate:"omitempty,min=1,max=200"`
-		Type           *string  `json:"type" validate:"omitempty,oneof=single periodic"`
-		Amount         *float64 `json:"amount" validate:"omitempty"`
-		PeriodicExpr   *string  `json:"periodic_expr" validate:"omitempty,cron"`
-		Model          *string  `json:"model" validate:"omitempty,min=1,max=100"`
-		Condition      *string  `json:"condition" validate:"omitempty"`
-		Status         *bool    `json:"status"`
-		MaxExecPerUser *int     `json:"max_exec_per_user" validate:"omitempty,gte=0"`
+		Name           *string          `json:"name" validate:"omitempty,min=1,max=100"`
+		Title          *string          `json:"title" validate:"omitempty,min=1,max=200"`
+		Type           *string          `json:"type" validate:"omitempty,oneof=single periodic topup"`
+		Amount         *decimal.Decimal `json:"amount" validate:"omitempty"`
+		AmountExpr     *string          `json:"amount_expr" validate:"omitempty,max=2000"`
+		PeriodicExpr   *string          `json:"periodic_expr" validate:"omitempty,cron"`
+		Timezone       *string          `json:"timezone" validate:"omitempty,max=64"`
+		Model          *string          `json:"model" validate:"omitempty,min=1,max=100"`
+		Condition      *string          `json:"condition" validate:"omitempty"`
+		Status         *bool            `json:"status"`
+		MaxExecPerUser *int             `json:"max_exec_per_user" validate:"omitempty,gte=0"`
+		Shadow         *bool            `json:"shadow"`
+
+		TopupThreshold    *decimal.Decimal `json:"topup_threshold" validate:"omitempty,gte=0"`
+		TopupPeriod       *string          `json:"topup_period" validate:"omitempty,oneof=day week month"`
+		TopupMaxPerPeriod *int             `json:"topup_max_per_period" validate:"omitempty,gte=0"`
+		DripInstallments  *int             `json:"drip_installments" validate:"omitempty,gte=0,lte=365"`
+		DripInterval      *string          `json:"drip_interval" validate:"omitempty,max=20"`
+		ExclusionGroup    *string          `json:"exclusion_group" validate:"omitempty,max=100"`
+		Priority          *int             `json:"priority"`
+		RolloverPercent   *float64         `json:"rollover_percent" validate:"omitempty,gte=0,lte=100"`
+		RolloverMaxAmount *decimal.Decimal `json:"rollover_max_amount" validate:"omitempty,gte=0"`
+		RolloverMonths    *int             `json:"rollover_months" validate:"omitempty,gte=0,lte=12"`
+		GracePeriod       *string          `json:"grace_period" validate:"omitempty,max=20"`
+		FundPool          *string          `json:"fund_pool" validate:"omitempty,max=500"`
 	}
 
 	var req UpdateStrategyRequest
@@ -154,11 +235,31 @@ func (h *StrategyHandler) UpdateStrategy(c *gin.Context) {
 			return
 		}
 	}
+	if req.Timezone != nil || req.PeriodicExpr != nil {
+		var timezone, periodicExpr string
+		if req.Timezone != nil {
+			timezone = *req.Timezone
+		}
+		if req.PeriodicExpr != nil {
+			periodicExpr = *req.PeriodicExpr
+		}
+		if err := services.ValidateStrategyTimezone(timezone, periodicExpr); err != nil {
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+			return
+		}
+	}
 	if req.MaxExecPerUser != nil && *req.MaxExecPerUser < 0 {
 		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "max_exec_per_user must be >= 0"))
 		return
 	}
 
+	if req.AmountExpr != nil {
+		if err := services.ValidateAmountExpr(*req.AmountExpr); err != nil {
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+			return
+		}
+	}
+
 	// Special business logic: validate condition expression if present
 	if req.Condition != nil && *req.Condition != "" {
 		parser := condition.NewParser(*req.Condition)
@@ -182,9 +283,15 @@ func (h *StrategyHandler) UpdateStrategy(c *gin.Context) {
 	if req.Amount != nil {
 		updates["amount"] = *req.Amount
 	}
+	if req.AmountExpr != nil {
+		updates["amount_expr"] = *req.AmountExpr
+	}
 	if req.PeriodicExpr != nil {
 		updates["periodic_expr"] = *req.PeriodicExpr
 	}
+	if req.Timezone != nil {
+		updates["timezone"] = *req.Timezone
+	}
 	if req.Model != nil {
 		updates["model"] = *req.Model
 	}
@@ -197,8 +304,55 @@ func (h *StrategyHandler) UpdateStrategy(c *gin.Context) {
 	if req.MaxExecPerUser != nil {
 		updates["max_exec_per_user"] = *req.MaxExecPerUser
 	}
+	if req.Shadow != nil {
+		updates["shadow"] = *req.Shadow
+	}
+	if req.TopupThreshold != nil {
+		updates["topup_threshold"] = *req.TopupThreshold
+	}
+	if req.TopupPeriod != nil {
+		updates["topup_period"] = *req.TopupPeriod
+	}
+	if req.TopupMaxPerPeriod != nil {
+		updates["topup_max_per_period"] = *req.TopupMaxPerPeriod
+	}
+	if req.DripInstallments != nil {
+		updates["drip_installments"] = *req.DripInstallments
+	}
+	if req.DripInterval != nil {
+		updates["drip_interval"] = *req.DripInterval
+	}
+	if req.ExclusionGroup != nil {
+		updates["exclusion_group"] = *req.ExclusionGroup
+	}
+	if req.Priority != nil {
+		updates["priority"] = *req.Priority
+	}
+	if req.RolloverPercent != nil {
+		updates["rollover_percent"] = *req.RolloverPercent
+	}
+	if req.RolloverMaxAmount != nil {
+		updates["rollover_max_amount"] = *req.RolloverMaxAmount
+	}
+	if req.RolloverMonths != nil {
+		updates["rollover_months"] = *req.RolloverMonths
+	}
+	if req.GracePeriod != nil {
+		updates["grace_period"] = *req.GracePeriod
+	}
+	if req.FundPool != nil {
+		updates["fund_pool"] = *req.FundPool
+	}
 
 	if err := h.service.UpdateStrategy(id, updates); err != nil {
+		if isApprovalRequiredError(err) {
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.StrategyApprovalRequiredCode, err.Error()))
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.StrategyUpdateFailedCode, "Failed to update strategy: "+err.Error()))
 		return
 	}
@@ -216,6 +370,14 @@ func (h *StrategyHandler) EnableStrategy(c *gin.Context) {
 	}
 
 	if err := h.service.EnableStrategy(id); err != nil {
+		if isApprovalRequiredError(err) {
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.StrategyApprovalRequiredCode, err.Error()))
+			return
+		}
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.StrategyUpdateFailedCode, "Failed to enable strategy: "+err.Error()))
 		return
 	}
@@ -298,3 +460,165 @@ func (h *StrategyHandler) GetStrategyExecuteRecords(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Strategy execution records retrieved successfully"))
 }
+
+// isApprovalRequiredError checks if enabling was refused because the strategy is not approved
+func isApprovalRequiredError(err error) bool {
+	serviceErr, ok := err.(*services.ServiceError)
+	return ok && serviceErr.Code == services.ErrorApprovalRequired
+}
+
+// isValidationError checks if the service rejected the request as invalid
+func isValidationError(err error) bool {
+	serviceErr, ok := err.(*services.ServiceError)
+	return ok && serviceErr.Code == services.ErrorValidationFailed
+}
+
+// GetShadowResults gets the would-be grants recorded by a shadow strategy
+func (h *StrategyHandler) GetShadowResults(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.InvalidStrategyIDCode, "Invalid strategy ID format"))
+		return
+	}
+
+	var req PaginationQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+
+	page, pageSize, err := validation.ValidatePageParams(req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	records, total, err := h.service.GetShadowResults(id, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, "Failed to retrieve shadow results: "+err.Error()))
+		return
+	}
+
+	data := gin.H{
+		"total":   total,
+		"records": records,
+	}
+
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Shadow results retrieved successfully"))
+}
+
+// CompareShadowStrategy compares a shadow strategy's would-be grants with a live strategy's grants
+func (h *StrategyHandler) CompareShadowStrategy(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.InvalidStrategyIDCode, "Invalid strategy ID format"))
+		return
+	}
+
+	liveID, err := strconv.Atoi(c.Query("live_strategy_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "live_strategy_id is required and must be an integer"))
+		return
+	}
+
+	// Default window: the last 7 days
+	endTime := time.Now()
+	if v := c.Query("end_time"); v != "" {
+		if endTime, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid end_time, expected RFC3339: "+err.Error()))
+			return
+		}
+	}
+	startTime := endTime.AddDate(0, 0, -7)
+	if v := c.Query("start_time"); v != "" {
+		if startTime, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid start_time, expected RFC3339: "+err.Error()))
+			return
+		}
+	}
+	if !startTime.Before(endTime) {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "start_time must be before end_time"))
+		return
+	}
+
+	comparison, err := h.service.CompareShadowStrategy(id, liveID, startTime, endTime)
+	if err != nil {
+		if serviceErr, ok := err.(*services.ServiceError); ok {
+			switch serviceErr.Code {
+			case services.ErrorResourceNotFound:
+				c.JSON(http.StatusNotFound, response.NewErrorResponse(response.StrategyNotFoundCode, serviceErr.Message))
+				return
+			case services.ErrorValidationFailed:
+				c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, serviceErr.Message))
+				return
+			}
+		}
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, "Failed to compare shadow strategy: "+err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewSuccessResponse(comparison, "Shadow comparison computed successfully"))
+}
+
+// PreviewCron previews an arbitrary cron expression
+func (h *StrategyHandler) PreviewCron(c *gin.Context) {
+	expr := c.Query("expr")
+	if expr == "" {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "expr is required"))
+		return
+	}
+
+	count := services.DefaultCronPreviewCount
+	if v := c.Query("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "count must be a positive integer"))
+			return
+		}
+		count = n
+	}
+
+	preview, err := services.PreviewCron(expr, c.Query("timezone"), count)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewSuccessResponse(preview, "Cron expression previewed successfully"))
+}
+
+// GetTopupExecuteRecords gets the top-up execution records of a topup strategy
+func (h *StrategyHandler) GetTopupExecuteRecords(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.InvalidStrategyIDCode, "Invalid strategy ID format"))
+		return
+	}
+
+	var req PaginationQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return
+	}
+
+	page, pageSize, err := validation.ValidatePageParams(req.Page, req.PageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+
+	records, total, err := h.service.GetTopupExecuteRecords(id, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, "Failed to retrieve topup records: "+err.Error()))
+		return
+	}
+
+	data := gin.H{
+		"total":   total,
+		"records": records,
+	}
+
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Topup records retrieved successfully"))
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+	"quota-manager/internal/config"
+	"quota-manager/internal/models"
+	"quota-manager/internal/response"
+	"quota-manager/internal/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StrategyApprovalHandler handles strategy approval workflow HTTP requests
+type StrategyApprovalHandler struct {
+	service      *services.StrategyService
+	serverConfig *config.ServerConfig
+}
+
+// NewStrategyApprovalHandler creates a new strategy approval handler
+func NewStrategyApprovalHandler(service *services.StrategyService, serverConfig *config.ServerConfig) *StrategyApprovalHandler {
+	return &StrategyApprovalHandler{
+		service:      service,
+		serverConfig: serverConfig,
+	}
+}
+
+// ApprovalRequest represents the optional body of approval actions
+type ApprovalRequest struct {
+	Comment string `json:"comment" validate:"omitempty,max=1000"`
+}
+
+// handleApprovalAction runs one approval workflow action for the strategy in the path
+func (h *StrategyApprovalHandler) handleApprovalAction(c *gin.Context, action func(id int, operator, comment string) (*models.StrategyApproval, error), successMessage string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.InvalidStrategyIDCode, "Invalid strategy ID format"))
+		return
+	}
+
+	operator, err := getOperatorFromToken(c, h.serverConfig)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, response.NewErrorResponse(response.TokenInvalidCode,
+			"Failed to extract user from token: "+err.Error()))
+		return
+	}
+
+	var req ApprovalRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid request body: "+err.Error()))
+			return
+		}
+	}
+
+	record, err := action(id, operator, req.Comment)
+	if err != nil {
+		respondStrategyApprovalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.NewSuccessResponse(record, successMessage))
+}
+
+// SubmitStrategy handles POST /quota-manager/api/v1/strategies/:id/submit
+func (h *StrategyApprovalHandler) SubmitStrategy(c *gin.Context) {
+	h.handleApprovalAction(c, h.service.SubmitStrategyForApproval, "Strategy submitted for approval successfully")
+}
+
+// ApproveStrategy handles POST /quota-manager/api/v1/strategies/:id/approve
+func (h *StrategyApprovalHandler) ApproveStrategy(c *gin.Context) {
+	h.handleApprovalAction(c, h.service.ApproveStrategy, "Strategy approved successfully")
+}
+
+// RejectStrategy handles POST /quota-manager/api/v1/strategies/:id/reject
+func (h *StrategyApprovalHandler) RejectStrategy(c *gin.Context) {
+	h.handleApprovalAction(c, h.service.RejectStrategy, "Strategy rejected successfully")
+}
+
+// GetStrategyApprovals handles GET /quota-manager/api/v1/strategies/:id/approvals
+func (h *StrategyApprovalHandler) GetStrategyApprovals(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.InvalidStrategyIDCode, "Invalid strategy ID format"))
+		return
+	}
+
+	if _, err := h.service.GetStrategy(id); err != nil {
+		c.JSON(http.StatusNotFound, response.NewErrorResponse(response.StrategyNotFoundCode, "Strategy not found: "+err.Error()))
+		return
+	}
+
+	records, err := h.service.GetStrategyApprovals(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, "Failed to retrieve approval records: "+err.Error()))
+		return
+	}
+
+	data := gin.H{
+		"total":   len(records),
+		"records": records,
+	}
+
+	c.JSON(http.StatusOK, response.NewSuccessResponse(data, "Strategy approval records retrieved successfully"))
+}
+
+// respondStrategyApprovalError maps approval workflow errors to HTTP responses
+func respondStrategyApprovalError(c *gin.Context, err error) {
+	if serviceErr, ok := err.(*services.ServiceError); ok {
+		switch serviceErr.Code {
+		case services.ErrorResourceNotFound:
+			c.JSON(http.StatusNotFound, response.NewErrorResponse(response.StrategyNotFoundCode, serviceErr.Message))
+			return
+		case services.ErrorValidationFailed:
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.StrategyApprovalRequiredCode, serviceErr.Message))
+			return
+		case services.ErrorConflict:
+			c.JSON(http.StatusConflict, response.NewErrorResponse(response.StrategyApprovalStateCode, serviceErr.Message))
+			return
+		}
+	}
+
+	c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, err.Error()))
+}
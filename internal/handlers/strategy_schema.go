@@ -7,6 +7,7 @@ import (
 	"quota-manager/internal/response"
 	"quota-manager/internal/services"
 	"quota-manager/internal/validation"
+	"quota-manager/pkg/decimal"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -92,7 +93,7 @@ func (h *StrategySchemaHandler) UpdateStrategySchema(c *gin.Context) {
 	}
 	if amount, exists := updates["amount"]; exists {
 		if amountFloat, ok := amount.(float64); ok {
-			tempStrategy.Amount = amountFloat
+			tempStrategy.Amount = decimal.NewFromFloat(amountFloat)
 		}
 	}
 	if model, exists := updates["model"]; exists {
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"net/http"
+	"quota-manager/internal/response"
+	"quota-manager/internal/services"
+	"quota-manager/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UsageAnalyticsHandler handles the admin usage analytics HTTP requests
+type UsageAnalyticsHandler struct {
+	quotaService *services.QuotaService
+}
+
+// NewUsageAnalyticsHandler creates a new usage analytics handler
+func NewUsageAnalyticsHandler(quotaService *services.QuotaService) *UsageAnalyticsHandler {
+	return &UsageAnalyticsHandler{quotaService: quotaService}
+}
+
+// UsageDateRangeQuery represents the date range of usage analytics, YYYY-MM-DD inclusive
+type UsageDateRangeQuery struct {
+	StartDate string `form:"start_date"`
+	EndDate   string `form:"end_date"`
+}
+
+// TopConsumersQuery represents the top consumers query
+type TopConsumersQuery struct {
+	UsageDateRangeQuery
+	Department string `form:"department" validate:"omitempty,max=500"`
+	Limit      int    `form:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+// DepartmentUsageQuery represents the per-department aggregate query
+type DepartmentUsageQuery struct {
+	UsageDateRangeQuery
+	Level int `form:"level" validate:"omitempty,min=1,max=20"` // department path level, 1 = top level
+}
+
+// UsageTrendQuery represents the month-over-month trend query
+type UsageTrendQuery struct {
+	Months     int    `form:"months" validate:"omitempty,min=1,max=24"`
+	UserID     string `form:"user_id" validate:"omitempty,max=255"`
+	Department string `form:"department" validate:"omitempty,max=500"`
+}
+
+// GetUserUsageSeries returns a user's daily usage snapshots
+func (h *UsageAnalyticsHandler) GetUserUsageSeries(c *gin.Context) {
+	var uri UserIDUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid user_id: "+err.Error()))
+		return
+	}
+	if err := validation.ValidateStruct(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return
+	}
+	var req UsageDateRangeQuery
+	if !bindUsageQuery(c, &req) {
+		return
+	}
+
+	series, err := h.quotaService.GetUsageSeries(uri.UserID, req.StartDate, req.EndDate)
+	if err != nil {
+		respondUsageAnalyticsError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(series, "Usage series retrieved successfully"))
+}
+
+// GetTopConsumers returns the users with the most usage in a date range
+func (h *UsageAnalyticsHandler) GetTopConsumers(c *gin.Context) {
+	var req TopConsumersQuery
+	if !bindUsageQuery(c, &req) {
+		return
+	}
+
+	consumers, err := h.quotaService.GetTopConsumers(req.StartDate, req.EndDate, req.Department, req.Limit)
+	if err != nil {
+		respondUsageAnalyticsError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(consumers, "Top consumers retrieved successfully"))
+}
+
+// GetDepartmentUsage returns usage aggregated by department in a date range
+func (h *UsageAnalyticsHandler) GetDepartmentUsage(c *gin.Context) {
+	var req DepartmentUsageQuery
+	if !bindUsageQuery(c, &req) {
+		return
+	}
+
+	aggregates, err := h.quotaService.GetDepartmentUsage(req.StartDate, req.EndDate, req.Level)
+	if err != nil {
+		respondUsageAnalyticsError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(aggregates, "Department usage retrieved successfully"))
+}
+
+// GetUsageTrends returns month-over-month usage
+func (h *UsageAnalyticsHandler) GetUsageTrends(c *gin.Context) {
+	var req UsageTrendQuery
+	if !bindUsageQuery(c, &req) {
+		return
+	}
+
+	trends, err := h.quotaService.GetUsageTrends(req.Months, req.UserID, req.Department)
+	if err != nil {
+		respondUsageAnalyticsError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.NewSuccessResponse(trends, "Usage trends retrieved successfully"))
+}
+
+// bindUsageQuery binds and validates a usage query, answering 400 when it is invalid
+func bindUsageQuery(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindQuery(req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, "Invalid query parameters: "+err.Error()))
+		return false
+	}
+	if err := validation.ValidateStruct(req); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, err.Error()))
+		return false
+	}
+	return true
+}
+
+// respondUsageAnalyticsError maps usage analytics errors to HTTP responses
+func respondUsageAnalyticsError(c *gin.Context, err error) {
+	if serviceErr, ok := err.(*services.ServiceError); ok {
+		switch serviceErr.Code {
+		case services.ErrorValidationFailed:
+			c.JSON(http.StatusBadRequest, response.NewErrorResponse(response.BadRequestCode, serviceErr.Message))
+			return
+		case services.ErrorResourceNotFound:
+			c.JSON(http.StatusNotFound, response.NewErrorResponse(response.NotFoundCode, serviceErr.Message))
+			return
+		}
+	}
+
+	c.JSON(http.StatusInternalServerError, response.NewErrorResponse(response.DatabaseErrorCode, err.Error()))
+}
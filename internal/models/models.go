@@ -9,6 +9,7 @@ import (
 
 	"quota-manager/internal/config"
 	"quota-manager/internal/utils"
+	"quota-manager/pkg/decimal"
 )
 
 // AuthUser struct for parsing user info from JWT
@@ -64,18 +65,34 @@ func ParseUserInfoFromToken(accessToken string) (*AuthUser, error) {
 
 // QuotaStrategy strategy table structure
 type QuotaStrategy struct {
-	ID             int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name           string    `gorm:"uniqueIndex;not null" json:"name" validate:"required,min=1,max=100"`
-	Title          string    `gorm:"not null" json:"title" validate:"required,min=1,max=200"`
-	Type           string    `gorm:"not null" json:"type" validate:"required,oneof=single periodic"` // periodic/single
-	Amount         float64   `gorm:"not null" json:"amount"`
-	Model          string    `json:"model" validate:"omitempty,min=1,max=100"`
-	PeriodicExpr   string    `gorm:"column:periodic_expr" json:"periodic_expr" validate:"omitempty,cron"`
-	Condition      string    `json:"condition" validate:"omitempty"`
-	MaxExecPerUser int       `gorm:"column:max_exec_per_user;default:0" json:"max_exec_per_user" validate:"gte=0"`
-	Status         bool      `gorm:"not null;default:true" json:"status"` // true=enabled, false=disabled
-	CreateTime     time.Time `gorm:"autoCreateTime" json:"create_time"`
-	UpdateTime     time.Time `gorm:"autoUpdateTime" json:"update_time"`
+	ID                int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name              string          `gorm:"uniqueIndex;not null" json:"name" validate:"required,min=1,max=100"`
+	Title             string          `gorm:"not null" json:"title" validate:"required,min=1,max=200"`
+	Type              string          `gorm:"not null" json:"type" validate:"required,oneof=single periodic topup"` // periodic/single/topup
+	Amount            decimal.Decimal `gorm:"not null" json:"amount"`
+	AmountExpr        string          `gorm:"column:amount_expr;type:text" json:"amount_expr,omitempty"` // per-user amount formula, e.g. 50 * vip; amount caps it when > 0
+	Model             string          `json:"model" validate:"omitempty,min=1,max=100"`
+	PeriodicExpr      string          `gorm:"column:periodic_expr" json:"periodic_expr" validate:"omitempty,cron"`
+	Timezone          string          `gorm:"size:64" json:"timezone" validate:"omitempty,max=64"` // IANA timezone for periodic_expr, empty = configured timezone
+	Condition         string          `json:"condition" validate:"omitempty"`
+	MaxExecPerUser    int             `gorm:"column:max_exec_per_user;default:0" json:"max_exec_per_user" validate:"gte=0"`
+	TopupThreshold    decimal.Decimal `gorm:"column:topup_threshold;default:0" json:"topup_threshold,omitempty" validate:"gte=0"`                  // topup: grant when remaining balance drops below this
+	TopupPeriod       string          `gorm:"column:topup_period;size:10" json:"topup_period,omitempty" validate:"omitempty,oneof=day week month"` // topup: cap window
+	TopupMaxPerPeriod int             `gorm:"column:topup_max_per_period;default:0" json:"topup_max_per_period,omitempty" validate:"gte=0"`        // topup: grants per user per period, 0=unlimited
+	DripInstallments  int             `gorm:"column:drip_installments;default:0" json:"drip_installments,omitempty" validate:"gte=0,lte=365"`      // drip: installments the amount is spread over, 0/1=grant at once
+	DripInterval      string          `gorm:"column:drip_interval;size:20" json:"drip_interval,omitempty"`                                         // drip: time between installments, e.g. 24h
+	ExclusionGroup    string          `gorm:"column:exclusion_group;size:100;index" json:"exclusion_group,omitempty" validate:"omitempty,max=100"` // users get one grant per group and calendar month
+	Priority          int             `gorm:"not null;default:0" json:"priority"`                                                                  // highest matching priority wins within the exclusion group
+	RolloverPercent   float64         `gorm:"column:rollover_percent;default:0" json:"rollover_percent,omitempty" validate:"gte=0,lte=100"`        // expiry: share of the unused amount carried into a new bucket
+	RolloverMaxAmount decimal.Decimal `gorm:"column:rollover_max_amount;default:0" json:"rollover_max_amount,omitempty" validate:"gte=0"`          // expiry: cap on the carried amount, 0=no cap
+	RolloverMonths    int             `gorm:"column:rollover_months;default:0" json:"rollover_months,omitempty" validate:"gte=0,lte=12"`           // expiry: months the rollover bucket stays valid, 0=1
+	GracePeriod       string          `gorm:"column:grace_period;size:20" json:"grace_period,omitempty" validate:"omitempty,max=20"`               // expiry: time quota stays usable past its expiry date, e.g. 72h
+	FundPool          string          `gorm:"column:fund_pool;size:500" json:"fund_pool,omitempty" validate:"omitempty,max=500"`                   // department pool funded with the amount of each matching user instead of the user
+	Status            bool            `gorm:"not null;default:true" json:"status"`                                                                 // true=enabled, false=disabled
+	ApprovalStatus    string          `gorm:"column:approval_status;not null;default:approved;size:20" json:"approval_status"`                     // draft/pending/approved
+	Shadow            bool            `gorm:"not null;default:false" json:"shadow"`                                                                // true=record would-be grants only
+	CreateTime        time.Time       `gorm:"autoCreateTime" json:"create_time"`
+	UpdateTime        time.Time       `gorm:"autoUpdateTime" json:"update_time"`
 }
 
 // QuotaExecute execution status table
@@ -114,55 +131,146 @@ type UserInfo struct {
 
 // Quota user quota table with expiry time
 type Quota struct {
-	ID         int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID     string    `gorm:"not null;index;size:255" json:"user_id"`
-	Amount     float64   `gorm:"not null" json:"amount"`
-	ExpiryDate time.Time `gorm:"not null;index" json:"expiry_date"`
-	Status     string    `gorm:"not null;default:VALID;index;size:20" json:"status"` // VALID/EXPIRED
-	CreateTime time.Time `gorm:"autoCreateTime" json:"create_time"`
-	UpdateTime time.Time `gorm:"autoUpdateTime" json:"update_time"`
+	ID           int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       string          `gorm:"not null;index;size:255" json:"user_id"`
+	Amount       decimal.Decimal `gorm:"not null" json:"amount"`
+	Consumed     decimal.Decimal `gorm:"not null;default:0" json:"consumed"`                        // usage charged to this bucket, FIFO by expiry
+	Model        string          `gorm:"not null;default:'';size:100;index" json:"model,omitempty"` // model pool, empty = any model
+	StrategyID   *int            `gorm:"index" json:"strategy_id,omitempty"`                        // originating strategy when it has its own expiry policy
+	RolloverFrom *int            `gorm:"column:rollover_from" json:"rollover_from,omitempty"`       // expired bucket this one was rolled over from
+	ExpiryDate   time.Time       `gorm:"not null;index" json:"expiry_date"`
+	Status       string          `gorm:"not null;default:VALID;index;size:20" json:"status"` // VALID/EXPIRED
+	CreateTime   time.Time       `gorm:"autoCreateTime" json:"create_time"`
+	UpdateTime   time.Time       `gorm:"autoUpdateTime" json:"update_time"`
+}
+
+// Remaining returns the part of the bucket not consumed yet
+func (q *Quota) Remaining() decimal.Decimal {
+	if q.Amount.LessThanOrEqual(q.Consumed) {
+		return decimal.Zero
+	}
+	return q.Amount.Sub(q.Consumed)
 }
 
 // QuotaAudit quota change audit log
 type QuotaAudit struct {
-	ID           int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID       string    `gorm:"not null;index;size:255" json:"user_id"`
-	Amount       float64   `gorm:"not null" json:"amount"`                  // positive or negative
-	Operation    string    `gorm:"not null;index;size:50" json:"operation"` // RECHARGE/TRANSFER_IN/TRANSFER_OUT
-	VoucherCode  string    `gorm:"index;size:1000" json:"voucher_code,omitempty"`
-	RelatedUser  string    `gorm:"size:255" json:"related_user,omitempty"`
-	StrategyID   *int      `gorm:"index" json:"strategy_id,omitempty"`            // Strategy ID for RECHARGE operations
-	StrategyName string    `gorm:"index;size:100" json:"strategy_name,omitempty"` // Strategy name for RECHARGE operations
-	ExpiryDate   time.Time `gorm:"not null" json:"expiry_date"`
-	Details      string    `gorm:"type:text" json:"details,omitempty"` // JSON string with detailed operation info
-	CreateTime   time.Time `gorm:"autoCreateTime;index" json:"create_time"`
+	ID           int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       string          `gorm:"not null;index;size:255" json:"user_id"`
+	Amount       decimal.Decimal `gorm:"not null" json:"amount"`                              // positive or negative
+	Operation    string          `gorm:"not null;index;size:50" json:"operation"`             // RECHARGE/TRANSFER_IN/TRANSFER_OUT
+	Model        string          `gorm:"not null;default:'';size:100" json:"model,omitempty"` // model pool, empty = any model or mixed
+	VoucherCode  string          `gorm:"index;size:1000" json:"voucher_code,omitempty"`
+	RelatedUser  string          `gorm:"index;size:255" json:"related_user,omitempty"`
+	StrategyID   *int            `gorm:"index" json:"strategy_id,omitempty"`            // Strategy ID for RECHARGE operations
+	StrategyName string          `gorm:"index;size:100" json:"strategy_name,omitempty"` // Strategy name for RECHARGE operations
+	ExpiryDate   time.Time       `gorm:"not null" json:"expiry_date"`
+	Details      string          `gorm:"type:text" json:"details,omitempty"` // JSON string with detailed operation info
+	CreateTime   time.Time       `gorm:"autoCreateTime;index" json:"create_time"`
+	PrevHash     string          `gorm:"not null;default:'';size:64" json:"prev_hash,omitempty"` // hash of the user's previous record, empty for the first
+	Hash         string          `gorm:"not null;default:'';size:64" json:"hash,omitempty"`      // keyed hash over the record and PrevHash, empty before chaining
 }
 
 // QuotaAuditDetails contains detailed information about quota operations
 type QuotaAuditDetails struct {
-	Operation string                 `json:"operation"`
-	Summary   QuotaAuditSummary      `json:"summary"`
-	Items     []QuotaAuditDetailItem `json:"items,omitempty"`
+	Operation     string                 `json:"operation"`
+	Summary       QuotaAuditSummary      `json:"summary"`
+	Items         []QuotaAuditDetailItem `json:"items,omitempty"`
+	AmountFormula *AmountFormulaDetail   `json:"amount_formula,omitempty"` // For strategy grants with an amount expression
+	Rollover      *RolloverDetail        `json:"rollover,omitempty"`       // For ROLLOVER: the expired bucket and the policy applied
+	Reservation   *ReservationDetail     `json:"reservation,omitempty"`    // For RESERVE operations: the reservation and how it was settled
+	Credit        *CreditDetail          `json:"credit,omitempty"`         // For CREDIT operations: the credit line and debt after the change
+	Pool          *PoolDetail            `json:"pool,omitempty"`           // For POOL_DRAW: the department pool the quota came from
+	BalanceCap    *BalanceCapDetail      `json:"balance_cap,omitempty"`    // For grants cut by the user's maximum balance
+	LedgerReplay  *LedgerReplayDetail    `json:"ledger_replay,omitempty"`  // For LEDGER_REPLAY: the quota row the replay changed
+}
+
+// LedgerReplayDetail records a quota row rewritten by a ledger replay
+type LedgerReplayDetail struct {
+	Action         string          `json:"action"` // create/update/delete
+	QuotaID        int             `json:"quota_id"`
+	MergedQuotaIDs []int           `json:"merged_quota_ids,omitempty"` // rows merged into the quota row
+	RolloverFrom   *int            `json:"rollover_from,omitempty"`
+	CurrentAmount  decimal.Decimal `json:"current_amount"`
+	ReplayedAmount decimal.Decimal `json:"replayed_amount"`
+}
+
+// BalanceCapDetail records how a grant was cut to stay within the user's maximum balance
+type BalanceCapDetail struct {
+	MaxBalance      decimal.Decimal `json:"max_balance"`
+	BalanceBefore   decimal.Decimal `json:"balance_before"`
+	RequestedAmount decimal.Decimal `json:"requested_amount"`
+	TruncatedAmount decimal.Decimal `json:"truncated_amount"`
+	Source          string          `json:"source"` // global, user, or department:<name>
+}
+
+// PoolDetail links a pool draw audit record to its department pool
+type PoolDetail struct {
+	PoolID      int             `json:"pool_id"`
+	Department  string          `json:"department"`
+	BalanceLeft decimal.Decimal `json:"balance_left"` // pool balance after the draw
+	MonthDrawn  decimal.Decimal `json:"month_drawn"`  // drawn by the member this month, including this draw
+	MemberCap   decimal.Decimal `json:"member_cap,omitempty"`
+}
+
+// CreditDetail records the credit state of a user after a credit operation
+type CreditDetail struct {
+	CreditLimit   decimal.Decimal `json:"credit_limit"`
+	PreviousLimit decimal.Decimal `json:"previous_limit,omitempty"` // For CREDIT_LIMIT
+	Debt          decimal.Decimal `json:"debt"`                     // outstanding debt after the operation
+	QuotaID       int             `json:"quota_id,omitempty"`       // For CREDIT_SETTLE: the bucket the debt was paid from
+	Source        string          `json:"source,omitempty"`         // For CREDIT_LIMIT: user, or department:<name>
+	Operator      string          `json:"operator,omitempty"`
+}
+
+// ReservationDetail links a reservation audit record to its reservation
+type ReservationDetail struct {
+	ReservationID  int             `json:"reservation_id"`
+	HeldAmount     decimal.Decimal `json:"held_amount"`
+	ActualAmount   decimal.Decimal `json:"actual_amount,omitempty"`   // usage charged on commit
+	ReturnedAmount decimal.Decimal `json:"returned_amount,omitempty"` // held quota returned to the buckets
+	ExpiredAmount  decimal.Decimal `json:"expired_amount,omitempty"`  // held quota whose bucket expired meanwhile
+	Reason         string          `json:"reason,omitempty"`          // why a reservation was released, e.g. ttl
+}
+
+// RolloverDetail links a rollover to the expired bucket it carries quota from
+type RolloverDetail struct {
+	QuotaID           int             `json:"quota_id"`       // expired bucket
+	ExpiredAmount     decimal.Decimal `json:"expired_amount"` // bucket amount
+	UnusedAmount      decimal.Decimal `json:"unused_amount"`  // amount not consumed at expiry
+	RolloverPercent   float64         `json:"rollover_percent,omitempty"`
+	RolloverMaxAmount decimal.Decimal `json:"rollover_max_amount,omitempty"`
+	PolicySource      string          `json:"policy_source"` // global or strategy
+}
+
+// AmountFormulaDetail records how a strategy's amount expression was evaluated for a user
+type AmountFormulaDetail struct {
+	Expression     string                 `json:"expression"`
+	Inputs         map[string]interface{} `json:"inputs"`
+	ComputedAmount decimal.Decimal        `json:"computed_amount"` // before the cap
+	Cap            decimal.Decimal        `json:"cap,omitempty"`   // strategy amount, 0 = no cap
+	Amount         decimal.Decimal        `json:"amount"`          // granted amount
 }
 
 // QuotaAuditSummary contains summary information
 type QuotaAuditSummary struct {
-	TotalAmount        float64 `json:"total_amount"`
-	TotalItems         int     `json:"total_items"`
-	SuccessfulItems    int     `json:"successful_items,omitempty"`
-	FailedItems        int     `json:"failed_items,omitempty"`
-	ExpiredItems       int     `json:"expired_items,omitempty"`
-	EarliestExpiryDate string  `json:"earliest_expiry_date"`
+	TotalAmount        decimal.Decimal `json:"total_amount"`
+	TotalItems         int             `json:"total_items"`
+	SuccessfulItems    int             `json:"successful_items,omitempty"`
+	FailedItems        int             `json:"failed_items,omitempty"`
+	ExpiredItems       int             `json:"expired_items,omitempty"`
+	EarliestExpiryDate string          `json:"earliest_expiry_date"`
 }
 
 // QuotaAuditDetailItem represents individual quota item in audit
 type QuotaAuditDetailItem struct {
-	Amount        float64 `json:"amount"`
-	ExpiryDate    string  `json:"expiry_date"`
-	Status        string  `json:"status"` // SUCCESS/FAILED/EXPIRED
-	FailureReason string  `json:"failure_reason,omitempty"`
-	OriginalQuota float64 `json:"original_quota,omitempty"` // For TRANSFER_IN: existing quota before transfer
-	NewQuota      float64 `json:"new_quota,omitempty"`      // For TRANSFER_IN: quota after transfer
+	Amount        decimal.Decimal `json:"amount"`
+	ExpiryDate    string          `json:"expiry_date"`
+	Model         string          `json:"model,omitempty"` // model pool the item belongs to, empty = any model
+	Status        string          `json:"status"`          // SUCCESS/FAILED/EXPIRED
+	FailureReason string          `json:"failure_reason,omitempty"`
+	OriginalQuota decimal.Decimal `json:"original_quota,omitempty"` // For TRANSFER_IN: existing quota before transfer
+	NewQuota      decimal.Decimal `json:"new_quota,omitempty"`      // For TRANSFER_IN: quota after transfer
+	Truncated     decimal.Decimal `json:"truncated,omitempty"`      // amount cut off by the balance cap
 }
 
 // VoucherRedemption track redeemed vouchers to prevent duplicate redemption
@@ -173,6 +281,32 @@ type VoucherRedemption struct {
 	CreateTime  time.Time `gorm:"autoCreateTime" json:"create_time"`
 }
 
+// IdempotencyKey stores the result of a client request made with an Idempotency-Key
+// header, so retries of the same request replay it instead of running it again
+type IdempotencyKey struct {
+	ID           int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       string    `gorm:"not null;size:255;uniqueIndex:idx_idempotency_user_endpoint_key" json:"user_id"`
+	Endpoint     string    `gorm:"not null;size:100;uniqueIndex:idx_idempotency_user_endpoint_key" json:"endpoint"`
+	Key          string    `gorm:"column:idempotency_key;not null;size:255;uniqueIndex:idx_idempotency_user_endpoint_key" json:"key"`
+	RequestHash  string    `gorm:"not null;size:64" json:"request_hash"`
+	Status       string    `gorm:"not null;size:20" json:"status"` // processing/completed
+	ResponseCode int       `gorm:"not null;default:0" json:"response_code"`
+	ResponseBody string    `gorm:"type:text" json:"response_body"`
+	CreateTime   time.Time `gorm:"autoCreateTime;index" json:"create_time"`
+	UpdateTime   time.Time `gorm:"autoUpdateTime" json:"update_time"`
+}
+
+// Idempotency key status constants
+const (
+	IdempotencyStatusProcessing = "processing"
+	IdempotencyStatusCompleted  = "completed"
+)
+
+// TableName sets the table name
+func (IdempotencyKey) TableName() string {
+	return "quota_idempotency_key"
+}
+
 // TableName sets the table name
 func (QuotaStrategy) TableName() string {
 	return "quota_strategy"
@@ -190,10 +324,84 @@ func (Quota) TableName() string {
 	return "quota"
 }
 
+// QuotaConsumption records usage charged to a quota bucket
+type QuotaConsumption struct {
+	ID         int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     string          `gorm:"not null;index;size:255" json:"user_id"`
+	QuotaID    int             `gorm:"not null;index" json:"quota_id"`
+	Amount     decimal.Decimal `gorm:"not null" json:"amount"`
+	UsedQuota  decimal.Decimal `gorm:"not null" json:"used_quota"` // AiGateway used quota the usage was derived from
+	CreateTime time.Time       `gorm:"autoCreateTime;index" json:"create_time"`
+}
+
+// TableName sets the table name
+func (QuotaConsumption) TableName() string {
+	return "quota_consumption"
+}
+
+// QuotaUsageCursor holds the AiGateway used quota already charged to a user's buckets of one model pool
+type QuotaUsageCursor struct {
+	UserID     string          `gorm:"primaryKey;size:255" json:"user_id"`
+	Model      string          `gorm:"primaryKey;size:100;default:''" json:"model"` // model pool, empty = any model
+	UsedQuota  decimal.Decimal `gorm:"not null;default:0" json:"used_quota"`
+	Overused   decimal.Decimal `gorm:"not null;default:0" json:"overused"` // usage no valid bucket could absorb
+	UpdateTime time.Time       `gorm:"autoUpdateTime" json:"update_time"`
+}
+
+// TableName sets the table name
+func (QuotaUsageCursor) TableName() string {
+	return "quota_usage_cursor"
+}
+
+// QuotaExpiryNotice warns a user that a quota bucket is about to expire
+type QuotaExpiryNotice struct {
+	ID         int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     string          `gorm:"not null;index;size:255" json:"user_id"`
+	QuotaID    int             `gorm:"not null;uniqueIndex:idx_expiry_notice_quota_days" json:"quota_id"`
+	DaysBefore int             `gorm:"not null;uniqueIndex:idx_expiry_notice_quota_days" json:"days_before"` // warning window the notice was raised for
+	Amount     decimal.Decimal `gorm:"not null" json:"amount"`                                               // remaining amount when the notice was raised
+	ExpiryDate time.Time       `gorm:"not null;index" json:"expiry_date"`
+	CreateTime time.Time       `gorm:"autoCreateTime" json:"create_time"`
+}
+
+// TableName sets the table name
+func (QuotaExpiryNotice) TableName() string {
+	return "quota_expiry_notice"
+}
+
 func (QuotaAudit) TableName() string {
 	return "quota_audit"
 }
 
+// QuotaAuditChainHead is the last record of a user's audit hash chain, locked to append to it
+type QuotaAuditChainHead struct {
+	UserID      string    `gorm:"primaryKey;size:255" json:"user_id"`
+	LastAuditID int       `gorm:"not null;default:0" json:"last_audit_id"`
+	LastHash    string    `gorm:"not null;default:'';size:64" json:"last_hash"`
+	Length      int64     `gorm:"not null;default:0" json:"length"` // chained records of the user
+	UpdateTime  time.Time `gorm:"autoUpdateTime" json:"update_time"`
+}
+
+// TableName sets the table name
+func (QuotaAuditChainHead) TableName() string {
+	return "quota_audit_chain_head"
+}
+
+// QuotaAuditChainBreak is a break found while verifying a user's audit hash chain
+type QuotaAuditChainBreak struct {
+	ID         int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     string    `gorm:"not null;size:255;uniqueIndex:idx_audit_chain_break" json:"user_id"`
+	AuditID    int       `gorm:"not null;uniqueIndex:idx_audit_chain_break" json:"audit_id"`           // record where the chain breaks
+	Kind       string    `gorm:"not null;size:30;uniqueIndex:idx_audit_chain_break;index" json:"kind"` // hash_mismatch/link_mismatch/unsealed/head_mismatch
+	Detail     string    `gorm:"type:text" json:"detail,omitempty"`
+	CreateTime time.Time `gorm:"autoCreateTime" json:"create_time"`
+}
+
+// TableName sets the table name
+func (QuotaAuditChainBreak) TableName() string {
+	return "quota_audit_chain_break"
+}
+
 func (VoucherRedemption) TableName() string {
 	return "voucher_redemption"
 }
@@ -372,6 +580,7 @@ const (
 	OperationStarCheckSettingUpdate  = "star_check_setting_update"
 	OperationQuotaCheckSet           = "quota_check_set"
 	OperationQuotaCheckSettingUpdate = "quota_check_setting_update"
+	OperationDeclarativeApply        = "declarative_apply"
 )
 
 // IsEnabled checks if the strategy is enabled
@@ -379,6 +588,21 @@ func (s *QuotaStrategy) IsEnabled() bool {
 	return s.Status
 }
 
+// IsShadow checks if the strategy only records would-be grants
+func (s *QuotaStrategy) IsShadow() bool {
+	return s.Shadow
+}
+
+// IsDrip checks if the strategy spreads its amount over several installments
+func (s *QuotaStrategy) IsDrip() bool {
+	return s.DripInstallments > 1
+}
+
+// IsApproved checks if the strategy may be enabled and registered with cron
+func (s *QuotaStrategy) IsApproved() bool {
+	return s.ApprovalStatus == ApprovalStatusApproved
+}
+
 // Enable enables the strategy
 func (s *QuotaStrategy) Enable() {
 	s.Status = true
@@ -415,9 +639,21 @@ func (q *Quota) Expire() {
 
 // Operation constants
 const (
-	OperationRecharge    = "RECHARGE"
-	OperationTransferIn  = "TRANSFER_IN"
-	OperationTransferOut = "TRANSFER_OUT"
+	OperationRecharge       = "RECHARGE"
+	OperationTransferIn     = "TRANSFER_IN"
+	OperationTransferOut    = "TRANSFER_OUT"
+	OperationExpire         = "EXPIRE"
+	OperationTopup          = "TOPUP"
+	OperationRollover       = "ROLLOVER"
+	OperationReserve        = "RESERVE"         // quota held for a reservation
+	OperationReserveCommit  = "RESERVE_COMMIT"  // reservation settled with the actual amount used
+	OperationReserveRelease = "RESERVE_RELEASE" // reservation released unused, by request or TTL
+	OperationCreditLimit    = "CREDIT_LIMIT"    // credit line added to or removed from the AiGateway total
+	OperationCreditDraw     = "CREDIT_DRAW"     // usage beyond the user's quota, taken on credit
+	OperationCreditSettle   = "CREDIT_SETTLE"   // debt paid from a recharge
+	OperationPoolFund       = "POOL_FUND"       // department pool funded by an admin or a strategy
+	OperationPoolDraw       = "POOL_DRAW"       // quota drawn by a member from a department pool
+	OperationLedgerReplay   = "LEDGER_REPLAY"   // quota row rewritten to match the audit log, left out of the audit net
 )
 
 // Status constants for quota audit detail items
@@ -461,19 +697,427 @@ func (q *QuotaAudit) UnmarshalDetails() (*QuotaAuditDetails, error) {
 const (
 	StatusValid   = "VALID"
 	StatusExpired = "EXPIRED"
+	StatusVoided  = "VOIDED" // removed by a ledger replay, kept so its consumption log stays linked
 )
 
 // MonthlyQuotaUsage monthly quota usage record table
 type MonthlyQuotaUsage struct {
-	ID         int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID     string    `gorm:"column:user_id;not null;index" json:"user_id"`
-	YearMonth  string    `gorm:"column:year_month;not null;index" json:"year_month"` // 格式: YYYY-MM
-	UsedQuota  float64   `gorm:"column:used_quota;not null" json:"used_quota"`
-	RecordTime time.Time `gorm:"column:record_time;type:timestamptz(0)" json:"record_time"`
-	CreateTime time.Time `gorm:"column:create_time;type:timestamptz(0);autoCreateTime" json:"create_time"`
+	ID                  int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID              string          `gorm:"column:user_id;not null;index" json:"user_id"`
+	YearMonth           string          `gorm:"column:year_month;not null;index" json:"year_month"` // 格式: YYYY-MM
+	UsedQuota           decimal.Decimal `gorm:"column:used_quota;not null" json:"used_quota"`
+	CatalogRevisionFrom int             `gorm:"column:catalog_revision_from;not null;default:0" json:"catalog_revision_from"` // model cost catalog revision in effect when the month started
+	CatalogRevision     int             `gorm:"column:catalog_revision;not null;default:0" json:"catalog_revision"`           // model cost catalog revision in effect when the month ended
+	RecordTime          time.Time       `gorm:"column:record_time;type:timestamptz(0)" json:"record_time"`
+	CreateTime          time.Time       `gorm:"column:create_time;type:timestamptz(0);autoCreateTime" json:"create_time"`
 }
 
 // TableName sets the table name
 func (MonthlyQuotaUsage) TableName() string {
 	return "monthly_quota_usage"
 }
+
+// DailyQuotaUsage is a daily snapshot of a user's AiGateway total and used quota
+type DailyQuotaUsage struct {
+	ID              int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID          string          `gorm:"not null;size:255;uniqueIndex:idx_daily_usage_user_date" json:"user_id"`
+	SnapshotDate    string          `gorm:"not null;size:10;uniqueIndex:idx_daily_usage_user_date;index" json:"snapshot_date"` // YYYY-MM-DD in the configured timezone
+	TotalQuota      decimal.Decimal `gorm:"not null" json:"total_quota"`
+	UsedQuota       decimal.Decimal `gorm:"not null" json:"used_quota"`
+	Usage           decimal.Decimal `gorm:"not null;default:0" json:"usage"`                           // used quota added since the previous snapshot, expiry resets of the counter added back
+	Department      string          `gorm:"type:text;not null;default:''" json:"department,omitempty"` // comma-separated department levels at snapshot time
+	CatalogRevision int             `gorm:"not null;default:0" json:"catalog_revision"`                // model cost catalog revision the usage was weighted with
+	RecordTime      time.Time       `gorm:"type:timestamptz(0)" json:"record_time"`
+	CreateTime      time.Time       `gorm:"autoCreateTime" json:"create_time"`
+}
+
+// TableName sets the table name
+func (DailyQuotaUsage) TableName() string {
+	return "daily_quota_usage"
+}
+
+// Strategy approval status constants
+const (
+	ApprovalStatusDraft    = "draft"
+	ApprovalStatusPending  = "pending"
+	ApprovalStatusApproved = "approved"
+)
+
+// Strategy approval action constants
+const (
+	ApprovalActionSubmit  = "submit"
+	ApprovalActionApprove = "approve"
+	ApprovalActionReject  = "reject"
+	ApprovalActionRevoke  = "revoke" // approval dropped because the grant changed
+)
+
+// StrategyApproval audit trail of strategy approval requests and decisions
+type StrategyApproval struct {
+	ID           int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	StrategyID   int             `gorm:"not null;index" json:"strategy_id"`
+	StrategyName string          `gorm:"not null;size:100" json:"strategy_name"`
+	Action       string          `gorm:"not null;size:20" json:"action"` // submit/approve/reject/revoke
+	Operator     string          `gorm:"not null;size:255" json:"operator"`
+	Amount       decimal.Decimal `gorm:"not null" json:"amount"`
+	AudienceSize int             `gorm:"not null;default:0" json:"audience_size"` // simulated number of matching users
+	Comment      string          `gorm:"type:text" json:"comment,omitempty"`
+	CreateTime   time.Time       `gorm:"autoCreateTime;index" json:"create_time"`
+}
+
+// TableName sets the table name
+func (StrategyApproval) TableName() string {
+	return "strategy_approval"
+}
+
+// StrategyShadowResult would-be grant recorded by a shadow strategy run
+type StrategyShadowResult struct {
+	ID             int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	StrategyID     int             `gorm:"not null;index" json:"strategy_id"`
+	StrategyName   string          `gorm:"not null;size:100" json:"strategy_name"`
+	UserID         string          `gorm:"not null;index;size:255" json:"user_id"`
+	Amount         decimal.Decimal `gorm:"not null" json:"amount"`
+	BatchNumber    string          `gorm:"not null;index" json:"batch_number"` // run identifier
+	Condition      string          `gorm:"type:text" json:"condition"`
+	ConditionTrace string          `gorm:"type:text" json:"condition_trace"` // JSON array of evaluated function calls
+	CreateTime     time.Time       `gorm:"autoCreateTime;index" json:"create_time"`
+}
+
+// TableName sets the table name
+func (StrategyShadowResult) TableName() string {
+	return "strategy_shadow_result"
+}
+
+// TopupExecute execution record of a low-balance top-up
+type TopupExecute struct {
+	ID            int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	StrategyID    int             `gorm:"not null;index;uniqueIndex:idx_topup_execute_slot" json:"strategy_id"`
+	User          string          `gorm:"column:user_id;not null;index;uniqueIndex:idx_topup_execute_slot" json:"user"`
+	PeriodKey     string          `gorm:"not null;size:20;index;uniqueIndex:idx_topup_execute_slot" json:"period_key"` // e.g. 2025-01-15, 2025-W03, 2025-01
+	Seq           int             `gorm:"not null;default:0;uniqueIndex:idx_topup_execute_slot" json:"seq"`            // attempt number within the period, claimed once across replicas
+	BalanceBefore decimal.Decimal `gorm:"not null" json:"balance_before"`
+	Amount        decimal.Decimal `gorm:"not null" json:"amount"`
+	Status        string          `gorm:"not null;size:20" json:"status"` // processing/completed/failed/shadow
+	CreateTime    time.Time       `gorm:"autoCreateTime" json:"create_time"`
+	UpdateTime    time.Time       `gorm:"autoUpdateTime" json:"update_time"`
+}
+
+// TableName sets the table name
+func (TopupExecute) TableName() string {
+	return "quota_topup_execute"
+}
+
+// Drip plan and installment statuses
+const (
+	DripPlanStatusActive    = "active"
+	DripPlanStatusCompleted = "completed"
+	DripPlanStatusCancelled = "cancelled"
+
+	DripInstallmentPending   = "pending"
+	DripInstallmentReleased  = "released"
+	DripInstallmentCancelled = "cancelled"
+)
+
+// DripPlan spreads one strategy grant to a user over several installments
+type DripPlan struct {
+	ID             int               `gorm:"primaryKey;autoIncrement" json:"id"`
+	StrategyID     int               `gorm:"not null;index" json:"strategy_id"`
+	StrategyName   string            `gorm:"not null;size:100" json:"strategy_name"`
+	User           string            `gorm:"column:user_id;not null;index" json:"user"`
+	TotalAmount    decimal.Decimal   `gorm:"not null" json:"total_amount"`
+	Installments   int               `gorm:"not null" json:"installments"`
+	Interval       string            `gorm:"not null;size:20" json:"interval"`
+	ReleasedAmount decimal.Decimal   `gorm:"not null;default:0" json:"released_amount"`
+	AmountFormula  string            `gorm:"type:text" json:"amount_formula,omitempty"` // JSON AmountFormulaDetail when the total came from an amount expression
+	Status         string            `gorm:"not null;size:20;index" json:"status"`      // active/completed/cancelled
+	CreateTime     time.Time         `gorm:"autoCreateTime" json:"create_time"`
+	UpdateTime     time.Time         `gorm:"autoUpdateTime" json:"update_time"`
+	Items          []DripInstallment `gorm:"foreignKey:PlanID" json:"schedule,omitempty"`
+}
+
+// TableName sets the table name
+func (DripPlan) TableName() string {
+	return "quota_drip_plan"
+}
+
+// DripInstallment is one scheduled release of a drip plan
+type DripInstallment struct {
+	ID           int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	PlanID       int             `gorm:"not null;index" json:"plan_id"`
+	Sequence     int             `gorm:"not null" json:"sequence"` // 1-based
+	Amount       decimal.Decimal `gorm:"not null" json:"amount"`
+	ReleaseTime  time.Time       `gorm:"not null;index" json:"release_time"`
+	Status       string          `gorm:"not null;size:20;index" json:"status"` // pending/released/cancelled
+	ReleasedTime *time.Time      `json:"released_time,omitempty"`
+	CreateTime   time.Time       `gorm:"autoCreateTime" json:"create_time"`
+	UpdateTime   time.Time       `gorm:"autoUpdateTime" json:"update_time"`
+}
+
+// TableName sets the table name
+func (DripInstallment) TableName() string {
+	return "quota_drip_installment"
+}
+
+// GatewayOutbox is an AiGateway quota mutation written in the same transaction as
+// the quota change it mirrors and delivered by the outbox dispatcher
+type GatewayOutbox struct {
+	ID              int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	DedupKey        string          `gorm:"uniqueIndex;not null;size:255" json:"dedup_key"` // sent as Idempotency-Key to the gateway
+	UserID          string          `gorm:"not null;size:255;index" json:"user_id"`
+	Mutation        string          `gorm:"not null;size:30" json:"mutation"`                    // delta_quota/delta_used_quota
+	Model           string          `gorm:"not null;default:'';size:100" json:"model,omitempty"` // model pool counter, empty = any model
+	Value           decimal.Decimal `gorm:"not null" json:"value"`
+	Source          string          `gorm:"not null;size:50" json:"source"` // audit operation that caused the mutation
+	Status          string          `gorm:"not null;size:20;index:idx_gateway_outbox_status_next" json:"status"`
+	Attempts        int             `gorm:"not null;default:0" json:"attempts"`
+	LastError       string          `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptTime time.Time       `gorm:"not null;index:idx_gateway_outbox_status_next" json:"next_attempt_time"`
+	DeliveredTime   *time.Time      `json:"delivered_time,omitempty"`
+	CreateTime      time.Time       `gorm:"autoCreateTime" json:"create_time"`
+	UpdateTime      time.Time       `gorm:"autoUpdateTime" json:"update_time"`
+}
+
+// TableName sets the table name
+func (GatewayOutbox) TableName() string {
+	return "gateway_outbox"
+}
+
+// Gateway outbox mutation constants
+const (
+	OutboxMutationDeltaQuota     = "delta_quota"
+	OutboxMutationDeltaUsedQuota = "delta_used_quota"
+)
+
+// Gateway outbox status constants
+const (
+	OutboxStatusPending    = "pending"
+	OutboxStatusDelivering = "delivering"
+	OutboxStatusDelivered  = "delivered"
+	OutboxStatusFailed     = "failed"
+)
+
+// ReconciliationReport is one comparison run of the quota ledger, the audit log and
+// the AiGateway balances
+type ReconciliationReport struct {
+	ID               int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	Mode             string     `gorm:"not null;size:20" json:"mode"`         // dry_run/fix
+	Status           string     `gorm:"not null;size:20;index" json:"status"` // running/completed/failed
+	TriggeredBy      string     `gorm:"size:50" json:"triggered_by"`          // schedule/api/scan
+	TotalUsers       int        `gorm:"not null;default:0" json:"total_users"`
+	ConsistentUsers  int        `gorm:"not null;default:0" json:"consistent_users"`
+	DiscrepancyUsers int        `gorm:"not null;default:0" json:"discrepancy_users"`
+	FixedUsers       int        `gorm:"not null;default:0" json:"fixed_users"`
+	Error            string     `gorm:"type:text" json:"error,omitempty"`
+	StartTime        time.Time  `gorm:"not null" json:"start_time"`
+	EndTime          *time.Time `json:"end_time,omitempty"`
+	CreateTime       time.Time  `gorm:"autoCreateTime" json:"create_time"`
+}
+
+// TableName sets the table name
+func (ReconciliationReport) TableName() string {
+	return "reconciliation_report"
+}
+
+// ReconciliationItem is a user whose ledger, audit log and gateway balances disagree
+type ReconciliationItem struct {
+	ID             int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	ReportID       int             `gorm:"not null;index" json:"report_id"`
+	UserID         string          `gorm:"not null;size:255;index" json:"user_id"`
+	Classification string          `gorm:"not null;size:50;index" json:"classification"`
+	LedgerSum      decimal.Decimal `gorm:"not null" json:"ledger_sum"`     // SUM(quota.amount) of valid buckets
+	AuditNet       decimal.Decimal `gorm:"not null" json:"audit_net"`      // SUM(quota_audit.amount)
+	GatewayTotal   decimal.Decimal `gorm:"not null" json:"gateway_total"`  // AiGateway total quota
+	GatewayUsed    decimal.Decimal `gorm:"not null" json:"gateway_used"`   // AiGateway used quota
+	PendingDelta   decimal.Decimal `gorm:"not null" json:"pending_delta"`  // undelivered outbox delta_quota mutations
+	Action         string          `gorm:"not null;size:30" json:"action"` // none/refreshed/manual_review/fix_failed
+	Detail         string          `gorm:"type:text" json:"detail,omitempty"`
+	CreateTime     time.Time       `gorm:"autoCreateTime" json:"create_time"`
+}
+
+// TableName sets the table name
+func (ReconciliationItem) TableName() string {
+	return "reconciliation_item"
+}
+
+// Reconciliation constants
+const (
+	ReconcileModeDryRun = "dry_run"
+	ReconcileModeFix    = "fix"
+
+	ReconcileStatusRunning   = "running"
+	ReconcileStatusCompleted = "completed"
+	ReconcileStatusFailed    = "failed"
+
+	ReconcileLedgerAuditMismatch  = "ledger_audit_mismatch"  // quota rows disagree with the audit log
+	ReconcileGatewayTotalMismatch = "gateway_total_mismatch" // gateway total differs from the ledger
+	ReconcilePendingDelivery      = "pending_delivery"       // gateway differs only by undelivered outbox mutations
+	ReconcileGatewayOverused      = "gateway_overused"       // gateway used exceeds gateway total
+	ReconcileReadError            = "read_error"             // ledger or gateway balances could not be read
+
+	ReconcileActionNone         = "none"
+	ReconcileActionRefreshed    = "refreshed"
+	ReconcileActionManualReview = "manual_review"
+	ReconcileActionFixFailed    = "fix_failed"
+)
+
+// Audit hash chain break kinds
+const (
+	AuditChainHashMismatch = "hash_mismatch" // record content no longer matches its hash
+	AuditChainLinkMismatch = "link_mismatch" // record does not link to the previous record, one was removed or inserted
+	AuditChainUnsealed     = "unsealed"      // record without a hash after the user's chain started
+	AuditChainHeadMismatch = "head_mismatch" // chain head does not point at the last record, the tail was removed
+)
+
+// ModelCostMultiplier is one change to the model cost catalog. Every change is made in a
+// new catalog revision; the catalog at a revision is the latest change of each model up
+// to that revision, so usage recorded under an older revision stays interpretable.
+type ModelCostMultiplier struct {
+	ID            int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Revision      int       `gorm:"not null;uniqueIndex" json:"revision"` // catalog revision the change was made in
+	Model         string    `gorm:"not null;size:100;index" json:"model"`
+	Multiplier    float64   `gorm:"not null" json:"multiplier"`            // quota charged per unit of the model's cost
+	Removed       bool      `gorm:"not null;default:false" json:"removed"` // the model left the catalog, costing 1x again
+	Operator      string    `gorm:"size:255" json:"operator,omitempty"`
+	Reason        string    `gorm:"size:500" json:"reason,omitempty"`
+	Pushed        bool      `gorm:"not null;default:false" json:"pushed"` // the catalog at this revision reached AiGateway
+	EffectiveTime time.Time `gorm:"not null" json:"effective_time"`
+	CreateTime    time.Time `gorm:"autoCreateTime" json:"create_time"`
+}
+
+// TableName sets the table name
+func (ModelCostMultiplier) TableName() string {
+	return "model_cost_multiplier"
+}
+
+// Reservation status constants
+const (
+	ReservationStatusHeld      = "HELD"
+	ReservationStatusCommitted = "COMMITTED"
+	ReservationStatusReleased  = "RELEASED"
+	ReservationStatusExpired   = "EXPIRED" // released by the TTL job
+)
+
+// QuotaReservation holds quota for a long-running job until it is committed with the actual
+// amount used or released. The held quota is taken out of the user's buckets, so it can be
+// neither used nor transferred while the reservation is held.
+type QuotaReservation struct {
+	ID           int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       string          `gorm:"not null;index;size:255" json:"user_id"`
+	Model        string          `gorm:"not null;default:'';size:100" json:"model,omitempty"` // model pool, empty = any model
+	Amount       decimal.Decimal `gorm:"not null" json:"amount"`                              // held amount
+	ActualAmount decimal.Decimal `gorm:"not null;default:0" json:"actual_amount"`             // amount used, set on commit
+	Status       string          `gorm:"not null;default:HELD;size:20;index:idx_reservation_status_expires" json:"status"`
+	Reference    string          `gorm:"size:255" json:"reference,omitempty"` // caller's job reference
+	ExpiresAt    time.Time       `gorm:"not null;index:idx_reservation_status_expires" json:"expires_at"`
+	SettleTime   *time.Time      `json:"settle_time,omitempty"`
+	CreateTime   time.Time       `gorm:"autoCreateTime" json:"create_time"`
+	UpdateTime   time.Time       `gorm:"autoUpdateTime" json:"update_time"`
+}
+
+// TableName sets the table name
+func (QuotaReservation) TableName() string {
+	return "quota_reservation"
+}
+
+// QuotaReservationHold is the part of a reservation taken from one bucket, returned to
+// the bucket when the reservation is settled
+type QuotaReservationHold struct {
+	ID            int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	ReservationID int             `gorm:"not null;index" json:"reservation_id"`
+	QuotaID       int             `gorm:"not null" json:"quota_id"`
+	Amount        decimal.Decimal `gorm:"not null" json:"amount"`
+	ExpiryDate    time.Time       `gorm:"not null" json:"expiry_date"` // bucket expiry, to recreate a bucket merged away meanwhile
+	StrategyID    *int            `json:"strategy_id,omitempty"`
+	CreateTime    time.Time       `gorm:"autoCreateTime" json:"create_time"`
+}
+
+// TableName sets the table name
+func (QuotaReservationHold) TableName() string {
+	return "quota_reservation_hold"
+}
+
+// CreditLimitSetting is the credit limit of a user or department: how far below zero
+// the available balance of a user may go
+type CreditLimitSetting struct {
+	ID               int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	TargetType       string          `gorm:"not null;size:20;uniqueIndex:idx_credit_limit_target" json:"target_type"`        // 'user' or 'department'
+	TargetIdentifier string          `gorm:"not null;size:500;uniqueIndex:idx_credit_limit_target" json:"target_identifier"` // user ID for user, department name for department
+	CreditLimit      decimal.Decimal `gorm:"not null" json:"credit_limit"`
+	Operator         string          `gorm:"size:255" json:"operator,omitempty"`
+	CreateTime       time.Time       `gorm:"autoCreateTime" json:"create_time"`
+	UpdateTime       time.Time       `gorm:"autoUpdateTime" json:"update_time"`
+}
+
+// TableName sets the table name
+func (CreditLimitSetting) TableName() string {
+	return "credit_limit_setting"
+}
+
+// QuotaCredit is the credit state of a user's "any model" pool. The credit limit is added
+// to the AiGateway total; usage beyond the user's buckets is recorded as debt and paid
+// from the next recharge.
+type QuotaCredit struct {
+	UserID      string          `gorm:"primaryKey;size:255" json:"user_id"`
+	CreditLimit decimal.Decimal `gorm:"not null;default:0" json:"credit_limit"` // limit granted to AiGateway
+	Debt        decimal.Decimal `gorm:"not null;default:0" json:"debt"`         // outstanding debt
+	UpdateTime  time.Time       `gorm:"autoUpdateTime" json:"update_time"`
+}
+
+// TableName sets the table name
+func (QuotaCredit) TableName() string {
+	return "quota_credit"
+}
+
+// DepartmentPool is a department's shared quota budget. Members whose personal balance
+// is exhausted draw from the pool of their most specific department that has one.
+type DepartmentPool struct {
+	ID         int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	Department string          `gorm:"uniqueIndex;not null;size:500" json:"department"`
+	Balance    decimal.Decimal `gorm:"not null;default:0" json:"balance"`
+	MemberCap  decimal.Decimal `gorm:"not null;default:0" json:"member_cap"`  // max drawn per member and calendar month, 0=unlimited
+	DrawAmount decimal.Decimal `gorm:"not null;default:0" json:"draw_amount"` // quota moved per automatic draw
+	Operator   string          `gorm:"size:255" json:"operator,omitempty"`
+	CreateTime time.Time       `gorm:"autoCreateTime" json:"create_time"`
+	UpdateTime time.Time       `gorm:"autoUpdateTime" json:"update_time"`
+}
+
+// TableName sets the table name
+func (DepartmentPool) TableName() string {
+	return "department_pool"
+}
+
+// DepartmentPoolLedger records every movement of a department pool's balance
+type DepartmentPoolLedger struct {
+	ID           int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	PoolID       int             `gorm:"not null;index" json:"pool_id"`
+	Operation    string          `gorm:"not null;size:20" json:"operation"` // POOL_FUND or POOL_DRAW
+	Amount       decimal.Decimal `gorm:"not null" json:"amount"`            // positive for funding, negative for draws
+	BalanceAfter decimal.Decimal `gorm:"not null" json:"balance_after"`
+	UserID       string          `gorm:"size:255;index" json:"user_id,omitempty"` // For POOL_DRAW: the drawing member
+	YearMonth    string          `gorm:"size:7;index" json:"year_month"`          // member caps count draws per calendar month
+	StrategyID   *int            `json:"strategy_id,omitempty"`
+	StrategyName string          `gorm:"size:100" json:"strategy_name,omitempty"`
+	Operator     string          `gorm:"size:255" json:"operator,omitempty"`
+	CreateTime   time.Time       `gorm:"autoCreateTime" json:"create_time"`
+}
+
+// TableName sets the table name
+func (DepartmentPoolLedger) TableName() string {
+	return "department_pool_ledger"
+}
+
+// BalanceCapSetting overrides the global maximum balance for a user or department
+type BalanceCapSetting struct {
+	ID               int             `gorm:"primaryKey;autoIncrement" json:"id"`
+	TargetType       string          `gorm:"not null;size:20;uniqueIndex:idx_balance_cap_target" json:"target_type"`        // 'user' or 'department'
+	TargetIdentifier string          `gorm:"not null;size:500;uniqueIndex:idx_balance_cap_target" json:"target_identifier"` // user ID for user, department name for department
+	MaxBalance       decimal.Decimal `gorm:"not null" json:"max_balance"`                                                   // 0 = no cap
+	Policy           string          `gorm:"size:20" json:"policy,omitempty"`                                               // truncate or reject, empty = global policy
+	Operator         string          `gorm:"size:255" json:"operator,omitempty"`
+	CreateTime       time.Time       `gorm:"autoCreateTime" json:"create_time"`
+	UpdateTime       time.Time       `gorm:"autoUpdateTime" json:"update_time"`
+}
+
+// TableName sets the table name
+func (BalanceCapSetting) TableName() string {
+	return "balance_cap_setting"
+}
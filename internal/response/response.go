@@ -58,4 +58,10 @@ const (
 	StrategyDeleteFailedCode = "quota-manager.strategy_delete_failed"
 	DatabaseErrorCode        = "quota-manager.database_error"
 	AiGatewayErrorCode       = "quota-manager.aigateway_error"
+	ConfigApplyFailedCode    = "quota-manager.config_apply_failed"
+
+	StrategyApprovalRequiredCode = "quota-manager.strategy_approval_required"
+	StrategyApprovalStateCode    = "quota-manager.strategy_approval_state_invalid"
+
+	IdempotencyConflictCode = "quota-manager.idempotency_conflict"
 )
\ No newline at end of file
@@ -0,0 +1,929 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"quota-manager/internal/condition"
+	"quota-manager/internal/database"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+	"quota-manager/pkg/logger"
+	"sort"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// DesiredState describes strategies and permission settings as they should exist in the database.
+// A nil section is not managed: objects of that kind are left untouched by plan/apply.
+// An empty (but present) section means every object of that kind should be deleted.
+type DesiredState struct {
+	Strategies         []DesiredStrategy       `yaml:"strategies" json:"strategies"`
+	ModelWhitelists    []DesiredModelWhitelist `yaml:"model_whitelists" json:"model_whitelists"`
+	StarCheckSettings  []DesiredToggleSetting  `yaml:"star_check_settings" json:"star_check_settings"`
+	QuotaCheckSettings []DesiredToggleSetting  `yaml:"quota_check_settings" json:"quota_check_settings"`
+}
+
+// DesiredStrategy is the declarative form of a QuotaStrategy, keyed by name
+type DesiredStrategy struct {
+	Name           string          `yaml:"name" json:"name"`
+	Title          string          `yaml:"title" json:"title"`
+	Type           string          `yaml:"type" json:"type"`
+	Amount         decimal.Decimal `yaml:"amount" json:"amount"`
+	AmountExpr     string          `yaml:"amount_expr,omitempty" json:"amount_expr,omitempty"` // per-user formula, amount caps it when > 0
+	Model          string          `yaml:"model,omitempty" json:"model,omitempty"`
+	PeriodicExpr   string          `yaml:"periodic_expr,omitempty" json:"periodic_expr,omitempty"`
+	Timezone       string          `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+	Condition      string          `yaml:"condition,omitempty" json:"condition,omitempty"`
+	MaxExecPerUser int             `yaml:"max_exec_per_user,omitempty" json:"max_exec_per_user,omitempty"`
+	Status         *bool           `yaml:"status,omitempty" json:"status,omitempty"` // defaults to enabled
+	Shadow         bool            `yaml:"shadow,omitempty" json:"shadow,omitempty"` // record would-be grants only
+
+	TopupThreshold    decimal.Decimal `yaml:"topup_threshold,omitempty" json:"topup_threshold,omitempty"`
+	TopupPeriod       string          `yaml:"topup_period,omitempty" json:"topup_period,omitempty"`
+	TopupMaxPerPeriod int             `yaml:"topup_max_per_period,omitempty" json:"topup_max_per_period,omitempty"`
+
+	DripInstallments int    `yaml:"drip_installments,omitempty" json:"drip_installments,omitempty"`
+	DripInterval     string `yaml:"drip_interval,omitempty" json:"drip_interval,omitempty"`
+
+	ExclusionGroup string `yaml:"exclusion_group,omitempty" json:"exclusion_group,omitempty"`
+	Priority       int    `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	RolloverPercent   float64         `yaml:"rollover_percent,omitempty" json:"rollover_percent,omitempty"`
+	RolloverMaxAmount decimal.Decimal `yaml:"rollover_max_amount,omitempty" json:"rollover_max_amount,omitempty"`
+	RolloverMonths    int             `yaml:"rollover_months,omitempty" json:"rollover_months,omitempty"`
+	GracePeriod       string          `yaml:"grace_period,omitempty" json:"grace_period,omitempty"`
+
+	FundPool string `yaml:"fund_pool,omitempty" json:"fund_pool,omitempty"`
+}
+
+// DesiredModelWhitelist is the declarative form of a ModelWhitelist, keyed by target
+type DesiredModelWhitelist struct {
+	TargetType       string   `yaml:"target_type" json:"target_type"`             // 'user' or 'department'
+	TargetIdentifier string   `yaml:"target_identifier" json:"target_identifier"` // employee_number for user, department name for department
+	Models           []string `yaml:"models" json:"models"`
+}
+
+// DesiredToggleSetting is the declarative form of a star check or quota check setting, keyed by target
+type DesiredToggleSetting struct {
+	TargetType       string `yaml:"target_type" json:"target_type"`             // 'user' or 'department'
+	TargetIdentifier string `yaml:"target_identifier" json:"target_identifier"` // employee_number for user, department name for department
+	Enabled          bool   `yaml:"enabled" json:"enabled"`
+}
+
+// Kinds of objects managed by the declarative configuration
+const (
+	ConfigKindStrategy          = "strategy"
+	ConfigKindModelWhitelist    = "model_whitelist"
+	ConfigKindStarCheckSetting  = "star_check_setting"
+	ConfigKindQuotaCheckSetting = "quota_check_setting"
+)
+
+// PlanAction represents the action needed to bring one object to its desired state
+type PlanAction string
+
+const (
+	PlanActionCreate PlanAction = "create"
+	PlanActionUpdate PlanAction = "update"
+	PlanActionDelete PlanAction = "delete"
+)
+
+// PlanChange represents a single object change in a configuration plan
+type PlanChange struct {
+	Kind   string      `json:"kind"`
+	Action PlanAction  `json:"action"`
+	Key    string      `json:"key"`              // strategy name, or target_type:target_identifier
+	Fields []string    `json:"fields,omitempty"` // changed fields for updates
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// PlanSummary counts plan changes per action
+type PlanSummary struct {
+	Create int `json:"create"`
+	Update int `json:"update"`
+	Delete int `json:"delete"`
+}
+
+// ConfigPlan is the diff between the desired state and the database
+type ConfigPlan struct {
+	Changes []PlanChange `json:"changes"`
+	Summary PlanSummary  `json:"summary"`
+}
+
+// HasChanges reports whether applying the plan would modify anything
+func (p *ConfigPlan) HasChanges() bool {
+	return len(p.Changes) > 0
+}
+
+func (p *ConfigPlan) add(change PlanChange) {
+	p.Changes = append(p.Changes, change)
+	switch change.Action {
+	case PlanActionCreate:
+		p.Summary.Create++
+	case PlanActionUpdate:
+		p.Summary.Update++
+	case PlanActionDelete:
+		p.Summary.Delete++
+	}
+}
+
+// ParseDesiredState decodes a YAML or JSON desired-state document and validates it
+func ParseDesiredState(data []byte) (*DesiredState, error) {
+	var state DesiredState
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&state); err != nil && !errors.Is(err, io.EOF) {
+		return nil, NewValidationFailedError(fmt.Sprintf("failed to parse desired state: %v", err))
+	}
+
+	if err := ValidateDesiredState(&state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// ValidateDesiredState checks the desired state for syntax errors and duplicate keys
+func ValidateDesiredState(state *DesiredState) error {
+	var problems []string
+
+	names := make(map[string]bool)
+	for i, strategy := range state.Strategies {
+		prefix := fmt.Sprintf("strategies[%d]", i)
+		if strategy.Name == "" {
+			problems = append(problems, prefix+": name is required")
+		} else if names[strategy.Name] {
+			problems = append(problems, fmt.Sprintf("%s: duplicate strategy name '%s'", prefix, strategy.Name))
+		}
+		names[strategy.Name] = true
+
+		if strategy.Title == "" {
+			problems = append(problems, prefix+": title is required")
+		}
+		switch strategy.Type {
+		case "single":
+		case "periodic":
+			if strategy.PeriodicExpr == "" {
+				problems = append(problems, prefix+": periodic_expr is required for periodic strategy")
+			} else if _, err := cron.New(cron.WithSeconds()).AddFunc(strategy.PeriodicExpr, func() {}); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: invalid cron expression '%s': %v", prefix, strategy.PeriodicExpr, err))
+			}
+			if err := ValidateStrategyTimezone(strategy.Timezone, strategy.PeriodicExpr); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", prefix, err))
+			}
+		case "topup":
+			if err := ValidateTopupStrategy(&models.QuotaStrategy{
+				Type:              strategy.Type,
+				TopupThreshold:    strategy.TopupThreshold,
+				TopupPeriod:       strategy.TopupPeriod,
+				TopupMaxPerPeriod: strategy.TopupMaxPerPeriod,
+			}); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", prefix, err))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("%s: type must be 'single', 'periodic' or 'topup'", prefix))
+		}
+		if strategy.MaxExecPerUser < 0 {
+			problems = append(problems, prefix+": max_exec_per_user must be >= 0")
+		}
+		if err := ValidateDripSettings(&models.QuotaStrategy{
+			Type:             strategy.Type,
+			DripInstallments: strategy.DripInstallments,
+			DripInterval:     strategy.DripInterval,
+		}); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", prefix, err))
+		}
+		if err := ValidateAmountExpr(strategy.AmountExpr); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", prefix, err))
+		}
+		if err := ValidateExclusionGroup(&models.QuotaStrategy{
+			Type:           strategy.Type,
+			ExclusionGroup: strategy.ExclusionGroup,
+		}); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", prefix, err))
+		}
+		if err := ValidateExpiryPolicy(&models.QuotaStrategy{
+			RolloverPercent:   strategy.RolloverPercent,
+			RolloverMaxAmount: strategy.RolloverMaxAmount,
+			RolloverMonths:    strategy.RolloverMonths,
+			GracePeriod:       strategy.GracePeriod,
+		}); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", prefix, err))
+		}
+		if err := ValidateFundPool(&models.QuotaStrategy{
+			Type:             strategy.Type,
+			Model:            strategy.Model,
+			DripInstallments: strategy.DripInstallments,
+			RolloverPercent:  strategy.RolloverPercent,
+			RolloverMonths:   strategy.RolloverMonths,
+			GracePeriod:      strategy.GracePeriod,
+			FundPool:         strategy.FundPool,
+		}); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", prefix, err))
+		}
+		if strategy.Condition != "" {
+			if _, err := condition.NewParser(strategy.Condition).Parse(); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: invalid condition expression: %v", prefix, err))
+			}
+		}
+	}
+
+	targets := make(map[string]bool)
+	for i, whitelist := range state.ModelWhitelists {
+		prefix := fmt.Sprintf("model_whitelists[%d]", i)
+		problems = append(problems, validateDesiredTarget(prefix, whitelist.TargetType, whitelist.TargetIdentifier, targets)...)
+	}
+
+	targets = make(map[string]bool)
+	for i, setting := range state.StarCheckSettings {
+		prefix := fmt.Sprintf("star_check_settings[%d]", i)
+		problems = append(problems, validateDesiredTarget(prefix, setting.TargetType, setting.TargetIdentifier, targets)...)
+	}
+
+	targets = make(map[string]bool)
+	for i, setting := range state.QuotaCheckSettings {
+		prefix := fmt.Sprintf("quota_check_settings[%d]", i)
+		problems = append(problems, validateDesiredTarget(prefix, setting.TargetType, setting.TargetIdentifier, targets)...)
+	}
+
+	if len(problems) > 0 {
+		return NewValidationFailedError("invalid desired state: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateDesiredTarget validates a target reference and records it for duplicate detection
+func validateDesiredTarget(prefix, targetType, targetIdentifier string, seen map[string]bool) []string {
+	var problems []string
+	if targetType != models.TargetTypeUser && targetType != models.TargetTypeDepartment {
+		problems = append(problems, fmt.Sprintf("%s: target_type must be 'user' or 'department'", prefix))
+	}
+	if targetIdentifier == "" {
+		problems = append(problems, prefix+": target_identifier is required")
+	}
+	key := targetKey(targetType, targetIdentifier)
+	if seen[key] {
+		problems = append(problems, fmt.Sprintf("%s: duplicate target '%s'", prefix, key))
+	}
+	seen[key] = true
+	return problems
+}
+
+// targetKey builds the plan key for user/department scoped settings
+func targetKey(targetType, targetIdentifier string) string {
+	return targetType + ":" + targetIdentifier
+}
+
+// DeclarativeConfigService diffs and applies desired-state documents
+type DeclarativeConfigService struct {
+	db                          *database.DB
+	strategyService             *StrategyService
+	permissionService           *PermissionService
+	starCheckPermissionService  *StarCheckPermissionService
+	quotaCheckPermissionService *QuotaCheckPermissionService
+}
+
+// NewDeclarativeConfigService creates a new declarative config service
+func NewDeclarativeConfigService(db *database.DB, strategyService *StrategyService, permissionService *PermissionService, starCheckPermissionService *StarCheckPermissionService, quotaCheckPermissionService *QuotaCheckPermissionService) *DeclarativeConfigService {
+	return &DeclarativeConfigService{
+		db:                          db,
+		strategyService:             strategyService,
+		permissionService:           permissionService,
+		starCheckPermissionService:  starCheckPermissionService,
+		quotaCheckPermissionService: quotaCheckPermissionService,
+	}
+}
+
+// Export returns the current database contents as a desired-state document
+func (s *DeclarativeConfigService) Export() (*DesiredState, error) {
+	state := &DesiredState{
+		Strategies:         []DesiredStrategy{},
+		ModelWhitelists:    []DesiredModelWhitelist{},
+		StarCheckSettings:  []DesiredToggleSetting{},
+		QuotaCheckSettings: []DesiredToggleSetting{},
+	}
+
+	var strategies []models.QuotaStrategy
+	if err := s.db.DB.Order("name ASC").Find(&strategies).Error; err != nil {
+		return nil, NewDatabaseError("load strategies", err)
+	}
+	for i := range strategies {
+		state.Strategies = append(state.Strategies, desiredStrategyFromModel(&strategies[i]))
+	}
+
+	var whitelists []models.ModelWhitelist
+	if err := s.db.DB.Order("target_type ASC, target_identifier ASC").Find(&whitelists).Error; err != nil {
+		return nil, NewDatabaseError("load model whitelists", err)
+	}
+	for _, whitelist := range whitelists {
+		state.ModelWhitelists = append(state.ModelWhitelists, DesiredModelWhitelist{
+			TargetType:       whitelist.TargetType,
+			TargetIdentifier: whitelist.TargetIdentifier,
+			Models:           whitelist.GetAllowedModelsAsSlice(),
+		})
+	}
+
+	var starSettings []models.StarCheckSetting
+	if err := s.db.DB.Order("target_type ASC, target_identifier ASC").Find(&starSettings).Error; err != nil {
+		return nil, NewDatabaseError("load star check settings", err)
+	}
+	for _, setting := range starSettings {
+		state.StarCheckSettings = append(state.StarCheckSettings, DesiredToggleSetting{
+			TargetType:       setting.TargetType,
+			TargetIdentifier: setting.TargetIdentifier,
+			Enabled:          setting.Enabled,
+		})
+	}
+
+	var quotaSettings []models.QuotaCheckSetting
+	if err := s.db.DB.Order("target_type ASC, target_identifier ASC").Find(&quotaSettings).Error; err != nil {
+		return nil, NewDatabaseError("load quota check settings", err)
+	}
+	for _, setting := range quotaSettings {
+		state.QuotaCheckSettings = append(state.QuotaCheckSettings, DesiredToggleSetting{
+			TargetType:       setting.TargetType,
+			TargetIdentifier: setting.TargetIdentifier,
+			Enabled:          setting.Enabled,
+		})
+	}
+
+	return state, nil
+}
+
+// Plan computes the changes needed to bring the database to the desired state
+func (s *DeclarativeConfigService) Plan(state *DesiredState) (*ConfigPlan, error) {
+	return s.plan(s.db.DB, state)
+}
+
+// plan computes the diff using the given connection so Apply can plan inside its transaction
+func (s *DeclarativeConfigService) plan(db *gorm.DB, state *DesiredState) (*ConfigPlan, error) {
+	plan := &ConfigPlan{Changes: []PlanChange{}}
+
+	if state.Strategies != nil {
+		if err := s.planStrategies(db, state.Strategies, plan); err != nil {
+			return nil, err
+		}
+	}
+	if state.ModelWhitelists != nil {
+		if err := s.planModelWhitelists(db, state.ModelWhitelists, plan); err != nil {
+			return nil, err
+		}
+	}
+	if state.StarCheckSettings != nil {
+		var current []models.StarCheckSetting
+		if err := db.Find(&current).Error; err != nil {
+			return nil, NewDatabaseError("load star check settings", err)
+		}
+		existing := make(map[string]bool, len(current))
+		for _, setting := range current {
+			existing[targetKey(setting.TargetType, setting.TargetIdentifier)] = setting.Enabled
+		}
+		planToggleSettings(ConfigKindStarCheckSetting, existing, state.StarCheckSettings, plan)
+	}
+	if state.QuotaCheckSettings != nil {
+		var current []models.QuotaCheckSetting
+		if err := db.Find(&current).Error; err != nil {
+			return nil, NewDatabaseError("load quota check settings", err)
+		}
+		existing := make(map[string]bool, len(current))
+		for _, setting := range current {
+			existing[targetKey(setting.TargetType, setting.TargetIdentifier)] = setting.Enabled
+		}
+		planToggleSettings(ConfigKindQuotaCheckSetting, existing, state.QuotaCheckSettings, plan)
+	}
+
+	return plan, nil
+}
+
+// planStrategies diffs desired strategies against quota_strategy by name
+func (s *DeclarativeConfigService) planStrategies(db *gorm.DB, desired []DesiredStrategy, plan *ConfigPlan) error {
+	var current []models.QuotaStrategy
+	if err := db.Find(&current).Error; err != nil {
+		return NewDatabaseError("load strategies", err)
+	}
+	existing := make(map[string]DesiredStrategy, len(current))
+	for i := range current {
+		existing[current[i].Name] = desiredStrategyFromModel(&current[i])
+	}
+
+	wanted := make(map[string]bool, len(desired))
+	for _, strategy := range desired {
+		strategy = normalizeDesiredStrategy(strategy)
+		wanted[strategy.Name] = true
+
+		before, found := existing[strategy.Name]
+		if !found {
+			plan.add(PlanChange{Kind: ConfigKindStrategy, Action: PlanActionCreate, Key: strategy.Name, After: strategy})
+			continue
+		}
+		if fields := diffDesiredStrategy(before, strategy); len(fields) > 0 {
+			plan.add(PlanChange{Kind: ConfigKindStrategy, Action: PlanActionUpdate, Key: strategy.Name, Fields: fields, Before: before, After: strategy})
+		}
+	}
+
+	for _, name := range sortedKeys(existing) {
+		if !wanted[name] {
+			plan.add(PlanChange{Kind: ConfigKindStrategy, Action: PlanActionDelete, Key: name, Before: existing[name]})
+		}
+	}
+	return nil
+}
+
+// planModelWhitelists diffs desired model whitelists against model_whitelist by target
+func (s *DeclarativeConfigService) planModelWhitelists(db *gorm.DB, desired []DesiredModelWhitelist, plan *ConfigPlan) error {
+	var current []models.ModelWhitelist
+	if err := db.Find(&current).Error; err != nil {
+		return NewDatabaseError("load model whitelists", err)
+	}
+	existing := make(map[string]DesiredModelWhitelist, len(current))
+	for _, whitelist := range current {
+		existing[targetKey(whitelist.TargetType, whitelist.TargetIdentifier)] = DesiredModelWhitelist{
+			TargetType:       whitelist.TargetType,
+			TargetIdentifier: whitelist.TargetIdentifier,
+			Models:           whitelist.GetAllowedModelsAsSlice(),
+		}
+	}
+
+	wanted := make(map[string]bool, len(desired))
+	for _, whitelist := range desired {
+		if whitelist.Models == nil {
+			whitelist.Models = []string{}
+		}
+		key := targetKey(whitelist.TargetType, whitelist.TargetIdentifier)
+		wanted[key] = true
+
+		before, found := existing[key]
+		if !found {
+			plan.add(PlanChange{Kind: ConfigKindModelWhitelist, Action: PlanActionCreate, Key: key, After: whitelist})
+			continue
+		}
+		if strings.Join(before.Models, ",") != strings.Join(whitelist.Models, ",") {
+			plan.add(PlanChange{Kind: ConfigKindModelWhitelist, Action: PlanActionUpdate, Key: key, Fields: []string{"models"}, Before: before, After: whitelist})
+		}
+	}
+
+	for _, key := range sortedKeys(existing) {
+		if !wanted[key] {
+			plan.add(PlanChange{Kind: ConfigKindModelWhitelist, Action: PlanActionDelete, Key: key, Before: existing[key]})
+		}
+	}
+	return nil
+}
+
+// planToggleSettings diffs desired enabled/disabled settings against the current ones by target
+func planToggleSettings(kind string, existing map[string]bool, desired []DesiredToggleSetting, plan *ConfigPlan) {
+	wanted := make(map[string]bool, len(desired))
+	for _, setting := range desired {
+		key := targetKey(setting.TargetType, setting.TargetIdentifier)
+		wanted[key] = true
+
+		enabled, found := existing[key]
+		if !found {
+			plan.add(PlanChange{Kind: kind, Action: PlanActionCreate, Key: key, After: setting})
+			continue
+		}
+		if enabled != setting.Enabled {
+			before := setting
+			before.Enabled = enabled
+			plan.add(PlanChange{Kind: kind, Action: PlanActionUpdate, Key: key, Fields: []string{"enabled"}, Before: before, After: setting})
+		}
+	}
+
+	for _, key := range sortedKeys(existing) {
+		if !wanted[key] {
+			targetType, targetIdentifier, _ := strings.Cut(key, ":")
+			plan.add(PlanChange{Kind: kind, Action: PlanActionDelete, Key: key, Before: DesiredToggleSetting{
+				TargetType:       targetType,
+				TargetIdentifier: targetIdentifier,
+				Enabled:          existing[key],
+			}})
+		}
+	}
+}
+
+// Apply brings the database to the desired state in a single transaction and returns the executed plan.
+// Cron registration and effective permission recalculation run after the commit, through the
+// existing strategy and permission services.
+func (s *DeclarativeConfigService) Apply(state *DesiredState) (*ConfigPlan, error) {
+	var plan *ConfigPlan
+	var deletedStrategyIDs []int
+
+	err := s.db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		plan, err = s.plan(tx, state)
+		if err != nil {
+			return err
+		}
+
+		desiredStrategies := make(map[string]DesiredStrategy, len(state.Strategies))
+		for _, strategy := range state.Strategies {
+			desiredStrategies[strategy.Name] = normalizeDesiredStrategy(strategy)
+		}
+
+		for _, change := range plan.Changes {
+			switch change.Kind {
+			case ConfigKindStrategy:
+				err = s.applyStrategyChange(tx, change, desiredStrategies[change.Key], &deletedStrategyIDs)
+			case ConfigKindModelWhitelist:
+				err = s.applyModelWhitelistChange(tx, change)
+			case ConfigKindStarCheckSetting:
+				err = applyToggleSettingChange(tx, change)
+			case ConfigKindQuotaCheckSetting:
+				err = applyToggleSettingChange(tx, change)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to %s %s '%s': %w", change.Action, change.Kind, change.Key, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range deletedStrategyIDs {
+		s.strategyService.unregisterPeriodicStrategy(id)
+	}
+	if plan.HasChanges() {
+		s.afterApply(plan)
+	}
+	return plan, nil
+}
+
+// applyStrategyChange writes a single strategy change inside the apply transaction,
+// through the strategy service so that its validation and approval gating apply
+func (s *DeclarativeConfigService) applyStrategyChange(tx *gorm.DB, change PlanChange, desired DesiredStrategy, deletedIDs *[]int) error {
+	switch change.Action {
+	case PlanActionCreate:
+		strategy := &models.QuotaStrategy{
+			Name:           desired.Name,
+			Title:          desired.Title,
+			Type:           desired.Type,
+			Amount:         desired.Amount,
+			AmountExpr:     desired.AmountExpr,
+			Model:          desired.Model,
+			PeriodicExpr:   desired.PeriodicExpr,
+			Timezone:       desired.Timezone,
+			Condition:      desired.Condition,
+			MaxExecPerUser: desired.MaxExecPerUser,
+			Status:         *desired.Status,
+			Shadow:         desired.Shadow,
+
+			TopupThreshold:    desired.TopupThreshold,
+			TopupPeriod:       desired.TopupPeriod,
+			TopupMaxPerPeriod: desired.TopupMaxPerPeriod,
+			DripInstallments:  desired.DripInstallments,
+			DripInterval:      desired.DripInterval,
+			ExclusionGroup:    desired.ExclusionGroup,
+			Priority:          desired.Priority,
+			RolloverPercent:   desired.RolloverPercent,
+			RolloverMaxAmount: desired.RolloverMaxAmount,
+			RolloverMonths:    desired.RolloverMonths,
+			GracePeriod:       desired.GracePeriod,
+			FundPool:          desired.FundPool,
+		}
+		if err := s.strategyService.createStrategy(tx, strategy); err != nil {
+			return err
+		}
+		if strategy.ApprovalStatus == models.ApprovalStatusDraft {
+			logger.Logger.Warn("Strategy requires approval, created it as a disabled draft",
+				zap.String("strategy", strategy.Name))
+			return nil
+		}
+		// A false status is a zero value and gets replaced by the column default on create
+		if !*desired.Status {
+			return tx.Model(strategy).Update("status", false).Error
+		}
+		return nil
+	case PlanActionUpdate:
+		var current models.QuotaStrategy
+		if err := tx.Where("name = ?", desired.Name).First(&current).Error; err != nil {
+			return err
+		}
+		updates := map[string]interface{}{
+			"title":             desired.Title,
+			"type":              desired.Type,
+			"amount":            desired.Amount,
+			"amount_expr":       desired.AmountExpr,
+			"model":             desired.Model,
+			"periodic_expr":     desired.PeriodicExpr,
+			"timezone":          desired.Timezone,
+			"condition":         desired.Condition,
+			"max_exec_per_user": desired.MaxExecPerUser,
+			"status":            *desired.Status,
+			"shadow":            desired.Shadow,
+
+			"topup_threshold":      desired.TopupThreshold,
+			"topup_period":         desired.TopupPeriod,
+			"topup_max_per_period": desired.TopupMaxPerPeriod,
+			"drip_installments":    desired.DripInstallments,
+			"drip_interval":        desired.DripInterval,
+			"exclusion_group":      desired.ExclusionGroup,
+			"priority":             desired.Priority,
+			"rollover_percent":     desired.RolloverPercent,
+			"rollover_max_amount":  desired.RolloverMaxAmount,
+			"rollover_months":      desired.RolloverMonths,
+			"grace_period":         desired.GracePeriod,
+			"fund_pool":            desired.FundPool,
+		}
+		// Gated strategies stay disabled until approved; a later apply enables them
+		_, blocked, err := s.strategyService.updateStrategy(tx, current.ID, updates)
+		if err != nil {
+			return err
+		}
+		if blocked && *desired.Status {
+			logger.Logger.Warn("Strategy requires approval, keeping it disabled",
+				zap.String("strategy", current.Name))
+		}
+		return nil
+	case PlanActionDelete:
+		var strategy models.QuotaStrategy
+		if err := tx.Where("name = ?", change.Key).First(&strategy).Error; err != nil {
+			return err
+		}
+		*deletedIDs = append(*deletedIDs, strategy.ID)
+		return s.strategyService.deleteStrategy(tx, strategy.ID)
+	}
+	return nil
+}
+
+// applyModelWhitelistChange writes a single model whitelist change inside the apply transaction
+func (s *DeclarativeConfigService) applyModelWhitelistChange(tx *gorm.DB, change PlanChange) error {
+	switch change.Action {
+	case PlanActionCreate:
+		desired := change.After.(DesiredModelWhitelist)
+		whitelist := &models.ModelWhitelist{
+			TargetType:       desired.TargetType,
+			TargetIdentifier: desired.TargetIdentifier,
+		}
+		whitelist.SetAllowedModelsFromSlice(desired.Models)
+		return tx.Create(whitelist).Error
+	case PlanActionUpdate:
+		desired := change.After.(DesiredModelWhitelist)
+		whitelist := &models.ModelWhitelist{}
+		whitelist.SetAllowedModelsFromSlice(desired.Models)
+		return tx.Model(&models.ModelWhitelist{}).
+			Where("target_type = ? AND target_identifier = ?", desired.TargetType, desired.TargetIdentifier).
+			Update("allowed_models", whitelist.AllowedModels).Error
+	case PlanActionDelete:
+		current := change.Before.(DesiredModelWhitelist)
+		return tx.Where("target_type = ? AND target_identifier = ?", current.TargetType, current.TargetIdentifier).
+			Delete(&models.ModelWhitelist{}).Error
+	}
+	return nil
+}
+
+// applyToggleSettingChange writes a single star check or quota check setting change inside the apply transaction
+func applyToggleSettingChange(tx *gorm.DB, change PlanChange) error {
+	var model interface{} = &models.StarCheckSetting{}
+	if change.Kind == ConfigKindQuotaCheckSetting {
+		model = &models.QuotaCheckSetting{}
+	}
+
+	switch change.Action {
+	case PlanActionCreate:
+		desired := change.After.(DesiredToggleSetting)
+		if change.Kind == ConfigKindQuotaCheckSetting {
+			model = &models.QuotaCheckSetting{TargetType: desired.TargetType, TargetIdentifier: desired.TargetIdentifier, Enabled: desired.Enabled}
+		} else {
+			model = &models.StarCheckSetting{TargetType: desired.TargetType, TargetIdentifier: desired.TargetIdentifier, Enabled: desired.Enabled}
+		}
+		// Select the columns explicitly so that enabled=false is written
+		return tx.Select("target_type", "target_identifier", "enabled", "create_time", "update_time").Create(model).Error
+	case PlanActionUpdate:
+		desired := change.After.(DesiredToggleSetting)
+		return tx.Model(model).
+			Where("target_type = ? AND target_identifier = ?", desired.TargetType, desired.TargetIdentifier).
+			Update("enabled", desired.Enabled).Error
+	case PlanActionDelete:
+		current := change.Before.(DesiredToggleSetting)
+		return tx.Where("target_type = ? AND target_identifier = ?", current.TargetType, current.TargetIdentifier).
+			Delete(model).Error
+	}
+	return nil
+}
+
+// afterApply propagates committed changes to cron and the effective permission tables
+func (s *DeclarativeConfigService) afterApply(plan *ConfigPlan) {
+	for _, change := range plan.Changes {
+		switch change.Kind {
+		case ConfigKindStrategy:
+			s.syncStrategyCron(change)
+		case ConfigKindModelWhitelist:
+			targetType, targetIdentifier := changeTarget(change)
+			var err error
+			if targetType == models.TargetTypeUser {
+				err = s.permissionService.UpdateEmployeePermissions(targetIdentifier)
+			} else {
+				err = s.permissionService.UpdateDepartmentPermissions(targetIdentifier)
+			}
+			if err != nil {
+				logger.Logger.Error("Failed to update effective permissions after apply",
+					zap.String("target", change.Key), zap.Error(err))
+			}
+		case ConfigKindStarCheckSetting:
+			targetType, targetIdentifier := changeTarget(change)
+			var err error
+			if targetType == models.TargetTypeUser {
+				err = s.starCheckPermissionService.UpdateEmployeeStarCheckPermissions(targetIdentifier)
+			} else {
+				err = s.starCheckPermissionService.UpdateDepartmentStarCheckPermissions(targetIdentifier)
+			}
+			if err != nil {
+				logger.Logger.Error("Failed to update effective star check settings after apply",
+					zap.String("target", change.Key), zap.Error(err))
+			}
+		case ConfigKindQuotaCheckSetting:
+			targetType, targetIdentifier := changeTarget(change)
+			var err error
+			if targetType == models.TargetTypeUser {
+				err = s.quotaCheckPermissionService.UpdateEmployeeQuotaCheckPermissions(targetIdentifier)
+			} else {
+				err = s.quotaCheckPermissionService.UpdateDepartmentQuotaCheckPermissions(targetIdentifier)
+			}
+			if err != nil {
+				logger.Logger.Error("Failed to update effective quota check settings after apply",
+					zap.String("target", change.Key), zap.Error(err))
+			}
+		}
+	}
+
+	// Record audit
+	keys := make([]string, 0, len(plan.Changes))
+	for _, change := range plan.Changes {
+		keys = append(keys, fmt.Sprintf("%s %s %s", change.Action, change.Kind, change.Key))
+	}
+	detailsJSON, _ := json.Marshal(map[string]interface{}{
+		"summary": plan.Summary,
+		"changes": keys,
+	})
+	audit := &models.PermissionAudit{
+		Operation: models.OperationDeclarativeApply,
+		Details:   string(detailsJSON),
+	}
+	if err := s.db.DB.Create(audit).Error; err != nil {
+		logger.Logger.Error("Failed to record audit", zap.Error(err))
+	}
+}
+
+// syncStrategyCron registers or unregisters a strategy's cron job after apply
+func (s *DeclarativeConfigService) syncStrategyCron(change PlanChange) {
+	if change.Action == PlanActionDelete {
+		// Deleted strategies are unregistered by ID in Apply
+		return
+	}
+
+	var strategy models.QuotaStrategy
+	if err := s.db.DB.Where("name = ?", change.Key).First(&strategy).Error; err != nil {
+		logger.Logger.Error("Failed to reload strategy after apply",
+			zap.String("strategy", change.Key), zap.Error(err))
+		return
+	}
+	if strategy.Type == "periodic" && strategy.IsEnabled() {
+		if err := s.strategyService.registerPeriodicStrategy(&strategy); err != nil {
+			logger.Logger.Error("Failed to register periodic strategy after apply",
+				zap.String("strategy", strategy.Name), zap.Error(err))
+		}
+	} else {
+		s.strategyService.unregisterPeriodicStrategy(strategy.ID)
+	}
+}
+
+// changeTarget returns the target referenced by a settings change
+func changeTarget(change PlanChange) (string, string) {
+	targetType, targetIdentifier, _ := strings.Cut(change.Key, ":")
+	return targetType, targetIdentifier
+}
+
+// desiredStrategyFromModel converts a stored strategy to its declarative form
+func desiredStrategyFromModel(strategy *models.QuotaStrategy) DesiredStrategy {
+	status := strategy.Status
+	return DesiredStrategy{
+		Name:           strategy.Name,
+		Title:          strategy.Title,
+		Type:           strategy.Type,
+		Amount:         strategy.Amount,
+		AmountExpr:     strategy.AmountExpr,
+		Model:          strategy.Model,
+		PeriodicExpr:   strategy.PeriodicExpr,
+		Timezone:       strategy.Timezone,
+		Condition:      strategy.Condition,
+		MaxExecPerUser: strategy.MaxExecPerUser,
+		Status:         &status,
+		Shadow:         strategy.Shadow,
+
+		TopupThreshold:    strategy.TopupThreshold,
+		TopupPeriod:       strategy.TopupPeriod,
+		TopupMaxPerPeriod: strategy.TopupMaxPerPeriod,
+		DripInstallments:  strategy.DripInstallments,
+		DripInterval:      strategy.DripInterval,
+		ExclusionGroup:    strategy.ExclusionGroup,
+		Priority:          strategy.Priority,
+		RolloverPercent:   strategy.RolloverPercent,
+		RolloverMaxAmount: strategy.RolloverMaxAmount,
+		RolloverMonths:    strategy.RolloverMonths,
+		GracePeriod:       strategy.GracePeriod,
+
+		FundPool: strategy.FundPool,
+	}
+}
+
+// normalizeDesiredStrategy fills defaults so desired and stored strategies compare equal
+func normalizeDesiredStrategy(strategy DesiredStrategy) DesiredStrategy {
+	if strategy.Status == nil {
+		enabled := true
+		strategy.Status = &enabled
+	}
+	return strategy
+}
+
+// diffDesiredStrategy returns the names of fields that differ between two strategies
+func diffDesiredStrategy(before, after DesiredStrategy) []string {
+	var fields []string
+	if before.Title != after.Title {
+		fields = append(fields, "title")
+	}
+	if before.Type != after.Type {
+		fields = append(fields, "type")
+	}
+	if !before.Amount.Equal(after.Amount) {
+		fields = append(fields, "amount")
+	}
+	if before.AmountExpr != after.AmountExpr {
+		fields = append(fields, "amount_expr")
+	}
+	if before.Model != after.Model {
+		fields = append(fields, "model")
+	}
+	if before.PeriodicExpr != after.PeriodicExpr {
+		fields = append(fields, "periodic_expr")
+	}
+	if before.Timezone != after.Timezone {
+		fields = append(fields, "timezone")
+	}
+	if before.Condition != after.Condition {
+		fields = append(fields, "condition")
+	}
+	if before.MaxExecPerUser != after.MaxExecPerUser {
+		fields = append(fields, "max_exec_per_user")
+	}
+	if *before.Status != *after.Status {
+		fields = append(fields, "status")
+	}
+	if before.Shadow != after.Shadow {
+		fields = append(fields, "shadow")
+	}
+	if !before.TopupThreshold.Equal(after.TopupThreshold) {
+		fields = append(fields, "topup_threshold")
+	}
+	if before.TopupPeriod != after.TopupPeriod {
+		fields = append(fields, "topup_period")
+	}
+	if before.TopupMaxPerPeriod != after.TopupMaxPerPeriod {
+		fields = append(fields, "topup_max_per_period")
+	}
+	if before.DripInstallments != after.DripInstallments {
+		fields = append(fields, "drip_installments")
+	}
+	if before.DripInterval != after.DripInterval {
+		fields = append(fields, "drip_interval")
+	}
+	if before.ExclusionGroup != after.ExclusionGroup {
+		fields = append(fields, "exclusion_group")
+	}
+	if before.Priority != after.Priority {
+		fields = append(fields, "priority")
+	}
+	if before.RolloverPercent != after.RolloverPercent {
+		fields = append(fields, "rollover_percent")
+	}
+	if !before.RolloverMaxAmount.Equal(after.RolloverMaxAmount) {
+		fields = append(fields, "rollover_max_amount")
+	}
+	if before.RolloverMonths != after.RolloverMonths {
+		fields = append(fields, "rollover_months")
+	}
+	if before.GracePeriod != after.GracePeriod {
+		fields = append(fields, "grace_period")
+	}
+	if before.FundPool != after.FundPool {
+		fields = append(fields, "fund_pool")
+	}
+	return fields
+}
+
+// sortedKeys returns map keys in stable order so plans are deterministic
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
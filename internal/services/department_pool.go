@@ -0,0 +1,558 @@
+package services
+
+import (
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/internal/utils"
+	"quota-manager/pkg/decimal"
+	"quota-manager/pkg/logger"
+	"sync"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultPoolDrawSweepInterval is the schedule of the department pool draw sweep when none is configured
+const DefaultPoolDrawSweepInterval = "0 */15 * * * *"
+
+// maxConcurrentPoolDraws bounds the department pool draws started by quota reads
+const maxConcurrentPoolDraws = 4
+
+// MemberPoolInfo is a department pool a user can draw from, as seen by that user
+type MemberPoolInfo struct {
+	models.DepartmentPool
+	MonthDrawn decimal.Decimal  `json:"month_drawn"`        // drawn by the user this calendar month
+	CapLeft    *decimal.Decimal `json:"cap_left,omitempty"` // what the member cap still allows this month, omitted when uncapped
+}
+
+// PoolDrawResult is the outcome of a member's draw from a department pool
+type PoolDrawResult struct {
+	PoolID      int             `json:"pool_id"`
+	Department  string          `json:"department"`
+	Amount      decimal.Decimal `json:"amount"`
+	BalanceLeft decimal.Decimal `json:"balance_left"`
+}
+
+// DepartmentPoolUsage summarizes a pool's funding and draws over a calendar month
+type DepartmentPoolUsage struct {
+	Pool      models.DepartmentPool `json:"pool"`
+	YearMonth string                `json:"year_month"`
+	Funded    decimal.Decimal       `json:"funded"`
+	Drawn     decimal.Decimal       `json:"drawn"`
+	Members   []PoolMemberUsage     `json:"members"`
+}
+
+// PoolMemberUsage is what one member drew from a pool over a calendar month
+type PoolMemberUsage struct {
+	UserID string          `json:"user_id"`
+	Drawn  decimal.Decimal `json:"drawn"`
+	Draws  int             `json:"draws"`
+}
+
+// ValidateFundPool checks the pool funding settings of a strategy. Funded quota becomes
+// "any model" pool quota when members draw it, so model pools and expiry policies do not apply.
+func ValidateFundPool(strategy *models.QuotaStrategy) error {
+	if strategy.FundPool == "" {
+		return nil
+	}
+	if strategy.Type == "topup" {
+		return fmt.Errorf("topup strategies cannot fund a department pool")
+	}
+	if strategy.DripInstallments > 1 {
+		return fmt.Errorf("drip strategies cannot fund a department pool")
+	}
+	if strategy.Model != "" {
+		return fmt.Errorf("strategies funding a department pool cannot target a model")
+	}
+	if HasExpiryPolicy(strategy) {
+		return fmt.Errorf("strategies funding a department pool cannot have an expiry policy")
+	}
+	return nil
+}
+
+// SaveDepartmentPool creates a department's pool or updates its member cap and draw amount;
+// the balance only changes through funding and draws
+func (s *QuotaService) SaveDepartmentPool(department string, memberCap, drawAmount decimal.Decimal, operator string) (*models.DepartmentPool, error) {
+	if department == "" {
+		return nil, NewValidationFailedError("department is required")
+	}
+	if memberCap.IsNegative() || drawAmount.IsNegative() {
+		return nil, NewValidationFailedError("member_cap and draw_amount must be >= 0")
+	}
+
+	pool := &models.DepartmentPool{
+		Department: department,
+		MemberCap:  memberCap,
+		DrawAmount: drawAmount,
+		Operator:   operator,
+	}
+	if err := s.db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "department"}},
+		DoUpdates: clause.AssignmentColumns([]string{"member_cap", "draw_amount", "operator", "update_time"}),
+	}).Create(pool).Error; err != nil {
+		return nil, NewDatabaseError("save department pool", err)
+	}
+
+	var saved models.DepartmentPool
+	if err := s.db.DB.Where("department = ?", department).First(&saved).Error; err != nil {
+		return nil, NewDatabaseError("query department pool", err)
+	}
+	return &saved, nil
+}
+
+// GetDepartmentPools lists department pools
+func (s *QuotaService) GetDepartmentPools(page, pageSize int) ([]models.DepartmentPool, int64, error) {
+	var total int64
+	if err := s.db.DB.Model(&models.DepartmentPool{}).Count(&total).Error; err != nil {
+		return nil, 0, NewDatabaseError("count department pools", err)
+	}
+
+	var pools []models.DepartmentPool
+	if err := s.db.DB.Order("id ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&pools).Error; err != nil {
+		return nil, 0, NewDatabaseError("query department pools", err)
+	}
+	return pools, total, nil
+}
+
+// GetDepartmentPool returns a department pool and its balance
+func (s *QuotaService) GetDepartmentPool(id int) (*models.DepartmentPool, error) {
+	var pool models.DepartmentPool
+	result := s.db.DB.Where("id = ?", id).Limit(1).Find(&pool)
+	if result.Error != nil {
+		return nil, NewDatabaseError("query department pool", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, NewResourceNotFoundError("department pool", fmt.Sprintf("%d", id))
+	}
+	return &pool, nil
+}
+
+// FundDepartmentPool adds quota to a department pool on behalf of an admin
+func (s *QuotaService) FundDepartmentPool(id int, amount decimal.Decimal, operator string) (*models.DepartmentPool, error) {
+	return s.fundDepartmentPool("id = ?", id, amount, models.DepartmentPoolLedger{Operator: operator})
+}
+
+// FundDepartmentPoolForStrategy adds a strategy's per-user amount to the pool of a department
+func (s *QuotaService) FundDepartmentPoolForStrategy(department string, amount decimal.Decimal, strategyID int, strategyName string) error {
+	_, err := s.fundDepartmentPool("department = ?", department, amount, models.DepartmentPoolLedger{
+		StrategyID:   &strategyID,
+		StrategyName: strategyName,
+	})
+	return err
+}
+
+// fundDepartmentPool credits the pool matching the condition and records a POOL_FUND ledger entry
+func (s *QuotaService) fundDepartmentPool(condition string, arg interface{}, amount decimal.Decimal, entry models.DepartmentPoolLedger) (*models.DepartmentPool, error) {
+	if !amount.IsPositive() {
+		return nil, NewValidationFailedError("amount must be greater than 0")
+	}
+
+	var pool models.DepartmentPool
+	err := s.db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(condition, arg).Limit(1).Find(&pool)
+		if result.Error != nil {
+			return NewDatabaseError("lock department pool", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return NewResourceNotFoundError("department pool", fmt.Sprintf("%v", arg))
+		}
+
+		pool.Balance = pool.Balance.Add(amount)
+		if err := tx.Model(&models.DepartmentPool{}).Where("id = ?", pool.ID).
+			Update("balance", pool.Balance).Error; err != nil {
+			return NewDatabaseError("fund department pool", err)
+		}
+
+		entry.PoolID = pool.ID
+		entry.Operation = models.OperationPoolFund
+		entry.Amount = amount
+		entry.BalanceAfter = pool.Balance
+		entry.YearMonth = utils.NowInConfigTimezone(s.configManager.GetDirect()).Format("2006-01")
+		if err := tx.Create(&entry).Error; err != nil {
+			return NewDatabaseError("record pool funding", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Department pool funded",
+		zap.String("department", pool.Department),
+		zap.Stringer("amount", amount),
+		zap.Stringer("balance", pool.Balance),
+		zap.String("strategy", entry.StrategyName),
+		zap.String("operator", entry.Operator))
+	return &pool, nil
+}
+
+// GetDepartmentPoolLedger lists a pool's funding and draws, newest first, optionally of one operation or member
+func (s *QuotaService) GetDepartmentPoolLedger(id int, operation, userID string, page, pageSize int) ([]models.DepartmentPoolLedger, int64, error) {
+	if _, err := s.GetDepartmentPool(id); err != nil {
+		return nil, 0, err
+	}
+
+	query := s.db.DB.Model(&models.DepartmentPoolLedger{}).Where("pool_id = ?", id)
+	if operation != "" {
+		query = query.Where("operation = ?", operation)
+	}
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, NewDatabaseError("count pool ledger", err)
+	}
+
+	var entries []models.DepartmentPoolLedger
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&entries).Error; err != nil {
+		return nil, 0, NewDatabaseError("query pool ledger", err)
+	}
+	return entries, total, nil
+}
+
+// GetDepartmentPoolUsage summarizes a pool's funding and per-member draws in a calendar
+// month, the current one when yearMonth is empty
+func (s *QuotaService) GetDepartmentPoolUsage(id int, yearMonth string) (*DepartmentPoolUsage, error) {
+	pool, err := s.GetDepartmentPool(id)
+	if err != nil {
+		return nil, err
+	}
+	if yearMonth == "" {
+		yearMonth = utils.NowInConfigTimezone(s.configManager.GetDirect()).Format("2006-01")
+	}
+
+	usage := &DepartmentPoolUsage{Pool: *pool, YearMonth: yearMonth, Members: make([]PoolMemberUsage, 0)}
+	if err := s.db.DB.Model(&models.DepartmentPoolLedger{}).
+		Where("pool_id = ? AND year_month = ? AND operation = ?", id, yearMonth, models.OperationPoolFund).
+		Select("COALESCE(SUM(amount), 0)").Scan(&usage.Funded).Error; err != nil {
+		return nil, NewDatabaseError("sum pool funding", err)
+	}
+
+	var rows []struct {
+		UserID string
+		Drawn  decimal.Decimal
+		Draws  int
+	}
+	if err := s.db.DB.Model(&models.DepartmentPoolLedger{}).
+		Select("user_id, -SUM(amount) AS drawn, COUNT(*) AS draws").
+		Where("pool_id = ? AND year_month = ? AND operation = ?", id, yearMonth, models.OperationPoolDraw).
+		Group("user_id").Order("drawn DESC").Scan(&rows).Error; err != nil {
+		return nil, NewDatabaseError("sum pool draws", err)
+	}
+	for _, row := range rows {
+		usage.Drawn = usage.Drawn.Add(row.Drawn)
+		usage.Members = append(usage.Members, PoolMemberUsage{UserID: row.UserID, Drawn: row.Drawn, Draws: row.Draws})
+	}
+	return usage, nil
+}
+
+// GetMemberPools lists the pools a user can draw from, most specific department first
+func (s *QuotaService) GetMemberPools(userID string) ([]MemberPoolInfo, error) {
+	pools, err := s.memberPools(userID)
+	if err != nil {
+		return nil, NewDatabaseError("resolve department pools", err)
+	}
+
+	yearMonth := utils.NowInConfigTimezone(s.configManager.GetDirect()).Format("2006-01")
+	infos := make([]MemberPoolInfo, 0, len(pools))
+	for _, pool := range pools {
+		drawn, err := s.memberMonthDrawn(s.db.DB, pool.ID, userID, yearMonth)
+		if err != nil {
+			return nil, NewDatabaseError("sum member draws", err)
+		}
+		info := MemberPoolInfo{DepartmentPool: pool, MonthDrawn: drawn}
+		if pool.MemberCap.IsPositive() {
+			capLeft := decimal.Max(pool.MemberCap.Sub(drawn), decimal.Zero)
+			info.CapLeft = &capLeft
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// DrawFromDepartmentPool moves quota from the user's department pools into a personal bucket
+// once the user's personal balance is exhausted. A zero amount draws the pool's draw amount.
+// Pools are tried from the most specific department up; a draw is cut to the pool balance
+// and what the member cap still allows.
+func (s *QuotaService) DrawFromDepartmentPool(userID string, amount decimal.Decimal) (*PoolDrawResult, error) {
+	if amount.IsNegative() {
+		return nil, NewValidationFailedError("amount must be >= 0")
+	}
+
+	remaining, err := s.personalBalance(userID)
+	if err != nil {
+		return nil, err
+	}
+	if remaining.IsPositive() {
+		return nil, NewConflictError(fmt.Sprintf("personal balance is not exhausted, %s left", remaining.StringFixed()))
+	}
+
+	pools, err := s.memberPools(userID)
+	if err != nil {
+		return nil, NewDatabaseError("resolve department pools", err)
+	}
+	yearMonth := utils.NowInConfigTimezone(s.configManager.GetDirect()).Format("2006-01")
+	for i := range pools {
+		pool := &pools[i]
+		want := amount
+		if want.IsZero() {
+			want = pool.DrawAmount
+		}
+		drawable, err := s.drawableAmount(pool, userID, yearMonth, want)
+		if err != nil {
+			return nil, NewDatabaseError("sum member draws", err)
+		}
+		if !drawable.IsPositive() {
+			continue
+		}
+
+		if err := s.addStrategyGrant(userID, drawable, 0, "", strategyGrant{
+			operation: models.OperationPoolDraw,
+			pool:      pool,
+		}); err != nil {
+			return nil, err
+		}
+		logger.Info("Quota drawn from department pool",
+			zap.String("user_id", userID),
+			zap.String("department", pool.Department),
+			zap.Stringer("amount", drawable))
+
+		result := &PoolDrawResult{PoolID: pool.ID, Department: pool.Department, Amount: drawable}
+		if updated, err := s.GetDepartmentPool(pool.ID); err == nil {
+			result.BalanceLeft = updated.Balance
+		}
+		return result, nil
+	}
+	return nil, NewConflictError("no department pool with available balance and member cap")
+}
+
+// AutoDrawFromDepartmentPool draws a pool's draw amount for a user whose personal balance
+// is exhausted, doing nothing when no pool of the user's departments can be drawn from
+func (s *QuotaService) AutoDrawFromDepartmentPool(userID string) {
+	pools, err := s.memberPools(userID)
+	if err != nil {
+		logger.Error("Failed to resolve department pools", zap.String("user_id", userID), zap.Error(err))
+		return
+	}
+	drawable := false
+	for _, pool := range pools {
+		if pool.DrawAmount.IsPositive() && pool.Balance.IsPositive() {
+			drawable = true
+			break
+		}
+	}
+	if !drawable {
+		return
+	}
+
+	if _, err := s.DrawFromDepartmentPool(userID, decimal.Zero); err != nil {
+		if serviceErr, ok := err.(*ServiceError); ok && serviceErr.Code == ErrorConflict {
+			return
+		}
+		logger.Error("Failed to draw from department pool", zap.String("user_id", userID), zap.Error(err))
+	}
+}
+
+// requestPoolDraw starts a department pool draw for a user in the background, unless one is
+// already in flight for the user or all draw slots are busy; the scheduled sweep picks up
+// users skipped here
+func (s *QuotaService) requestPoolDraw(userID string) {
+	if _, inFlight := s.poolDrawPending.LoadOrStore(userID, struct{}{}); inFlight {
+		return
+	}
+	select {
+	case s.poolDrawSlots <- struct{}{}:
+	default:
+		s.poolDrawPending.Delete(userID)
+		return
+	}
+	go func() {
+		defer func() {
+			<-s.poolDrawSlots
+			s.poolDrawPending.Delete(userID)
+		}()
+		s.AutoDrawFromDepartmentPool(userID)
+	}()
+}
+
+// SweepDepartmentPools draws for the members of every pool with automatic draws and balance
+// left, so members who are not querying their quota are not stuck at zero
+func (s *QuotaService) SweepDepartmentPools() {
+	var pools []models.DepartmentPool
+	if err := s.db.DB.Where("draw_amount > 0 AND balance > 0").Find(&pools).Error; err != nil {
+		logger.Error("Failed to load department pools", zap.Error(err))
+		return
+	}
+	if len(pools) == 0 {
+		return
+	}
+
+	seen := make(map[string]bool)
+	var userIDs []string
+	for _, pool := range pools {
+		members, err := s.departmentUserIDs(pool.Department)
+		if err != nil {
+			logger.Error("Failed to list department pool members",
+				zap.String("department", pool.Department),
+				zap.Error(err))
+			continue
+		}
+		for _, userID := range members {
+			if !seen[userID] {
+				seen[userID] = true
+				userIDs = append(userIDs, userID)
+			}
+		}
+	}
+
+	logger.Info("Running department pool draw sweep",
+		zap.Int("pool_count", len(pools)),
+		zap.Int("member_count", len(userIDs)))
+
+	// Each member costs AiGateway queries, bound them like the topup sweep does
+	sem := make(chan struct{}, topupSweepConcurrency())
+	var wg sync.WaitGroup
+	for _, userID := range userIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(userID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.AutoDrawFromDepartmentPool(userID)
+		}(userID)
+	}
+	wg.Wait()
+}
+
+// memberPools lists the pools of a user's departments, most specific department first,
+// so members of a department without a pool inherit the pool of a parent department
+func (s *QuotaService) memberPools(userID string) ([]models.DepartmentPool, error) {
+	var poolCount int64
+	if err := s.db.DB.Model(&models.DepartmentPool{}).Count(&poolCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count department pools: %w", err)
+	}
+	if poolCount == 0 {
+		return nil, nil
+	}
+
+	departments, err := s.userDepartments(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(departments) == 0 {
+		return nil, nil
+	}
+
+	var found []models.DepartmentPool
+	if err := s.db.DB.Where("department IN ?", departments).Find(&found).Error; err != nil {
+		return nil, fmt.Errorf("failed to query department pools: %w", err)
+	}
+	byDepartment := make(map[string]models.DepartmentPool, len(found))
+	for _, pool := range found {
+		byDepartment[pool.Department] = pool
+	}
+	pools := make([]models.DepartmentPool, 0, len(found))
+	for _, department := range departments {
+		if pool, ok := byDepartment[department]; ok {
+			pools = append(pools, pool)
+		}
+	}
+	return pools, nil
+}
+
+// personalBalance charges new usage to the user's "any model" buckets and returns what is left of them
+func (s *QuotaService) personalBalance(userID string) (decimal.Decimal, error) {
+	info, err := s.poolQuota(userID, "")
+	if err != nil {
+		return decimal.Zero, err
+	}
+	remaining := decimal.Zero
+	for _, item := range info.QuotaList {
+		remaining = remaining.Add(item.Amount)
+	}
+	return remaining, nil
+}
+
+// drawableAmount cuts a wanted draw to the pool balance and what the member cap still allows this month
+func (s *QuotaService) drawableAmount(pool *models.DepartmentPool, userID, yearMonth string, want decimal.Decimal) (decimal.Decimal, error) {
+	amount := decimal.Min(want, pool.Balance)
+	if pool.MemberCap.IsPositive() {
+		drawn, err := s.memberMonthDrawn(s.db.DB, pool.ID, userID, yearMonth)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		amount = decimal.Min(amount, pool.MemberCap.Sub(drawn))
+	}
+	return amount, nil
+}
+
+// memberMonthDrawn sums a member's draws from a pool in a calendar month
+func (s *QuotaService) memberMonthDrawn(db *gorm.DB, poolID int, userID, yearMonth string) (decimal.Decimal, error) {
+	var drawn decimal.Decimal
+	if err := db.Model(&models.DepartmentPoolLedger{}).
+		Where("pool_id = ? AND user_id = ? AND year_month = ? AND operation = ?", poolID, userID, yearMonth, models.OperationPoolDraw).
+		Select("COALESCE(-SUM(amount), 0)").Scan(&drawn).Error; err != nil {
+		return decimal.Zero, fmt.Errorf("failed to sum member draws: %w", err)
+	}
+	return drawn, nil
+}
+
+// debitDepartmentPool takes a member's draw out of the pool and records a POOL_DRAW ledger
+// entry. Balance, member cap and the member's exhausted personal balance are checked again
+// under lock, since they may have moved since the draw was sized. The caller holds the
+// user's usage cursor lock.
+func (s *QuotaService) debitDepartmentPool(tx *gorm.DB, userID string, amount decimal.Decimal, pool *models.DepartmentPool) (*models.PoolDetail, error) {
+	var personal decimal.Decimal
+	if err := tx.Model(&models.Quota{}).
+		Where("user_id = ? AND model = '' AND status = ?", userID, models.StatusValid).
+		Select("COALESCE(SUM(amount - consumed), 0)").Scan(&personal).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum personal balance: %w", err)
+	}
+	if personal.IsPositive() {
+		return nil, NewConflictError(fmt.Sprintf("personal balance is not exhausted, %s left", personal.StringFixed()))
+	}
+
+	var locked models.DepartmentPool
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", pool.ID).First(&locked).Error; err != nil {
+		return nil, fmt.Errorf("failed to lock department pool: %w", err)
+	}
+	if locked.Balance.LessThan(amount) {
+		return nil, NewConflictError(fmt.Sprintf("department pool %s has only %s left", locked.Department, locked.Balance.StringFixed()))
+	}
+
+	yearMonth := utils.NowInConfigTimezone(s.configManager.GetDirect()).Format("2006-01")
+	drawn, err := s.memberMonthDrawn(tx, locked.ID, userID, yearMonth)
+	if err != nil {
+		return nil, err
+	}
+	if locked.MemberCap.IsPositive() && drawn.Add(amount).GreaterThan(locked.MemberCap) {
+		return nil, NewConflictError(fmt.Sprintf("member cap of department pool %s reached", locked.Department))
+	}
+
+	balance := locked.Balance.Sub(amount)
+	if err := tx.Model(&models.DepartmentPool{}).Where("id = ?", locked.ID).
+		Update("balance", balance).Error; err != nil {
+		return nil, fmt.Errorf("failed to debit department pool: %w", err)
+	}
+	if err := tx.Create(&models.DepartmentPoolLedger{
+		PoolID:       locked.ID,
+		Operation:    models.OperationPoolDraw,
+		Amount:       amount.Neg(),
+		BalanceAfter: balance,
+		UserID:       userID,
+		YearMonth:    yearMonth,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to record pool draw: %w", err)
+	}
+
+	return &models.PoolDetail{
+		PoolID:      locked.ID,
+		Department:  locked.Department,
+		BalanceLeft: balance,
+		MonthDrawn:  drawn.Add(amount),
+		MemberCap:   locked.MemberCap,
+	}, nil
+}
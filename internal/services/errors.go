@@ -20,6 +20,7 @@ const (
 	ErrorValidationFailed = "validation_failed"
 	ErrorResourceNotFound = "resource_not_found"
 	ErrorConflict         = "conflict"
+	ErrorApprovalRequired = "approval_required"
 )
 
 // NewUserNotFoundError creates a new user not found error
@@ -69,3 +70,11 @@ func NewConflictError(message string) *ServiceError {
 		Message: message,
 	}
 }
+
+// NewApprovalRequiredError creates an error for a strategy that cannot be enabled before approval
+func NewApprovalRequiredError(message string) *ServiceError {
+	return &ServiceError{
+		Code:    ErrorApprovalRequired,
+		Message: message,
+	}
+}
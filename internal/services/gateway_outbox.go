@@ -0,0 +1,292 @@
+package services
+
+import (
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+	"quota-manager/pkg/logger"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultOutboxDispatchInterval is the outbox dispatch schedule when none is configured
+	DefaultOutboxDispatchInterval = "0 * * * * *"
+	// OutboxMaxAttempts is the number of deliveries after which an entry is marked failed
+	OutboxMaxAttempts = 10
+	// OutboxDeliveryLease is how long an entry may stay delivering before it is marked failed,
+	// covering dispatchers that crashed between claiming and recording the delivery
+	OutboxDeliveryLease = 5 * time.Minute
+	// outboxBatchSize bounds the entries delivered per dispatch run
+	outboxBatchSize = 500
+)
+
+// OutboxSummary counts outbox entries by status
+type OutboxSummary struct {
+	Pending    int64 `json:"pending"`
+	Delivering int64 `json:"delivering"`
+	Failed     int64 `json:"failed"`
+	Delivered  int64 `json:"delivered"`
+	Stuck      int64 `json:"stuck"` // delivering for longer than the lease
+}
+
+// outboxBackoff returns the delay before the next delivery after the given number of attempts
+func outboxBackoff(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > time.Hour {
+		delay = time.Hour
+	}
+	return delay
+}
+
+// outboxDedupKey identifies the gateway mutation caused by an audit record
+func outboxDedupKey(auditID int, mutation string) string {
+	return fmt.Sprintf("audit:%d:%s", auditID, mutation)
+}
+
+// enqueueGatewayMutation writes a gateway mutation of a model pool's counters to the outbox
+// inside the caller's transaction, so it is delivered if and only if the quota change commits
+func (s *QuotaService) enqueueGatewayMutation(tx *gorm.DB, userID, model, mutation string, value decimal.Decimal, source, dedupKey string) (*models.GatewayOutbox, error) {
+	entry := &models.GatewayOutbox{
+		DedupKey:        dedupKey,
+		UserID:          userID,
+		Model:           model,
+		Mutation:        mutation,
+		Value:           value,
+		Source:          source,
+		Status:          models.OutboxStatusPending,
+		NextAttemptTime: time.Now(),
+	}
+	if err := tx.Create(entry).Error; err != nil {
+		return nil, fmt.Errorf("failed to enqueue gateway mutation: %w", err)
+	}
+	return entry, nil
+}
+
+// deliverOutboxEntries delivers freshly committed entries right away; failures stay
+// in the outbox and are retried by the dispatcher
+func (s *QuotaService) deliverOutboxEntries(entries []*models.GatewayOutbox) {
+	for _, entry := range entries {
+		if err := s.deliverOutboxEntry(entry); err != nil {
+			logger.Warn("Gateway mutation delivery failed, it will be retried by the outbox dispatcher",
+				zap.Int("outbox_id", entry.ID),
+				zap.String("user_id", entry.UserID),
+				zap.String("mutation", entry.Mutation),
+				zap.Stringer("value", entry.Value),
+				zap.Error(err))
+		}
+	}
+}
+
+// deliverOutboxEntry claims a pending entry and sends it to the gateway. An entry
+// claimed by another dispatcher is skipped.
+func (s *QuotaService) deliverOutboxEntry(entry *models.GatewayOutbox) error {
+	claim := s.db.DB.Model(&models.GatewayOutbox{}).
+		Where("id = ? AND status = ?", entry.ID, models.OutboxStatusPending).
+		Updates(map[string]interface{}{
+			"status":            models.OutboxStatusDelivering,
+			"next_attempt_time": time.Now().Add(OutboxDeliveryLease),
+		})
+	if claim.Error != nil {
+		return fmt.Errorf("failed to claim outbox entry: %w", claim.Error)
+	}
+	if claim.RowsAffected == 0 {
+		return nil
+	}
+
+	// A single attempt: the gateway does not deduplicate, so a retry after a timeout could
+	// apply the mutation twice. The dispatcher's backoff retries failures it knows about.
+	var err error
+	switch entry.Mutation {
+	case models.OutboxMutationDeltaQuota:
+		err = s.aiGatewayClient.DeltaQuotaForModelWithKey(entry.UserID, entry.Model, entry.Value, entry.DedupKey)
+	case models.OutboxMutationDeltaUsedQuota:
+		err = s.aiGatewayClient.DeltaUsedQuotaForModelWithKey(entry.UserID, entry.Model, entry.Value, entry.DedupKey)
+	default:
+		err = fmt.Errorf("unknown outbox mutation %s", entry.Mutation)
+	}
+
+	attempts := entry.Attempts + 1
+	if err == nil {
+		now := time.Now()
+		if updateErr := s.db.DB.Model(&models.GatewayOutbox{}).Where("id = ?", entry.ID).
+			Updates(map[string]interface{}{
+				"status":         models.OutboxStatusDelivered,
+				"attempts":       attempts,
+				"last_error":     "",
+				"delivered_time": now,
+			}).Error; updateErr != nil {
+			// The lease expires and the entry is marked failed for review, the gateway
+			// does not drop a redelivered mutation
+			logger.Error("Failed to record gateway mutation delivery",
+				zap.Int("outbox_id", entry.ID),
+				zap.Error(updateErr))
+		}
+		return nil
+	}
+
+	status := models.OutboxStatusPending
+	if attempts >= OutboxMaxAttempts {
+		status = models.OutboxStatusFailed
+	}
+	if updateErr := s.db.DB.Model(&models.GatewayOutbox{}).Where("id = ?", entry.ID).
+		Updates(map[string]interface{}{
+			"status":            status,
+			"attempts":          attempts,
+			"last_error":        err.Error(),
+			"next_attempt_time": time.Now().Add(outboxBackoff(attempts)),
+		}).Error; updateErr != nil {
+		logger.Error("Failed to record gateway mutation failure",
+			zap.Int("outbox_id", entry.ID),
+			zap.Error(updateErr))
+	}
+	if status == models.OutboxStatusFailed {
+		logger.Error("Gateway mutation failed permanently",
+			zap.Int("outbox_id", entry.ID),
+			zap.String("user_id", entry.UserID),
+			zap.Int("attempts", attempts))
+	}
+	return err
+}
+
+// DispatchGatewayOutbox delivers due outbox entries, oldest first. Entries whose delivery
+// lease expired may or may not have reached the gateway, which does not deduplicate
+// redeliveries, so they are marked failed for review instead of being requeued.
+func (s *QuotaService) DispatchGatewayOutbox() {
+	stuck := s.db.DB.Model(&models.GatewayOutbox{}).
+		Where("status = ? AND next_attempt_time <= ?", models.OutboxStatusDelivering, time.Now()).
+		Updates(map[string]interface{}{
+			"status":     models.OutboxStatusFailed,
+			"last_error": "delivery lease expired, outcome unknown",
+		})
+	if stuck.Error != nil {
+		logger.Error("Failed to mark stuck outbox entries failed", zap.Error(stuck.Error))
+	} else if stuck.RowsAffected > 0 {
+		logger.Error("Stuck outbox entries marked failed, check the gateway before retrying them",
+			zap.Int64("entries", stuck.RowsAffected))
+	}
+
+	var entries []models.GatewayOutbox
+	if err := s.db.DB.Where("status = ? AND next_attempt_time <= ?", models.OutboxStatusPending, time.Now()).
+		Order("id ASC").Limit(outboxBatchSize).Find(&entries).Error; err != nil {
+		logger.Error("Failed to load outbox entries", zap.Error(err))
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	delivered, failed := 0, 0
+	for i := range entries {
+		if err := s.deliverOutboxEntry(&entries[i]); err != nil {
+			failed++
+			continue
+		}
+		delivered++
+	}
+
+	logger.Info("Gateway outbox dispatched",
+		zap.Int("delivered", delivered),
+		zap.Int("failed", failed))
+}
+
+// GetGatewayOutboxEntries lists outbox entries, newest first, optionally filtered by
+// status and user. The stuck status selects entries delivering past their lease.
+func (s *QuotaService) GetGatewayOutboxEntries(status, userID string, page, pageSize int) ([]models.GatewayOutbox, int64, error) {
+	query := s.db.DB.Model(&models.GatewayOutbox{})
+	switch status {
+	case "":
+	case "stuck":
+		query = query.Where("status = ? AND next_attempt_time <= ?", models.OutboxStatusDelivering, time.Now())
+	default:
+		query = query.Where("status = ?", status)
+	}
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, NewDatabaseError("count outbox entries", err)
+	}
+
+	var entries []models.GatewayOutbox
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&entries).Error; err != nil {
+		return nil, 0, NewDatabaseError("query outbox entries", err)
+	}
+	return entries, total, nil
+}
+
+// GetGatewayOutboxSummary counts outbox entries by status
+func (s *QuotaService) GetGatewayOutboxSummary() (*OutboxSummary, error) {
+	type statusCount struct {
+		Status string
+		Count  int64
+	}
+	var counts []statusCount
+	if err := s.db.DB.Model(&models.GatewayOutbox{}).
+		Select("status, COUNT(*) AS count").Group("status").Scan(&counts).Error; err != nil {
+		return nil, NewDatabaseError("count outbox entries", err)
+	}
+
+	summary := &OutboxSummary{}
+	for _, count := range counts {
+		switch count.Status {
+		case models.OutboxStatusPending:
+			summary.Pending = count.Count
+		case models.OutboxStatusDelivering:
+			summary.Delivering = count.Count
+		case models.OutboxStatusFailed:
+			summary.Failed = count.Count
+		case models.OutboxStatusDelivered:
+			summary.Delivered = count.Count
+		}
+	}
+	if err := s.db.DB.Model(&models.GatewayOutbox{}).
+		Where("status = ? AND next_attempt_time <= ?", models.OutboxStatusDelivering, time.Now()).
+		Count(&summary.Stuck).Error; err != nil {
+		return nil, NewDatabaseError("count stuck outbox entries", err)
+	}
+	return summary, nil
+}
+
+// RetryGatewayOutboxEntry requeues a failed or stuck entry and delivers it right away
+func (s *QuotaService) RetryGatewayOutboxEntry(id int) (*models.GatewayOutbox, error) {
+	var entry models.GatewayOutbox
+	if err := s.db.DB.First(&entry, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, NewResourceNotFoundError("outbox entry", fmt.Sprintf("%d", id))
+		}
+		return nil, NewDatabaseError("query outbox entry", err)
+	}
+
+	stuck := entry.Status == models.OutboxStatusDelivering && !entry.NextAttemptTime.After(time.Now())
+	if entry.Status != models.OutboxStatusFailed && !stuck {
+		return nil, NewConflictError(fmt.Sprintf("outbox entry %d is %s, only failed or stuck entries can be retried", id, entry.Status))
+	}
+
+	if err := s.db.DB.Model(&entry).Updates(map[string]interface{}{
+		"status":            models.OutboxStatusPending,
+		"attempts":          0,
+		"next_attempt_time": time.Now(),
+	}).Error; err != nil {
+		return nil, NewDatabaseError("requeue outbox entry", err)
+	}
+	entry.Attempts = 0
+
+	if err := s.deliverOutboxEntry(&entry); err != nil {
+		logger.Warn("Retried gateway mutation failed again",
+			zap.Int("outbox_id", entry.ID),
+			zap.Error(err))
+	}
+
+	if err := s.db.DB.First(&entry, id).Error; err != nil {
+		return nil, NewDatabaseError("query outbox entry", err)
+	}
+	return &entry, nil
+}
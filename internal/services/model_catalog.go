@@ -0,0 +1,374 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+	"quota-manager/pkg/logger"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// MaxCostMultiplier bounds the cost multiplier of a model
+const MaxCostMultiplier = 1000
+
+// multiplierScale is the precision multipliers are stored with, DECIMAL(10,4)
+const multiplierScale = 10000
+
+// multiplierUnits converts a multiplier to the fixed point units it is stored with
+func multiplierUnits(multiplier float64) int64 {
+	return int64(math.Round(multiplier * multiplierScale))
+}
+
+// ModelCostEntry is a model's cost multiplier in the catalog at some revision
+type ModelCostEntry struct {
+	Model         string    `json:"model"`
+	Multiplier    float64   `json:"multiplier"`
+	Revision      int       `json:"revision"` // revision the multiplier was set in
+	EffectiveTime time.Time `json:"effective_time"`
+}
+
+// ModelCatalog is the model cost catalog at a revision
+type ModelCatalog struct {
+	Revision int              `json:"revision"`
+	Models   []ModelCostEntry `json:"models"`
+}
+
+// MonthlyUsageRecord is a month of recorded usage with the multipliers it was weighted with.
+// A month the catalog changed in was weighted with the catalog at catalog_revision_from
+// until the first of the listed changes, and so on up to catalog_revision.
+type MonthlyUsageRecord struct {
+	YearMonth           string                       `json:"year_month"`
+	UsedQuota           decimal.Decimal              `json:"used_quota"`
+	CatalogRevisionFrom int                          `json:"catalog_revision_from"`
+	CatalogRevision     int                          `json:"catalog_revision"`
+	Multipliers         map[string]float64           `json:"multipliers"`               // model -> multiplier at catalog_revision_from, unlisted models cost 1x
+	CatalogChanges      []models.ModelCostMultiplier `json:"catalog_changes,omitempty"` // changes made during the month, oldest first
+	RecordTime          time.Time                    `json:"record_time"`
+}
+
+// currentCatalogRevision returns the latest catalog revision, 0 when the catalog was never changed
+func currentCatalogRevision(db *gorm.DB) (int, error) {
+	var revision int
+	if err := db.Model(&models.ModelCostMultiplier{}).
+		Select("COALESCE(MAX(revision), 0)").Scan(&revision).Error; err != nil {
+		return 0, fmt.Errorf("failed to get model catalog revision: %w", err)
+	}
+	return revision, nil
+}
+
+// catalogRevisionAt returns the catalog revision in effect at a time, 0 before the first change
+func catalogRevisionAt(db *gorm.DB, at time.Time) (int, error) {
+	var revision int
+	if err := db.Model(&models.ModelCostMultiplier{}).Where("effective_time < ?", at).
+		Select("COALESCE(MAX(revision), 0)").Scan(&revision).Error; err != nil {
+		return 0, fmt.Errorf("failed to get model catalog revision: %w", err)
+	}
+	return revision, nil
+}
+
+// loadCatalog folds the catalog changes up to a revision into the catalog at that revision
+func (s *QuotaService) loadCatalog(revision int) ([]ModelCostEntry, error) {
+	if revision <= 0 {
+		return []ModelCostEntry{}, nil
+	}
+	var changes []models.ModelCostMultiplier
+	if err := s.db.DB.Where("revision <= ?", revision).
+		Order("revision ASC").Find(&changes).Error; err != nil {
+		return nil, fmt.Errorf("failed to load model catalog: %w", err)
+	}
+
+	current := make(map[string]models.ModelCostMultiplier)
+	for _, change := range changes {
+		if change.Removed {
+			delete(current, change.Model)
+			continue
+		}
+		current[change.Model] = change
+	}
+
+	entries := make([]ModelCostEntry, 0, len(current))
+	for _, change := range current {
+		entries = append(entries, ModelCostEntry{
+			Model:         change.Model,
+			Multiplier:    change.Multiplier,
+			Revision:      change.Revision,
+			EffectiveTime: change.EffectiveTime,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Model < entries[j].Model })
+	return entries, nil
+}
+
+// GetModelCatalog returns the model cost catalog at a revision, 0 meaning the current one
+func (s *QuotaService) GetModelCatalog(revision int) (*ModelCatalog, error) {
+	if revision < 0 {
+		return nil, NewValidationFailedError("revision must be >= 0")
+	}
+	current, err := currentCatalogRevision(s.db.DB)
+	if err != nil {
+		return nil, NewDatabaseError("get model catalog revision", err)
+	}
+	if revision == 0 {
+		revision = current
+	} else if revision > current {
+		return nil, NewResourceNotFoundError("model catalog revision", fmt.Sprintf("%d", revision))
+	}
+
+	entries, err := s.loadCatalog(revision)
+	if err != nil {
+		return nil, NewDatabaseError("load model catalog", err)
+	}
+	return &ModelCatalog{Revision: revision, Models: entries}, nil
+}
+
+// SetModelCostMultiplier sets a model's cost multiplier in a new catalog revision and
+// pushes the catalog to AiGateway when model cost push is enabled. Setting the multiplier a model already has is a no-op.
+func (s *QuotaService) SetModelCostMultiplier(model string, multiplier float64, operator, reason string) (*models.ModelCostMultiplier, error) {
+	model = strings.TrimSpace(model)
+	if model == "" || len(model) > 100 {
+		return nil, NewValidationFailedError("model is required and must be at most 100 characters")
+	}
+	// Compare and store at the column's precision, a float read back from DECIMAL(10,4)
+	// may not equal the float it was written from
+	units := multiplierUnits(multiplier)
+	if units <= 0 || units > MaxCostMultiplier*multiplierScale {
+		return nil, NewValidationFailedError(fmt.Sprintf("multiplier must be greater than 0 and at most %d", MaxCostMultiplier))
+	}
+
+	latest, err := s.latestModelCostChange(model)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && !latest.Removed && multiplierUnits(latest.Multiplier) == units {
+		return latest, nil
+	}
+
+	return s.recordCatalogChange(&models.ModelCostMultiplier{
+		Model:      model,
+		Multiplier: float64(units) / multiplierScale,
+		Operator:   operator,
+		Reason:     reason,
+	})
+}
+
+// RemoveModelCostMultiplier takes a model out of the catalog in a new revision, so it
+// costs 1x again, and pushes the catalog to AiGateway when model cost push is enabled
+func (s *QuotaService) RemoveModelCostMultiplier(model, operator, reason string) (*models.ModelCostMultiplier, error) {
+	model = strings.TrimSpace(model)
+	latest, err := s.latestModelCostChange(model)
+	if err != nil {
+		return nil, err
+	}
+	if latest == nil || latest.Removed {
+		return nil, NewResourceNotFoundError("model cost multiplier", model)
+	}
+
+	return s.recordCatalogChange(&models.ModelCostMultiplier{
+		Model:      model,
+		Multiplier: 1,
+		Removed:    true,
+		Operator:   operator,
+		Reason:     reason,
+	})
+}
+
+// latestModelCostChange returns the latest catalog change of a model, nil when it has none
+func (s *QuotaService) latestModelCostChange(model string) (*models.ModelCostMultiplier, error) {
+	var change models.ModelCostMultiplier
+	result := s.db.DB.Where("model = ?", model).Order("revision DESC").Limit(1).Find(&change)
+	if result.Error != nil {
+		return nil, NewDatabaseError("query model cost multiplier", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &change, nil
+}
+
+// recordCatalogChange stores a change under the next catalog revision. Two concurrent
+// changes compete for the same revision and the loser is reported as a conflict.
+func (s *QuotaService) recordCatalogChange(change *models.ModelCostMultiplier) (*models.ModelCostMultiplier, error) {
+	revision, err := currentCatalogRevision(s.db.DB)
+	if err != nil {
+		return nil, NewDatabaseError("get model catalog revision", err)
+	}
+	change.Revision = revision + 1
+	change.EffectiveTime = time.Now()
+	if err := s.db.DB.Create(change).Error; err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, NewConflictError("the model catalog was changed concurrently, please retry")
+		}
+		return nil, NewDatabaseError("record model cost multiplier", err)
+	}
+
+	logger.Info("Model cost catalog changed",
+		zap.Int("revision", change.Revision),
+		zap.String("model", change.Model),
+		zap.Float64("multiplier", change.Multiplier),
+		zap.Bool("removed", change.Removed),
+		zap.String("operator", change.Operator))
+
+	// Best effort, a failed push is retried by the scheduler
+	if s.aiGatewayConf.ModelCostPush {
+		if err := s.pushModelCatalog(false); err != nil {
+			s.recordCatalogPushFailure()
+			logger.Warn("Failed to push model cost catalog to AiGateway, it will be retried", zap.Error(err))
+		} else {
+			change.Pushed = true
+		}
+	}
+	return change, nil
+}
+
+// pushModelCatalog sends the current catalog to AiGateway unless it already has it, or
+// always when forced
+func (s *QuotaService) pushModelCatalog(force bool) error {
+	var latest models.ModelCostMultiplier
+	result := s.db.DB.Order("revision DESC").Limit(1).Find(&latest)
+	if result.Error != nil {
+		return fmt.Errorf("failed to get model catalog revision: %w", result.Error)
+	}
+	if result.RowsAffected == 0 || (latest.Pushed && !force) {
+		return nil
+	}
+
+	entries, err := s.loadCatalog(latest.Revision)
+	if err != nil {
+		return err
+	}
+	multipliers := make(map[string]float64, len(entries))
+	for _, entry := range entries {
+		multipliers[entry.Model] = entry.Multiplier
+	}
+	if err := s.aiGatewayClient.SetModelCostMultipliers(multipliers, latest.Revision); err != nil {
+		return err
+	}
+
+	if err := s.db.DB.Model(&models.ModelCostMultiplier{}).
+		Where("revision <= ? AND pushed = ?", latest.Revision, false).
+		Update("pushed", true).Error; err != nil {
+		return fmt.Errorf("failed to record model catalog push: %w", err)
+	}
+	logger.Info("Model cost catalog pushed to AiGateway",
+		zap.Int("revision", latest.Revision),
+		zap.Int("models", len(multipliers)))
+	return nil
+}
+
+// recordCatalogPushFailure backs scheduled catalog pushes off like outbox deliveries
+func (s *QuotaService) recordCatalogPushFailure() {
+	s.catalogPushMu.Lock()
+	defer s.catalogPushMu.Unlock()
+	s.catalogPushFailures++
+	s.catalogPushRetryAt = time.Now().Add(outboxBackoff(s.catalogPushFailures))
+}
+
+// PushModelCatalog pushes a catalog change AiGateway has not received yet, for the scheduler.
+// It does nothing unless model cost push is enabled, and backs off after failed pushes.
+func (s *QuotaService) PushModelCatalog() {
+	if !s.aiGatewayConf.ModelCostPush {
+		return
+	}
+	s.catalogPushMu.Lock()
+	due := !time.Now().Before(s.catalogPushRetryAt)
+	s.catalogPushMu.Unlock()
+	if !due {
+		return
+	}
+
+	if err := s.pushModelCatalog(false); err != nil {
+		s.recordCatalogPushFailure()
+		logger.Error("Failed to push model cost catalog to AiGateway", zap.Error(err))
+		return
+	}
+	s.catalogPushMu.Lock()
+	s.catalogPushFailures = 0
+	s.catalogPushRetryAt = time.Time{}
+	s.catalogPushMu.Unlock()
+}
+
+// PushModelCatalogNow resends the current catalog to AiGateway, e.g. after the gateway lost it
+func (s *QuotaService) PushModelCatalogNow() error {
+	if !s.aiGatewayConf.ModelCostPush {
+		return NewValidationFailedError("model cost push is disabled, set aigateway.model_cost_push to enable it")
+	}
+	return s.pushModelCatalog(true)
+}
+
+// GetModelCostHistory lists catalog changes, newest first, optionally for one model
+func (s *QuotaService) GetModelCostHistory(model string, page, pageSize int) ([]models.ModelCostMultiplier, int64, error) {
+	query := s.db.DB.Model(&models.ModelCostMultiplier{})
+	if model != "" {
+		query = query.Where("model = ?", model)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, NewDatabaseError("count model cost multipliers", err)
+	}
+
+	var changes []models.ModelCostMultiplier
+	if err := query.Order("revision DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&changes).Error; err != nil {
+		return nil, 0, NewDatabaseError("query model cost multipliers", err)
+	}
+	return changes, total, nil
+}
+
+// GetMonthlyUsageReport lists a user's recorded monthly usage, newest month first, with
+// the multipliers each month started with and the catalog changes made during it
+func (s *QuotaService) GetMonthlyUsageReport(userID string, page, pageSize int) ([]MonthlyUsageRecord, int64, error) {
+	query := s.db.DB.Model(&models.MonthlyQuotaUsage{}).Where("user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, NewDatabaseError("count monthly usage", err)
+	}
+
+	var usages []models.MonthlyQuotaUsage
+	if err := query.Order("year_month DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&usages).Error; err != nil {
+		return nil, 0, NewDatabaseError("query monthly usage", err)
+	}
+
+	catalogs := make(map[int]map[string]float64)
+	records := make([]MonthlyUsageRecord, len(usages))
+	for i, usage := range usages {
+		from := usage.CatalogRevisionFrom
+		multipliers, ok := catalogs[from]
+		if !ok {
+			entries, err := s.loadCatalog(from)
+			if err != nil {
+				return nil, 0, NewDatabaseError("load model catalog", err)
+			}
+			multipliers = make(map[string]float64, len(entries))
+			for _, entry := range entries {
+				multipliers[entry.Model] = entry.Multiplier
+			}
+			catalogs[from] = multipliers
+		}
+		var changes []models.ModelCostMultiplier
+		if from < usage.CatalogRevision {
+			if err := s.db.DB.Where("revision > ? AND revision <= ?", from, usage.CatalogRevision).
+				Order("revision ASC").Find(&changes).Error; err != nil {
+				return nil, 0, NewDatabaseError("query model cost multipliers", err)
+			}
+		}
+		records[i] = MonthlyUsageRecord{
+			YearMonth:           usage.YearMonth,
+			UsedQuota:           usage.UsedQuota,
+			CatalogRevisionFrom: from,
+			CatalogRevision:     usage.CatalogRevision,
+			Multipliers:         multipliers,
+			CatalogChanges:      changes,
+			RecordTime:          usage.RecordTime,
+		}
+	}
+	return records, total, nil
+}
@@ -7,12 +7,15 @@ import (
 	"quota-manager/internal/models"
 	"quota-manager/internal/utils"
 	"quota-manager/pkg/aigateway"
+	"quota-manager/pkg/decimal"
 	"quota-manager/pkg/logger"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // QuotaService handles quota-related operations
@@ -22,6 +25,16 @@ type QuotaService struct {
 	configManager   *config.Manager
 	aiGatewayClient *aigateway.Client
 	voucherSvc      *VoucherService
+	balanceObserver func(userID string, remaining decimal.Decimal)
+	auditVerifyMu   sync.Mutex // held while every user's audit chain is verified
+	usageSnapshotMu sync.Mutex // held while the daily usage snapshot runs
+
+	catalogPushMu       sync.Mutex // guards the catalog push backoff below
+	catalogPushFailures int        // consecutive failed catalog pushes
+	catalogPushRetryAt  time.Time  // scheduled catalog pushes wait until then after a failure
+
+	poolDrawSlots   chan struct{} // bounds the department pool draws started by quota reads
+	poolDrawPending sync.Map      // users with such a draw in flight
 }
 
 // GetConfigManager returns the config manager
@@ -37,27 +50,67 @@ func NewQuotaService(db *database.DB, configManager *config.Manager, aiGatewayCl
 		configManager:   configManager,
 		aiGatewayClient: aiGatewayClient,
 		voucherSvc:      voucherSvc,
+		poolDrawSlots:   make(chan struct{}, maxConcurrentPoolDraws),
 	}
 }
 
+// SetBalanceObserver registers a callback that is invoked asynchronously whenever
+// a user's remaining balance has been read from the AiGateway
+func (s *QuotaService) SetBalanceObserver(observer func(userID string, remaining decimal.Decimal)) {
+	s.balanceObserver = observer
+}
+
+// notifyBalance hands a freshly queried balance to the observer, if any
+func (s *QuotaService) notifyBalance(userID string, remaining decimal.Decimal) {
+	if s.balanceObserver != nil {
+		go s.balanceObserver(userID, remaining)
+	}
+}
+
+// QueryRemainingQuota gets the user's remaining balance (total - used) from AiGateway. The
+// gateway total holds the credit line, which is left out, so the balance goes negative
+// while the user draws on credit.
+func (s *QuotaService) QueryRemainingQuota(userID string) (decimal.Decimal, error) {
+	totalQuota, err := s.aiGatewayClient.QueryQuotaValue(userID)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to get total quota: %w", err)
+	}
+	usedQuota, err := s.aiGatewayClient.QueryUsedQuotaValue(userID)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to get used quota: %w", err)
+	}
+	credit, err := s.loadCredit(s.db.DB, userID)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return totalQuota.Sub(credit.CreditLimit).Sub(usedQuota), nil
+}
+
 // QuotaInfo represents user quota information
 type QuotaInfo struct {
-	TotalQuota float64           `json:"total_quota"`
-	UsedQuota  float64           `json:"used_quota"`
+	TotalQuota decimal.Decimal   `json:"total_quota"`
+	UsedQuota  decimal.Decimal   `json:"used_quota"`
 	QuotaList  []QuotaDetailItem `json:"quota_list"`
-	IsStar     string            `json:"is_star,omitempty"`
+	Models     []ModelQuotaInfo  `json:"models,omitempty"`      // model pools, the fields above being the "any model" pool
+	ModelCosts *ModelCatalog     `json:"model_costs,omitempty"` // cost multipliers usage is weighted with
+	// Credit of the "any model" pool; total_quota excludes the credit line, so
+	// total_quota - used_quota goes negative while the user is in debt
+	CreditLimit     decimal.Decimal `json:"credit_limit,omitempty"`
+	OutstandingDebt decimal.Decimal `json:"outstanding_debt,omitempty"`
+	IsStar          string          `json:"is_star,omitempty"`
 }
 
 // QuotaDetailItem represents quota detail item
 type QuotaDetailItem struct {
-	Amount     float64   `json:"amount"`
-	ExpiryDate time.Time `json:"expiry_date"`
+	Amount     decimal.Decimal `json:"amount"`
+	ExpiryDate time.Time       `json:"expiry_date"`
 }
 
 // QuotaAuditRecord represents quota audit record
 type QuotaAuditRecord struct {
-	Amount       float64                   `json:"amount"`
+	Amount       decimal.Decimal           `json:"amount"`
 	Operation    string                    `json:"operation"`
+	Model        string                    `json:"model,omitempty"`
 	VoucherCode  string                    `json:"voucher_code,omitempty"`
 	RelatedUser  string                    `json:"related_user,omitempty"`
 	StrategyName string                    `json:"strategy_name,omitempty"`
@@ -74,8 +127,9 @@ type TransferOutRequest struct {
 
 // TransferQuotaItem represents quota item for transfer
 type TransferQuotaItem struct {
-	Amount     float64   `json:"amount" validate:"required,gt=0"`
-	ExpiryDate time.Time `json:"expiry_date" validate:"required"`
+	Amount     decimal.Decimal `json:"amount" validate:"required,gt=0"`
+	ExpiryDate time.Time       `json:"expiry_date" validate:"required"`
+	Model      string          `json:"model,omitempty" validate:"max=100"` // model pool, empty = any model
 }
 
 // TransferOutResponse represents transfer out response
@@ -107,6 +161,8 @@ type TransferFailureReason string
 const (
 	TransferFailureReasonExpired TransferFailureReason = "EXPIRED"
 	TransferFailureReasonPending TransferFailureReason = "PENDING"
+	// TransferFailureReasonBalanceCap marks an item cut off entirely by the receiver's maximum balance
+	TransferFailureReasonBalanceCap TransferFailureReason = "BALANCE_CAP"
 )
 
 // TransferInResponse represents transfer in response
@@ -119,63 +175,71 @@ type TransferInResponse struct {
 	QuotaList   []TransferQuotaResult `json:"quota_list"`
 	VoucherCode string                `json:"voucher_code"`
 	Operation   string                `json:"operation"`
-	Amount      float64               `json:"amount"`
+	Amount      decimal.Decimal       `json:"amount"`
+	Truncated   decimal.Decimal       `json:"truncated,omitempty"` // amount cut off by the receiver's balance cap
 	Status      TransferStatus        `json:"status"`
 	Message     string                `json:"message,omitempty"`
 }
 
 // TransferQuotaResult represents transfer quota result
 type TransferQuotaResult struct {
-	Amount        float64                `json:"amount"`
+	Amount        decimal.Decimal        `json:"amount"`
 	ExpiryDate    time.Time              `json:"expiry_date"`
+	Model         string                 `json:"model,omitempty"`
 	IsExpired     bool                   `json:"is_expired"`
+	Truncated     decimal.Decimal        `json:"truncated,omitempty"` // amount cut off by the balance cap, amount being what was credited
 	Success       bool                   `json:"success"`
 	FailureReason *TransferFailureReason `json:"failure_reason,omitempty"`
 }
 
 // GetUserQuota retrieves user quota information
 func (s *QuotaService) GetUserQuota(userID string) (*QuotaInfo, error) {
-	// Get total quota from AiGateway
-	totalQuota, err := s.aiGatewayClient.QueryQuotaValue(userID)
+	// Charge new usage to the "any model" pool, then list what is left of each bucket
+	anyModel, err := s.poolQuota(userID, "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get total quota: %w", err)
+		return nil, err
 	}
+	totalQuota, usedQuota, quotaList := anyModel.TotalQuota, anyModel.UsedQuota, anyModel.QuotaList
 
-	// Get used quota from AiGateway
-	usedQuota, err := s.aiGatewayClient.QueryUsedQuotaValue(userID)
+	// The credit line is not balance, top-ups watching the balance fire before it is drawn on
+	credit, err := s.loadCredit(s.db.DB, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get used quota: %w", err)
+		return nil, err
 	}
+	totalQuota = totalQuota.Sub(credit.CreditLimit)
+	s.notifyBalance(userID, totalQuota.Sub(usedQuota))
 
-	// Get quota list from database
-	var quotas []models.Quota
-	if err := s.db.DB.Where("user_id = ? AND status = ?", userID, models.StatusValid).
-		Order("expiry_date ASC").Find(&quotas).Error; err != nil {
-		return nil, fmt.Errorf("failed to get quota list: %w", err)
-	}
-
-	// Calculate remaining quotas considering used quota
-	quotaList := make([]QuotaDetailItem, 0)
-	remainingUsed := usedQuota
-
-	for _, quota := range quotas {
-		if remainingUsed <= 0 {
-			// No more used quota to deduct
-			quotaList = append(quotaList, QuotaDetailItem{
-				Amount:     quota.Amount,
-				ExpiryDate: quota.ExpiryDate,
-			})
-		} else if quota.Amount > remainingUsed {
-			// This quota is partially consumed
-			quotaList = append(quotaList, QuotaDetailItem{
-				Amount:     quota.Amount - remainingUsed,
-				ExpiryDate: quota.ExpiryDate,
-			})
-			remainingUsed = 0
-		} else {
-			// This quota is fully consumed
-			remainingUsed -= quota.Amount
+	// Members whose personal buckets ran dry draw from their department pool
+	personal := decimal.Zero
+	for _, item := range quotaList {
+		personal = personal.Add(item.Amount)
+	}
+	if !personal.IsPositive() {
+		s.requestPoolDraw(userID)
+	}
+
+	// Same for every model pool the user holds quota in
+	pools, err := s.userModelPools(userID)
+	if err != nil {
+		return nil, err
+	}
+	var modelQuotas []ModelQuotaInfo
+	for _, model := range pools {
+		info, err := s.poolQuota(userID, model)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get quota of model pool %s: %w", model, err)
 		}
+		modelQuotas = append(modelQuotas, *info)
+	}
+
+	// Usage above is weighted by the model cost catalog, which is informational here
+	var modelCosts *ModelCatalog
+	if catalog, err := s.GetModelCatalog(0); err != nil {
+		logger.Warn("Failed to load model cost catalog",
+			zap.String("user_id", userID),
+			zap.Error(err))
+	} else if len(catalog.Models) > 0 {
+		modelCosts = catalog
 	}
 
 	// checkGithubStar checks if user has starred the required GitHub repository
@@ -201,17 +265,25 @@ func (s *QuotaService) GetUserQuota(userID string) (*QuotaInfo, error) {
 			}
 		}
 		return &QuotaInfo{
-			TotalQuota: totalQuota,
-			UsedQuota:  usedQuota,
-			QuotaList:  quotaList,
-			IsStar:     isStar,
+			TotalQuota:      totalQuota,
+			UsedQuota:       usedQuota,
+			QuotaList:       quotaList,
+			Models:          modelQuotas,
+			ModelCosts:      modelCosts,
+			CreditLimit:     credit.CreditLimit,
+			OutstandingDebt: credit.Debt,
+			IsStar:          isStar,
 		}, nil
 	}
 
 	return &QuotaInfo{
-		TotalQuota: totalQuota,
-		UsedQuota:  usedQuota,
-		QuotaList:  quotaList,
+		TotalQuota:      totalQuota,
+		UsedQuota:       usedQuota,
+		QuotaList:       quotaList,
+		Models:          modelQuotas,
+		ModelCosts:      modelCosts,
+		CreditLimit:     credit.CreditLimit,
+		OutstandingDebt: credit.Debt,
 	}, nil
 }
 
@@ -250,6 +322,7 @@ func (s *QuotaService) GetQuotaAuditRecords(userID string, page, pageSize int) (
 		result[i] = QuotaAuditRecord{
 			Amount:       record.Amount,
 			Operation:    record.Operation,
+			Model:        record.Model,
 			VoucherCode:  record.VoucherCode,
 			RelatedUser:  record.RelatedUser,
 			StrategyName: record.StrategyName,
@@ -269,38 +342,21 @@ func (s *QuotaService) TransferOut(giver *models.AuthUser, req *TransferOutReque
 		return nil, fmt.Errorf("receiver_id cannot be empty")
 	}
 
-	// Get used quota from AiGateway to check availability
-	usedQuota, err := s.aiGatewayClient.QueryUsedQuotaValue(giver.ID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get used quota: %w", err)
-	}
-
-	// Get quota list ordered by expiry date to check availability
-	var quotas []models.Quota
-	if err := s.db.DB.Where("user_id = ? AND status = ?", giver.ID, models.StatusValid).
-		Order("expiry_date ASC").Find(&quotas).Error; err != nil {
-		return nil, fmt.Errorf("failed to get quota list: %w", err)
-	}
-
-	// Calculate remaining quotas for each expiry date
-	quotaAvailabilityMap := make(map[string]float64) // key: expiry_date as string, value: available amount
-	remainingUsed := usedQuota
-
-	for _, quota := range quotas {
-		dateKey := quota.ExpiryDate.Format("2006-01-02T15:04:05Z07:00")
-		var availableFromThisQuota float64
-		if remainingUsed <= 0 {
-			availableFromThisQuota = quota.Amount
-		} else if quota.Amount > remainingUsed {
-			availableFromThisQuota = quota.Amount - remainingUsed
-			remainingUsed = 0
-		} else {
-			availableFromThisQuota = 0
-			remainingUsed -= quota.Amount
+	// Resolve the model pool of each item; items without a model draw from the "any model" pool
+	quotaItems := make([]TransferQuotaItem, len(req.QuotaList))
+	var pools []string
+	poolSeen := make(map[string]bool)
+	for i, item := range req.QuotaList {
+		model, err := s.resolveTransferModel(item.Model)
+		if err != nil {
+			return nil, err
+		}
+		item.Model = model
+		quotaItems[i] = item
+		if !poolSeen[model] {
+			poolSeen[model] = true
+			pools = append(pools, model)
 		}
-
-		// Add to existing amount for the same expiry date (accumulate instead of overwriting)
-		quotaAvailabilityMap[dateKey] += availableFromThisQuota
 	}
 
 	// Start transaction
@@ -311,45 +367,53 @@ func (s *QuotaService) TransferOut(giver *models.AuthUser, req *TransferOutReque
 		}
 	}()
 
-	// Validate quota availability for each requested quota
-	for _, quotaItem := range req.QuotaList {
-		dateKey := quotaItem.ExpiryDate.Format("2006-01-02T15:04:05Z07:00")
-		available, exists := quotaAvailabilityMap[dateKey]
-		if !exists {
+	// Charge the giver's usage and lock their buckets, so concurrent transfers of the
+	// same user are serialized and each one sees the amounts left by the previous one
+	var quotas []models.Quota
+	for _, model := range pools {
+		poolQuotas, _, err := s.chargeUsage(tx, giver.ID, model)
+		if err != nil {
 			tx.Rollback()
-			return nil, fmt.Errorf("quota not found for expiry date %v", quotaItem.ExpiryDate)
+			return nil, err
 		}
+		quotas = append(quotas, poolQuotas...)
+	}
 
-		if available < quotaItem.Amount {
-			tx.Rollback()
-			return nil, fmt.Errorf("insufficient available quota for expiry date %v: have %g, need %g",
-				quotaItem.ExpiryDate, available, quotaItem.Amount)
-		}
+	// Calculate remaining quotas for each model pool and expiry date
+	quotaAvailabilityMap := make(map[string]decimal.Decimal) // key: model and expiry_date, value: available amount
+	for i := range quotas {
+		key := transferAvailabilityKey(quotas[i].Model, quotas[i].ExpiryDate)
+		quotaAvailabilityMap[key] = quotaAvailabilityMap[key].Add(quotas[i].Remaining())
+	}
 
-		// Also validate the total quota exists in database for this expiry date
-		var totalQuotaAmount float64
-		if err := tx.Model(&models.Quota{}).
-			Where("user_id = ? AND expiry_date = ? AND status = ?",
-				giver.ID, quotaItem.ExpiryDate, models.StatusValid).
-			Select("COALESCE(SUM(amount), 0)").
-			Scan(&totalQuotaAmount).Error; err != nil {
+	// Validate quota availability for each requested quota
+	for _, quotaItem := range quotaItems {
+		key := transferAvailabilityKey(quotaItem.Model, quotaItem.ExpiryDate)
+		available, exists := quotaAvailabilityMap[key]
+		if !exists {
 			tx.Rollback()
-			return nil, fmt.Errorf("failed to check quota for expiry date %v: %w", quotaItem.ExpiryDate, err)
+			if quotaItem.Model != "" {
+				return nil, fmt.Errorf("quota not found for model %s and expiry date %v", quotaItem.Model, quotaItem.ExpiryDate)
+			}
+			return nil, fmt.Errorf("quota not found for expiry date %v", quotaItem.ExpiryDate)
 		}
 
-		if totalQuotaAmount < quotaItem.Amount {
+		if available.LessThan(quotaItem.Amount) {
 			tx.Rollback()
-			return nil, fmt.Errorf("insufficient quota for expiry date %v: have %f, need %f",
-				quotaItem.ExpiryDate, totalQuotaAmount, quotaItem.Amount)
+			return nil, fmt.Errorf("insufficient available quota for expiry date %v: have %s, need %s",
+				quotaItem.ExpiryDate, available, quotaItem.Amount)
 		}
+		// Items repeating a pool and expiry date draw from the same availability
+		quotaAvailabilityMap[key] = available.Sub(quotaItem.Amount)
 	}
 
 	// Generate voucher code
-	voucherQuotaList := make([]VoucherQuotaItem, len(req.QuotaList))
-	for i, item := range req.QuotaList {
+	voucherQuotaList := make([]VoucherQuotaItem, len(quotaItems))
+	for i, item := range quotaItems {
 		voucherQuotaList[i] = VoucherQuotaItem{
 			Amount:     item.Amount,
 			ExpiryDate: item.ExpiryDate,
+			Model:      item.Model,
 		}
 	}
 
@@ -380,91 +444,130 @@ func (s *QuotaService) TransferOut(giver *models.AuthUser, req *TransferOutReque
 		return nil, fmt.Errorf("failed to generate voucher: %w", err)
 	}
 
-	// Update quota table - reduce giver's quota
-	for _, quotaItem := range req.QuotaList {
-		if err := tx.Model(&models.Quota{}).
-			Where("user_id = ? AND expiry_date = ? AND status = ?",
-				giver.ID, quotaItem.ExpiryDate, models.StatusValid).
-			Update("amount", gorm.Expr("amount - ?", quotaItem.Amount)).Error; err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("failed to update quota: %w", err)
-		}
+	// Update quota table - take the transferred amounts from the unconsumed part of
+	// the giver's buckets, dropping buckets that end up empty
+	for _, quotaItem := range quotaItems {
+		needed := quotaItem.Amount
+		for i := range quotas {
+			if !needed.IsPositive() {
+				break
+			}
+			if quotas[i].Model != quotaItem.Model || !quotas[i].ExpiryDate.Equal(quotaItem.ExpiryDate) {
+				continue
+			}
+			take := decimal.Min(quotas[i].Remaining(), needed)
+			if !take.IsPositive() {
+				continue
+			}
+			quotas[i].Amount = quotas[i].Amount.Sub(take)
+			needed = needed.Sub(take)
 
-		// Delete quota records with zero or negative amounts
-		if err := tx.Where("user_id = ? AND expiry_date = ? AND status = ? AND amount <= 0",
-			giver.ID, quotaItem.ExpiryDate, models.StatusValid).Delete(&models.Quota{}).Error; err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("failed to delete zero quota records: %w", err)
+			if !quotas[i].Amount.IsPositive() && !quotas[i].Consumed.IsPositive() {
+				if err := tx.Delete(&models.Quota{}, quotas[i].ID).Error; err != nil {
+					tx.Rollback()
+					return nil, fmt.Errorf("failed to delete zero quota records: %w", err)
+				}
+				continue
+			}
+			if err := tx.Model(&models.Quota{}).Where("id = ?", quotas[i].ID).
+				Update("amount", quotas[i].Amount).Error; err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("failed to update quota: %w", err)
+			}
 		}
 	}
 
-	// Calculate total amount for audit record
-	totalAmount := 0.0
-	// Find earliest expiry date for audit record
-	var earliestExpiryDate time.Time
-	for i, item := range req.QuotaList {
-		totalAmount += item.Amount
-		if i == 0 || item.ExpiryDate.Before(earliestExpiryDate) {
-			earliestExpiryDate = item.ExpiryDate
+	// Record one audit log per model pool, each queuing the AiGateway update of its pool
+	// in the same transaction; they are delivered after commit
+	var outboxEntries []*models.GatewayOutbox
+	for _, model := range pools {
+		poolItems := make([]TransferQuotaItem, 0, len(quotaItems))
+		for _, item := range quotaItems {
+			if item.Model == model {
+				poolItems = append(poolItems, item)
+			}
 		}
-	}
 
-	// Prepare detailed audit information
-	auditDetails := &models.QuotaAuditDetails{
-		Operation: models.OperationTransferOut,
-		Summary: models.QuotaAuditSummary{
-			TotalAmount:        totalAmount,
-			TotalItems:         len(req.QuotaList),
-			SuccessfulItems:    len(req.QuotaList), // All items are successful in transfer out
-			EarliestExpiryDate: earliestExpiryDate.Format(time.RFC3339),
-		},
-		Items: make([]models.QuotaAuditDetailItem, len(req.QuotaList)),
-	}
+		// Calculate total amount for audit record
+		totalAmount := decimal.Zero
+		// Find earliest expiry date for audit record
+		var earliestExpiryDate time.Time
+		for i, item := range poolItems {
+			totalAmount = totalAmount.Add(item.Amount)
+			if i == 0 || item.ExpiryDate.Before(earliestExpiryDate) {
+				earliestExpiryDate = item.ExpiryDate
+			}
+		}
 
-	// Record each quota item detail
-	for i, item := range req.QuotaList {
-		auditDetails.Items[i] = models.QuotaAuditDetailItem{
-			Amount:     item.Amount,
-			ExpiryDate: item.ExpiryDate.Format(time.RFC3339),
-			Status:     models.AuditStatusSuccess,
+		// Prepare detailed audit information
+		auditDetails := &models.QuotaAuditDetails{
+			Operation: models.OperationTransferOut,
+			Summary: models.QuotaAuditSummary{
+				TotalAmount:        totalAmount,
+				TotalItems:         len(poolItems),
+				SuccessfulItems:    len(poolItems), // All items are successful in transfer out
+				EarliestExpiryDate: earliestExpiryDate.Format(time.RFC3339),
+			},
+			Items: make([]models.QuotaAuditDetailItem, len(poolItems)),
 		}
-	}
 
-	// Record audit log
-	auditRecord := &models.QuotaAudit{
-		UserID:      giver.ID,
-		Amount:      -totalAmount,
-		Operation:   models.OperationTransferOut,
-		VoucherCode: voucherCode,
-		RelatedUser: cleanReceiverID,
-		ExpiryDate:  earliestExpiryDate, // Use earliest expiry date for audit
-		// StrategyName is empty for transfer operations
-	}
-	if err := auditRecord.MarshalDetails(auditDetails); err != nil {
-		tx.Rollback()
-		return nil, fmt.Errorf("failed to marshal audit details: %w", err)
-	}
-	if err := tx.Create(auditRecord).Error; err != nil {
-		tx.Rollback()
-		return nil, fmt.Errorf("failed to create audit record: %w", err)
-	}
+		// Record each quota item detail
+		for i, item := range poolItems {
+			auditDetails.Items[i] = models.QuotaAuditDetailItem{
+				Amount:     item.Amount,
+				ExpiryDate: item.ExpiryDate.Format(time.RFC3339),
+				Model:      item.Model,
+				Status:     models.AuditStatusSuccess,
+			}
+		}
 
-	// Update AiGateway quota
-	if err := s.aiGatewayClient.DeltaQuota(giver.ID, -totalAmount); err != nil {
-		tx.Rollback()
-		return nil, fmt.Errorf("failed to update AiGateway quota: %w", err)
+		// Record audit log
+		auditRecord := &models.QuotaAudit{
+			UserID:      giver.ID,
+			Amount:      totalAmount.Neg(),
+			Operation:   models.OperationTransferOut,
+			Model:       model,
+			VoucherCode: voucherCode,
+			RelatedUser: cleanReceiverID,
+			ExpiryDate:  earliestExpiryDate, // Use earliest expiry date for audit
+			// StrategyName is empty for transfer operations
+		}
+		if err := auditRecord.MarshalDetails(auditDetails); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to marshal audit details: %w", err)
+		}
+		if err := s.createAuditRecord(tx, auditRecord); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to create audit record: %w", err)
+		}
+
+		outboxEntry, err := s.enqueueGatewayMutation(tx, giver.ID, model, models.OutboxMutationDeltaQuota, totalAmount.Neg(),
+			models.OperationTransferOut, outboxDedupKey(auditRecord.ID, models.OutboxMutationDeltaQuota))
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		outboxEntries = append(outboxEntries, outboxEntry)
 	}
 
-	tx.Commit()
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit transfer: %w", err)
+	}
+	s.deliverOutboxEntries(outboxEntries)
 
 	return &TransferOutResponse{
 		VoucherCode: voucherCode,
 		RelatedUser: cleanReceiverID,
 		Operation:   models.OperationTransferOut,
-		QuotaList:   req.QuotaList,
+		QuotaList:   quotaItems,
 	}, nil
 }
 
+// transferAvailabilityKey groups buckets by model pool and expiry date for transfers
+func transferAvailabilityKey(model string, expiryDate time.Time) string {
+	return model + "|" + expiryDate.Format("2006-01-02T15:04:05Z07:00")
+}
+
 // TransferIn handles quota transfer in
 func (s *QuotaService) TransferIn(receiver *models.AuthUser, req *TransferInRequest) (*TransferInResponse, error) {
 	// Validate voucher
@@ -500,6 +603,14 @@ func (s *QuotaService) TransferIn(receiver *models.AuthUser, req *TransferInRequ
 		}, nil
 	}
 
+	balanceCap, err := s.effectiveBalanceCap(receiver.ID)
+	if err != nil {
+		return &TransferInResponse{
+			Status:  TransferStatusFailed,
+			Message: "Failed to resolve balance cap",
+		}, nil
+	}
+
 	// Start transaction
 	tx := s.db.DB.Begin()
 	defer func() {
@@ -508,6 +619,36 @@ func (s *QuotaService) TransferIn(receiver *models.AuthUser, req *TransferInRequ
 		}
 	}()
 
+	// Received quota stops at the receiver's maximum balance: the excess is truncated, or the
+	// voucher is refused and left unredeemed so it can be redeemed once the balance is lower
+	var capDetail *models.BalanceCapDetail
+	capRoom := decimal.Zero
+	if balanceCap != nil {
+		validTotal := decimal.Zero
+		for _, quotaItem := range voucherData.QuotaList {
+			if !utils.NowInConfigTimezone(s.configManager.GetDirect()).Truncate(time.Second).After(quotaItem.ExpiryDate.Truncate(time.Second)) {
+				validTotal = validTotal.Add(quotaItem.Amount)
+			}
+		}
+		if validTotal.IsPositive() {
+			capRoom, capDetail, err = s.applyBalanceCap(tx, receiver.ID, validTotal, balanceCap)
+			if err != nil {
+				tx.Rollback()
+				return &TransferInResponse{
+					GiverID:     voucherData.GiverID,
+					GiverName:   voucherData.GiverName,
+					GiverPhone:  voucherData.GiverPhone,
+					GiverGithub: voucherData.GiverGithub,
+					ReceiverID:  receiver.ID,
+					VoucherCode: req.VoucherCode,
+					Operation:   models.OperationTransferIn,
+					Status:      TransferStatusFailed,
+					Message:     "Balance cap reached: " + err.Error(),
+				}, nil
+			}
+		}
+	}
+
 	// Record redemption to prevent duplicate usage
 	redemption := &models.VoucherRedemption{
 		VoucherCode: req.VoucherCode,
@@ -521,11 +662,12 @@ func (s *QuotaService) TransferIn(receiver *models.AuthUser, req *TransferInRequ
 		}, nil
 	}
 
-	totalAmount := 0.0
+	totalAmount := decimal.Zero
+	totalTruncated := decimal.Zero
 	successCount := 0
 	quotaResults := make([]TransferQuotaResult, len(voucherData.QuotaList))
-	var earliestExpiryDate time.Time
-	hasValidQuota := false
+	var pools []string
+	poolSeen := make(map[string]bool)
 
 	// Process quota transfer
 	for i, quotaItem := range voucherData.QuotaList {
@@ -534,19 +676,40 @@ func (s *QuotaService) TransferIn(receiver *models.AuthUser, req *TransferInRequ
 		quotaResult := TransferQuotaResult{
 			Amount:     quotaItem.Amount,
 			ExpiryDate: quotaItem.ExpiryDate,
+			Model:      quotaItem.Model,
 			IsExpired:  isExpired,
 			Success:    false,
 		}
+		if !poolSeen[quotaItem.Model] {
+			poolSeen[quotaItem.Model] = true
+			pools = append(pools, quotaItem.Model)
+		}
+
+		// Cut the item to what the balance cap still leaves room for
+		if !isExpired && capDetail != nil {
+			credited := decimal.Min(quotaItem.Amount, capRoom)
+			capRoom = capRoom.Sub(credited)
+			quotaResult.Amount = credited
+			quotaResult.Truncated = quotaItem.Amount.Sub(credited)
+			totalTruncated = totalTruncated.Add(quotaResult.Truncated)
+			quotaItem.Amount = credited
+		}
 
 		// Only process valid quota
-		if !isExpired {
+		if !isExpired && !quotaItem.Amount.IsPositive() {
+			reason := TransferFailureReasonBalanceCap
+			quotaResult.FailureReason = &reason
+		} else if !isExpired {
+			// Received quota follows the global expiry policy, whatever the giver's buckets had,
+			// and stays in the model pool it was given from
 			var existingQuota models.Quota
-			if err := tx.Where("user_id = ? AND expiry_date = ? AND status = ?",
-				receiver.ID, quotaItem.ExpiryDate, models.StatusValid).First(&existingQuota).Error; err != nil {
+			if err := tx.Where("user_id = ? AND model = ? AND expiry_date = ? AND status = ? AND strategy_id IS NULL AND rollover_from IS NULL",
+				receiver.ID, quotaItem.Model, quotaItem.ExpiryDate, models.StatusValid).First(&existingQuota).Error; err != nil {
 				// Create new quota record
 				newQuota := &models.Quota{
 					UserID:     receiver.ID,
 					Amount:     quotaItem.Amount,
+					Model:      quotaItem.Model,
 					ExpiryDate: quotaItem.ExpiryDate,
 					Status:     models.StatusValid,
 				}
@@ -557,30 +720,18 @@ func (s *QuotaService) TransferIn(receiver *models.AuthUser, req *TransferInRequ
 				} else {
 					quotaResult.Success = true
 					successCount++
-					totalAmount += quotaItem.Amount
-
-					// Track earliest expiry date for valid quota
-					if !hasValidQuota || quotaItem.ExpiryDate.Before(earliestExpiryDate) {
-						earliestExpiryDate = quotaItem.ExpiryDate
-						hasValidQuota = true
-					}
+					totalAmount = totalAmount.Add(quotaItem.Amount)
 				}
 			} else {
 				// Update existing quota
-				if err := tx.Model(&existingQuota).Update("amount", existingQuota.Amount+quotaItem.Amount).Error; err != nil {
+				if err := tx.Model(&existingQuota).Update("amount", existingQuota.Amount.Add(quotaItem.Amount)).Error; err != nil {
 					// Individual quota update failed, mark as pending
 					reason := TransferFailureReasonPending
 					quotaResult.FailureReason = &reason
 				} else {
 					quotaResult.Success = true
 					successCount++
-					totalAmount += quotaItem.Amount
-
-					// Track earliest expiry date for valid quota
-					if !hasValidQuota || quotaItem.ExpiryDate.Before(earliestExpiryDate) {
-						earliestExpiryDate = quotaItem.ExpiryDate
-						hasValidQuota = true
-					}
+					totalAmount = totalAmount.Add(quotaItem.Amount)
 				}
 			}
 		} else {
@@ -592,21 +743,54 @@ func (s *QuotaService) TransferIn(receiver *models.AuthUser, req *TransferInRequ
 		quotaResults[i] = quotaResult
 	}
 
-	// Record audit log for valid quota only if there's valid quota
-	if hasValidQuota {
+	// Record one audit log per model pool that received valid quota, each queuing the
+	// AiGateway update of its pool; they are delivered after commit
+	var outboxEntries []*models.GatewayOutbox
+	for _, model := range pools {
+		poolResults := make([]TransferQuotaResult, 0, len(quotaResults))
+		for _, result := range quotaResults {
+			if result.Model == model {
+				poolResults = append(poolResults, result)
+			}
+		}
+
+		poolAmount := decimal.Zero
+		poolTruncated := decimal.Zero
+		poolSuccess := 0
+		var earliestExpiryDate time.Time
+		hasValidQuota := false
+		for _, result := range poolResults {
+			poolTruncated = poolTruncated.Add(result.Truncated)
+			if !result.Success {
+				continue
+			}
+			poolAmount = poolAmount.Add(result.Amount)
+			poolSuccess++
+			// Track earliest expiry date for valid quota
+			if !hasValidQuota || result.ExpiryDate.Before(earliestExpiryDate) {
+				earliestExpiryDate = result.ExpiryDate
+				hasValidQuota = true
+			}
+		}
+		if !hasValidQuota {
+			continue
+		}
+
 		// Prepare detailed audit information
 		expiredCount := 0
 		failedCount := 0
 		auditDetails := &models.QuotaAuditDetails{
 			Operation: models.OperationTransferIn,
-			Items:     make([]models.QuotaAuditDetailItem, len(quotaResults)),
+			Items:     make([]models.QuotaAuditDetailItem, len(poolResults)),
 		}
 
 		// Record each quota item detail
-		for i, result := range quotaResults {
+		for i, result := range poolResults {
 			item := models.QuotaAuditDetailItem{
 				Amount:     result.Amount,
 				ExpiryDate: result.ExpiryDate.Format(time.RFC3339),
+				Model:      result.Model,
+				Truncated:  result.Truncated,
 			}
 
 			if result.IsExpired {
@@ -627,18 +811,25 @@ func (s *QuotaService) TransferIn(receiver *models.AuthUser, req *TransferInRequ
 		}
 
 		auditDetails.Summary = models.QuotaAuditSummary{
-			TotalAmount:        totalAmount,
-			TotalItems:         len(voucherData.QuotaList),
-			SuccessfulItems:    successCount,
+			TotalAmount:        poolAmount,
+			TotalItems:         len(poolResults),
+			SuccessfulItems:    poolSuccess,
 			FailedItems:        failedCount,
 			ExpiredItems:       expiredCount,
 			EarliestExpiryDate: earliestExpiryDate.Format(time.RFC3339),
 		}
+		if poolTruncated.IsPositive() {
+			poolCap := *capDetail
+			poolCap.RequestedAmount = poolAmount.Add(poolTruncated)
+			poolCap.TruncatedAmount = poolTruncated
+			auditDetails.BalanceCap = &poolCap
+		}
 
 		auditRecord := &models.QuotaAudit{
 			UserID:      receiver.ID,
-			Amount:      totalAmount,
+			Amount:      poolAmount,
 			Operation:   models.OperationTransferIn,
+			Model:       model,
 			VoucherCode: req.VoucherCode,
 			RelatedUser: voucherData.GiverID,
 			ExpiryDate:  earliestExpiryDate, // Use earliest expiry date from valid quota
@@ -651,24 +842,25 @@ func (s *QuotaService) TransferIn(receiver *models.AuthUser, req *TransferInRequ
 				Message: "Failed to marshal audit details",
 			}, nil
 		}
-		if err := tx.Create(auditRecord).Error; err != nil {
+		if err := s.createAuditRecord(tx, auditRecord); err != nil {
 			tx.Rollback()
 			return &TransferInResponse{
 				Status:  TransferStatusFailed,
 				Message: "Failed to create audit record",
 			}, nil
 		}
-	}
 
-	// Update AiGateway quota only for valid quota
-	if totalAmount > 0 {
-		if err := s.aiGatewayClient.DeltaQuota(receiver.ID, totalAmount); err != nil {
+		// Queue the AiGateway update only for valid quota
+		entry, err := s.enqueueGatewayMutation(tx, receiver.ID, model, models.OutboxMutationDeltaQuota, poolAmount,
+			models.OperationTransferIn, outboxDedupKey(auditRecord.ID, models.OutboxMutationDeltaQuota))
+		if err != nil {
 			tx.Rollback()
 			return &TransferInResponse{
 				Status:  TransferStatusFailed,
-				Message: "Failed to update AiGateway quota",
+				Message: "Failed to queue AiGateway quota update",
 			}, nil
 		}
+		outboxEntries = append(outboxEntries, entry)
 	}
 
 	// Check and handle GitHub star status if giver has starred projects
@@ -683,7 +875,13 @@ func (s *QuotaService) TransferIn(receiver *models.AuthUser, req *TransferInRequ
 		}
 	}
 
-	tx.Commit()
+	if err := tx.Commit().Error; err != nil {
+		return &TransferInResponse{
+			Status:  TransferStatusFailed,
+			Message: "Failed to commit quota transfer",
+		}, nil
+	}
+	s.deliverOutboxEntries(outboxEntries)
 
 	// Determine overall transfer status
 	var status TransferStatus
@@ -699,6 +897,9 @@ func (s *QuotaService) TransferIn(receiver *models.AuthUser, req *TransferInRequ
 	if successCount == 0 {
 		status = TransferStatusFailed
 		message = "All quota transfers failed"
+	} else if successCount == totalQuotas && totalTruncated.IsPositive() {
+		status = TransferStatusPartialSuccess
+		message = fmt.Sprintf("All quota transfers completed, %s truncated by the balance cap", totalTruncated.StringFixed())
 	} else if successCount == totalQuotas {
 		status = TransferStatusSuccess
 		message = "All quota transfers completed successfully"
@@ -720,19 +921,80 @@ func (s *QuotaService) TransferIn(receiver *models.AuthUser, req *TransferInRequ
 		VoucherCode: req.VoucherCode,
 		Operation:   models.OperationTransferIn,
 		Amount:      totalAmount,
+		Truncated:   totalTruncated,
 		Status:      status,
 		Message:     message,
 	}, nil
 }
 
 // AddQuotaForStrategy adds quota for strategy execution
-func (s *QuotaService) AddQuotaForStrategy(userID string, amount float64, strategyID int, strategyName string) error {
-	// Calculate expiry date (end of this/next month)
+func (s *QuotaService) AddQuotaForStrategy(userID string, amount decimal.Decimal, strategyID int, strategyName string) error {
+	return s.addStrategyGrant(userID, amount, strategyID, strategyName, strategyGrant{operation: models.OperationRecharge})
+}
+
+// AddQuotaForStrategyWithFormula adds strategy quota whose amount came from an amount expression;
+// the formula inputs are recorded in the audit details
+func (s *QuotaService) AddQuotaForStrategyWithFormula(userID string, amount decimal.Decimal, strategyID int, strategyName string, formula *models.AmountFormulaDetail) error {
+	return s.addStrategyGrant(userID, amount, strategyID, strategyName, strategyGrant{operation: models.OperationRecharge, formula: formula})
+}
+
+// AddQuotaForTopup adds quota for a low-balance top-up strategy, audited as TOPUP
+func (s *QuotaService) AddQuotaForTopup(userID string, amount decimal.Decimal, strategyID int, strategyName string, formula *models.AmountFormulaDetail) error {
+	return s.addStrategyGrant(userID, amount, strategyID, strategyName, strategyGrant{operation: models.OperationTopup, formula: formula})
+}
+
+// AddQuotaForDripInstallment releases one drip installment as its own quota bucket,
+// valid for one month from the release. markReleased runs in the grant transaction, so the
+// installment is marked released exactly when its quota is granted.
+func (s *QuotaService) AddQuotaForDripInstallment(userID string, amount decimal.Decimal, strategyID int, strategyName string, sequence, installments int, formula *models.AmountFormulaDetail, markReleased func(tx *gorm.DB) error) error {
 	now := utils.NowInConfigTimezone(s.configManager.GetDirect()).Truncate(time.Second)
-	var expiryDate time.Time
+	return s.addStrategyGrant(userID, amount, strategyID, strategyName, strategyGrant{
+		operation:    models.OperationRecharge,
+		expiryDate:   now.AddDate(0, 1, 0),
+		note:         fmt.Sprintf("installment %d/%d", sequence, installments),
+		formula:      formula,
+		beforeCommit: markReleased,
+	})
+}
+
+// strategyGrant describes how strategy-granted quota is stored and audited
+type strategyGrant struct {
+	operation  string    // audit operation
+	expiryDate time.Time // zero means end of the current month
+	note       string    // appended to the audit detail
+	formula    *models.AmountFormulaDetail
+	pool       *models.DepartmentPool // For POOL_DRAW: the department pool debited for the grant
+
+	beforeCommit func(tx *gorm.DB) error // runs in the grant transaction, an error rolls the grant back
+}
 
-	// Always set to end of current month
-	expiryDate = time.Date(now.Year(), now.Month()+1, 0, 23, 59, 59, 0, now.Location())
+// addStrategyGrant adds strategy-granted quota and records it under the grant's audit operation
+func (s *QuotaService) addStrategyGrant(userID string, amount decimal.Decimal, strategyID int, strategyName string, grant strategyGrant) error {
+	operation := grant.operation
+	expiryDate := grant.expiryDate
+	if expiryDate.IsZero() {
+		// Always set to end of current month
+		now := utils.NowInConfigTimezone(s.configManager.GetDirect()).Truncate(time.Second)
+		expiryDate = time.Date(now.Year(), now.Month()+1, 0, 23, 59, 59, 0, now.Location())
+	}
+
+	target, err := s.loadGrantTarget(strategyID)
+	if err != nil {
+		return err
+	}
+	policyStrategyID := target.policyStrategyID
+
+	// Grants to the "any model" pool pay outstanding credit debt first
+	credit, err := s.loadCredit(s.db.DB, userID)
+	if err != nil {
+		return err
+	}
+	settleDebt := target.model == "" && credit.Debt.IsPositive()
+
+	balanceCap, err := s.effectiveBalanceCap(userID)
+	if err != nil {
+		return err
+	}
 
 	// Start transaction
 	tx := s.db.DB.Begin()
@@ -742,16 +1004,49 @@ func (s *QuotaService) AddQuotaForStrategy(userID string, amount float64, strate
 		}
 	}()
 
+	// Lock the usage cursor before the bucket, in the order usage charging takes them
+	var cursor *models.QuotaUsageCursor
+	if settleDebt || grant.pool != nil || balanceCap != nil {
+		if cursor, err = s.lockUsageCursor(tx, userID, target.model); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// Grants stop at the user's maximum balance, truncated or rejected per policy
+	var capDetail *models.BalanceCapDetail
+	if amount, capDetail, err = s.applyBalanceCap(tx, userID, amount, balanceCap); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Pool draws debit the department pool, the cursor lock keeping concurrent draws of the user apart
+	var poolDetail *models.PoolDetail
+	if grant.pool != nil {
+		if poolDetail, err = s.debitDepartmentPool(tx, userID, amount, grant.pool); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
 	// Add or update quota
 	var quota models.Quota
-	err := tx.Where("user_id = ? AND expiry_date = ? AND status = ?",
-		userID, expiryDate, models.StatusValid).First(&quota).Error
+	query := tx.Where("user_id = ? AND model = ? AND expiry_date = ? AND status = ? AND rollover_from IS NULL",
+		userID, target.model, expiryDate, models.StatusValid)
+	if policyStrategyID != nil {
+		query = query.Where("strategy_id = ?", *policyStrategyID)
+	} else {
+		query = query.Where("strategy_id IS NULL")
+	}
+	err = query.First(&quota).Error
 
 	if err == gorm.ErrRecordNotFound {
 		// Create new quota record
 		quota = models.Quota{
 			UserID:     userID,
 			Amount:     amount,
+			Model:      target.model,
+			StrategyID: policyStrategyID,
 			ExpiryDate: expiryDate,
 			Status:     models.StatusValid,
 		}
@@ -764,7 +1059,7 @@ func (s *QuotaService) AddQuotaForStrategy(userID string, amount float64, strate
 		return fmt.Errorf("failed to query quota: %w", err)
 	} else {
 		// Update existing quota
-		if err := tx.Model(&quota).Update("amount", quota.Amount+amount).Error; err != nil {
+		if err := tx.Model(&quota).Update("amount", quota.Amount.Add(amount)).Error; err != nil {
 			tx.Rollback()
 			return fmt.Errorf("failed to update quota: %w", err)
 		}
@@ -772,7 +1067,7 @@ func (s *QuotaService) AddQuotaForStrategy(userID string, amount float64, strate
 
 	// Prepare detailed audit information for recharge
 	auditDetails := &models.QuotaAuditDetails{
-		Operation: models.OperationRecharge,
+		Operation: operation,
 		Summary: models.QuotaAuditSummary{
 			TotalAmount:        amount,
 			TotalItems:         1,
@@ -783,24 +1078,40 @@ func (s *QuotaService) AddQuotaForStrategy(userID string, amount float64, strate
 			{
 				Amount:        amount,
 				ExpiryDate:    expiryDate.Format(time.RFC3339),
+				Model:         target.model,
 				Status:        models.AuditStatusSuccess,
-				OriginalQuota: quota.Amount - amount, // Before recharge
-				NewQuota:      quota.Amount,          // After recharge
+				OriginalQuota: quota.Amount.Sub(amount), // Before recharge
+				NewQuota:      quota.Amount,             // After recharge
 			},
 		},
 	}
 
+	auditDetails.AmountFormula = grant.formula
+	auditDetails.Pool = poolDetail
+	if capDetail != nil {
+		auditDetails.BalanceCap = capDetail
+		auditDetails.Items[0].Truncated = capDetail.TruncatedAmount
+	}
+
 	// Add strategy information if available
 	if strategyName != "" {
 		auditDetails.Items[0].FailureReason = fmt.Sprintf("Strategy: %s", strategyName)
+		if grant.note != "" {
+			auditDetails.Items[0].FailureReason += ", " + grant.note
+		}
 	}
 
-	// Record audit log only if it's not expired yet
+	// Record audit log only if it's not expired yet; pool draws have no strategy
+	var auditStrategyID *int
+	if strategyID != 0 {
+		auditStrategyID = &strategyID
+	}
 	auditRecord := &models.QuotaAudit{
 		UserID:       userID,
 		Amount:       amount,
-		Operation:    models.OperationRecharge,
-		StrategyID:   &strategyID,
+		Operation:    operation,
+		Model:        target.model,
+		StrategyID:   auditStrategyID,
 		StrategyName: strategyName,
 		ExpiryDate:   expiryDate,
 	}
@@ -808,18 +1119,37 @@ func (s *QuotaService) AddQuotaForStrategy(userID string, amount float64, strate
 		tx.Rollback()
 		return fmt.Errorf("failed to marshal audit details: %w", err)
 	}
-	if err := tx.Create(auditRecord).Error; err != nil {
+	if err := s.createAuditRecord(tx, auditRecord); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to create audit record: %w", err)
 	}
 
-	// Update AiGateway quota
-	if err := s.aiGatewayClient.DeltaQuota(userID, amount); err != nil {
+	if settleDebt {
+		if err := s.settleCreditDebt(tx, cursor, &quota); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if grant.beforeCommit != nil {
+		if err := grant.beforeCommit(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// Queue the AiGateway update in the same transaction, it is delivered after commit
+	outboxEntry, err := s.enqueueGatewayMutation(tx, userID, target.model, models.OutboxMutationDeltaQuota, amount,
+		operation, outboxDedupKey(auditRecord.ID, models.OutboxMutationDeltaQuota))
+	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("failed to update AiGateway quota: %w", err)
+		return err
 	}
 
-	tx.Commit()
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit quota grant: %w", err)
+	}
+	s.deliverOutboxEntries([]*models.GatewayOutbox{outboxEntry})
 	return nil
 }
 
@@ -835,21 +1165,90 @@ func (s *QuotaService) ExpireQuotas() error {
 		// because monthly quota recording failure should not affect the main quota expiry functionality
 	}
 
-	// Step 2: Find expired but still valid quotas (original logic)
-	logger.Info("Step 2: Finding expired but still valid quotas")
-	var expiredQuotas []models.Quota
-	if err := s.db.DB.Where("status = ? AND expiry_date < ?", models.StatusValid, now).Find(&expiredQuotas).Error; err != nil {
+	// Step 2: Expire quotas past their expiry date
+	logger.Info("Step 2: Expiring quotas past their expiry date")
+	return s.expireDueQuotas(now)
+}
+
+// ExpireDueQuotas expires the buckets past their expiry date, without recording monthly
+// used quota. It is run by the expiry sweep, so buckets lapse close to their own expiry time.
+func (s *QuotaService) ExpireDueQuotas() error {
+	now := utils.NowInConfigTimezone(s.configManager.GetDirect()).Truncate(time.Second)
+	return s.expireDueQuotas(now)
+}
+
+// expiryPool identifies the AiGateway counters of a user's model pool
+type expiryPool struct {
+	userID string
+	model  string
+}
+
+// expireDueQuotas expires valid buckets whose expiry date, plus the grace period of their
+// expiry policy, is before now, rolls unused quota over where the policy asks for it, and
+// synchronizes the affected users with AiGateway. Each pool is expired in its own transaction,
+// so a failing user does not hold back or roll back the others.
+func (s *QuotaService) expireDueQuotas(now time.Time) error {
+	var candidates []models.Quota
+	if err := s.db.DB.Where("status = ? AND expiry_date < ?", models.StatusValid, now).Find(&candidates).Error; err != nil {
 		return fmt.Errorf("failed to find expired quotas: %w", err)
 	}
 
-	if len(expiredQuotas) == 0 {
+	if len(candidates) == 0 {
 		return nil
 	}
 
-	// Group by user
-	userQuotaMap := make(map[string]float64)
-	for _, quota := range expiredQuotas {
-		userQuotaMap[quota.UserID] += quota.Amount
+	policies, err := s.loadExpiryPolicies(candidates)
+	if err != nil {
+		return err
+	}
+
+	// Buckets still in their grace period stay valid; group the rest by user and model pool,
+	// each pool having its own AiGateway counters
+	expiring := make(map[int]bool)
+	var pools []expiryPool
+	seen := make(map[expiryPool]bool)
+	for i := range candidates {
+		if !policies[candidates[i].ID].expiresBy(&candidates[i], now) {
+			continue
+		}
+		expiring[candidates[i].ID] = true
+		key := expiryPool{candidates[i].UserID, candidates[i].Model}
+		if !seen[key] {
+			seen[key] = true
+			pools = append(pools, key)
+		}
+	}
+
+	var firstErr error
+	failed := 0
+	for _, pool := range pools {
+		if err := s.expirePool(pool, expiring, policies, now); err != nil {
+			logger.Error("Failed to expire quota pool",
+				zap.String("user_id", pool.userID),
+				zap.String("model", pool.model),
+				zap.Error(err))
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+		}
+	}
+	if firstErr != nil {
+		return fmt.Errorf("failed to expire %d of %d quota pools: %w", failed, len(pools), firstErr)
+	}
+	return nil
+}
+
+// expirePool expires the lapsing buckets of one model pool in a single transaction and
+// delivers the AiGateway mutations after commit. The gateway counters are read before
+// the transaction starts, so no row locks are held across the HTTP calls.
+func (s *QuotaService) expirePool(pool expiryPool, expiring map[int]bool, policies map[int]ExpiryPolicy, now time.Time) error {
+	userID, model := pool.userID, pool.model
+
+	// Get current quota info from AiGateway
+	totalQuota, err := s.aiGatewayClient.QueryQuotaValueForModel(userID, model)
+	if err != nil {
+		return fmt.Errorf("failed to get total quota from AiGateway for user %s: %w", userID, err)
 	}
 
 	// Start transaction
@@ -860,97 +1259,212 @@ func (s *QuotaService) ExpireQuotas() error {
 		}
 	}()
 
-	// Update status to expired
-	if err := tx.Model(&models.Quota{}).
-		Where("status = ? AND expiry_date < ?", models.StatusValid, now).
-		Update("status", models.StatusExpired).Error; err != nil {
+	// Charge usage up to now while the expiring buckets can still absorb it, then
+	// keep only what is left of the buckets that stay valid
+	quotas, usedQuota, err := s.chargeUsage(tx, userID, model)
+	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("failed to update quota status: %w", err)
+		return fmt.Errorf("failed to charge usage for user %s: %w", userID, err)
+	}
+	var validRemaining, expiredAmount decimal.Decimal
+	var lapsing []models.Quota
+	var lapsingIDs []int
+	for i := range quotas {
+		if expiring[quotas[i].ID] {
+			lapsing = append(lapsing, quotas[i])
+			lapsingIDs = append(lapsingIDs, quotas[i].ID)
+			expiredAmount = expiredAmount.Add(quotas[i].Amount)
+			continue
+		}
+		validRemaining = validRemaining.Add(quotas[i].Remaining())
 	}
 
-	// Process each user
-	for userID, expiredAmount := range userQuotaMap {
-		// Get user's remaining valid quota
-		var validQuotaSum float64
-		if err := tx.Model(&models.Quota{}).
-			Where("user_id = ? AND status = ?", userID, models.StatusValid).
-			Select("COALESCE(SUM(amount), 0)").Scan(&validQuotaSum).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to calculate valid quota for user %s: %w", userID, err)
-		}
+	// The buckets were expired by a concurrent run since they were selected
+	if len(lapsing) == 0 {
+		tx.Rollback()
+		return nil
+	}
+
+	// Create audit record for quota expiry
+	auditRecord := &models.QuotaAudit{
+		UserID:     userID,
+		Amount:     expiredAmount.Neg(), // Negative amount for expiry
+		Operation:  models.OperationExpire,
+		Model:      model,
+		ExpiryDate: now, // Use current time as expiry time
+		CreateTime: utils.NowInConfigTimezone(s.configManager.GetDirect()),
+	}
+	if err := s.createAuditRecord(tx, auditRecord); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to create expiry audit record for user %s: %w", userID, err)
+	}
 
-		// Get current quota info from AiGateway
-		totalQuota, err := s.aiGatewayClient.QueryQuotaValue(userID)
+	// Carry unused quota of the lapsing buckets into rollover buckets
+	for i := range lapsing {
+		rolled, err := s.rollOverQuota(tx, &lapsing[i], policies[lapsing[i].ID])
 		if err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to get total quota from AiGateway for user %s: %w", userID, err)
+			return fmt.Errorf("failed to roll over quota %d for user %s: %w", lapsing[i].ID, userID, err)
 		}
+		validRemaining = validRemaining.Add(rolled)
+	}
 
-		usedQuota, err := s.aiGatewayClient.QueryUsedQuotaValue(userID)
+	// The credit line stays on top of the valid quota, less the debt already drawn on it
+	if model == "" {
+		credit, err := s.lockCredit(tx, userID)
 		if err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to get used quota from AiGateway for user %s: %w", userID, err)
+			return err
 		}
-
-		remainingQuota := totalQuota - usedQuota
-
-		// Reset used quota first
-		if err := s.aiGatewayClient.DeltaUsedQuota(userID, -usedQuota); err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to reset used quota for user %s: %w", userID, err)
+		if credit != nil {
+			validRemaining = validRemaining.Add(credit.CreditLimit.Sub(credit.Debt))
 		}
+	}
 
-		// Adjust total quota
-		validQuota := validQuotaSum
-		var newTotalQuota float64
-		if validQuota >= remainingQuota {
-			newTotalQuota = remainingQuota
-		} else {
-			newTotalQuota = validQuota
-		}
+	remainingQuota := totalQuota.Sub(usedQuota)
 
-		deltaQuota := newTotalQuota - totalQuota
-		if deltaQuota != 0 {
-			if err := s.aiGatewayClient.DeltaQuota(userID, deltaQuota); err != nil {
-				tx.Rollback()
-				return fmt.Errorf("failed to adjust total quota for user %s: %w", userID, err)
-			}
-		}
+	// Adjust total quota
+	newTotalQuota := decimal.Min(validRemaining, remainingQuota)
 
-		// Create audit record for quota expiry
-		auditRecord := &models.QuotaAudit{
-			UserID:     userID,
-			Amount:     -expiredAmount, // Negative amount for expiry
-			Operation:  "EXPIRE",
-			ExpiryDate: now, // Use current time as expiry time
-			CreateTime: utils.NowInConfigTimezone(s.configManager.GetDirect()),
-		}
-		if err := tx.Create(auditRecord).Error; err != nil {
+	// Queue the AiGateway updates in the same transaction: reset used quota first,
+	// then adjust total quota; they are delivered after commit
+	var outboxEntries []*models.GatewayOutbox
+	entry, err := s.enqueueGatewayMutation(tx, userID, model, models.OutboxMutationDeltaUsedQuota, usedQuota.Neg(),
+		models.OperationExpire, outboxDedupKey(auditRecord.ID, models.OutboxMutationDeltaUsedQuota))
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to reset used quota for user %s: %w", userID, err)
+	}
+	outboxEntries = append(outboxEntries, entry)
+	if err := s.resetUsageCursor(tx, userID, model, usedQuota.Neg()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	deltaQuota := newTotalQuota.Sub(totalQuota)
+	if !deltaQuota.IsZero() {
+		entry, err := s.enqueueGatewayMutation(tx, userID, model, models.OutboxMutationDeltaQuota, deltaQuota,
+			models.OperationExpire, outboxDedupKey(auditRecord.ID, models.OutboxMutationDeltaQuota))
+		if err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to create expiry audit record for user %s: %w", userID, err)
+			return fmt.Errorf("failed to adjust total quota for user %s: %w", userID, err)
 		}
+		outboxEntries = append(outboxEntries, entry)
 	}
 
-	tx.Commit()
+	// Update status to expired
+	if err := tx.Model(&models.Quota{}).
+		Where("id IN ? AND status = ?", lapsingIDs, models.StatusValid).
+		Update("status", models.StatusExpired).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update quota status: %w", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit quota expiry for user %s: %w", userID, err)
+	}
+	s.deliverOutboxEntries(outboxEntries)
 	return nil
 }
 
-// MergeQuotaRecords merges quota records for the same user and expiry date
+// rollOverQuota carries the unused part of a lapsing bucket into a new bucket as the
+// policy allows, recording a ROLLOVER audit linked to the lapsing bucket. Rollover
+// buckets do not roll over again. It returns the amount carried over.
+func (s *QuotaService) rollOverQuota(tx *gorm.DB, quota *models.Quota, policy ExpiryPolicy) (decimal.Decimal, error) {
+	if quota.RolloverFrom != nil {
+		return decimal.Zero, nil
+	}
+	unused := quota.Remaining()
+	amount := policy.rolloverAmount(unused)
+	if !amount.IsPositive() {
+		return decimal.Zero, nil
+	}
+
+	expiredID := quota.ID
+	rollover := &models.Quota{
+		UserID:       quota.UserID,
+		Amount:       amount,
+		Model:        quota.Model,
+		StrategyID:   quota.StrategyID,
+		RolloverFrom: &expiredID,
+		ExpiryDate:   policy.rolloverExpiry(quota.ExpiryDate),
+		Status:       models.StatusValid,
+	}
+	if err := tx.Create(rollover).Error; err != nil {
+		return decimal.Zero, fmt.Errorf("failed to create rollover quota: %w", err)
+	}
+
+	expiryDate := rollover.ExpiryDate.Format(time.RFC3339)
+	auditDetails := &models.QuotaAuditDetails{
+		Operation: models.OperationRollover,
+		Summary: models.QuotaAuditSummary{
+			TotalAmount:        amount,
+			TotalItems:         1,
+			SuccessfulItems:    1,
+			EarliestExpiryDate: expiryDate,
+		},
+		Items: []models.QuotaAuditDetailItem{
+			{
+				Amount:     amount,
+				ExpiryDate: expiryDate,
+				Model:      quota.Model,
+				Status:     models.AuditStatusSuccess,
+			},
+		},
+		Rollover: &models.RolloverDetail{
+			QuotaID:           quota.ID,
+			ExpiredAmount:     quota.Amount,
+			UnusedAmount:      unused,
+			RolloverPercent:   policy.RolloverPercent,
+			RolloverMaxAmount: policy.RolloverMaxAmount,
+			PolicySource:      policy.Source,
+		},
+	}
+	auditRecord := &models.QuotaAudit{
+		UserID:     quota.UserID,
+		Amount:     amount,
+		Operation:  models.OperationRollover,
+		Model:      quota.Model,
+		StrategyID: quota.StrategyID,
+		ExpiryDate: rollover.ExpiryDate,
+		CreateTime: utils.NowInConfigTimezone(s.configManager.GetDirect()),
+	}
+	if err := auditRecord.MarshalDetails(auditDetails); err != nil {
+		return decimal.Zero, fmt.Errorf("failed to marshal audit details: %w", err)
+	}
+	if err := s.createAuditRecord(tx, auditRecord); err != nil {
+		return decimal.Zero, fmt.Errorf("failed to create rollover audit record: %w", err)
+	}
+
+	logger.Info("Rolled over unused quota",
+		zap.String("user_id", quota.UserID),
+		zap.Int("expired_quota_id", quota.ID),
+		zap.Stringer("unused", unused),
+		zap.Stringer("amount", amount),
+		zap.String("policy", policy.Source))
+	return amount, nil
+}
+
+// MergeQuotaRecords merges valid quota records for the same user, model pool and expiry date.
+// Rollover buckets and the expired buckets they carry quota from stay separate, as do
+// expired buckets, so rollover links and expiry audits keep pointing at existing rows.
 func (s *QuotaService) MergeQuotaRecords() error {
-	// QuotaGroup represents quota records grouped by user and expiry date
+	// QuotaGroup represents quota records grouped by user, expiry date and expiry policy
 	type QuotaGroup struct {
 		UserID      string    `gorm:"column:user_id"`
 		ExpiryDate  time.Time `gorm:"column:expiry_date"`
-		Status      string    `gorm:"column:status"`
-		TotalAmount float64   `gorm:"column:total_amount"`
+		StrategyID  *int      `gorm:"column:strategy_id"`
+		Model       string    `gorm:"column:model"`
 		RecordCount int       `gorm:"column:record_count"`
 	}
 
 	// Find groups with multiple records
 	var groups []QuotaGroup
 	result := s.db.DB.Model(&models.Quota{}).
-		Select("user_id, expiry_date, status, SUM(amount) as total_amount, COUNT(*) as record_count").
-		Group("user_id, expiry_date, status").
+		Select("user_id, expiry_date, strategy_id, model, COUNT(*) as record_count").
+		Where("status = ? AND rollover_from IS NULL", models.StatusValid).
+		Where("id NOT IN (?)", s.db.DB.Model(&models.Quota{}).Select("rollover_from").Where("rollover_from IS NOT NULL")).
+		Group("user_id, expiry_date, strategy_id, model").
 		Having("COUNT(*) > 1").
 		Scan(&groups)
 
@@ -968,29 +1482,71 @@ func (s *QuotaService) MergeQuotaRecords() error {
 
 	// Process each group that has duplicates
 	for _, group := range groups {
+		// Lock the records and sum them again, usage may have been charged since the groups were read
+		var records []models.Quota
+		groupQuery := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND model = ? AND expiry_date = ? AND status = ? AND rollover_from IS NULL",
+				group.UserID, group.Model, group.ExpiryDate, models.StatusValid).
+			Where("id NOT IN (?)", tx.Model(&models.Quota{}).Select("rollover_from").Where("rollover_from IS NOT NULL"))
+		if group.StrategyID != nil {
+			groupQuery = groupQuery.Where("strategy_id = ?", *group.StrategyID)
+		} else {
+			groupQuery = groupQuery.Where("strategy_id IS NULL")
+		}
+		if err := groupQuery.Order("id ASC").Find(&records).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to find duplicate quota records: %w", err)
+		}
+		if len(records) < 2 {
+			continue
+		}
+
+		// Remember the merged records so their consumption log and holds can follow
+		mergedIDs := make([]int, 0, len(records))
+		var totalAmount, totalConsumed decimal.Decimal
+		for _, record := range records {
+			mergedIDs = append(mergedIDs, record.ID)
+			totalAmount = totalAmount.Add(record.Amount)
+			totalConsumed = totalConsumed.Add(record.Consumed)
+		}
+
 		// Delete all existing records for this group
-		if err := tx.Where("user_id = ? AND expiry_date = ? AND status = ?",
-			group.UserID, group.ExpiryDate, group.Status).Delete(&models.Quota{}).Error; err != nil {
+		if err := tx.Where("id IN ?", mergedIDs).Delete(&models.Quota{}).Error; err != nil {
 			tx.Rollback()
 			return fmt.Errorf("failed to delete duplicate quota records: %w", err)
 		}
 
 		// Create a single merged record (only if total amount is positive)
-		if group.TotalAmount > 0 {
+		if totalAmount.IsPositive() {
 			mergedQuota := &models.Quota{
 				UserID:     group.UserID,
-				Amount:     group.TotalAmount,
+				Amount:     totalAmount,
+				Consumed:   totalConsumed,
+				Model:      group.Model,
+				StrategyID: group.StrategyID,
 				ExpiryDate: group.ExpiryDate,
-				Status:     group.Status,
+				Status:     models.StatusValid,
 			}
 			if err := tx.Create(mergedQuota).Error; err != nil {
 				tx.Rollback()
 				return fmt.Errorf("failed to create merged quota record: %w", err)
 			}
+			if err := tx.Model(&models.QuotaConsumption{}).Where("quota_id IN ?", mergedIDs).
+				Update("quota_id", mergedQuota.ID).Error; err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to move quota consumption records: %w", err)
+			}
+			if err := tx.Model(&models.QuotaReservationHold{}).Where("quota_id IN ?", mergedIDs).
+				Update("quota_id", mergedQuota.ID).Error; err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to move quota reservation holds: %w", err)
+			}
 		}
 	}
 
-	tx.Commit()
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit quota merge: %w", err)
+	}
 	return nil
 }
 
@@ -1020,6 +1576,7 @@ func (s *QuotaService) GetUserQuotaAuditRecords(userID string, page, pageSize in
 		auditRecord := QuotaAuditRecord{
 			Amount:       record.Amount,
 			Operation:    record.Operation,
+			Model:        record.Model,
 			VoucherCode:  record.VoucherCode,
 			RelatedUser:  record.RelatedUser,
 			StrategyName: record.StrategyName,
@@ -1056,8 +1613,9 @@ func (s *QuotaService) GetUsersWithValidQuota() ([]string, error) {
 	return userIDs, nil
 }
 
-// recordUserMonthlyUsedQuota records monthly used quota for a single user
-func (s *QuotaService) recordUserMonthlyUsedQuota(userID string, yearMonth string) error {
+// recordUserMonthlyUsedQuota records monthly used quota for a single user, with the range of
+// model cost catalog revisions in effect during the month
+func (s *QuotaService) recordUserMonthlyUsedQuota(userID string, yearMonth string, revisionFrom, revisionTo int) error {
 	// Get user's used quota from aigateway
 	usedQuota, err := s.aiGatewayClient.QueryUsedQuotaValue(userID)
 	if err != nil {
@@ -1068,19 +1626,21 @@ func (s *QuotaService) recordUserMonthlyUsedQuota(userID string, yearMonth strin
 	}
 
 	// Do not record if used quota is 0 or does not exist
-	if usedQuota <= 0 {
+	if !usedQuota.IsPositive() {
 		logger.Info("Skip recording zero or negative used quota",
 			zap.String("user_id", userID),
-			zap.Float64("used_quota", usedQuota))
+			zap.Stringer("used_quota", usedQuota))
 		return nil
 	}
 
 	// Create record
 	record := &models.MonthlyQuotaUsage{
-		UserID:     userID,
-		YearMonth:  yearMonth,
-		UsedQuota:  usedQuota,
-		RecordTime: utils.NowInConfigTimezone(s.configManager.GetDirect()),
+		UserID:              userID,
+		YearMonth:           yearMonth,
+		UsedQuota:           usedQuota,
+		CatalogRevisionFrom: revisionFrom,
+		CatalogRevision:     revisionTo,
+		RecordTime:          utils.NowInConfigTimezone(s.configManager.GetDirect()),
 	}
 
 	// Use ON CONFLICT to handle duplicate records
@@ -1090,15 +1650,17 @@ func (s *QuotaService) recordUserMonthlyUsedQuota(userID string, yearMonth strin
 			if err := s.db.DB.Model(&models.MonthlyQuotaUsage{}).
 				Where("user_id = ? AND year_month = ?", userID, yearMonth).
 				Updates(map[string]interface{}{
-					"used_quota":  usedQuota,
-					"record_time": utils.NowInConfigTimezone(s.configManager.GetDirect()),
+					"used_quota":            usedQuota,
+					"catalog_revision_from": revisionFrom,
+					"catalog_revision":      revisionTo,
+					"record_time":           utils.NowInConfigTimezone(s.configManager.GetDirect()),
 				}).Error; err != nil {
 				return fmt.Errorf("failed to update monthly quota usage for user %s: %w", userID, err)
 			}
 			logger.Info("Updated existing monthly quota usage record",
 				zap.String("user_id", userID),
 				zap.String("year_month", yearMonth),
-				zap.Float64("used_quota", usedQuota))
+				zap.Stringer("used_quota", usedQuota))
 		} else {
 			return fmt.Errorf("failed to create monthly quota usage record for user %s: %w", userID, err)
 		}
@@ -1106,7 +1668,7 @@ func (s *QuotaService) recordUserMonthlyUsedQuota(userID string, yearMonth strin
 		logger.Info("Created monthly quota usage record",
 			zap.String("user_id", userID),
 			zap.String("year_month", yearMonth),
-			zap.Float64("used_quota", usedQuota))
+			zap.Stringer("used_quota", usedQuota))
 	}
 
 	return nil
@@ -1126,6 +1688,11 @@ func (s *QuotaService) SyncQuotasWithAiGateway() error {
 
 	// Step 2: Process each user
 	for _, userID := range userIDs {
+		if err := s.SyncUserConsumption(userID); err != nil {
+			logger.Error("Failed to sync user quota consumption",
+				zap.String("user_id", userID),
+				zap.Error(err))
+		}
 		if err := s.syncUserQuotaWithAiGateway(userID); err != nil {
 			logger.Error("Failed to sync user quota",
 				zap.String("user_id", userID),
@@ -1139,56 +1706,122 @@ func (s *QuotaService) SyncQuotasWithAiGateway() error {
 	return nil
 }
 
-// syncUserQuotaWithAiGateway synchronizes a single user's quota with AiGateway
+// syncUserQuotaWithAiGateway synchronizes a single user's quota with AiGateway, for the
+// "any model" pool and every model pool the user holds quota in
 func (s *QuotaService) syncUserQuotaWithAiGateway(userID string) error {
+	pools, err := s.userModelPools(userID)
+	if err != nil {
+		return err
+	}
+	for _, model := range append([]string{""}, pools...) {
+		if err := s.syncPoolQuotaWithAiGateway(userID, model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncPoolQuotaWithAiGateway synchronizes the quota counter of one model pool with AiGateway
+// Pools with undelivered quota mutations are skipped, since those would be applied on top of the refresh.
+func (s *QuotaService) syncPoolQuotaWithAiGateway(userID, model string) error {
+	var undelivered int64
+	if err := s.db.DB.Model(&models.GatewayOutbox{}).
+		Where("user_id = ? AND model = ? AND mutation = ? AND status IN ?", userID, model, models.OutboxMutationDeltaQuota,
+			[]string{models.OutboxStatusPending, models.OutboxStatusDelivering, models.OutboxStatusFailed}).
+		Count(&undelivered).Error; err != nil {
+		return fmt.Errorf("failed to count undelivered outbox mutations: %w", err)
+	}
+	if undelivered > 0 {
+		logger.Warn("Skipping quota sync, undelivered outbox mutations pending",
+			zap.String("user_id", userID),
+			zap.String("model", model),
+			zap.Int64("undelivered", undelivered))
+		return nil
+	}
+
 	// Step 2.1: Get total quota from AiGateway
-	aigatewayTotalQuota, err := s.aiGatewayClient.QueryQuotaValue(userID)
+	aigatewayTotalQuota, err := s.aiGatewayClient.QueryQuotaValueForModel(userID, model)
 	if err != nil {
 		return fmt.Errorf("failed to get quota from AiGateway: %w", err)
 	}
 
 	// Step 2.2: Get total valid quota from quota table
-	var totalValidQuota float64
+	var totalValidQuota decimal.Decimal
 	if err := s.db.DB.Model(&models.Quota{}).
-		Where("user_id = ? AND status = ?", userID, models.StatusValid).
+		Where("user_id = ? AND model = ? AND status = ?", userID, model, models.StatusValid).
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&totalValidQuota).Error; err != nil {
 		return fmt.Errorf("failed to calculate user valid quota: %w", err)
 	}
 
+	// The "any model" pool's total also holds the user's credit line
+	if model == "" {
+		credit, err := s.loadCredit(s.db.DB, userID)
+		if err != nil {
+			return err
+		}
+		totalValidQuota = totalValidQuota.Add(credit.CreditLimit)
+	}
+
 	// Step 2.3: If a != b, set the user's quota to b using AiGateway refresh interface
-	if aigatewayTotalQuota != totalValidQuota {
+	if !aigatewayTotalQuota.Equal(totalValidQuota) {
 		logger.Warn("Detected quota inconsistency, will sync",
 			zap.String("user_id", userID),
-			zap.Float64("aigateway_quota", aigatewayTotalQuota),
-			zap.Float64("database_quota", totalValidQuota))
+			zap.String("model", model),
+			zap.Stringer("aigateway_quota", aigatewayTotalQuota),
+			zap.Stringer("database_quota", totalValidQuota))
 
 		// Use RefreshQuota for full quota setting
-		if err := s.aiGatewayClient.RefreshQuota(userID, totalValidQuota); err != nil {
+		if err := s.aiGatewayClient.RefreshQuotaForModel(userID, model, totalValidQuota); err != nil {
 			return fmt.Errorf("failed to refresh AiGateway quota: %w", err)
 		}
 
 		logger.Info("Quota sync completed",
 			zap.String("user_id", userID),
-			zap.Float64("original_quota", aigatewayTotalQuota),
-			zap.Float64("new_quota", totalValidQuota))
+			zap.String("model", model),
+			zap.Stringer("original_quota", aigatewayTotalQuota),
+			zap.Stringer("new_quota", totalValidQuota))
 	} else {
 		logger.Info("Quota is consistent, no sync needed",
 			zap.String("user_id", userID),
-			zap.Float64("quota_value", aigatewayTotalQuota))
+			zap.String("model", model),
+			zap.Stringer("quota_value", aigatewayTotalQuota))
 	}
 
 	return nil
 }
 
+// MonthlyUsageRecorded reports whether used quota has been recorded for the given YYYY-MM month
+func (s *QuotaService) MonthlyUsageRecorded(yearMonth string) (bool, error) {
+	var count int64
+	if err := s.db.DB.Model(&models.MonthlyQuotaUsage{}).
+		Where("year_month = ?", yearMonth).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to count monthly quota usage: %w", err)
+	}
+	return count > 0, nil
+}
+
 // recordMonthlyUsedQuota records monthly used quota for all users
 func (s *QuotaService) recordMonthlyUsedQuota(now time.Time) error {
 	logger.Info("Starting to record monthly used quota")
 
-	// Get last month's year-month in YYYY-MM format
-	lastMonth := now.AddDate(0, -1, 0)
+	// Get last month's year-month in YYYY-MM format; stepping back to the last day of the
+	// previous month stays right when the recording runs late in a long month
+	lastMonth := now.AddDate(0, 0, -now.Day())
 	yearMonth := lastMonth.Format("2006-01")
 
+	// The usage was weighted with every catalog revision in effect during the month
+	monthStart := time.Date(lastMonth.Year(), lastMonth.Month(), 1, 0, 0, 0, 0, now.Location())
+	monthEnd := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	revisionFrom, err := catalogRevisionAt(s.db.DB, monthStart)
+	if err != nil {
+		return err
+	}
+	revisionTo, err := catalogRevisionAt(s.db.DB, monthEnd)
+	if err != nil {
+		return err
+	}
+
 	// Get all users with valid quota
 	userIDs, err := s.GetUsersWithValidQuota()
 	if err != nil {
@@ -1199,7 +1832,7 @@ func (s *QuotaService) recordMonthlyUsedQuota(now time.Time) error {
 
 	// Process users in batch
 	for _, userID := range userIDs {
-		err := s.recordUserMonthlyUsedQuota(userID, yearMonth)
+		err := s.recordUserMonthlyUsedQuota(userID, yearMonth, revisionFrom, revisionTo)
 		if err != nil {
 			logger.Error("Failed to record monthly used quota for user",
 				zap.String("user_id", userID),
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/logger"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultAuditVerifyInterval is the audit hash chain verification schedule when none is configured
+	DefaultAuditVerifyInterval = "0 30 2 * * *"
+	// auditChainKeyPurpose derives the audit chain key from the voucher signing key
+	auditChainKeyPurpose = "quota-audit-chain"
+	// auditChainVersion is part of the hashed record, bumped when the canonical form changes
+	auditChainVersion = 1
+)
+
+// auditChainRecord is the canonical form of an audit record covered by its hash. Times are
+// whole Unix seconds, the precision quota_audit stores, and amounts have two decimals.
+type auditChainRecord struct {
+	Version      int    `json:"v"`
+	PrevHash     string `json:"prev_hash"`
+	UserID       string `json:"user_id"`
+	Amount       string `json:"amount"`
+	Operation    string `json:"operation"`
+	Model        string `json:"model"`
+	VoucherCode  string `json:"voucher_code"`
+	RelatedUser  string `json:"related_user"`
+	StrategyID   *int   `json:"strategy_id"`
+	StrategyName string `json:"strategy_name"`
+	ExpiryDate   int64  `json:"expiry_date"`
+	Details      string `json:"details"`
+	CreateTime   int64  `json:"create_time"`
+}
+
+// AuditChainVerification is the result of verifying one user's audit hash chain
+type AuditChainVerification struct {
+	UserID   string                        `json:"user_id"`
+	Records  int64                         `json:"records"`  // records checked
+	Chained  int64                         `json:"chained"`  // records carrying a hash
+	Unsealed int64                         `json:"unsealed"` // records written before chaining started
+	Valid    bool                          `json:"valid"`
+	Breaks   []models.QuotaAuditChainBreak `json:"breaks"`
+}
+
+// auditChainHash computes the keyed hash of a record linked to prevHash
+func (s *QuotaService) auditChainHash(record *models.QuotaAudit, prevHash string) (string, error) {
+	canonical, err := json.Marshal(auditChainRecord{
+		Version:      auditChainVersion,
+		PrevHash:     prevHash,
+		UserID:       record.UserID,
+		Amount:       record.Amount.StringFixed(),
+		Operation:    record.Operation,
+		Model:        record.Model,
+		VoucherCode:  record.VoucherCode,
+		RelatedUser:  record.RelatedUser,
+		StrategyID:   record.StrategyID,
+		StrategyName: record.StrategyName,
+		ExpiryDate:   record.ExpiryDate.Unix(),
+		Details:      record.Details,
+		CreateTime:   record.CreateTime.Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	h := hmac.New(sha256.New, s.voucherSvc.DeriveKey(auditChainKeyPurpose))
+	h.Write(canonical)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// lockAuditChainHead loads the user's audit chain head for update, creating it when missing
+func (s *QuotaService) lockAuditChainHead(tx *gorm.DB, userID string) (*models.QuotaAuditChainHead, error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.QuotaAuditChainHead{UserID: userID}).Error; err != nil {
+		return nil, fmt.Errorf("failed to create audit chain head: %w", err)
+	}
+	var head models.QuotaAuditChainHead
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", userID).First(&head).Error; err != nil {
+		return nil, fmt.Errorf("failed to lock audit chain head: %w", err)
+	}
+	return &head, nil
+}
+
+// createAuditRecord appends an audit record to its user's hash chain. The chain head stays
+// locked until tx ends, so records of one user are chained in commit order.
+func (s *QuotaService) createAuditRecord(tx *gorm.DB, record *models.QuotaAudit) error {
+	head, err := s.lockAuditChainHead(tx, record.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Hash what the columns will hold, they keep whole seconds
+	if record.CreateTime.IsZero() {
+		record.CreateTime = time.Now()
+	}
+	record.CreateTime = record.CreateTime.Truncate(time.Second)
+	record.ExpiryDate = record.ExpiryDate.Truncate(time.Second)
+	record.PrevHash = head.LastHash
+	if record.Hash, err = s.auditChainHash(record, record.PrevHash); err != nil {
+		return err
+	}
+	if err := tx.Create(record).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.QuotaAuditChainHead{}).Where("user_id = ?", record.UserID).
+		Updates(map[string]interface{}{
+			"last_audit_id": record.ID,
+			"last_hash":     record.Hash,
+			"length":        gorm.Expr("length + 1"),
+		}).Error; err != nil {
+		return fmt.Errorf("failed to move audit chain head: %w", err)
+	}
+	return nil
+}
+
+// VerifyAuditChain walks a user's audit records oldest first and reports where the hash
+// chain breaks. Records written before chaining started are counted, not reported.
+func (s *QuotaService) VerifyAuditChain(userID string) (*AuditChainVerification, error) {
+	// Read the bounds before the records: a record and its head commit together, so
+	// records appended while verifying are left for the next run
+	var maxID int
+	if err := s.db.DB.Model(&models.QuotaAudit{}).Where("user_id = ?", userID).
+		Select("COALESCE(MAX(id), 0)").Scan(&maxID).Error; err != nil {
+		return nil, NewDatabaseError("query audit records", err)
+	}
+	var head *models.QuotaAuditChainHead
+	var loaded models.QuotaAuditChainHead
+	err := s.db.DB.Where("user_id = ?", userID).First(&loaded).Error
+	switch {
+	case err == nil:
+		head = &loaded
+		maxID = loaded.LastAuditID
+	case err != gorm.ErrRecordNotFound:
+		return nil, NewDatabaseError("query audit chain head", err)
+	}
+
+	result := &AuditChainVerification{UserID: userID, Breaks: []models.QuotaAuditChainBreak{}}
+	addBreak := func(auditID int, kind, detail string) {
+		result.Breaks = append(result.Breaks, models.QuotaAuditChainBreak{
+			UserID:  userID,
+			AuditID: auditID,
+			Kind:    kind,
+			Detail:  detail,
+		})
+	}
+
+	prevHash := ""
+	lastChainedID := 0
+	cursor := 0
+	for {
+		var records []models.QuotaAudit
+		if err := s.db.DB.Where("user_id = ? AND id > ? AND id <= ?", userID, cursor, maxID).
+			Order("id ASC").Limit(auditExportBatchSize).Find(&records).Error; err != nil {
+			return nil, NewDatabaseError("query audit records", err)
+		}
+
+		for i := range records {
+			record := &records[i]
+			result.Records++
+			if record.Hash == "" {
+				if lastChainedID == 0 {
+					result.Unsealed++
+				} else {
+					addBreak(record.ID, models.AuditChainUnsealed, "record has no hash after the chain started")
+				}
+				continue
+			}
+
+			result.Chained++
+			if record.PrevHash != prevHash {
+				addBreak(record.ID, models.AuditChainLinkMismatch,
+					fmt.Sprintf("prev_hash %q does not match the previous record hash %q", record.PrevHash, prevHash))
+			}
+			expected, err := s.auditChainHash(record, record.PrevHash)
+			if err != nil {
+				return nil, err
+			}
+			if !hmac.Equal([]byte(expected), []byte(record.Hash)) {
+				addBreak(record.ID, models.AuditChainHashMismatch, "record content does not match its hash")
+			}
+			prevHash = record.Hash
+			lastChainedID = record.ID
+		}
+
+		if len(records) < auditExportBatchSize {
+			break
+		}
+		cursor = records[len(records)-1].ID
+	}
+
+	switch {
+	case head == nil && lastChainedID != 0:
+		addBreak(lastChainedID, models.AuditChainHeadMismatch, "chained records exist but the chain head is missing")
+	case head != nil && (head.LastAuditID != lastChainedID || head.LastHash != prevHash):
+		addBreak(head.LastAuditID, models.AuditChainHeadMismatch,
+			fmt.Sprintf("chain head points at record %d, last chained record is %d", head.LastAuditID, lastChainedID))
+	case head != nil && head.Length != result.Chained:
+		addBreak(head.LastAuditID, models.AuditChainHeadMismatch,
+			fmt.Sprintf("chain head counts %d records, found %d", head.Length, result.Chained))
+	}
+
+	result.Valid = len(result.Breaks) == 0
+	return result, nil
+}
+
+// StartAuditChainVerification verifies every user's audit hash chain in the background
+func (s *QuotaService) StartAuditChainVerification() error {
+	if !s.auditVerifyMu.TryLock() {
+		return NewConflictError("an audit chain verification is already running")
+	}
+	go func() {
+		defer s.auditVerifyMu.Unlock()
+		s.verifyAuditChains()
+	}()
+	return nil
+}
+
+// RunScheduledAuditChainVerification verifies every user's audit hash chain for the scheduler
+func (s *QuotaService) RunScheduledAuditChainVerification() {
+	if !s.auditVerifyMu.TryLock() {
+		logger.Warn("Previous audit chain verification still in progress, skipping run")
+		return
+	}
+	defer s.auditVerifyMu.Unlock()
+	s.verifyAuditChains()
+}
+
+// verifyAuditChains verifies the chain of every user with audit records or a chain head
+// and records the breaks found; a break already recorded is kept as first detected
+func (s *QuotaService) verifyAuditChains() {
+	logger.Info("Starting audit chain verification")
+
+	var userIDs []string
+	if err := s.db.DB.Raw("SELECT user_id FROM quota_audit_chain_head UNION SELECT user_id FROM quota_audit ORDER BY user_id").
+		Scan(&userIDs).Error; err != nil {
+		logger.Error("Failed to load users for audit chain verification", zap.Error(err))
+		return
+	}
+
+	var records int64
+	brokenUsers, breaks := 0, 0
+	for _, userID := range userIDs {
+		result, err := s.VerifyAuditChain(userID)
+		if err != nil {
+			logger.Error("Failed to verify audit chain", zap.String("user_id", userID), zap.Error(err))
+			continue
+		}
+		records += result.Records
+		if result.Valid {
+			continue
+		}
+
+		brokenUsers++
+		breaks += len(result.Breaks)
+		logger.Error("Audit chain broken",
+			zap.String("user_id", userID),
+			zap.Int("breaks", len(result.Breaks)),
+			zap.Int("first_audit_id", result.Breaks[0].AuditID),
+			zap.String("first_kind", result.Breaks[0].Kind))
+		if err := s.db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&result.Breaks).Error; err != nil {
+			logger.Error("Failed to record audit chain breaks", zap.String("user_id", userID), zap.Error(err))
+		}
+	}
+
+	logger.Info("Audit chain verification completed",
+		zap.Int("users", len(userIDs)),
+		zap.Int64("records", records),
+		zap.Int("broken_users", brokenUsers),
+		zap.Int("breaks", breaks))
+}
+
+// GetAuditChainBreaks lists recorded audit chain breaks, newest first, optionally for one user
+func (s *QuotaService) GetAuditChainBreaks(userID string, page, pageSize int) ([]models.QuotaAuditChainBreak, int64, error) {
+	query := s.db.DB.Model(&models.QuotaAuditChainBreak{})
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, NewDatabaseError("count audit chain breaks", err)
+	}
+
+	var breaks []models.QuotaAuditChainBreak
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&breaks).Error; err != nil {
+		return nil, 0, NewDatabaseError("query audit chain breaks", err)
+	}
+	return breaks, total, nil
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultAuditSearchLimit is the page size of an audit search when the caller sets none
+	DefaultAuditSearchLimit = 50
+	// MaxAuditSearchLimit bounds a single audit search page
+	MaxAuditSearchLimit = 1000
+	// auditExportBatchSize is how many audit records an export reads per query
+	auditExportBatchSize = 500
+)
+
+// AuditOperations are the operations recorded in the quota audit log
+var AuditOperations = []string{
+	models.OperationRecharge, models.OperationTransferIn, models.OperationTransferOut, models.OperationExpire,
+	models.OperationTopup, models.OperationRollover, models.OperationReserve, models.OperationReserveCommit,
+	models.OperationReserveRelease, models.OperationCreditLimit, models.OperationCreditDraw, models.OperationCreditSettle,
+	models.OperationPoolFund, models.OperationPoolDraw, models.OperationLedgerReplay,
+}
+
+// AuditSearchFilter selects quota audit records across all users; zero fields do not filter
+type AuditSearchFilter struct {
+	UserID       string
+	Operations   []string
+	StrategyID   int
+	StrategyName string
+	RelatedUser  string
+	MinAmount    *decimal.Decimal // inclusive
+	MaxAmount    *decimal.Decimal // inclusive
+	StartTime    *time.Time       // inclusive
+	EndTime      *time.Time       // exclusive
+}
+
+// AuditSearchPage is one keyset page of audit search results, newest first
+type AuditSearchPage struct {
+	Records    []models.QuotaAudit `json:"records"`
+	NextCursor int                 `json:"next_cursor,omitempty"` // pass as cursor for the next page, absent on the last page
+}
+
+// AuditOperationTotal aggregates the filtered audit records of one operation
+type AuditOperationTotal struct {
+	Operation   string          `json:"operation"`
+	Count       int64           `json:"count"`
+	TotalAmount decimal.Decimal `json:"total_amount"`
+	Credited    decimal.Decimal `json:"credited"` // sum of positive amounts
+	Debited     decimal.Decimal `json:"debited"`  // sum of negative amounts
+}
+
+// AuditSearchSummary aggregates all audit records matching a filter
+type AuditSearchSummary struct {
+	Count       int64                 `json:"count"`
+	TotalAmount decimal.Decimal       `json:"total_amount"`
+	Credited    decimal.Decimal       `json:"credited"`
+	Debited     decimal.Decimal       `json:"debited"`
+	Operations  []AuditOperationTotal `json:"operations"`
+}
+
+// ValidateAuditSearchFilter checks the operations and ranges of a filter
+func ValidateAuditSearchFilter(filter *AuditSearchFilter) error {
+	for _, operation := range filter.Operations {
+		known := false
+		for _, candidate := range AuditOperations {
+			if operation == candidate {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return NewValidationFailedError(fmt.Sprintf("unknown operation '%s'", operation))
+		}
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && filter.MinAmount.GreaterThan(*filter.MaxAmount) {
+		return NewValidationFailedError("min_amount must not be greater than max_amount")
+	}
+	if filter.StartTime != nil && filter.EndTime != nil && !filter.StartTime.Before(*filter.EndTime) {
+		return NewValidationFailedError("start_time must be before end_time")
+	}
+	return nil
+}
+
+// auditSearchQuery applies a filter to a quota audit query
+func (s *QuotaService) auditSearchQuery(filter *AuditSearchFilter) *gorm.DB {
+	query := s.db.DB.Model(&models.QuotaAudit{})
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if len(filter.Operations) > 0 {
+		query = query.Where("operation IN ?", filter.Operations)
+	}
+	if filter.StrategyID > 0 {
+		query = query.Where("strategy_id = ?", filter.StrategyID)
+	}
+	if filter.StrategyName != "" {
+		query = query.Where("strategy_name = ?", filter.StrategyName)
+	}
+	if filter.RelatedUser != "" {
+		query = query.Where("related_user = ?", filter.RelatedUser)
+	}
+	if filter.MinAmount != nil {
+		query = query.Where("amount >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		query = query.Where("amount <= ?", *filter.MaxAmount)
+	}
+	if filter.StartTime != nil {
+		query = query.Where("create_time >= ?", *filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		query = query.Where("create_time < ?", *filter.EndTime)
+	}
+	return query
+}
+
+// SearchQuotaAudit returns a page of audit records matching the filter, newest first. Pages
+// are keyed by record ID: cursor is the next_cursor of the previous page, 0 for the first page.
+func (s *QuotaService) SearchQuotaAudit(filter *AuditSearchFilter, cursor, limit int) (*AuditSearchPage, error) {
+	if err := ValidateAuditSearchFilter(filter); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultAuditSearchLimit
+	}
+	if limit > MaxAuditSearchLimit {
+		limit = MaxAuditSearchLimit
+	}
+
+	query := s.auditSearchQuery(filter)
+	if cursor > 0 {
+		query = query.Where("id < ?", cursor)
+	}
+	// One extra record tells whether there is a next page
+	var records []models.QuotaAudit
+	if err := query.Order("id DESC").Limit(limit + 1).Find(&records).Error; err != nil {
+		return nil, NewDatabaseError("search quota audit", err)
+	}
+
+	page := &AuditSearchPage{Records: records}
+	if len(records) > limit {
+		page.Records = records[:limit]
+		page.NextCursor = records[limit-1].ID
+	}
+	return page, nil
+}
+
+// SummarizeQuotaAudit aggregates the audit records matching the filter, overall and per operation
+func (s *QuotaService) SummarizeQuotaAudit(filter *AuditSearchFilter) (*AuditSearchSummary, error) {
+	if err := ValidateAuditSearchFilter(filter); err != nil {
+		return nil, err
+	}
+
+	var totals []AuditOperationTotal
+	if err := s.auditSearchQuery(filter).
+		Select("operation, COUNT(*) AS count, COALESCE(SUM(amount), 0) AS total_amount, " +
+			"COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0) AS credited, " +
+			"COALESCE(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END), 0) AS debited").
+		Group("operation").Order("operation").Scan(&totals).Error; err != nil {
+		return nil, NewDatabaseError("summarize quota audit", err)
+	}
+
+	summary := &AuditSearchSummary{Operations: totals}
+	if summary.Operations == nil {
+		summary.Operations = []AuditOperationTotal{}
+	}
+	for _, total := range totals {
+		summary.Count += total.Count
+		summary.TotalAmount = summary.TotalAmount.Add(total.TotalAmount)
+		summary.Credited = summary.Credited.Add(total.Credited)
+		summary.Debited = summary.Debited.Add(total.Debited)
+	}
+	return summary, nil
+}
+
+// streamQuotaAudit walks the audit records matching the filter newest first, in keyset
+// batches so the cost of a batch does not grow with the size of the export
+func (s *QuotaService) streamQuotaAudit(filter *AuditSearchFilter, fn func(records []models.QuotaAudit) error) error {
+	cursor := 0
+	for {
+		query := s.auditSearchQuery(filter)
+		if cursor > 0 {
+			query = query.Where("id < ?", cursor)
+		}
+		var records []models.QuotaAudit
+		if err := query.Order("id DESC").Limit(auditExportBatchSize).Find(&records).Error; err != nil {
+			return fmt.Errorf("failed to read audit records: %w", err)
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		if err := fn(records); err != nil {
+			return err
+		}
+		if len(records) < auditExportBatchSize {
+			return nil
+		}
+		cursor = records[len(records)-1].ID
+	}
+}
+
+// ExportQuotaAuditCSV writes the audit records matching the filter as CSV, newest first
+func (s *QuotaService) ExportQuotaAuditCSV(filter *AuditSearchFilter, w io.Writer) error {
+	if err := ValidateAuditSearchFilter(filter); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"id", "user_id", "operation", "amount", "model", "strategy_id", "strategy_name",
+		"related_user", "expiry_date", "create_time"}); err != nil {
+		return err
+	}
+
+	err := s.streamQuotaAudit(filter, func(records []models.QuotaAudit) error {
+		for _, record := range records {
+			strategyID := ""
+			if record.StrategyID != nil {
+				strategyID = strconv.Itoa(*record.StrategyID)
+			}
+			if err := writer.Write([]string{
+				strconv.Itoa(record.ID),
+				record.UserID,
+				record.Operation,
+				record.Amount.StringFixed(),
+				record.Model,
+				strategyID,
+				record.StrategyName,
+				record.RelatedUser,
+				record.ExpiryDate.Format(time.RFC3339),
+				record.CreateTime.Format(time.RFC3339),
+			}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ExportQuotaAuditNDJSON writes the audit records matching the filter as newline-delimited JSON, newest first
+func (s *QuotaService) ExportQuotaAuditNDJSON(filter *AuditSearchFilter, w io.Writer) error {
+	if err := ValidateAuditSearchFilter(filter); err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	return s.streamQuotaAudit(filter, func(records []models.QuotaAudit) error {
+		for i := range records {
+			if err := encoder.Encode(&records[i]); err != nil {
+				return err
+			}
+		}
+		if flusher, ok := w.(interface{ Flush() }); ok {
+			flusher.Flush()
+		}
+		return nil
+	})
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Balance cap policies: what happens to a grant that would take a user past the cap
+const (
+	BalanceCapPolicyTruncate = "truncate" // grant what fits, the excess is recorded and dropped
+	BalanceCapPolicyReject   = "reject"   // refuse the whole grant
+)
+
+// BalanceCap is the maximum balance that applies to a user
+type BalanceCap struct {
+	MaxBalance decimal.Decimal `json:"max_balance"`
+	Policy     string          `json:"policy"`
+	Source     string          `json:"source"` // global, user, or department:<name>
+}
+
+// BalanceCapInfo is a user's effective balance cap and current balance
+type BalanceCapInfo struct {
+	UserID  string          `json:"user_id"`
+	Cap     *BalanceCap     `json:"cap"` // nil when the user's balance is not capped
+	Balance decimal.Decimal `json:"balance"`
+}
+
+// SetBalanceCap creates or updates the balance cap of a user or department. A zero
+// max balance exempts the target from the cap; an empty policy follows the global one.
+func (s *QuotaService) SetBalanceCap(targetType, identifier string, maxBalance decimal.Decimal, policy, operator string) (*models.BalanceCapSetting, error) {
+	if identifier == "" {
+		return nil, NewValidationFailedError("target identifier is required")
+	}
+	if maxBalance.IsNegative() {
+		return nil, NewValidationFailedError("max_balance must be >= 0")
+	}
+	if err := validateBalanceCapPolicy(policy); err != nil {
+		return nil, err
+	}
+
+	setting := &models.BalanceCapSetting{
+		TargetType:       targetType,
+		TargetIdentifier: identifier,
+		MaxBalance:       maxBalance,
+		Policy:           policy,
+		Operator:         operator,
+	}
+	if err := s.db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "target_type"}, {Name: "target_identifier"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_balance", "policy", "operator", "update_time"}),
+	}).Create(setting).Error; err != nil {
+		return nil, NewDatabaseError("save balance cap setting", err)
+	}
+
+	var saved models.BalanceCapSetting
+	if err := s.db.DB.Where("target_type = ? AND target_identifier = ?", targetType, identifier).
+		First(&saved).Error; err != nil {
+		return nil, NewDatabaseError("query balance cap setting", err)
+	}
+	return &saved, nil
+}
+
+// DeleteBalanceCap removes a balance cap setting, so the department or global cap applies again
+func (s *QuotaService) DeleteBalanceCap(id int) error {
+	result := s.db.DB.Where("id = ?", id).Delete(&models.BalanceCapSetting{})
+	if result.Error != nil {
+		return NewDatabaseError("delete balance cap setting", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return NewResourceNotFoundError("balance cap setting", fmt.Sprintf("%d", id))
+	}
+	return nil
+}
+
+// GetBalanceCapSettings lists balance cap settings, optionally of one target type
+func (s *QuotaService) GetBalanceCapSettings(targetType string, page, pageSize int) ([]models.BalanceCapSetting, int64, error) {
+	query := s.db.DB.Model(&models.BalanceCapSetting{})
+	if targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, NewDatabaseError("count balance cap settings", err)
+	}
+
+	var settings []models.BalanceCapSetting
+	if err := query.Order("id ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&settings).Error; err != nil {
+		return nil, 0, NewDatabaseError("query balance cap settings", err)
+	}
+	return settings, total, nil
+}
+
+// GetBalanceCapInfo returns a user's effective balance cap and current balance
+func (s *QuotaService) GetBalanceCapInfo(userID string) (*BalanceCapInfo, error) {
+	balanceCap, err := s.effectiveBalanceCap(userID)
+	if err != nil {
+		return nil, NewDatabaseError("resolve balance cap", err)
+	}
+	balance, err := s.ledgerBalance(s.db.DB, userID)
+	if err != nil {
+		return nil, NewDatabaseError("sum balance", err)
+	}
+	return &BalanceCapInfo{UserID: userID, Cap: balanceCap, Balance: balance}, nil
+}
+
+// validateBalanceCapPolicy checks a balance cap policy, empty meaning the default
+func validateBalanceCapPolicy(policy string) error {
+	switch policy {
+	case "", BalanceCapPolicyTruncate, BalanceCapPolicyReject:
+		return nil
+	}
+	return NewValidationFailedError(fmt.Sprintf("invalid balance cap policy '%s', must be truncate or reject", policy))
+}
+
+// effectiveBalanceCap resolves a user's balance cap: their own setting, else the setting of
+// their most specific department that has one, else the global cap. It returns nil when the
+// user's balance is not capped.
+func (s *QuotaService) effectiveBalanceCap(userID string) (*BalanceCap, error) {
+	global := s.configManager.GetDirect().BalanceCap
+	globalPolicy := global.Policy
+	if globalPolicy == "" {
+		globalPolicy = BalanceCapPolicyTruncate
+	}
+	capOf := func(setting *models.BalanceCapSetting, source string) *BalanceCap {
+		if !setting.MaxBalance.IsPositive() {
+			return nil
+		}
+		policy := setting.Policy
+		if policy == "" {
+			policy = globalPolicy
+		}
+		return &BalanceCap{MaxBalance: setting.MaxBalance, Policy: policy, Source: source}
+	}
+
+	var userSetting models.BalanceCapSetting
+	result := s.db.DB.Where("target_type = ? AND target_identifier = ?", models.TargetTypeUser, userID).
+		Limit(1).Find(&userSetting)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query user balance cap: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return capOf(&userSetting, models.TargetTypeUser), nil
+	}
+
+	var departmentSettings int64
+	if err := s.db.DB.Model(&models.BalanceCapSetting{}).
+		Where("target_type = ?", models.TargetTypeDepartment).Count(&departmentSettings).Error; err != nil {
+		return nil, fmt.Errorf("failed to count department balance caps: %w", err)
+	}
+	if departmentSettings > 0 {
+		departments, err := s.userDepartments(userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, department := range departments {
+			var deptSetting models.BalanceCapSetting
+			result := s.db.DB.Where("target_type = ? AND target_identifier = ?", models.TargetTypeDepartment, department).
+				Limit(1).Find(&deptSetting)
+			if result.Error != nil {
+				return nil, fmt.Errorf("failed to query department balance cap: %w", result.Error)
+			}
+			if result.RowsAffected > 0 {
+				return capOf(&deptSetting, models.TargetTypeDepartment+":"+department), nil
+			}
+		}
+	}
+
+	globalMax := global.MaxBalance
+	if !globalMax.IsPositive() {
+		return nil, nil
+	}
+	return &BalanceCap{MaxBalance: globalMax, Policy: globalPolicy, Source: "global"}, nil
+}
+
+// ledgerBalance sums what is left of a user's valid buckets across all model pools
+func (s *QuotaService) ledgerBalance(db *gorm.DB, userID string) (decimal.Decimal, error) {
+	var balance decimal.Decimal
+	if err := db.Model(&models.Quota{}).
+		Where("user_id = ? AND status = ?", userID, models.StatusValid).
+		Select("COALESCE(SUM(amount - consumed), 0)").Scan(&balance).Error; err != nil {
+		return decimal.Zero, fmt.Errorf("failed to sum balance: %w", err)
+	}
+	return balance, nil
+}
+
+// applyBalanceCap cuts a grant to what the user's balance cap leaves room for. It returns the
+// amount to grant and, when the grant was cut, the detail to audit. Grants that do not fit at
+// all, or do not fit whole under the reject policy, fail with a conflict error.
+func (s *QuotaService) applyBalanceCap(tx *gorm.DB, userID string, amount decimal.Decimal, balanceCap *BalanceCap) (decimal.Decimal, *models.BalanceCapDetail, error) {
+	if balanceCap == nil {
+		return amount, nil, nil
+	}
+	balance, err := s.ledgerBalance(tx, userID)
+	if err != nil {
+		return decimal.Zero, nil, err
+	}
+
+	room := balanceCap.MaxBalance.Sub(balance)
+	if room.GreaterThanOrEqual(amount) {
+		return amount, nil, nil
+	}
+	if !room.IsPositive() || balanceCap.Policy == BalanceCapPolicyReject {
+		return decimal.Zero, nil, NewConflictError(fmt.Sprintf("grant of %s exceeds the maximum balance of %s, balance is %s",
+			amount.StringFixed(), balanceCap.MaxBalance.StringFixed(), balance.StringFixed()))
+	}
+	return room, &models.BalanceCapDetail{
+		MaxBalance:      balanceCap.MaxBalance,
+		BalanceBefore:   balance,
+		RequestedAmount: amount,
+		TruncatedAmount: amount.Sub(room),
+		Source:          balanceCap.Source,
+	}, nil
+}
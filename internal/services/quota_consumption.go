@@ -0,0 +1,188 @@
+package services
+
+import (
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+	"quota-manager/pkg/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// pendingUsedDelta sums the used quota mutations of a model pool still on their way to AiGateway.
+// They are already reflected in the usage cursor, so they are added to the gateway reading.
+// Failed mutations were never applied and are left to reconciliation.
+func (s *QuotaService) pendingUsedDelta(tx *gorm.DB, userID, model string) (decimal.Decimal, error) {
+	var pending decimal.Decimal
+	if err := tx.Model(&models.GatewayOutbox{}).
+		Where("user_id = ? AND model = ? AND mutation = ? AND status IN ?", userID, model, models.OutboxMutationDeltaUsedQuota,
+			[]string{models.OutboxStatusPending, models.OutboxStatusDelivering}).
+		Select("COALESCE(SUM(value), 0)").Scan(&pending).Error; err != nil {
+		return decimal.Zero, fmt.Errorf("failed to sum pending used quota mutations: %w", err)
+	}
+	return pending, nil
+}
+
+// lockUsageCursor loads the user's usage cursor of a model pool for update, creating it when missing
+func (s *QuotaService) lockUsageCursor(tx *gorm.DB, userID, model string) (*models.QuotaUsageCursor, error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.QuotaUsageCursor{UserID: userID, Model: model}).Error; err != nil {
+		return nil, fmt.Errorf("failed to create usage cursor: %w", err)
+	}
+	var cursor models.QuotaUsageCursor
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND model = ?", userID, model).First(&cursor).Error; err != nil {
+		return nil, fmt.Errorf("failed to lock usage cursor: %w", err)
+	}
+	return &cursor, nil
+}
+
+// chargeUsage locks the user's valid buckets of a model pool and charges the AiGateway usage
+// recorded since the last call to them, earliest expiry first. The gateway's used quota counter
+// of the pool is read once the cursor is locked, so concurrent callers never charge the same
+// usage twice; it is returned with the buckets, which are ordered by expiry and stay locked
+// until tx ends.
+func (s *QuotaService) chargeUsage(tx *gorm.DB, userID, model string) ([]models.Quota, decimal.Decimal, error) {
+	cursor, err := s.lockUsageCursor(tx, userID, model)
+	if err != nil {
+		return nil, decimal.Zero, err
+	}
+
+	var quotas []models.Quota
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND model = ? AND status = ?", userID, model, models.StatusValid).
+		Order("expiry_date ASC, id ASC").Find(&quotas).Error; err != nil {
+		return nil, decimal.Zero, fmt.Errorf("failed to get quota list: %w", err)
+	}
+
+	usedQuota, err := s.aiGatewayClient.QueryUsedQuotaValueForModel(userID, model)
+	if err != nil {
+		return nil, decimal.Zero, fmt.Errorf("failed to get used quota: %w", err)
+	}
+	pending, err := s.pendingUsedDelta(tx, userID, model)
+	if err != nil {
+		return nil, decimal.Zero, err
+	}
+	effectiveUsed := usedQuota.Add(pending)
+
+	delta := effectiveUsed.Sub(cursor.UsedQuota)
+	if delta.IsNegative() {
+		// The counter moved back without going through the outbox, e.g. a manual reset, or a
+		// reset was applied before its outbox entry was marked delivered. The cursor never
+		// moves back, that would charge the same usage again.
+		logger.Warn("AiGateway used quota is below the usage cursor, leaving the cursor",
+			zap.String("user_id", userID),
+			zap.String("model", model),
+			zap.Stringer("cursor_used", cursor.UsedQuota),
+			zap.Stringer("gateway_used", effectiveUsed))
+		return quotas, usedQuota, nil
+	}
+	if delta.IsZero() {
+		return quotas, usedQuota, nil
+	}
+
+	remaining := delta
+	for i := range quotas {
+		if !remaining.IsPositive() {
+			break
+		}
+		available := quotas[i].Remaining()
+		if !available.IsPositive() {
+			continue
+		}
+		charge := decimal.Min(available, remaining)
+
+		if err := tx.Model(&models.Quota{}).Where("id = ?", quotas[i].ID).
+			Update("consumed", gorm.Expr("consumed + ?", charge)).Error; err != nil {
+			return nil, decimal.Zero, fmt.Errorf("failed to charge quota %d: %w", quotas[i].ID, err)
+		}
+		if err := tx.Create(&models.QuotaConsumption{
+			UserID:    userID,
+			QuotaID:   quotas[i].ID,
+			Amount:    charge,
+			UsedQuota: effectiveUsed,
+		}).Error; err != nil {
+			return nil, decimal.Zero, fmt.Errorf("failed to record quota consumption: %w", err)
+		}
+		quotas[i].Consumed = quotas[i].Consumed.Add(charge)
+		remaining = remaining.Sub(charge)
+	}
+
+	updates := map[string]interface{}{"used_quota": effectiveUsed}
+	if remaining.IsPositive() && model == "" {
+		// Users with a credit line take the rest on credit
+		drawn, err := s.drawCredit(tx, userID, remaining)
+		if err != nil {
+			return nil, decimal.Zero, err
+		}
+		if drawn {
+			remaining = decimal.Zero
+		}
+	}
+	if remaining.IsPositive() {
+		logger.Warn("Usage exceeds the user's valid quota",
+			zap.String("user_id", userID),
+			zap.String("model", model),
+			zap.Stringer("uncharged", remaining))
+		updates["overused"] = gorm.Expr("overused + ?", remaining)
+	}
+	if err := s.moveUsageCursor(tx, cursor, updates); err != nil {
+		return nil, decimal.Zero, err
+	}
+	return quotas, usedQuota, nil
+}
+
+// moveUsageCursor updates a locked cursor, addressed by its full key
+func (s *QuotaService) moveUsageCursor(tx *gorm.DB, cursor *models.QuotaUsageCursor, updates map[string]interface{}) error {
+	if err := tx.Model(&models.QuotaUsageCursor{}).
+		Where("user_id = ? AND model = ?", cursor.UserID, cursor.Model).
+		Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update usage cursor: %w", err)
+	}
+	return nil
+}
+
+// resetUsageCursor moves the cursor of a model pool by the used quota mutation queued in
+// the same transaction, so the reset is not charged as negative usage
+func (s *QuotaService) resetUsageCursor(tx *gorm.DB, userID, model string, deltaUsed decimal.Decimal) error {
+	if err := tx.Model(&models.QuotaUsageCursor{}).Where("user_id = ? AND model = ?", userID, model).
+		Update("used_quota", gorm.Expr("used_quota + ?", deltaUsed)).Error; err != nil {
+		return fmt.Errorf("failed to move usage cursor: %w", err)
+	}
+	return nil
+}
+
+// SyncUserConsumption charges the user's AiGateway usage to their quota buckets, for the
+// "any model" pool and every model pool the user holds quota in
+func (s *QuotaService) SyncUserConsumption(userID string) error {
+	pools, err := s.userModelPools(userID)
+	if err != nil {
+		return err
+	}
+	for _, model := range append([]string{""}, pools...) {
+		if err := s.syncPoolConsumption(userID, model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncPoolConsumption charges the usage of one model pool to its buckets
+func (s *QuotaService) syncPoolConsumption(userID, model string) error {
+	tx := s.db.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if _, _, err := s.chargeUsage(tx, userID, model); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit quota consumption: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,459 @@
+package services
+
+import (
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+	"quota-manager/pkg/logger"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreditInfo is a user's effective credit limit and outstanding debt
+type CreditInfo struct {
+	UserID          string          `json:"user_id"`
+	CreditLimit     decimal.Decimal `json:"credit_limit"`     // effective limit
+	Source          string          `json:"source,omitempty"` // user, or department:<name> the limit comes from
+	GrantedLimit    decimal.Decimal `json:"granted_limit"`    // limit currently added to the AiGateway total
+	OutstandingDebt decimal.Decimal `json:"outstanding_debt"`
+}
+
+// SetUserCreditLimit sets a user's credit limit, 0 removing the user setting so a
+// department limit applies again, and applies it to the user's AiGateway total
+func (s *QuotaService) SetUserCreditLimit(userID string, limit decimal.Decimal, operator string) (*CreditInfo, error) {
+	if err := s.saveCreditLimitSetting(models.TargetTypeUser, userID, limit, operator); err != nil {
+		return nil, err
+	}
+	if err := s.applyCreditLimit(userID, operator); err != nil {
+		return nil, NewDatabaseError("apply credit limit", err)
+	}
+	return s.GetCreditInfo(userID)
+}
+
+// SetDepartmentCreditLimit sets the credit limit of a department's users without a
+// limit of their own, 0 removing it, and applies it to the users known to the department
+func (s *QuotaService) SetDepartmentCreditLimit(department string, limit decimal.Decimal, operator string) (int, error) {
+	if err := s.saveCreditLimitSetting(models.TargetTypeDepartment, department, limit, operator); err != nil {
+		return 0, err
+	}
+
+	userIDs, err := s.departmentUserIDs(department)
+	if err != nil {
+		return 0, NewDatabaseError("list department users", err)
+	}
+	applied := 0
+	for _, userID := range userIDs {
+		if err := s.applyCreditLimit(userID, operator); err != nil {
+			logger.Error("Failed to apply department credit limit",
+				zap.String("department", department),
+				zap.String("user_id", userID),
+				zap.Error(err))
+			continue
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+// GetCreditLimitSettings lists credit limit settings, optionally of one target type
+func (s *QuotaService) GetCreditLimitSettings(targetType string, page, pageSize int) ([]models.CreditLimitSetting, int64, error) {
+	query := s.db.DB.Model(&models.CreditLimitSetting{})
+	if targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, NewDatabaseError("count credit limit settings", err)
+	}
+
+	var settings []models.CreditLimitSetting
+	if err := query.Order("id ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&settings).Error; err != nil {
+		return nil, 0, NewDatabaseError("query credit limit settings", err)
+	}
+	return settings, total, nil
+}
+
+// GetCreditInfo returns a user's effective credit limit and outstanding debt
+func (s *QuotaService) GetCreditInfo(userID string) (*CreditInfo, error) {
+	limit, source, err := s.effectiveCreditLimit(userID)
+	if err != nil {
+		return nil, NewDatabaseError("resolve credit limit", err)
+	}
+	credit, err := s.loadCredit(s.db.DB, userID)
+	if err != nil {
+		return nil, NewDatabaseError("query credit", err)
+	}
+	return &CreditInfo{
+		UserID:          userID,
+		CreditLimit:     limit,
+		Source:          source,
+		GrantedLimit:    credit.CreditLimit,
+		OutstandingDebt: credit.Debt,
+	}, nil
+}
+
+// saveCreditLimitSetting creates, updates or, for a zero limit, deletes a credit limit setting
+func (s *QuotaService) saveCreditLimitSetting(targetType, identifier string, limit decimal.Decimal, operator string) error {
+	if identifier == "" {
+		return NewValidationFailedError("target identifier is required")
+	}
+	if limit.IsNegative() {
+		return NewValidationFailedError("credit_limit must be >= 0")
+	}
+
+	if limit.IsZero() {
+		if err := s.db.DB.Where("target_type = ? AND target_identifier = ?", targetType, identifier).
+			Delete(&models.CreditLimitSetting{}).Error; err != nil {
+			return NewDatabaseError("delete credit limit setting", err)
+		}
+		return nil
+	}
+
+	setting := &models.CreditLimitSetting{
+		TargetType:       targetType,
+		TargetIdentifier: identifier,
+		CreditLimit:      limit,
+		Operator:         operator,
+	}
+	if err := s.db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "target_type"}, {Name: "target_identifier"}},
+		DoUpdates: clause.AssignmentColumns([]string{"credit_limit", "operator", "update_time"}),
+	}).Create(setting).Error; err != nil {
+		return NewDatabaseError("save credit limit setting", err)
+	}
+	return nil
+}
+
+// effectiveCreditLimit resolves a user's credit limit: their own setting, else the setting
+// of their most specific department that has one, else no credit
+func (s *QuotaService) effectiveCreditLimit(userID string) (decimal.Decimal, string, error) {
+	var userSetting models.CreditLimitSetting
+	result := s.db.DB.Where("target_type = ? AND target_identifier = ?", models.TargetTypeUser, userID).
+		Limit(1).Find(&userSetting)
+	if result.Error != nil {
+		return decimal.Zero, "", fmt.Errorf("failed to query user credit limit: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return userSetting.CreditLimit, models.TargetTypeUser, nil
+	}
+
+	var departmentSettings int64
+	if err := s.db.DB.Model(&models.CreditLimitSetting{}).
+		Where("target_type = ?", models.TargetTypeDepartment).Count(&departmentSettings).Error; err != nil {
+		return decimal.Zero, "", fmt.Errorf("failed to count department credit limits: %w", err)
+	}
+	if departmentSettings == 0 {
+		return decimal.Zero, "", nil
+	}
+
+	departments, err := s.userDepartments(userID)
+	if err != nil {
+		return decimal.Zero, "", err
+	}
+	for _, department := range departments {
+		var deptSetting models.CreditLimitSetting
+		result := s.db.DB.Where("target_type = ? AND target_identifier = ?", models.TargetTypeDepartment, department).
+			Limit(1).Find(&deptSetting)
+		if result.Error != nil {
+			return decimal.Zero, "", fmt.Errorf("failed to query department credit limit: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			return deptSetting.CreditLimit, models.TargetTypeDepartment + ":" + department, nil
+		}
+	}
+	return decimal.Zero, "", nil
+}
+
+// userDepartments lists the departments of a user's employee record, most specific first,
+// nil when the user has no employee record
+func (s *QuotaService) userDepartments(userID string) ([]string, error) {
+	var userInfo models.UserInfo
+	if err := s.db.AuthDB.Where("id = ?", userID).Limit(1).Find(&userInfo).Error; err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	if userInfo.EmployeeNumber == "" {
+		return nil, nil
+	}
+	var employee models.EmployeeDepartment
+	result := s.db.DB.Where("employee_number = ?", userInfo.EmployeeNumber).Limit(1).Find(&employee)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query employee department: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	levels := employee.GetDeptFullLevelNamesAsSlice()
+	departments := make([]string, 0, len(levels))
+	for i := len(levels) - 1; i >= 0; i-- {
+		departments = append(departments, levels[i])
+	}
+	return departments, nil
+}
+
+// departmentUserIDs lists the users of the employees in a department
+func (s *QuotaService) departmentUserIDs(department string) ([]string, error) {
+	var employeeNumbers []string
+	if err := s.db.DB.Model(&models.EmployeeDepartment{}).
+		Where("dept_full_level_names LIKE ?", "%"+department+"%").
+		Pluck("employee_number", &employeeNumbers).Error; err != nil {
+		return nil, fmt.Errorf("failed to get employees in department: %w", err)
+	}
+	if len(employeeNumbers) == 0 {
+		return nil, nil
+	}
+
+	var userIDs []string
+	if err := s.db.AuthDB.Model(&models.UserInfo{}).
+		Where("employee_number IN ?", employeeNumbers).Pluck("id", &userIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get department users: %w", err)
+	}
+	return userIDs, nil
+}
+
+// loadCredit reads a user's credit state, zero when the user never had credit
+func (s *QuotaService) loadCredit(db *gorm.DB, userID string) (*models.QuotaCredit, error) {
+	credit := &models.QuotaCredit{UserID: userID}
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(credit).Error; err != nil {
+		return nil, fmt.Errorf("failed to query credit: %w", err)
+	}
+	return credit, nil
+}
+
+// lockCredit loads a user's credit state for update, nil when the user never had credit
+func (s *QuotaService) lockCredit(tx *gorm.DB, userID string) (*models.QuotaCredit, error) {
+	var credit models.QuotaCredit
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).Limit(1).Find(&credit)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to lock credit: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &credit, nil
+}
+
+// applyCreditLimit brings the credit line added to a user's AiGateway total in line with
+// their effective credit limit. The credit line only covers the "any model" pool.
+func (s *QuotaService) applyCreditLimit(userID, operator string) error {
+	limit, source, err := s.effectiveCreditLimit(userID)
+	if err != nil {
+		return err
+	}
+
+	tx := s.db.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	credit, err := s.lockCredit(tx, userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if credit == nil {
+		if limit.IsZero() {
+			tx.Rollback()
+			return nil
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.QuotaCredit{UserID: userID}).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to create credit: %w", err)
+		}
+		if credit, err = s.lockCredit(tx, userID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if limit.Equal(credit.CreditLimit) {
+		tx.Rollback()
+		return nil
+	}
+
+	delta := limit.Sub(credit.CreditLimit)
+	if err := tx.Model(&models.QuotaCredit{}).Where("user_id = ?", userID).
+		Update("credit_limit", limit).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update credit limit: %w", err)
+	}
+
+	now := time.Now()
+	auditRecord := &models.QuotaAudit{
+		UserID:     userID,
+		Amount:     delta,
+		Operation:  models.OperationCreditLimit,
+		ExpiryDate: now,
+	}
+	if err := auditRecord.MarshalDetails(&models.QuotaAuditDetails{
+		Operation: models.OperationCreditLimit,
+		Summary: models.QuotaAuditSummary{
+			TotalAmount:        delta,
+			TotalItems:         1,
+			SuccessfulItems:    1,
+			EarliestExpiryDate: now.Format(time.RFC3339),
+		},
+		Credit: &models.CreditDetail{
+			CreditLimit:   limit,
+			PreviousLimit: credit.CreditLimit,
+			Debt:          credit.Debt,
+			Source:        source,
+			Operator:      operator,
+		},
+	}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := s.createAuditRecord(tx, auditRecord); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to create audit record: %w", err)
+	}
+
+	outboxEntry, err := s.enqueueGatewayMutation(tx, userID, "", models.OutboxMutationDeltaQuota, delta,
+		models.OperationCreditLimit, outboxDedupKey(auditRecord.ID, models.OutboxMutationDeltaQuota))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit credit limit: %w", err)
+	}
+	s.deliverOutboxEntries([]*models.GatewayOutbox{outboxEntry})
+
+	logger.Info("Credit limit applied",
+		zap.String("user_id", userID),
+		zap.Stringer("credit_limit", limit),
+		zap.Stringer("previous_limit", credit.CreditLimit),
+		zap.String("source", source))
+	return nil
+}
+
+// drawCredit records usage of the "any model" pool that no bucket could absorb as debt,
+// when the user has a credit line. It returns false when the user has none, leaving the
+// usage to be recorded as overused. The caller holds the user's usage cursor lock.
+func (s *QuotaService) drawCredit(tx *gorm.DB, userID string, amount decimal.Decimal) (bool, error) {
+	credit, err := s.lockCredit(tx, userID)
+	if err != nil {
+		return false, err
+	}
+	if credit == nil || !credit.CreditLimit.IsPositive() {
+		return false, nil
+	}
+
+	debt := credit.Debt.Add(amount)
+	if err := tx.Model(&models.QuotaCredit{}).Where("user_id = ?", userID).
+		Update("debt", debt).Error; err != nil {
+		return false, fmt.Errorf("failed to record credit draw: %w", err)
+	}
+
+	now := time.Now()
+	auditRecord := &models.QuotaAudit{
+		UserID:     userID,
+		Amount:     amount.Neg(),
+		Operation:  models.OperationCreditDraw,
+		ExpiryDate: now,
+	}
+	if err := auditRecord.MarshalDetails(&models.QuotaAuditDetails{
+		Operation: models.OperationCreditDraw,
+		Summary: models.QuotaAuditSummary{
+			TotalAmount:        amount,
+			TotalItems:         1,
+			SuccessfulItems:    1,
+			EarliestExpiryDate: now.Format(time.RFC3339),
+		},
+		Credit: &models.CreditDetail{
+			CreditLimit: credit.CreditLimit,
+			Debt:        debt,
+		},
+	}); err != nil {
+		return false, err
+	}
+	if err := s.createAuditRecord(tx, auditRecord); err != nil {
+		return false, fmt.Errorf("failed to create audit record: %w", err)
+	}
+
+	if debt.GreaterThan(credit.CreditLimit) {
+		logger.Warn("Debt exceeds the credit limit",
+			zap.String("user_id", userID),
+			zap.Stringer("debt", debt),
+			zap.Stringer("credit_limit", credit.CreditLimit))
+	}
+	return true, nil
+}
+
+// settleCreditDebt pays the user's outstanding debt from a freshly granted bucket of the
+// "any model" pool: the settled part is charged to the bucket as consumed, since the usage
+// behind the debt is already in the AiGateway used quota. The caller holds the usage cursor lock.
+func (s *QuotaService) settleCreditDebt(tx *gorm.DB, cursor *models.QuotaUsageCursor, quota *models.Quota) error {
+	credit, err := s.lockCredit(tx, quota.UserID)
+	if err != nil {
+		return err
+	}
+	if credit == nil || !credit.Debt.IsPositive() {
+		return nil
+	}
+
+	settled := decimal.Min(credit.Debt, quota.Remaining())
+	if !settled.IsPositive() {
+		return nil
+	}
+	debt := credit.Debt.Sub(settled)
+
+	if err := tx.Model(&models.Quota{}).Where("id = ?", quota.ID).
+		Update("consumed", gorm.Expr("consumed + ?", settled)).Error; err != nil {
+		return fmt.Errorf("failed to charge debt settlement to quota %d: %w", quota.ID, err)
+	}
+	quota.Consumed = quota.Consumed.Add(settled)
+	if err := tx.Create(&models.QuotaConsumption{
+		UserID:    quota.UserID,
+		QuotaID:   quota.ID,
+		Amount:    settled,
+		UsedQuota: cursor.UsedQuota,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to record quota consumption: %w", err)
+	}
+	if err := tx.Model(&models.QuotaCredit{}).Where("user_id = ?", quota.UserID).
+		Update("debt", debt).Error; err != nil {
+		return fmt.Errorf("failed to settle debt: %w", err)
+	}
+
+	auditRecord := &models.QuotaAudit{
+		UserID:     quota.UserID,
+		Amount:     settled,
+		Operation:  models.OperationCreditSettle,
+		ExpiryDate: quota.ExpiryDate,
+	}
+	if err := auditRecord.MarshalDetails(&models.QuotaAuditDetails{
+		Operation: models.OperationCreditSettle,
+		Summary: models.QuotaAuditSummary{
+			TotalAmount:        settled,
+			TotalItems:         1,
+			SuccessfulItems:    1,
+			EarliestExpiryDate: quota.ExpiryDate.Format(time.RFC3339),
+		},
+		Credit: &models.CreditDetail{
+			CreditLimit: credit.CreditLimit,
+			Debt:        debt,
+			QuotaID:     quota.ID,
+		},
+	}); err != nil {
+		return err
+	}
+	if err := s.createAuditRecord(tx, auditRecord); err != nil {
+		return fmt.Errorf("failed to create audit record: %w", err)
+	}
+
+	logger.Info("Credit debt settled from recharge",
+		zap.String("user_id", quota.UserID),
+		zap.Int("quota_id", quota.ID),
+		zap.Stringer("settled", settled),
+		zap.Stringer("remaining_debt", debt))
+	return nil
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/internal/utils"
+	"quota-manager/pkg/decimal"
+	"quota-manager/pkg/logger"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultExpirySweepInterval is the expiry sweep schedule when none is configured
+	DefaultExpirySweepInterval = "0 */5 * * * *"
+	// DefaultExpiryWarningInterval is the expiry warning schedule when none is configured
+	DefaultExpiryWarningInterval = "0 0 * * * *"
+)
+
+// DefaultExpiryWarningDays are the days ahead of expiry a notice is raised when none are configured
+var DefaultExpiryWarningDays = []int{7, 1}
+
+// ExpiringQuotaItem is an expiry notice of a bucket that has not expired yet
+type ExpiringQuotaItem struct {
+	Amount        decimal.Decimal `json:"amount"`         // remaining amount when the notice was raised
+	ExpiryDate    time.Time       `json:"expiry_date"`    // bucket expiry
+	DaysBefore    int             `json:"days_before"`    // warning window, e.g. 7 or 1
+	NotifiedTime  time.Time       `json:"notified_time"`  // when the notice was raised
+	RemainingTime string          `json:"remaining_time"` // time left until expiry, e.g. 23h10m0s
+}
+
+// RecordExpiryWarnings raises a notice for every valid bucket that enters one of the
+// warning windows. A bucket gets one notice per window, and only for the narrowest
+// window it falls in, so a bucket found 12 hours before expiry is not also warned
+// about as 7 days ahead.
+func (s *QuotaService) RecordExpiryWarnings(days []int) error {
+	windows := normalizeWarningDays(days)
+	if len(windows) == 0 {
+		return nil
+	}
+	now := utils.NowInConfigTimezone(s.configManager.GetDirect()).Truncate(time.Second)
+	horizon := now.AddDate(0, 0, windows[len(windows)-1])
+
+	// Bring consumption up to date, so the notices carry what is actually left
+	var userIDs []string
+	if err := s.db.DB.Model(&models.Quota{}).
+		Where("status = ? AND expiry_date >= ? AND expiry_date <= ?", models.StatusValid, now, horizon).
+		Distinct("user_id").Find(&userIDs).Error; err != nil {
+		return fmt.Errorf("failed to find users with expiring quota: %w", err)
+	}
+	for _, userID := range userIDs {
+		if err := s.SyncUserConsumption(userID); err != nil {
+			logger.Warn("Failed to sync consumption before expiry warning, using charged usage",
+				zap.String("user_id", userID),
+				zap.Error(err))
+		}
+	}
+
+	var quotas []models.Quota
+	if err := s.db.DB.Where("status = ? AND expiry_date >= ? AND expiry_date <= ?", models.StatusValid, now, horizon).
+		Order("expiry_date ASC, id ASC").Find(&quotas).Error; err != nil {
+		return fmt.Errorf("failed to find expiring quotas: %w", err)
+	}
+
+	created := 0
+	for i := range quotas {
+		remaining := quotas[i].Remaining()
+		if !remaining.IsPositive() {
+			continue
+		}
+
+		window := 0
+		for _, d := range windows {
+			if !quotas[i].ExpiryDate.After(now.AddDate(0, 0, d)) {
+				window = d
+				break
+			}
+		}
+		if window == 0 {
+			continue
+		}
+
+		notice := &models.QuotaExpiryNotice{
+			UserID:     quotas[i].UserID,
+			QuotaID:    quotas[i].ID,
+			DaysBefore: window,
+			Amount:     remaining,
+			ExpiryDate: quotas[i].ExpiryDate,
+		}
+		result := s.db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(notice)
+		if result.Error != nil {
+			return fmt.Errorf("failed to record expiry notice for quota %d: %w", quotas[i].ID, result.Error)
+		}
+		created += int(result.RowsAffected)
+	}
+
+	logger.Info("Expiry warnings recorded",
+		zap.Int("expiring_buckets", len(quotas)),
+		zap.Int("notices_created", created))
+	return nil
+}
+
+// GetExpiringQuotas lists the expiry notices of the user's buckets that are still valid,
+// soonest expiry first
+func (s *QuotaService) GetExpiringQuotas(userID string, page, pageSize int) ([]ExpiringQuotaItem, int64, error) {
+	now := utils.NowInConfigTimezone(s.configManager.GetDirect())
+	query := s.db.DB.Model(&models.QuotaExpiryNotice{}).
+		Joins("JOIN quota ON quota.id = quota_expiry_notice.quota_id").
+		Where("quota_expiry_notice.user_id = ? AND quota.status = ? AND quota.expiry_date >= ?",
+			userID, models.StatusValid, now)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, NewDatabaseError("count expiry notices", err)
+	}
+
+	var notices []models.QuotaExpiryNotice
+	if err := query.Select("quota_expiry_notice.*").
+		Order("quota_expiry_notice.expiry_date ASC, quota_expiry_notice.days_before ASC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&notices).Error; err != nil {
+		return nil, 0, NewDatabaseError("query expiry notices", err)
+	}
+
+	items := make([]ExpiringQuotaItem, len(notices))
+	for i, notice := range notices {
+		items[i] = ExpiringQuotaItem{
+			Amount:        notice.Amount,
+			ExpiryDate:    notice.ExpiryDate,
+			DaysBefore:    notice.DaysBefore,
+			NotifiedTime:  notice.CreateTime,
+			RemainingTime: notice.ExpiryDate.Sub(now).Truncate(time.Minute).String(),
+		}
+	}
+	return items, total, nil
+}
+
+// normalizeWarningDays drops non-positive and duplicate windows and sorts them ascending
+func normalizeWarningDays(days []int) []int {
+	seen := make(map[int]bool)
+	windows := make([]int, 0, len(days))
+	for _, d := range days {
+		if d > 0 && !seen[d] {
+			seen[d] = true
+			windows = append(windows, d)
+		}
+	}
+	sort.Ints(windows)
+	return windows
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"fmt"
+	"quota-manager/internal/config"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+	"quota-manager/pkg/logger"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// MaxGracePeriod bounds how long quota may stay usable past its expiry date
+const MaxGracePeriod = 90 * 24 * time.Hour
+
+// Expiry policy sources recorded on rollover audits
+const (
+	ExpiryPolicySourceGlobal   = "global"
+	ExpiryPolicySourceStrategy = "strategy"
+)
+
+// ExpiryPolicy decides what happens to a bucket at its expiry date
+type ExpiryPolicy struct {
+	RolloverPercent   float64         // share of the unused amount carried into a new bucket
+	RolloverMaxAmount decimal.Decimal // cap on the carried amount, 0 = no cap
+	RolloverMonths    int             // months the rollover bucket stays valid
+	GracePeriod       time.Duration   // time the bucket stays usable past its expiry date
+	Source            string          // global or strategy
+}
+
+// rolls reports whether the policy carries unused quota into a new bucket
+func (p ExpiryPolicy) rolls() bool {
+	return p.RolloverPercent > 0 || p.RolloverMaxAmount.IsPositive()
+}
+
+// rolloverAmount returns the part of the unused amount carried over, in whole cents.
+// A cap without a percentage carries up to the cap.
+func (p ExpiryPolicy) rolloverAmount(unused decimal.Decimal) decimal.Decimal {
+	if !p.rolls() || !unused.IsPositive() {
+		return decimal.Zero
+	}
+	amount := unused
+	if p.RolloverPercent > 0 {
+		amount = unused.MulTrunc(p.RolloverPercent / 100)
+	}
+	if p.RolloverMaxAmount.IsPositive() && amount.GreaterThan(p.RolloverMaxAmount) {
+		amount = p.RolloverMaxAmount
+	}
+	return amount
+}
+
+// rolloverExpiry returns the expiry of a rollover bucket: the end of the month
+// RolloverMonths after the month the expired bucket lapsed in
+func (p ExpiryPolicy) rolloverExpiry(expired time.Time) time.Time {
+	months := p.RolloverMonths
+	if months <= 0 {
+		months = 1
+	}
+	return time.Date(expired.Year(), expired.Month()+time.Month(months)+1, 0, 23, 59, 59, 0, expired.Location())
+}
+
+// expiresBy reports whether the bucket lapses at now, taking the grace period into account
+func (p ExpiryPolicy) expiresBy(quota *models.Quota, now time.Time) bool {
+	return quota.ExpiryDate.Add(p.GracePeriod).Before(now)
+}
+
+// HasExpiryPolicy reports whether the strategy overrides the global expiry policy
+func HasExpiryPolicy(strategy *models.QuotaStrategy) bool {
+	return strategy.RolloverPercent > 0 || strategy.RolloverMaxAmount.IsPositive() ||
+		strategy.RolloverMonths > 0 || strategy.GracePeriod != ""
+}
+
+// ValidateExpiryPolicy checks the rollover and grace period settings of a strategy
+func ValidateExpiryPolicy(strategy *models.QuotaStrategy) error {
+	if strategy.RolloverPercent < 0 || strategy.RolloverPercent > 100 {
+		return fmt.Errorf("rollover_percent must be between 0 and 100")
+	}
+	if strategy.RolloverMaxAmount.IsNegative() {
+		return fmt.Errorf("rollover_max_amount must be >= 0")
+	}
+	if strategy.RolloverMonths < 0 || strategy.RolloverMonths > 12 {
+		return fmt.Errorf("rollover_months must be between 0 and 12")
+	}
+	if _, err := parseGracePeriod(strategy.GracePeriod); err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseGracePeriod parses a grace period duration, empty meaning none
+func parseGracePeriod(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	grace, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid grace_period '%s': %w", value, err)
+	}
+	if grace < 0 || grace > MaxGracePeriod {
+		return 0, fmt.Errorf("grace_period must be between 0 and %s", MaxGracePeriod)
+	}
+	return grace, nil
+}
+
+// strategyExpiryPolicy returns the expiry policy defined on a strategy
+func strategyExpiryPolicy(strategy *models.QuotaStrategy) ExpiryPolicy {
+	// Strategies are validated on save, an invalid grace period counts as none
+	grace, _ := parseGracePeriod(strategy.GracePeriod)
+	return ExpiryPolicy{
+		RolloverPercent:   strategy.RolloverPercent,
+		RolloverMaxAmount: strategy.RolloverMaxAmount,
+		RolloverMonths:    strategy.RolloverMonths,
+		GracePeriod:       grace,
+		Source:            ExpiryPolicySourceStrategy,
+	}
+}
+
+// globalExpiryPolicy returns the configured expiry policy
+func globalExpiryPolicy(cfg *config.Config) ExpiryPolicy {
+	policy := ExpiryPolicy{Source: ExpiryPolicySourceGlobal}
+	if cfg == nil {
+		return policy
+	}
+	grace, err := parseGracePeriod(cfg.QuotaExpiry.GracePeriod)
+	if err != nil {
+		logger.Warn("Ignoring invalid quota_expiry.grace_period", zap.Error(err))
+	}
+	policy.RolloverPercent = cfg.QuotaExpiry.RolloverPercent
+	policy.RolloverMaxAmount = cfg.QuotaExpiry.RolloverMaxAmount
+	policy.RolloverMonths = cfg.QuotaExpiry.RolloverMonths
+	policy.GracePeriod = grace
+	return policy
+}
+
+// loadExpiryPolicies returns the expiry policy of every bucket, keyed by bucket ID.
+// Buckets without an originating strategy, or whose strategy was deleted or dropped
+// its policy, follow the global policy.
+func (s *QuotaService) loadExpiryPolicies(quotas []models.Quota) (map[int]ExpiryPolicy, error) {
+	global := globalExpiryPolicy(s.configManager.GetDirect())
+
+	strategyIDs := make([]int, 0)
+	seen := make(map[int]bool)
+	for i := range quotas {
+		if id := quotas[i].StrategyID; id != nil && !seen[*id] {
+			seen[*id] = true
+			strategyIDs = append(strategyIDs, *id)
+		}
+	}
+
+	byStrategy := make(map[int]ExpiryPolicy)
+	if len(strategyIDs) > 0 {
+		var strategies []models.QuotaStrategy
+		if err := s.db.DB.Where("id IN ?", strategyIDs).Find(&strategies).Error; err != nil {
+			return nil, fmt.Errorf("failed to load strategy expiry policies: %w", err)
+		}
+		for i := range strategies {
+			if HasExpiryPolicy(&strategies[i]) {
+				byStrategy[strategies[i].ID] = strategyExpiryPolicy(&strategies[i])
+			}
+		}
+	}
+
+	policies := make(map[int]ExpiryPolicy, len(quotas))
+	for i := range quotas {
+		policy := global
+		if id := quotas[i].StrategyID; id != nil {
+			if strategyPolicy, ok := byStrategy[*id]; ok {
+				policy = strategyPolicy
+			}
+		}
+		policies[quotas[i].ID] = policy
+	}
+	return policies, nil
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/logger"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultIdempotencyPurgeInterval is the idempotency key purge schedule when none is configured
+	DefaultIdempotencyPurgeInterval = "0 30 3 * * *"
+	// IdempotencyKeyTTL is how long the result of a keyed request is replayed
+	IdempotencyKeyTTL = 24 * time.Hour
+	// IdempotencyProcessingLease is how long a claim may stay processing. A claim left behind
+	// by a crashed request is reclaimed by the next retry once its lease has run out.
+	IdempotencyProcessingLease = 2 * time.Minute
+	// MaxIdempotencyKeyLength bounds the Idempotency-Key header
+	MaxIdempotencyKeyLength = 255
+)
+
+// HashIdempotentRequest fingerprints a request body so a key cannot be reused for a different request
+func HashIdempotentRequest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// BeginIdempotentRequest claims an idempotency key for the user and endpoint. It returns
+// the stored record and true when the request was already completed and its result must
+// be replayed; otherwise the caller runs the request and completes or releases the claim.
+func (s *QuotaService) BeginIdempotentRequest(userID, endpoint, key, requestHash string) (*models.IdempotencyKey, bool, error) {
+	if len(key) > MaxIdempotencyKeyLength {
+		return nil, false, NewValidationFailedError(fmt.Sprintf("Idempotency-Key must be at most %d characters", MaxIdempotencyKeyLength))
+	}
+
+	// A second attempt is needed when an expired record or a stale claim has to be replaced
+	for attempt := 0; attempt < 2; attempt++ {
+		record := &models.IdempotencyKey{
+			UserID:      userID,
+			Endpoint:    endpoint,
+			Key:         key,
+			RequestHash: requestHash,
+			Status:      models.IdempotencyStatusProcessing,
+		}
+		result := s.db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return nil, false, NewDatabaseError("claim idempotency key", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			return record, false, nil
+		}
+
+		var existing models.IdempotencyKey
+		if err := s.db.DB.Where("user_id = ? AND endpoint = ? AND idempotency_key = ?", userID, endpoint, key).
+			First(&existing).Error; err != nil {
+			return nil, false, NewDatabaseError("load idempotency key", err)
+		}
+
+		if time.Since(existing.CreateTime) > IdempotencyKeyTTL {
+			if err := s.db.DB.Delete(&existing).Error; err != nil {
+				return nil, false, NewDatabaseError("delete expired idempotency key", err)
+			}
+			continue
+		}
+		if existing.RequestHash != requestHash {
+			return nil, false, NewConflictError("Idempotency-Key was already used with a different request")
+		}
+		if existing.Status != models.IdempotencyStatusCompleted {
+			if time.Since(existing.UpdateTime) <= IdempotencyProcessingLease {
+				return nil, false, NewConflictError("a request with this Idempotency-Key is still being processed")
+			}
+			// The claim outlived its lease, its request never completed. Only one retry
+			// deletes it; the others find the new claim and get a conflict.
+			if err := s.db.DB.Where("id = ? AND status = ? AND update_time = ?",
+				existing.ID, models.IdempotencyStatusProcessing, existing.UpdateTime).
+				Delete(&models.IdempotencyKey{}).Error; err != nil {
+				return nil, false, NewDatabaseError("reclaim stale idempotency key", err)
+			}
+			logger.Warn("Reclaimed stale idempotency key",
+				zap.String("user_id", userID),
+				zap.String("endpoint", endpoint),
+				zap.Time("claimed_at", existing.UpdateTime))
+			continue
+		}
+		return &existing, true, nil
+	}
+
+	return nil, false, NewConflictError("Idempotency-Key is being reused concurrently")
+}
+
+// CompleteIdempotentRequest stores the result to replay for later requests with the same key
+func (s *QuotaService) CompleteIdempotentRequest(record *models.IdempotencyKey, responseCode int, responseBody []byte) error {
+	if err := s.db.DB.Model(record).Updates(map[string]interface{}{
+		"status":        models.IdempotencyStatusCompleted,
+		"response_code": responseCode,
+		"response_body": string(responseBody),
+	}).Error; err != nil {
+		return NewDatabaseError("complete idempotency key", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotentRequest drops a claim whose request failed on the server side,
+// so the client can retry it with the same key
+func (s *QuotaService) ReleaseIdempotentRequest(record *models.IdempotencyKey) error {
+	if err := s.db.DB.Delete(record).Error; err != nil {
+		return NewDatabaseError("release idempotency key", err)
+	}
+	return nil
+}
+
+// PurgeExpiredIdempotencyKeys deletes keys whose results are no longer replayed
+func (s *QuotaService) PurgeExpiredIdempotencyKeys() {
+	result := s.db.DB.Where("create_time < ?", time.Now().Add(-IdempotencyKeyTTL)).Delete(&models.IdempotencyKey{})
+	if result.Error != nil {
+		logger.Error("Failed to purge expired idempotency keys", zap.Error(result.Error))
+		return
+	}
+	if result.RowsAffected > 0 {
+		logger.Info("Purged expired idempotency keys", zap.Int64("count", result.RowsAffected))
+	}
+}
@@ -0,0 +1,698 @@
+package services
+
+import (
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/internal/utils"
+	"quota-manager/pkg/decimal"
+	"quota-manager/pkg/logger"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ledger replay modes
+const (
+	ReplayModeDryRun = "dry_run"
+	ReplayModeApply  = "apply"
+)
+
+// Bucket changes a replay makes to the quota table
+const (
+	ReplayActionCreate = "create"
+	ReplayActionUpdate = "update"
+	ReplayActionDelete = "delete"
+)
+
+// ReplayBucket is a valid quota bucket rebuilt from the audit log
+type ReplayBucket struct {
+	Model        string          `json:"model,omitempty"`
+	ExpiryDate   time.Time       `json:"expiry_date"`
+	StrategyID   *int            `json:"strategy_id,omitempty"`   // expiry policy of the bucket
+	RolloverFrom *int            `json:"rollover_from,omitempty"` // expired bucket a rollover came from
+	Amount       decimal.Decimal `json:"amount"`
+}
+
+// key identifies the bucket the way grants, transfers and rollovers look buckets up
+func (b *ReplayBucket) key() string {
+	return replayBucketKey(b.Model, b.ExpiryDate, b.StrategyID, b.RolloverFrom)
+}
+
+// replayBucketKey builds the key of a bucket from its identifying columns
+func replayBucketKey(model string, expiryDate time.Time, strategyID, rolloverFrom *int) string {
+	strategy, rollover := 0, 0
+	if strategyID != nil {
+		strategy = *strategyID
+	}
+	if rolloverFrom != nil {
+		rollover = *rolloverFrom
+	}
+	return fmt.Sprintf("%s|%d|%d|%d", model, expiryDate.Unix(), strategy, rollover)
+}
+
+// BalanceAt is a user's quota balance at a point in time, rebuilt from the audit log
+type BalanceAt struct {
+	UserID   string          `json:"user_id"`
+	At       time.Time       `json:"at"`
+	Balance  decimal.Decimal `json:"balance"` // sum of the valid buckets
+	Buckets  []ReplayBucket  `json:"buckets"` // valid buckets with quota left, earliest expiry first
+	Records  int             `json:"records"` // audit records replayed
+	Warnings []string        `json:"warnings,omitempty"`
+}
+
+// ReplayBucketChange is one difference between the quota table and the replayed buckets
+type ReplayBucketChange struct {
+	Action         string          `json:"action"` // create/update/delete
+	QuotaID        int             `json:"quota_id,omitempty"`
+	Model          string          `json:"model,omitempty"`
+	ExpiryDate     time.Time       `json:"expiry_date"`
+	StrategyID     *int            `json:"strategy_id,omitempty"`
+	RolloverFrom   *int            `json:"rollover_from,omitempty"`
+	CurrentAmount  decimal.Decimal `json:"current_amount"`
+	ReplayedAmount decimal.Decimal `json:"replayed_amount"`
+}
+
+// UserReplayResult is the replay of one user's audit log against their quota rows
+type UserReplayResult struct {
+	UserID   string               `json:"user_id"`
+	Records  int                  `json:"records"`
+	Changes  []ReplayBucketChange `json:"changes"`
+	Warnings []string             `json:"warnings,omitempty"`
+	Error    string               `json:"error,omitempty"`
+}
+
+// LedgerReplayReport is the result of replaying the audit log of one user or everyone
+type LedgerReplayReport struct {
+	Mode         string             `json:"mode"`
+	TotalUsers   int                `json:"total_users"`
+	ChangedUsers int                `json:"changed_users"`
+	FailedUsers  int                `json:"failed_users"`
+	Users        []UserReplayResult `json:"users"` // users with changes, warnings or errors
+}
+
+// ledgerReplay rebuilds the valid buckets of one user by applying audit records in order
+type ledgerReplay struct {
+	service  *QuotaService
+	buckets  []*ReplayBucket // valid buckets in creation order
+	policies map[int]*int    // granting strategy -> expiry policy of its buckets
+	records  int
+	warnings []string
+}
+
+// newLedgerReplay creates an empty replay
+func (s *QuotaService) newLedgerReplay() *ledgerReplay {
+	return &ledgerReplay{service: s, policies: make(map[int]*int)}
+}
+
+// warn records an audit record the replay could not apply exactly
+func (r *ledgerReplay) warn(record *models.QuotaAudit, format string, args ...interface{}) {
+	r.warnings = append(r.warnings, fmt.Sprintf("audit %d (%s): ", record.ID, record.Operation)+fmt.Sprintf(format, args...))
+}
+
+// find returns the first valid bucket with the key, nil when there is none
+func (r *ledgerReplay) find(key string) *ReplayBucket {
+	for _, bucket := range r.buckets {
+		if bucket.key() == key {
+			return bucket
+		}
+	}
+	return nil
+}
+
+// credit adds quota to the bucket with the given identity, creating it when missing
+func (r *ledgerReplay) credit(model string, expiryDate time.Time, strategyID, rolloverFrom *int, amount decimal.Decimal) {
+	if bucket := r.find(replayBucketKey(model, expiryDate, strategyID, rolloverFrom)); bucket != nil {
+		bucket.Amount = bucket.Amount.Add(amount)
+		return
+	}
+	r.buckets = append(r.buckets, &ReplayBucket{
+		Model:        model,
+		ExpiryDate:   expiryDate,
+		StrategyID:   strategyID,
+		RolloverFrom: rolloverFrom,
+		Amount:       amount,
+	})
+}
+
+// debit takes quota from the buckets of a pool and expiry date, in creation order, and
+// returns what could not be taken
+func (r *ledgerReplay) debit(model string, expiryDate time.Time, amount decimal.Decimal) decimal.Decimal {
+	for _, bucket := range r.buckets {
+		if !amount.IsPositive() {
+			break
+		}
+		if bucket.Model != model || !bucket.ExpiryDate.Equal(expiryDate) || !bucket.Amount.IsPositive() {
+			continue
+		}
+		take := decimal.Min(bucket.Amount, amount)
+		bucket.Amount = bucket.Amount.Sub(take)
+		amount = amount.Sub(take)
+	}
+	return amount
+}
+
+// policyOf resolves the expiry policy a strategy's grants are stored under
+func (r *ledgerReplay) policyOf(record *models.QuotaAudit) (*int, error) {
+	if record.StrategyID == nil || *record.StrategyID == 0 {
+		return nil, nil
+	}
+	strategyID := *record.StrategyID
+	if policy, ok := r.policies[strategyID]; ok {
+		return policy, nil
+	}
+	target, err := r.service.loadGrantTarget(strategyID)
+	if err != nil {
+		return nil, err
+	}
+	r.policies[strategyID] = target.policyStrategyID
+	return target.policyStrategyID, nil
+}
+
+// items returns the detail items of a record, or a single item built from the record
+// for records written before details were kept
+func (r *ledgerReplay) items(record *models.QuotaAudit) []models.QuotaAuditDetailItem {
+	details, err := record.UnmarshalDetails()
+	if err == nil && details != nil && len(details.Items) > 0 {
+		return details.Items
+	}
+	if err != nil {
+		r.warn(record, "unreadable details, replayed from the record amount: %v", err)
+	}
+	return []models.QuotaAuditDetailItem{{
+		Amount:     record.Amount.Abs(),
+		ExpiryDate: record.ExpiryDate.Format(time.RFC3339),
+		Model:      record.Model,
+		Status:     models.AuditStatusSuccess,
+	}}
+}
+
+// itemTarget reads the pool and expiry date of a detail item
+func (r *ledgerReplay) itemTarget(record *models.QuotaAudit, item *models.QuotaAuditDetailItem) (string, time.Time, bool) {
+	expiryDate, err := time.Parse(time.RFC3339, item.ExpiryDate)
+	if err != nil {
+		r.warn(record, "invalid item expiry date %q", item.ExpiryDate)
+		return "", time.Time{}, false
+	}
+	model := item.Model
+	if model == "" {
+		model = record.Model
+	}
+	return model, expiryDate, true
+}
+
+// apply replays one audit record onto the buckets
+func (r *ledgerReplay) apply(record *models.QuotaAudit) error {
+	r.records++
+	switch record.Operation {
+	case models.OperationRecharge, models.OperationTopup, models.OperationPoolDraw:
+		if !record.Amount.IsPositive() {
+			return nil
+		}
+		policy, err := r.policyOf(record)
+		if err != nil {
+			return err
+		}
+		r.credit(record.Model, record.ExpiryDate, policy, nil, record.Amount)
+
+	case models.OperationRollover:
+		details, err := record.UnmarshalDetails()
+		if err != nil || details == nil || details.Rollover == nil {
+			r.warn(record, "rollover without its expired bucket, replayed as a plain bucket")
+			r.credit(record.Model, record.ExpiryDate, record.StrategyID, nil, record.Amount)
+			return nil
+		}
+		expiredID := details.Rollover.QuotaID
+		r.credit(record.Model, record.ExpiryDate, record.StrategyID, &expiredID, record.Amount)
+
+	case models.OperationTransferIn, models.OperationReserveCommit, models.OperationReserveRelease:
+		// Received quota and returned holds go to the first bucket of their pool and expiry
+		// date; received quota follows the global expiry policy
+		for _, item := range r.items(record) {
+			if item.Status != models.AuditStatusSuccess {
+				continue
+			}
+			model, expiryDate, ok := r.itemTarget(record, &item)
+			if !ok {
+				continue
+			}
+			if record.Operation != models.OperationTransferIn {
+				if bucket := r.firstBucket(model, expiryDate); bucket != nil {
+					bucket.Amount = bucket.Amount.Add(item.Amount)
+					continue
+				}
+			}
+			r.credit(model, expiryDate, nil, nil, item.Amount)
+		}
+
+	case models.OperationTransferOut, models.OperationReserve:
+		for _, item := range r.items(record) {
+			if item.Status != models.AuditStatusSuccess {
+				continue
+			}
+			model, expiryDate, ok := r.itemTarget(record, &item)
+			if !ok {
+				continue
+			}
+			if missing := r.debit(model, expiryDate, item.Amount); missing.IsPositive() {
+				r.warn(record, "%s of %s expiring %s exceeds the replayed buckets",
+					missing.StringFixed(), model, expiryDate.Format(time.RFC3339))
+			}
+		}
+
+	case models.OperationExpire:
+		r.expire(record)
+
+	default:
+		// Credit operations move the credit line and debt, not the buckets, and ledger
+		// replays rewrote rows to what the records before them add up to
+	}
+	return nil
+}
+
+// firstBucket returns the first valid bucket of a pool and expiry date
+func (r *ledgerReplay) firstBucket(model string, expiryDate time.Time) *ReplayBucket {
+	for _, bucket := range r.buckets {
+		if bucket.Model == model && bucket.ExpiryDate.Equal(expiryDate) {
+			return bucket
+		}
+	}
+	return nil
+}
+
+// expire removes the buckets an EXPIRE record expired. The record only holds the expired
+// total of a pool, so the buckets of the pool past their expiry date at the time of the
+// record are taken, earliest expiry first, as far as they add up to it.
+func (r *ledgerReplay) expire(record *models.QuotaAudit) {
+	expired := record.Amount.Abs()
+	var candidates []*ReplayBucket
+	total := decimal.Zero
+	for _, bucket := range r.buckets {
+		if bucket.Model == record.Model && bucket.ExpiryDate.Before(record.ExpiryDate) {
+			candidates = append(candidates, bucket)
+			total = total.Add(bucket.Amount)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].ExpiryDate.Before(candidates[j].ExpiryDate)
+	})
+
+	// Buckets still in a grace period stay valid, so take all of them only when they match
+	taken := make(map[*ReplayBucket]bool)
+	if total.Equal(expired) {
+		for _, bucket := range candidates {
+			taken[bucket] = true
+		}
+	} else {
+		sum := decimal.Zero
+		for _, bucket := range candidates {
+			if sum.Add(bucket.Amount).LessThanOrEqual(expired) {
+				sum = sum.Add(bucket.Amount)
+				taken[bucket] = true
+			}
+		}
+		if !sum.Equal(expired) {
+			r.warn(record, "expired %s but the matching buckets hold %s", expired.StringFixed(), sum.StringFixed())
+		}
+	}
+
+	valid := r.buckets[:0]
+	for _, bucket := range r.buckets {
+		if !taken[bucket] {
+			valid = append(valid, bucket)
+		}
+	}
+	r.buckets = valid
+}
+
+// result returns the valid buckets, earliest expiry first
+func (r *ledgerReplay) result() []ReplayBucket {
+	buckets := make([]ReplayBucket, 0, len(r.buckets))
+	for _, bucket := range r.buckets {
+		buckets = append(buckets, *bucket)
+	}
+	sort.SliceStable(buckets, func(i, j int) bool {
+		return buckets[i].ExpiryDate.Before(buckets[j].ExpiryDate)
+	})
+	return buckets
+}
+
+// replayUserAudit replays the user's audit records created up to at, oldest first; a zero
+// at replays them all
+func (s *QuotaService) replayUserAudit(db *gorm.DB, userID string, at time.Time) (*ledgerReplay, error) {
+	replay := s.newLedgerReplay()
+	cursor := 0
+	for {
+		query := db.Where("user_id = ? AND id > ?", userID, cursor)
+		if !at.IsZero() {
+			query = query.Where("create_time <= ?", at)
+		}
+		var records []models.QuotaAudit
+		if err := query.Order("id ASC").Limit(auditExportBatchSize).Find(&records).Error; err != nil {
+			return nil, fmt.Errorf("failed to read audit records: %w", err)
+		}
+		for i := range records {
+			if err := replay.apply(&records[i]); err != nil {
+				return nil, err
+			}
+		}
+		if len(records) < auditExportBatchSize {
+			return replay, nil
+		}
+		cursor = records[len(records)-1].ID
+	}
+}
+
+// GetBalanceAt rebuilds the user's valid buckets as they stood at the given time from the
+// audit log. Usage is not part of the audit log, so amounts are granted quota, not what was
+// left unconsumed.
+func (s *QuotaService) GetBalanceAt(userID string, at time.Time) (*BalanceAt, error) {
+	if at.IsZero() {
+		return nil, NewValidationFailedError("at is required")
+	}
+	replay, err := s.replayUserAudit(s.db.DB, userID, at)
+	if err != nil {
+		return nil, NewDatabaseError("replay audit log", err)
+	}
+
+	result := &BalanceAt{
+		UserID:   userID,
+		At:       at,
+		Buckets:  []ReplayBucket{},
+		Records:  replay.records,
+		Warnings: replay.warnings,
+	}
+	for _, bucket := range replay.result() {
+		if !bucket.Amount.IsPositive() {
+			continue
+		}
+		result.Buckets = append(result.Buckets, bucket)
+		result.Balance = result.Balance.Add(bucket.Amount)
+	}
+	return result, nil
+}
+
+// ReplayQuotaLedger rebuilds the valid quota rows of a user, or of every user with audit
+// records or valid quota when userID is empty, from the audit log. Dry-run reports the
+// differences; apply also writes them.
+func (s *QuotaService) ReplayQuotaLedger(userID, mode string) (*LedgerReplayReport, error) {
+	if mode != ReplayModeDryRun && mode != ReplayModeApply {
+		return nil, NewValidationFailedError(fmt.Sprintf("invalid replay mode '%s', must be dry_run or apply", mode))
+	}
+
+	userIDs := []string{userID}
+	if userID == "" {
+		userIDs = nil
+		if err := s.db.DB.Raw("SELECT user_id FROM quota WHERE status = ? UNION SELECT user_id FROM quota_audit ORDER BY user_id",
+			models.StatusValid).Scan(&userIDs).Error; err != nil {
+			return nil, NewDatabaseError("load users", err)
+		}
+	}
+
+	report := &LedgerReplayReport{Mode: mode, TotalUsers: len(userIDs), Users: []UserReplayResult{}}
+	for _, id := range userIDs {
+		result, err := s.replayUserLedger(id, mode == ReplayModeApply)
+		if err != nil {
+			logger.Error("Failed to replay quota ledger", zap.String("user_id", id), zap.Error(err))
+			result.Error = err.Error()
+			report.FailedUsers++
+		}
+		if len(result.Changes) > 0 {
+			report.ChangedUsers++
+		}
+		if len(result.Changes) > 0 || len(result.Warnings) > 0 || result.Error != "" {
+			report.Users = append(report.Users, *result)
+		}
+	}
+
+	logger.Info("Quota ledger replay completed",
+		zap.String("mode", mode),
+		zap.Int("total_users", report.TotalUsers),
+		zap.Int("changed_users", report.ChangedUsers),
+		zap.Int("failed_users", report.FailedUsers))
+	return report, nil
+}
+
+// replayUserLedger compares one user's replayed buckets with their valid quota rows and, in
+// apply mode, rewrites the rows. Rows keep their consumed usage, which the audit log does not
+// hold; rows sharing a bucket identity are merged into one, their consumption log following the
+// kept row, and rows the log does not account for are voided rather than deleted. Every change
+// is recorded as a LEDGER_REPLAY audit record and the net change of each model pool is queued
+// for AiGateway through the outbox.
+func (s *QuotaService) replayUserLedger(userID string, apply bool) (*UserReplayResult, error) {
+	result := &UserReplayResult{UserID: userID, Changes: []ReplayBucketChange{}}
+
+	tx := s.db.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	// Dry runs only read, rolling back leaves nothing behind
+	defer tx.Rollback()
+
+	// Lock the rows, then the audit chain head: grants take them in this order, and holding
+	// the head keeps new audit records out until the rows match the log
+	lockRows := func() ([]models.Quota, error) {
+		var rows []models.Quota
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND status = ?", userID, models.StatusValid).
+			Order("id ASC").Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to lock quota rows: %w", err)
+		}
+		return rows, nil
+	}
+	if _, err := lockRows(); err != nil {
+		return result, err
+	}
+	if _, err := s.lockAuditChainHead(tx, userID); err != nil {
+		return result, err
+	}
+	rows, err := lockRows()
+	if err != nil {
+		return result, err
+	}
+
+	replay, err := s.replayUserAudit(tx, userID, time.Time{})
+	if err != nil {
+		return result, err
+	}
+	result.Records = replay.records
+	result.Warnings = replay.warnings
+
+	// Group the rows by bucket identity, the first row of a group is kept
+	groups := make(map[string][]*models.Quota)
+	var order []string
+	for i := range rows {
+		key := replayBucketKey(rows[i].Model, rows[i].ExpiryDate, rows[i].StrategyID, rows[i].RolloverFrom)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], &rows[i])
+	}
+
+	// Net change of each model pool's total, with the first audit record of the pool
+	// as the outbox dedup key
+	poolDeltas := make(map[string]decimal.Decimal)
+	poolAudits := make(map[string]int)
+	var poolOrder []string
+	record := func(change *ReplayBucketChange, mergedIDs []int) error {
+		auditRecord, err := s.recordReplayChange(tx, userID, change, mergedIDs)
+		if err != nil {
+			return err
+		}
+		if _, ok := poolAudits[change.Model]; !ok {
+			poolAudits[change.Model] = auditRecord.ID
+			poolOrder = append(poolOrder, change.Model)
+		}
+		poolDeltas[change.Model] = poolDeltas[change.Model].Add(auditRecord.Amount)
+		return nil
+	}
+
+	replayed := make(map[string]bool)
+	for _, bucket := range replay.result() {
+		key := bucket.key()
+		replayed[key] = true
+		group := groups[key]
+		if len(group) == 0 {
+			if !bucket.Amount.IsPositive() {
+				continue
+			}
+			change := ReplayBucketChange{
+				Action:         ReplayActionCreate,
+				Model:          bucket.Model,
+				ExpiryDate:     bucket.ExpiryDate,
+				StrategyID:     bucket.StrategyID,
+				RolloverFrom:   bucket.RolloverFrom,
+				ReplayedAmount: bucket.Amount,
+			}
+			if apply {
+				quota := &models.Quota{
+					UserID:       userID,
+					Amount:       bucket.Amount,
+					Model:        bucket.Model,
+					StrategyID:   bucket.StrategyID,
+					RolloverFrom: bucket.RolloverFrom,
+					ExpiryDate:   bucket.ExpiryDate,
+					Status:       models.StatusValid,
+				}
+				if err := tx.Create(quota).Error; err != nil {
+					return result, fmt.Errorf("failed to create quota: %w", err)
+				}
+				change.QuotaID = quota.ID
+				if err := record(&change, nil); err != nil {
+					return result, err
+				}
+			}
+			result.Changes = append(result.Changes, change)
+			continue
+		}
+
+		kept := group[0]
+		current, consumed := decimal.Zero, decimal.Zero
+		for _, row := range group {
+			current = current.Add(row.Amount)
+			consumed = consumed.Add(row.Consumed)
+		}
+		if current.Equal(bucket.Amount) && len(group) == 1 {
+			continue
+		}
+		change := ReplayBucketChange{
+			Action:         ReplayActionUpdate,
+			QuotaID:        kept.ID,
+			Model:          bucket.Model,
+			ExpiryDate:     bucket.ExpiryDate,
+			StrategyID:     bucket.StrategyID,
+			RolloverFrom:   bucket.RolloverFrom,
+			CurrentAmount:  current,
+			ReplayedAmount: bucket.Amount,
+		}
+		result.Changes = append(result.Changes, change)
+		if apply {
+			if err := tx.Model(&models.Quota{}).Where("id = ?", kept.ID).Updates(map[string]interface{}{
+				"amount":   bucket.Amount,
+				"consumed": decimal.Min(consumed, bucket.Amount),
+			}).Error; err != nil {
+				return result, fmt.Errorf("failed to update quota %d: %w", kept.ID, err)
+			}
+			var mergedIDs []int
+			for _, row := range group[1:] {
+				mergedIDs = append(mergedIDs, row.ID)
+			}
+			if len(mergedIDs) > 0 {
+				// The consumption log and reservation holds of merged rows follow the kept row
+				if err := tx.Model(&models.QuotaConsumption{}).Where("quota_id IN ?", mergedIDs).
+					Update("quota_id", kept.ID).Error; err != nil {
+					return result, fmt.Errorf("failed to move quota consumption records: %w", err)
+				}
+				if err := tx.Model(&models.QuotaReservationHold{}).Where("quota_id IN ?", mergedIDs).
+					Update("quota_id", kept.ID).Error; err != nil {
+					return result, fmt.Errorf("failed to move quota reservation holds: %w", err)
+				}
+				if err := tx.Where("id IN ?", mergedIDs).Delete(&models.Quota{}).Error; err != nil {
+					return result, fmt.Errorf("failed to delete merged quota rows: %w", err)
+				}
+			}
+			if err := record(&change, mergedIDs); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	// Rows the audit log does not account for are voided, their consumption log and the
+	// rollovers pointing at them keep an existing row to refer to
+	for _, key := range order {
+		if replayed[key] {
+			continue
+		}
+		for _, row := range groups[key] {
+			change := ReplayBucketChange{
+				Action:        ReplayActionDelete,
+				QuotaID:       row.ID,
+				Model:         row.Model,
+				ExpiryDate:    row.ExpiryDate,
+				StrategyID:    row.StrategyID,
+				RolloverFrom:  row.RolloverFrom,
+				CurrentAmount: row.Amount,
+			}
+			result.Changes = append(result.Changes, change)
+			if apply {
+				if err := tx.Model(&models.Quota{}).Where("id = ?", row.ID).
+					Update("status", models.StatusVoided).Error; err != nil {
+					return result, fmt.Errorf("failed to void quota %d: %w", row.ID, err)
+				}
+				if err := record(&change, nil); err != nil {
+					return result, err
+				}
+			}
+		}
+	}
+
+	if apply && len(result.Changes) > 0 {
+		// Queue the AiGateway total of each changed pool in the same transaction
+		var outboxEntries []*models.GatewayOutbox
+		for _, model := range poolOrder {
+			delta := poolDeltas[model]
+			if delta.IsZero() {
+				continue
+			}
+			entry, err := s.enqueueGatewayMutation(tx, userID, model, models.OutboxMutationDeltaQuota, delta,
+				models.OperationLedgerReplay, outboxDedupKey(poolAudits[model], models.OutboxMutationDeltaQuota))
+			if err != nil {
+				return result, err
+			}
+			outboxEntries = append(outboxEntries, entry)
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			return result, fmt.Errorf("failed to commit ledger replay: %w", err)
+		}
+		s.deliverOutboxEntries(outboxEntries)
+		logger.Info("Rebuilt quota rows from the audit log",
+			zap.String("user_id", userID),
+			zap.Int("changes", len(result.Changes)))
+	}
+	return result, nil
+}
+
+// recordReplayChange writes the LEDGER_REPLAY audit record of a quota row a replay changed,
+// its amount being the change of the row's amount
+func (s *QuotaService) recordReplayChange(tx *gorm.DB, userID string, change *ReplayBucketChange, mergedIDs []int) (*models.QuotaAudit, error) {
+	amount := change.ReplayedAmount.Sub(change.CurrentAmount)
+	expiryDate := change.ExpiryDate.Format(time.RFC3339)
+	auditDetails := &models.QuotaAuditDetails{
+		Operation: models.OperationLedgerReplay,
+		Summary: models.QuotaAuditSummary{
+			TotalAmount:        amount,
+			TotalItems:         1,
+			SuccessfulItems:    1,
+			EarliestExpiryDate: expiryDate,
+		},
+		LedgerReplay: &models.LedgerReplayDetail{
+			Action:         change.Action,
+			QuotaID:        change.QuotaID,
+			MergedQuotaIDs: mergedIDs,
+			RolloverFrom:   change.RolloverFrom,
+			CurrentAmount:  change.CurrentAmount,
+			ReplayedAmount: change.ReplayedAmount,
+		},
+	}
+	auditRecord := &models.QuotaAudit{
+		UserID:     userID,
+		Amount:     amount,
+		Operation:  models.OperationLedgerReplay,
+		Model:      change.Model,
+		StrategyID: change.StrategyID,
+		ExpiryDate: change.ExpiryDate,
+		CreateTime: utils.NowInConfigTimezone(s.configManager.GetDirect()),
+	}
+	if err := auditRecord.MarshalDetails(auditDetails); err != nil {
+		return nil, fmt.Errorf("failed to marshal audit details: %w", err)
+	}
+	if err := s.createAuditRecord(tx, auditRecord); err != nil {
+		return nil, fmt.Errorf("failed to create ledger replay audit record: %w", err)
+	}
+	return auditRecord, nil
+}
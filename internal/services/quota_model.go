@@ -0,0 +1,150 @@
+package services
+
+import (
+	"fmt"
+	"quota-manager/internal/config"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+	"sort"
+	"strings"
+)
+
+// ModelQuotaInfo is the balance of one model pool
+type ModelQuotaInfo struct {
+	Model      string            `json:"model"`
+	TotalQuota decimal.Decimal   `json:"total_quota"`
+	UsedQuota  decimal.Decimal   `json:"used_quota"`
+	QuotaList  []QuotaDetailItem `json:"quota_list"`
+}
+
+// ModelPool returns the model pool quota for a model is kept in: the pool named after
+// the model, or the first pool (by name) with a matching model pattern. An empty result
+// is the "any model" pool.
+func (s *QuotaService) ModelPool(model string) string {
+	return modelPoolFor(s.configManager.GetDirect(), model)
+}
+
+// modelPoolFor resolves a model to its configured pool
+func modelPoolFor(cfg *config.Config, model string) string {
+	model = strings.TrimSpace(model)
+	if model == "" || cfg == nil || len(cfg.ModelQuota.Pools) == 0 {
+		return ""
+	}
+	if _, ok := cfg.ModelQuota.Pools[model]; ok {
+		return model
+	}
+
+	pools := make([]string, 0, len(cfg.ModelQuota.Pools))
+	for pool := range cfg.ModelQuota.Pools {
+		pools = append(pools, pool)
+	}
+	sort.Strings(pools)
+	for _, pool := range pools {
+		for _, pattern := range cfg.ModelQuota.Pools[pool] {
+			if matchModelPattern(pattern, model) {
+				return pool
+			}
+		}
+	}
+	return ""
+}
+
+// matchModelPattern matches a model against a pool entry, a trailing * matching a prefix
+func matchModelPattern(pattern, model string) bool {
+	pattern = strings.TrimSpace(pattern)
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(model, prefix)
+	}
+	return pattern == model
+}
+
+// resolveTransferModel maps the model of a transfer item to its pool. A model that
+// belongs to no pool is rejected, since it would silently draw from the "any model" pool.
+func (s *QuotaService) resolveTransferModel(model string) (string, error) {
+	if model == "" {
+		return "", nil
+	}
+	pool := s.ModelPool(model)
+	if pool == "" {
+		return "", fmt.Errorf("quota not found for model %s: it belongs to no model pool", model)
+	}
+	return pool, nil
+}
+
+// userModelPools lists the model pools the user holds valid buckets in, the "any model"
+// pool excluded
+func (s *QuotaService) userModelPools(userID string) ([]string, error) {
+	var pools []string
+	if err := s.db.DB.Model(&models.Quota{}).
+		Where("user_id = ? AND status = ? AND model <> ''", userID, models.StatusValid).
+		Distinct("model").Order("model").Find(&pools).Error; err != nil {
+		return nil, fmt.Errorf("failed to list model pools: %w", err)
+	}
+	return pools, nil
+}
+
+// grantTarget is the key of the bucket a strategy grant is added to
+type grantTarget struct {
+	policyStrategyID *int   // the strategy when it has its own expiry policy, nil to follow the global policy
+	model            string // model pool of the strategy's model, empty = any model
+}
+
+// loadGrantTarget looks up the strategy of a grant. Grants of strategies with their own
+// expiry policy are kept in separate buckets, and grants for a pooled model go to that pool.
+func (s *QuotaService) loadGrantTarget(strategyID int) (grantTarget, error) {
+	var strategy models.QuotaStrategy
+	result := s.db.DB.Where("id = ?", strategyID).Limit(1).Find(&strategy)
+	if result.Error != nil {
+		return grantTarget{}, fmt.Errorf("failed to load strategy %d: %w", strategyID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return grantTarget{}, nil
+	}
+
+	target := grantTarget{model: s.ModelPool(strategy.Model)}
+	if HasExpiryPolicy(&strategy) {
+		id := strategy.ID
+		target.policyStrategyID = &id
+	}
+	return target, nil
+}
+
+// poolQuota reads a pool's counters from AiGateway, charges new usage to the pool's
+// buckets and lists what is left of each
+func (s *QuotaService) poolQuota(userID, model string) (*ModelQuotaInfo, error) {
+	totalQuota, err := s.aiGatewayClient.QueryQuotaValueForModel(userID, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get total quota: %w", err)
+	}
+
+	tx := s.db.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	quotas, usedQuota, err := s.chargeUsage(tx, userID, model)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit quota consumption: %w", err)
+	}
+
+	quotaList := make([]QuotaDetailItem, 0)
+	for i := range quotas {
+		if remaining := quotas[i].Remaining(); remaining.IsPositive() {
+			quotaList = append(quotaList, QuotaDetailItem{
+				Amount:     remaining,
+				ExpiryDate: quotas[i].ExpiryDate,
+			})
+		}
+	}
+	return &ModelQuotaInfo{
+		Model:      model,
+		TotalQuota: totalQuota,
+		UsedQuota:  usedQuota,
+		QuotaList:  quotaList,
+	}, nil
+}
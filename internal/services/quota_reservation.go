@@ -0,0 +1,499 @@
+package services
+
+import (
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+	"quota-manager/pkg/logger"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultReservationReleaseInterval is the schedule of the TTL release job when none is configured
+	DefaultReservationReleaseInterval = "0 * * * * *"
+	// DefaultReservationTTL is how long a reservation is held when the caller sets no TTL
+	DefaultReservationTTL = time.Hour
+	// MaxReservationTTL bounds how long quota can be held for a single job
+	MaxReservationTTL = 24 * time.Hour
+)
+
+// ReserveQuotaRequest represents a request to hold quota for a job
+type ReserveQuotaRequest struct {
+	Amount     decimal.Decimal `json:"amount" validate:"required,gt=0"`
+	Model      string          `json:"model" validate:"omitempty,max=100"`     // model to hold quota of, empty = any model
+	TTLSeconds int             `json:"ttl_seconds" validate:"omitempty,min=1"` // how long to hold, default one hour
+	Reference  string          `json:"reference" validate:"omitempty,max=255"` // caller's job reference
+}
+
+// ReserveQuota holds quota of the user's buckets, earliest expiry first, until the
+// reservation is committed, released or its TTL passes. The held quota is taken out of
+// the buckets and the AiGateway total, so neither usage nor transfers can draw on it.
+func (s *QuotaService) ReserveQuota(userID string, req *ReserveQuotaRequest) (*models.QuotaReservation, error) {
+	if !req.Amount.IsPositive() {
+		return nil, NewValidationFailedError("amount must be greater than 0")
+	}
+	ttl := DefaultReservationTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl > MaxReservationTTL {
+		return nil, NewValidationFailedError(fmt.Sprintf("ttl_seconds must be at most %d", int(MaxReservationTTL.Seconds())))
+	}
+	model, err := s.resolveTransferModel(req.Model)
+	if err != nil {
+		return nil, NewValidationFailedError(err.Error())
+	}
+
+	tx := s.db.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Charge outstanding usage first, so the hold only takes quota that is really left
+	quotas, _, err := s.chargeUsage(tx, userID, model)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	available := decimal.Zero
+	for i := range quotas {
+		available = available.Add(quotas[i].Remaining())
+	}
+	if available.LessThan(req.Amount) {
+		tx.Rollback()
+		return nil, NewValidationFailedError(fmt.Sprintf("insufficient available quota: have %s, need %s", available, req.Amount))
+	}
+
+	reservation := &models.QuotaReservation{
+		UserID:    userID,
+		Model:     model,
+		Amount:    req.Amount,
+		Status:    models.ReservationStatusHeld,
+		Reference: req.Reference,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := tx.Create(reservation).Error; err != nil {
+		tx.Rollback()
+		return nil, NewDatabaseError("create reservation", err)
+	}
+
+	// Take the held amount out of the buckets; buckets are kept even when emptied,
+	// the hold is returned to them on settlement
+	needed := req.Amount
+	auditItems := make([]models.QuotaAuditDetailItem, 0)
+	var earliestExpiryDate time.Time
+	for i := range quotas {
+		if !needed.IsPositive() {
+			break
+		}
+		available := quotas[i].Remaining()
+		if !available.IsPositive() {
+			continue
+		}
+		take := decimal.Min(available, needed)
+		needed = needed.Sub(take)
+
+		if err := tx.Model(&models.Quota{}).Where("id = ?", quotas[i].ID).
+			Update("amount", gorm.Expr("amount - ?", take)).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to hold quota %d: %w", quotas[i].ID, err)
+		}
+		if err := tx.Create(&models.QuotaReservationHold{
+			ReservationID: reservation.ID,
+			QuotaID:       quotas[i].ID,
+			Amount:        take,
+			ExpiryDate:    quotas[i].ExpiryDate,
+			StrategyID:    quotas[i].StrategyID,
+		}).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to record reservation hold: %w", err)
+		}
+
+		if len(auditItems) == 0 {
+			earliestExpiryDate = quotas[i].ExpiryDate
+		}
+		auditItems = append(auditItems, models.QuotaAuditDetailItem{
+			Amount:     take,
+			ExpiryDate: quotas[i].ExpiryDate.Format(time.RFC3339),
+			Model:      model,
+			Status:     models.AuditStatusSuccess,
+		})
+	}
+
+	auditRecord := &models.QuotaAudit{
+		UserID:     userID,
+		Amount:     req.Amount.Neg(),
+		Operation:  models.OperationReserve,
+		Model:      model,
+		ExpiryDate: earliestExpiryDate,
+	}
+	if err := auditRecord.MarshalDetails(&models.QuotaAuditDetails{
+		Operation: models.OperationReserve,
+		Summary: models.QuotaAuditSummary{
+			TotalAmount:        req.Amount,
+			TotalItems:         len(auditItems),
+			SuccessfulItems:    len(auditItems),
+			EarliestExpiryDate: earliestExpiryDate.Format(time.RFC3339),
+		},
+		Items: auditItems,
+		Reservation: &models.ReservationDetail{
+			ReservationID: reservation.ID,
+			HeldAmount:    req.Amount,
+		},
+	}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := s.createAuditRecord(tx, auditRecord); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to create audit record: %w", err)
+	}
+
+	outboxEntry, err := s.enqueueGatewayMutation(tx, userID, model, models.OutboxMutationDeltaQuota, req.Amount.Neg(),
+		models.OperationReserve, outboxDedupKey(auditRecord.ID, models.OutboxMutationDeltaQuota))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit reservation: %w", err)
+	}
+	s.deliverOutboxEntries([]*models.GatewayOutbox{outboxEntry})
+
+	logger.Info("Quota reserved",
+		zap.Int("reservation_id", reservation.ID),
+		zap.String("user_id", userID),
+		zap.String("model", model),
+		zap.Stringer("amount", req.Amount),
+		zap.Time("expires_at", reservation.ExpiresAt))
+	return reservation, nil
+}
+
+// CommitReservation settles a held reservation with the amount the job actually used. The
+// held quota goes back to the buckets and the actual amount is charged as usage, like usage
+// reported by AiGateway, so the unused part of the hold becomes available again.
+func (s *QuotaService) CommitReservation(userID string, reservationID int, actualAmount decimal.Decimal) (*models.QuotaReservation, error) {
+	if actualAmount.IsNegative() {
+		return nil, NewValidationFailedError("actual_amount must be >= 0")
+	}
+	return s.settleReservation(userID, reservationID, models.ReservationStatusCommitted, actualAmount, "")
+}
+
+// ReleaseReservation gives the whole held quota of a reservation back unused
+func (s *QuotaService) ReleaseReservation(userID string, reservationID int) (*models.QuotaReservation, error) {
+	return s.settleReservation(userID, reservationID, models.ReservationStatusReleased, decimal.Zero, "")
+}
+
+// GetReservation returns one of the user's reservations
+func (s *QuotaService) GetReservation(userID string, reservationID int) (*models.QuotaReservation, error) {
+	var reservation models.QuotaReservation
+	result := s.db.DB.Where("id = ? AND user_id = ?", reservationID, userID).Limit(1).Find(&reservation)
+	if result.Error != nil {
+		return nil, NewDatabaseError("query reservation", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, NewResourceNotFoundError("reservation", fmt.Sprintf("%d", reservationID))
+	}
+	return &reservation, nil
+}
+
+// GetReservations lists the user's reservations, newest first, optionally by status
+func (s *QuotaService) GetReservations(userID, status string, page, pageSize int) ([]models.QuotaReservation, int64, error) {
+	query := s.db.DB.Model(&models.QuotaReservation{}).Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, NewDatabaseError("count reservations", err)
+	}
+
+	var reservations []models.QuotaReservation
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&reservations).Error; err != nil {
+		return nil, 0, NewDatabaseError("query reservations", err)
+	}
+	return reservations, total, nil
+}
+
+// ReleaseExpiredReservations releases reservations held past their TTL, for the scheduler
+func (s *QuotaService) ReleaseExpiredReservations() {
+	var reservations []models.QuotaReservation
+	if err := s.db.DB.Where("status = ? AND expires_at <= ?", models.ReservationStatusHeld, time.Now()).
+		Order("expires_at ASC").Find(&reservations).Error; err != nil {
+		logger.Error("Failed to query expired reservations", zap.Error(err))
+		return
+	}
+
+	released := 0
+	for _, reservation := range reservations {
+		if _, err := s.settleReservation(reservation.UserID, reservation.ID, models.ReservationStatusExpired, decimal.Zero, "ttl"); err != nil {
+			// Settled concurrently by its owner, or retried on the next run
+			logger.Warn("Failed to release expired reservation",
+				zap.Int("reservation_id", reservation.ID),
+				zap.String("user_id", reservation.UserID),
+				zap.Error(err))
+			continue
+		}
+		released++
+	}
+	if released > 0 {
+		logger.Info("Released expired reservations", zap.Int("count", released))
+	}
+}
+
+// settleReservation returns the held quota of a reservation to the buckets it came from and,
+// on commit, charges the actual amount as usage. Holds whose bucket expired meanwhile are not
+// returned, they expire with the bucket.
+func (s *QuotaService) settleReservation(userID string, reservationID int, status string, actualAmount decimal.Decimal, reason string) (*models.QuotaReservation, error) {
+	reservation, err := s.GetReservation(userID, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	model := reservation.Model
+
+	tx := s.db.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Lock the reservation so it is settled exactly once
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", reservationID).First(reservation).Error; err != nil {
+		tx.Rollback()
+		return nil, NewDatabaseError("lock reservation", err)
+	}
+	if reservation.Status != models.ReservationStatusHeld {
+		tx.Rollback()
+		return nil, NewConflictError(fmt.Sprintf("reservation %d is already %s", reservationID, reservation.Status))
+	}
+	if actualAmount.GreaterThan(reservation.Amount) {
+		tx.Rollback()
+		return nil, NewValidationFailedError(fmt.Sprintf("actual_amount %s exceeds the reserved amount %s", actualAmount, reservation.Amount))
+	}
+
+	// Charge outstanding usage and lock the buckets before changing them
+	if _, _, err := s.chargeUsage(tx, userID, model); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var holds []models.QuotaReservationHold
+	if err := tx.Where("reservation_id = ?", reservationID).Order("id ASC").Find(&holds).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to load reservation holds: %w", err)
+	}
+
+	returned, expired := decimal.Zero, decimal.Zero
+	auditItems := make([]models.QuotaAuditDetailItem, 0, len(holds))
+	var earliestExpiryDate time.Time
+	for i, hold := range holds {
+		ok, err := s.returnHold(tx, reservation, &hold)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		itemStatus := models.AuditStatusSuccess
+		if ok {
+			returned = returned.Add(hold.Amount)
+		} else {
+			expired = expired.Add(hold.Amount)
+			itemStatus = models.AuditStatusExpired
+		}
+		if i == 0 || hold.ExpiryDate.Before(earliestExpiryDate) {
+			earliestExpiryDate = hold.ExpiryDate
+		}
+		auditItems = append(auditItems, models.QuotaAuditDetailItem{
+			Amount:     hold.Amount,
+			ExpiryDate: hold.ExpiryDate.Format(time.RFC3339),
+			Model:      model,
+			Status:     itemStatus,
+		})
+	}
+
+	var outboxEntries []*models.GatewayOutbox
+	operation := models.OperationReserveRelease
+	if status == models.ReservationStatusCommitted {
+		operation = models.OperationReserveCommit
+	}
+	auditRecord := &models.QuotaAudit{
+		UserID:     userID,
+		Amount:     returned,
+		Operation:  operation,
+		Model:      model,
+		ExpiryDate: earliestExpiryDate,
+	}
+	if err := auditRecord.MarshalDetails(&models.QuotaAuditDetails{
+		Operation: operation,
+		Summary: models.QuotaAuditSummary{
+			TotalAmount:        returned,
+			TotalItems:         len(auditItems),
+			SuccessfulItems:    len(auditItems),
+			EarliestExpiryDate: earliestExpiryDate.Format(time.RFC3339),
+		},
+		Items: auditItems,
+		Reservation: &models.ReservationDetail{
+			ReservationID:  reservationID,
+			HeldAmount:     reservation.Amount,
+			ActualAmount:   actualAmount,
+			ReturnedAmount: returned,
+			ExpiredAmount:  expired,
+			Reason:         reason,
+		},
+	}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := s.createAuditRecord(tx, auditRecord); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to create audit record: %w", err)
+	}
+
+	if returned.IsPositive() {
+		entry, err := s.enqueueGatewayMutation(tx, userID, model, models.OutboxMutationDeltaQuota, returned,
+			operation, outboxDedupKey(auditRecord.ID, models.OutboxMutationDeltaQuota))
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		outboxEntries = append(outboxEntries, entry)
+	}
+	if actualAmount.IsPositive() {
+		if err := s.chargeReservedUsage(tx, userID, model, actualAmount); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		entry, err := s.enqueueGatewayMutation(tx, userID, model, models.OutboxMutationDeltaUsedQuota, actualAmount,
+			operation, outboxDedupKey(auditRecord.ID, models.OutboxMutationDeltaUsedQuota))
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		outboxEntries = append(outboxEntries, entry)
+	}
+
+	now := time.Now()
+	if err := tx.Model(&models.QuotaReservation{}).Where("id = ?", reservationID).
+		Updates(map[string]interface{}{
+			"status":        status,
+			"actual_amount": actualAmount,
+			"settle_time":   now,
+		}).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to settle reservation: %w", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit reservation settlement: %w", err)
+	}
+	s.deliverOutboxEntries(outboxEntries)
+
+	reservation.Status = status
+	reservation.ActualAmount = actualAmount
+	reservation.SettleTime = &now
+	logger.Info("Quota reservation settled",
+		zap.Int("reservation_id", reservationID),
+		zap.String("user_id", userID),
+		zap.String("status", status),
+		zap.Stringer("actual_amount", actualAmount),
+		zap.Stringer("returned", returned),
+		zap.Stringer("expired", expired))
+	return reservation, nil
+}
+
+// returnHold gives a hold back to its bucket. A bucket merged away meanwhile is replaced by
+// a valid bucket of the same expiry, or recreated. It returns false when the bucket expired.
+func (s *QuotaService) returnHold(tx *gorm.DB, reservation *models.QuotaReservation, hold *models.QuotaReservationHold) (bool, error) {
+	var bucket models.Quota
+	result := tx.Where("id = ? AND status = ?", hold.QuotaID, models.StatusValid).Limit(1).Find(&bucket)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result = tx.Where("user_id = ? AND model = ? AND expiry_date = ? AND status = ?",
+			reservation.UserID, reservation.Model, hold.ExpiryDate, models.StatusValid).
+			Order("id ASC").Limit(1).Find(&bucket)
+	}
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to find bucket of reservation hold %d: %w", hold.ID, result.Error)
+	}
+
+	if result.RowsAffected > 0 {
+		if err := tx.Model(&models.Quota{}).Where("id = ?", bucket.ID).
+			Update("amount", gorm.Expr("amount + ?", hold.Amount)).Error; err != nil {
+			return false, fmt.Errorf("failed to return reservation hold %d: %w", hold.ID, err)
+		}
+		return true, nil
+	}
+	if !hold.ExpiryDate.After(time.Now()) {
+		return false, nil
+	}
+
+	if err := tx.Create(&models.Quota{
+		UserID:     reservation.UserID,
+		Amount:     hold.Amount,
+		Model:      reservation.Model,
+		StrategyID: hold.StrategyID,
+		ExpiryDate: hold.ExpiryDate,
+		Status:     models.StatusValid,
+	}).Error; err != nil {
+		return false, fmt.Errorf("failed to recreate bucket of reservation hold %d: %w", hold.ID, err)
+	}
+	return true, nil
+}
+
+// chargeReservedUsage charges the actual amount of a committed reservation to the pool's
+// buckets, earliest expiry first, and moves the usage cursor by the used quota mutation
+// queued with it, so the gateway does not charge it a second time
+func (s *QuotaService) chargeReservedUsage(tx *gorm.DB, userID, model string, amount decimal.Decimal) error {
+	cursor, err := s.lockUsageCursor(tx, userID, model)
+	if err != nil {
+		return err
+	}
+	usedAfter := cursor.UsedQuota.Add(amount)
+
+	var quotas []models.Quota
+	if err := tx.Where("user_id = ? AND model = ? AND status = ?", userID, model, models.StatusValid).
+		Order("expiry_date ASC, id ASC").Find(&quotas).Error; err != nil {
+		return fmt.Errorf("failed to get quota list: %w", err)
+	}
+
+	remaining := amount
+	for i := range quotas {
+		if !remaining.IsPositive() {
+			break
+		}
+		available := quotas[i].Remaining()
+		if !available.IsPositive() {
+			continue
+		}
+		charge := decimal.Min(available, remaining)
+		if err := tx.Model(&models.Quota{}).Where("id = ?", quotas[i].ID).
+			Update("consumed", gorm.Expr("consumed + ?", charge)).Error; err != nil {
+			return fmt.Errorf("failed to charge quota %d: %w", quotas[i].ID, err)
+		}
+		if err := tx.Create(&models.QuotaConsumption{
+			UserID:    userID,
+			QuotaID:   quotas[i].ID,
+			Amount:    charge,
+			UsedQuota: usedAfter,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to record quota consumption: %w", err)
+		}
+		remaining = remaining.Sub(charge)
+	}
+
+	updates := map[string]interface{}{"used_quota": usedAfter}
+	if remaining.IsPositive() {
+		// Only when held quota expired with its bucket before the commit
+		updates["overused"] = gorm.Expr("overused + ?", remaining)
+	}
+	return s.moveUsageCursor(tx, cursor, updates)
+}
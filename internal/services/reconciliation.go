@@ -0,0 +1,352 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+	"quota-manager/pkg/logger"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultReconcileInterval is the dry-run reconciliation schedule when none is configured
+	DefaultReconcileInterval = "0 0 2 * * *"
+	// reconcileRunTimeout is after how long a running report no longer blocks a new run,
+	// covering runs interrupted by a restart
+	reconcileRunTimeout = 6 * time.Hour
+)
+
+// reconcileBalances are the database balances of one user compared with the gateway
+type reconcileBalances struct {
+	ledgerSum    decimal.Decimal
+	auditNet     decimal.Decimal
+	pendingDelta decimal.Decimal // pending and delivering delta_quota outbox mutations
+	creditLimit  decimal.Decimal // credit line the gateway total holds on top of the ledger
+	undelivered  int64           // pending, delivering and failed outbox mutations
+}
+
+// StartReconciliation creates a report and runs the reconciliation in the background.
+// Fix mode refreshes gateway totals that differ from the ledger; dry-run only reports.
+func (s *QuotaService) StartReconciliation(mode, triggeredBy string) (*models.ReconciliationReport, error) {
+	if mode != models.ReconcileModeDryRun && mode != models.ReconcileModeFix {
+		return nil, NewValidationFailedError(fmt.Sprintf("invalid reconciliation mode '%s', must be dry_run or fix", mode))
+	}
+
+	report, err := s.createReconciliationReport(mode, triggeredBy)
+	if err != nil {
+		return nil, err
+	}
+	go s.runReconciliation(report)
+	return report, nil
+}
+
+// RunScheduledReconciliation runs a dry-run reconciliation for the scheduler
+func (s *QuotaService) RunScheduledReconciliation() {
+	report, err := s.createReconciliationReport(models.ReconcileModeDryRun, "schedule")
+	if err != nil {
+		logger.Warn("Skipping scheduled reconciliation", zap.Error(err))
+		return
+	}
+	s.runReconciliation(report)
+}
+
+// createReconciliationReport records a running report unless another run is in progress
+func (s *QuotaService) createReconciliationReport(mode, triggeredBy string) (*models.ReconciliationReport, error) {
+	var running int64
+	if err := s.db.DB.Model(&models.ReconciliationReport{}).
+		Where("status = ? AND start_time > ?", models.ReconcileStatusRunning, time.Now().Add(-reconcileRunTimeout)).
+		Count(&running).Error; err != nil {
+		return nil, NewDatabaseError("check running reconciliation", err)
+	}
+	if running > 0 {
+		return nil, NewConflictError("a reconciliation is already running")
+	}
+
+	report := &models.ReconciliationReport{
+		Mode:        mode,
+		Status:      models.ReconcileStatusRunning,
+		TriggeredBy: triggeredBy,
+		StartTime:   time.Now(),
+	}
+	if err := s.db.DB.Create(report).Error; err != nil {
+		return nil, NewDatabaseError("create reconciliation report", err)
+	}
+	return report, nil
+}
+
+// runReconciliation compares every user with quota rows or audit records and stores
+// the users whose balances disagree
+func (s *QuotaService) runReconciliation(report *models.ReconciliationReport) {
+	logger.Info("Starting quota reconciliation",
+		zap.Int("report_id", report.ID),
+		zap.String("mode", report.Mode))
+
+	var userIDs []string
+	if err := s.db.DB.Raw("SELECT user_id FROM quota WHERE status = ? UNION SELECT user_id FROM quota_audit ORDER BY user_id",
+		models.StatusValid).Scan(&userIDs).Error; err != nil {
+		s.finishReconciliation(report, fmt.Errorf("failed to load users: %w", err))
+		return
+	}
+
+	report.TotalUsers = len(userIDs)
+	for _, userID := range userIDs {
+		item := s.reconcileUser(userID, report.Mode)
+		if item == nil {
+			report.ConsistentUsers++
+			continue
+		}
+
+		item.ReportID = report.ID
+		if err := s.db.DB.Create(item).Error; err != nil {
+			logger.Error("Failed to record reconciliation item",
+				zap.Int("report_id", report.ID),
+				zap.String("user_id", userID),
+				zap.Error(err))
+		}
+		report.DiscrepancyUsers++
+		if item.Action == models.ReconcileActionRefreshed {
+			report.FixedUsers++
+		}
+	}
+
+	s.finishReconciliation(report, nil)
+	logger.Info("Quota reconciliation completed",
+		zap.Int("report_id", report.ID),
+		zap.Int("total_users", report.TotalUsers),
+		zap.Int("discrepancy_users", report.DiscrepancyUsers),
+		zap.Int("fixed_users", report.FixedUsers))
+}
+
+// finishReconciliation stores the final counts and status of a report
+func (s *QuotaService) finishReconciliation(report *models.ReconciliationReport, runErr error) {
+	now := time.Now()
+	report.EndTime = &now
+	report.Status = models.ReconcileStatusCompleted
+	if runErr != nil {
+		report.Status = models.ReconcileStatusFailed
+		report.Error = runErr.Error()
+		logger.Error("Quota reconciliation failed", zap.Int("report_id", report.ID), zap.Error(runErr))
+	}
+	if err := s.db.DB.Save(report).Error; err != nil {
+		logger.Error("Failed to update reconciliation report", zap.Int("report_id", report.ID), zap.Error(err))
+	}
+}
+
+// loadReconcileBalances reads the ledger, audit and outbox balances of a user's "any model"
+// pool, the one the gateway's plain user counters hold
+func (s *QuotaService) loadReconcileBalances(userID string) (*reconcileBalances, error) {
+	balances := &reconcileBalances{}
+	if err := s.db.DB.Model(&models.Quota{}).
+		Where("user_id = ? AND model = '' AND status = ?", userID, models.StatusValid).
+		Select("COALESCE(SUM(amount), 0)").Scan(&balances.ledgerSum).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum quota: %w", err)
+	}
+	// Credit operations move the credit line and debt, not the ledger, and ledger replays
+	// bring the ledger back to the audit log rather than moving it
+	if err := s.db.DB.Model(&models.QuotaAudit{}).
+		Where("user_id = ? AND model = '' AND operation NOT IN ?", userID,
+			[]string{models.OperationCreditLimit, models.OperationCreditDraw, models.OperationCreditSettle, models.OperationLedgerReplay}).
+		Select("COALESCE(SUM(amount), 0)").Scan(&balances.auditNet).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum audit records: %w", err)
+	}
+	credit, err := s.loadCredit(s.db.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	balances.creditLimit = credit.CreditLimit
+	if err := s.db.DB.Model(&models.GatewayOutbox{}).
+		Where("user_id = ? AND model = '' AND mutation = ? AND status IN ?", userID, models.OutboxMutationDeltaQuota,
+			[]string{models.OutboxStatusPending, models.OutboxStatusDelivering}).
+		Select("COALESCE(SUM(value), 0)").Scan(&balances.pendingDelta).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum pending outbox mutations: %w", err)
+	}
+	if err := s.db.DB.Model(&models.GatewayOutbox{}).
+		Where("user_id = ? AND status IN ?", userID,
+			[]string{models.OutboxStatusPending, models.OutboxStatusDelivering, models.OutboxStatusFailed}).
+		Count(&balances.undelivered).Error; err != nil {
+		return nil, fmt.Errorf("failed to count undelivered outbox mutations: %w", err)
+	}
+	return balances, nil
+}
+
+// reconcileUser classifies the balances of one user and, in fix mode, refreshes a
+// gateway total that differs from the ledger. It returns nil for consistent users.
+func (s *QuotaService) reconcileUser(userID, mode string) *models.ReconciliationItem {
+	balances, err := s.loadReconcileBalances(userID)
+	if err != nil {
+		return &models.ReconciliationItem{
+			UserID:         userID,
+			Classification: models.ReconcileReadError,
+			Action:         models.ReconcileActionNone,
+			Detail:         err.Error(),
+		}
+	}
+
+	item := &models.ReconciliationItem{
+		UserID:       userID,
+		LedgerSum:    balances.ledgerSum,
+		AuditNet:     balances.auditNet,
+		PendingDelta: balances.pendingDelta,
+		Action:       models.ReconcileActionNone,
+	}
+
+	if item.GatewayTotal, err = s.aiGatewayClient.QueryQuotaValue(userID); err == nil {
+		item.GatewayUsed, err = s.aiGatewayClient.QueryUsedQuotaValue(userID)
+	}
+	if err != nil {
+		item.Classification = models.ReconcileReadError
+		item.Detail = err.Error()
+		return item
+	}
+
+	// The gateway total holds the credit line on top of the ledger
+	expectedTotal := item.LedgerSum.Add(balances.creditLimit)
+	creditNote := ""
+	if !balances.creditLimit.IsZero() {
+		creditNote = fmt.Sprintf(" plus credit line %s", balances.creditLimit.StringFixed())
+	}
+
+	switch {
+	case !item.LedgerSum.Equal(item.AuditNet):
+		// The ledger itself is suspect, refreshing the gateway from it could spread the error
+		item.Classification = models.ReconcileLedgerAuditMismatch
+		item.Action = models.ReconcileActionManualReview
+		item.Detail = fmt.Sprintf("quota sum %s differs from audit net %s by %s",
+			item.LedgerSum.StringFixed(), item.AuditNet.StringFixed(), item.LedgerSum.Sub(item.AuditNet).StringFixed())
+	case !item.GatewayTotal.Equal(expectedTotal) && !item.PendingDelta.IsZero() &&
+		item.GatewayTotal.Add(item.PendingDelta).Equal(expectedTotal):
+		item.Classification = models.ReconcilePendingDelivery
+		item.Detail = fmt.Sprintf("gateway total %s plus undelivered %s matches quota sum %s%s",
+			item.GatewayTotal.StringFixed(), item.PendingDelta.StringFixed(), item.LedgerSum.StringFixed(), creditNote)
+	case !item.GatewayTotal.Equal(expectedTotal):
+		item.Classification = models.ReconcileGatewayTotalMismatch
+		item.Detail = fmt.Sprintf("gateway total %s differs from quota sum %s%s by %s",
+			item.GatewayTotal.StringFixed(), item.LedgerSum.StringFixed(), creditNote, item.GatewayTotal.Sub(expectedTotal).StringFixed())
+		if mode == models.ReconcileModeFix {
+			s.fixGatewayTotal(item, balances)
+		}
+	case item.GatewayUsed.GreaterThan(item.GatewayTotal):
+		item.Classification = models.ReconcileGatewayOverused
+		item.Action = models.ReconcileActionManualReview
+		item.Detail = fmt.Sprintf("gateway used %s exceeds gateway total %s",
+			item.GatewayUsed.StringFixed(), item.GatewayTotal.StringFixed())
+	default:
+		return nil
+	}
+	return item
+}
+
+// fixGatewayTotal refreshes the gateway total to the ledger sum plus the credit line. Users with undelivered
+// outbox mutations are left for review, since those would be applied on top of the refresh.
+func (s *QuotaService) fixGatewayTotal(item *models.ReconciliationItem, balances *reconcileBalances) {
+	if balances.undelivered > 0 {
+		item.Action = models.ReconcileActionManualReview
+		item.Detail += fmt.Sprintf("; not refreshed, %d undelivered outbox mutations", balances.undelivered)
+		return
+	}
+
+	expectedTotal := item.LedgerSum.Add(balances.creditLimit)
+	if err := s.aiGatewayClient.RefreshQuota(item.UserID, expectedTotal); err != nil {
+		item.Action = models.ReconcileActionFixFailed
+		item.Detail += "; refresh failed: " + err.Error()
+		return
+	}
+
+	item.Action = models.ReconcileActionRefreshed
+	logger.Info("Reconciliation refreshed gateway quota",
+		zap.String("user_id", item.UserID),
+		zap.Stringer("original_quota", item.GatewayTotal),
+		zap.Stringer("new_quota", expectedTotal))
+}
+
+// GetReconciliationReports lists reconciliation reports, newest first
+func (s *QuotaService) GetReconciliationReports(page, pageSize int) ([]models.ReconciliationReport, int64, error) {
+	var total int64
+	if err := s.db.DB.Model(&models.ReconciliationReport{}).Count(&total).Error; err != nil {
+		return nil, 0, NewDatabaseError("count reconciliation reports", err)
+	}
+
+	var reports []models.ReconciliationReport
+	if err := s.db.DB.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&reports).Error; err != nil {
+		return nil, 0, NewDatabaseError("query reconciliation reports", err)
+	}
+	return reports, total, nil
+}
+
+// GetReconciliationReport gets a reconciliation report
+func (s *QuotaService) GetReconciliationReport(id int) (*models.ReconciliationReport, error) {
+	var report models.ReconciliationReport
+	if err := s.db.DB.First(&report, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, NewResourceNotFoundError("reconciliation report", strconv.Itoa(id))
+		}
+		return nil, NewDatabaseError("query reconciliation report", err)
+	}
+	return &report, nil
+}
+
+// GetReconciliationItems lists the discrepancies of a report, optionally by classification
+func (s *QuotaService) GetReconciliationItems(reportID int, classification string, page, pageSize int) ([]models.ReconciliationItem, int64, error) {
+	query := s.db.DB.Model(&models.ReconciliationItem{}).Where("report_id = ?", reportID)
+	if classification != "" {
+		query = query.Where("classification = ?", classification)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, NewDatabaseError("count reconciliation items", err)
+	}
+
+	var items []models.ReconciliationItem
+	if err := query.Order("user_id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&items).Error; err != nil {
+		return nil, 0, NewDatabaseError("query reconciliation items", err)
+	}
+	return items, total, nil
+}
+
+// ExportReconciliationCSV writes the discrepancies of a report as CSV
+func (s *QuotaService) ExportReconciliationCSV(reportID int, w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"report_id", "user_id", "classification", "ledger_sum", "audit_net", "gateway_total",
+		"gateway_used", "pending_delta", "ledger_audit_diff", "gateway_diff", "action", "detail", "create_time"}); err != nil {
+		return err
+	}
+
+	var items []models.ReconciliationItem
+	result := s.db.DB.Where("report_id = ?", reportID).Order("id").
+		FindInBatches(&items, 500, func(tx *gorm.DB, batch int) error {
+			for _, item := range items {
+				if err := writer.Write([]string{
+					strconv.Itoa(item.ReportID),
+					item.UserID,
+					item.Classification,
+					item.LedgerSum.StringFixed(),
+					item.AuditNet.StringFixed(),
+					item.GatewayTotal.StringFixed(),
+					item.GatewayUsed.StringFixed(),
+					item.PendingDelta.StringFixed(),
+					item.LedgerSum.Sub(item.AuditNet).StringFixed(),
+					item.GatewayTotal.Sub(item.LedgerSum).StringFixed(),
+					item.Action,
+					item.Detail,
+					item.CreateTime.Format(time.RFC3339),
+				}); err != nil {
+					return err
+				}
+			}
+			writer.Flush()
+			return writer.Error()
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
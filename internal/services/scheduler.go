@@ -4,6 +4,8 @@ import (
 	"quota-manager/internal/config"
 	"quota-manager/internal/utils"
 	"quota-manager/pkg/logger"
+	"sync"
+	"time"
 
 	"github.com/robfig/cron/v3"
 	"go.uber.org/zap"
@@ -16,6 +18,8 @@ type SchedulerService struct {
 	employeeSyncService *EmployeeSyncService
 	config              *config.Config
 	cron                *cron.Cron
+	expiryMu            sync.Mutex // serializes expiry runs, a slow sweep is skipped rather than stacked
+	lastExpiryMonth     string     // month whose previous month's used quota is known to be recorded
 }
 
 // NewSchedulerService creates a new scheduler service
@@ -74,11 +78,133 @@ func (s *SchedulerService) Start() error {
 		return err
 	}
 
-	// Add quota expiry task - run at 00:00 on the first day of every month (6 fields with seconds)
-	// Cron expression: second minute hour day month weekday
-	_, err = s.cron.AddFunc("0 0 0 1 * *", s.expireQuotasTask)
+	// Add quota expiry sweep, expiring each bucket shortly after its own expiry date
+	expiryInterval := s.config.Scheduler.ExpirySweepInterval
+	if expiryInterval == "" {
+		expiryInterval = DefaultExpirySweepInterval
+	}
+	_, err = s.cron.AddFunc(expiryInterval, s.sweepExpiredQuotasTask)
+	if err != nil {
+		logger.Error("Failed to add quota expiry task", zap.String("interval", expiryInterval), zap.Error(err))
+		return err
+	}
+
+	// Add upcoming expiry warnings
+	warningInterval := s.config.Scheduler.ExpiryWarningInterval
+	if warningInterval == "" {
+		warningInterval = DefaultExpiryWarningInterval
+	}
+	_, err = s.cron.AddFunc(warningInterval, s.expiryWarningTask)
 	if err != nil {
-		logger.Error("Failed to add quota expiry task", zap.Error(err))
+		logger.Error("Failed to add expiry warning task", zap.String("interval", warningInterval), zap.Error(err))
+		return err
+	}
+
+	// Add topup balance sweep for users who are not actively querying their quota
+	topupInterval := s.config.Scheduler.TopupSweepInterval
+	if topupInterval == "" {
+		topupInterval = DefaultTopupSweepInterval
+	}
+	_, err = s.cron.AddFunc(topupInterval, s.strategyService.SweepTopupStrategies)
+	if err != nil {
+		logger.Error("Failed to add topup sweep task", zap.String("interval", topupInterval), zap.Error(err))
+		return err
+	}
+
+	// Add drip installment release task
+	dripInterval := s.config.Scheduler.DripReleaseInterval
+	if dripInterval == "" {
+		dripInterval = DefaultDripReleaseInterval
+	}
+	_, err = s.cron.AddFunc(dripInterval, s.strategyService.ReleaseDueDripInstallments)
+	if err != nil {
+		logger.Error("Failed to add drip release task", zap.String("interval", dripInterval), zap.Error(err))
+		return err
+	}
+
+	// Add AiGateway outbox dispatcher for mutations not delivered right after commit
+	outboxInterval := s.config.Scheduler.OutboxDispatchInterval
+	if outboxInterval == "" {
+		outboxInterval = DefaultOutboxDispatchInterval
+	}
+	_, err = s.cron.AddFunc(outboxInterval, s.quotaService.DispatchGatewayOutbox)
+	if err != nil {
+		logger.Error("Failed to add outbox dispatch task", zap.String("interval", outboxInterval), zap.Error(err))
+		return err
+	}
+
+	// Retry model cost catalog pushes AiGateway has not acknowledged, on the outbox schedule
+	if s.config.AiGateway.ModelCostPush {
+		_, err = s.cron.AddFunc(outboxInterval, s.quotaService.PushModelCatalog)
+		if err != nil {
+			logger.Error("Failed to add model catalog push task", zap.String("interval", outboxInterval), zap.Error(err))
+			return err
+		}
+	}
+
+	// Add release of quota reservations held past their TTL
+	reservationInterval := s.config.Scheduler.ReservationReleaseInterval
+	if reservationInterval == "" {
+		reservationInterval = DefaultReservationReleaseInterval
+	}
+	_, err = s.cron.AddFunc(reservationInterval, s.quotaService.ReleaseExpiredReservations)
+	if err != nil {
+		logger.Error("Failed to add reservation release task", zap.String("interval", reservationInterval), zap.Error(err))
+		return err
+	}
+
+	// Add department pool draws for members whose personal balance is exhausted
+	poolDrawInterval := s.config.Scheduler.PoolDrawSweepInterval
+	if poolDrawInterval == "" {
+		poolDrawInterval = DefaultPoolDrawSweepInterval
+	}
+	_, err = s.cron.AddFunc(poolDrawInterval, s.quotaService.SweepDepartmentPools)
+	if err != nil {
+		logger.Error("Failed to add department pool draw task", zap.String("interval", poolDrawInterval), zap.Error(err))
+		return err
+	}
+
+	// Add dry-run reconciliation report of ledger, audit log and AiGateway balances
+	reconcileInterval := s.config.Scheduler.ReconcileInterval
+	if reconcileInterval == "" {
+		reconcileInterval = DefaultReconcileInterval
+	}
+	_, err = s.cron.AddFunc(reconcileInterval, s.quotaService.RunScheduledReconciliation)
+	if err != nil {
+		logger.Error("Failed to add reconciliation task", zap.String("interval", reconcileInterval), zap.Error(err))
+		return err
+	}
+
+	// Add verification of the tamper-evident audit hash chains
+	auditVerifyInterval := s.config.Scheduler.AuditVerifyInterval
+	if auditVerifyInterval == "" {
+		auditVerifyInterval = DefaultAuditVerifyInterval
+	}
+	_, err = s.cron.AddFunc(auditVerifyInterval, s.quotaService.RunScheduledAuditChainVerification)
+	if err != nil {
+		logger.Error("Failed to add audit chain verification task", zap.String("interval", auditVerifyInterval), zap.Error(err))
+		return err
+	}
+
+	// Add daily usage snapshot of AiGateway total and used quota
+	usageSnapshotInterval := s.config.Scheduler.UsageSnapshotInterval
+	if usageSnapshotInterval == "" {
+		usageSnapshotInterval = DefaultUsageSnapshotInterval
+	}
+	_, err = s.cron.AddFunc(usageSnapshotInterval, s.quotaService.SnapshotDailyUsage)
+	if err != nil {
+		logger.Error("Failed to add usage snapshot task", zap.String("interval", usageSnapshotInterval), zap.Error(err))
+		return err
+	}
+
+	// Purge idempotency keys whose results are no longer replayed
+	idempotencyPurgeInterval := s.config.Scheduler.IdempotencyPurgeInterval
+	if idempotencyPurgeInterval == "" {
+		idempotencyPurgeInterval = DefaultIdempotencyPurgeInterval
+	}
+	_, err = s.cron.AddFunc(idempotencyPurgeInterval, s.quotaService.PurgeExpiredIdempotencyKeys)
+	if err != nil {
+		logger.Error("Failed to add idempotency key purge task", zap.String("interval", idempotencyPurgeInterval), zap.Error(err))
 		return err
 	}
 
@@ -97,8 +223,64 @@ func (s *SchedulerService) Stop() {
 	logger.Info("Scheduler service stopped")
 }
 
+// sweepExpiredQuotasTask expires buckets past their expiry date. The first run of a new
+// month also records last month's used quota, as the monthly expiry task used to, unless
+// monthly_quota_usage already holds it, so a restart neither skips nor repeats the recording.
+func (s *SchedulerService) sweepExpiredQuotasTask() {
+	if !s.expiryMu.TryLock() {
+		logger.Warn("Previous quota expiry run still in progress, skipping sweep")
+		return
+	}
+	defer s.expiryMu.Unlock()
+
+	now := utils.NowInConfigTimezone(s.config)
+	month := now.Format("2006-01")
+	if month != s.lastExpiryMonth {
+		lastMonth := now.AddDate(0, 0, -now.Day()).Format("2006-01") // same month recordMonthlyUsedQuota records
+		recorded, err := s.quotaService.MonthlyUsageRecorded(lastMonth)
+		if err != nil {
+			logger.Error("Failed to check monthly used quota", zap.String("month", lastMonth), zap.Error(err))
+			return
+		}
+		if recorded {
+			s.lastExpiryMonth = month
+		}
+	}
+	if month != s.lastExpiryMonth {
+		logger.Info("Starting monthly quota expiry task", zap.String("month", month))
+		if err := s.quotaService.ExpireQuotas(); err != nil {
+			logger.Error("Failed to expire quotas", zap.Error(err))
+			return
+		}
+		s.lastExpiryMonth = month
+		logger.Info("Monthly quota expiry task completed")
+		return
+	}
+
+	start := time.Now()
+	if err := s.quotaService.ExpireDueQuotas(); err != nil {
+		logger.Error("Failed to expire due quotas", zap.Error(err))
+		return
+	}
+	logger.Debug("Quota expiry sweep completed", zap.Duration("duration", time.Since(start)))
+}
+
+// expiryWarningTask records notices for buckets about to expire
+func (s *SchedulerService) expiryWarningTask() {
+	days := s.config.Scheduler.ExpiryWarningDays
+	if len(days) == 0 {
+		days = DefaultExpiryWarningDays
+	}
+	if err := s.quotaService.RecordExpiryWarnings(days); err != nil {
+		logger.Error("Failed to record expiry warnings", zap.Error(err))
+	}
+}
+
 // expireQuotasTask handles quota expiry task
 func (s *SchedulerService) expireQuotasTask() {
+	s.expiryMu.Lock()
+	defer s.expiryMu.Unlock()
+
 	logger.Info("Starting quota expiry task")
 
 	if err := s.quotaService.ExpireQuotas(); err != nil {
@@ -7,6 +7,7 @@ import (
 	"quota-manager/internal/database"
 	"quota-manager/internal/models"
 	"quota-manager/pkg/aigateway"
+	"quota-manager/pkg/decimal"
 	"quota-manager/pkg/logger"
 	"strings"
 	"sync"
@@ -66,12 +67,18 @@ func NewStrategyService(db *database.DB, gateway *aigateway.Client, quotaService
 	dbQuerier := &StrategyDatabaseQuerier{db: db}
 	cfgQuerier := &StrategyConfigQuerier{employeeSyncConfig: employeeSyncConfig}
 
+	// quota-le reads the pool of its model when model pools are configured
+	quotaQuerier := condition.NewAiGatewayQuotaQuerier(gateway)
+	if quotaService != nil {
+		quotaQuerier = condition.NewAiGatewayModelQuotaQuerier(gateway, quotaService.ModelPool)
+	}
+
 	return &StrategyService{
 		db:                 db,
 		gateway:            gateway,
-		quotaQuerier:       condition.NewAiGatewayQuotaQuerier(gateway),
+		quotaQuerier:       quotaQuerier,
 		quotaService:       quotaService,
-		cron:               cron.New(cron.WithSeconds()),
+		cron:               cron.New(cron.WithSeconds(), cron.WithLocation(strategyCronLocation())),
 		cronJobs:           make(map[int]cron.EntryID),
 		databaseQuerier:    dbQuerier,
 		configQuerier:      cfgQuerier,
@@ -111,6 +118,9 @@ func (s *StrategyService) registerPeriodicStrategy(strategy *models.QuotaStrateg
 	if strategy.Type != "periodic" || strategy.PeriodicExpr == "" {
 		return fmt.Errorf("strategy %s is not a valid periodic strategy", strategy.Name)
 	}
+	if !strategy.IsApproved() {
+		return fmt.Errorf("strategy %s is awaiting approval (approval status: %s)", strategy.Name, strategy.ApprovalStatus)
+	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -122,7 +132,7 @@ func (s *StrategyService) registerPeriodicStrategy(strategy *models.QuotaStrateg
 	}
 
 	// Add new job
-	entryID, err := s.cron.AddFunc(strategy.PeriodicExpr, func() {
+	entryID, err := s.cron.AddFunc(cronSpecForStrategy(strategy), func() {
 		s.executePeriodicStrategy(strategy.ID)
 	})
 	if err != nil {
@@ -132,7 +142,8 @@ func (s *StrategyService) registerPeriodicStrategy(strategy *models.QuotaStrateg
 	s.cronJobs[strategy.ID] = entryID
 	logger.Info("Registered periodic strategy to cron",
 		zap.String("strategy", strategy.Name),
-		zap.String("expression", strategy.PeriodicExpr))
+		zap.String("expression", strategy.PeriodicExpr),
+		zap.String("timezone", strategy.Timezone))
 	return nil
 }
 
@@ -199,7 +210,7 @@ func (s *StrategyService) loadEnabledPeriodicStrategies() ([]models.QuotaStrateg
 			}
 		}
 
-		err = s.db.Where("status = ? AND type = ?", true, "periodic").Find(&strategies).Error
+		err = s.db.Where("status = ? AND type = ? AND approval_status = ?", true, "periodic", models.ApprovalStatusApproved).Find(&strategies).Error
 		if err == nil {
 			logger.Info("Successfully loaded enabled periodic strategies", zap.Int("count", len(strategies)))
 			return strategies, nil
@@ -270,7 +281,9 @@ func (s *StrategyService) loadEnabledSingleStrategies() ([]models.QuotaStrategy,
 			}
 		}
 
-		err = s.db.Where("status = ? AND type = ?", true, "single").Find(&strategies).Error
+		// Higher priorities run first, so exclusion group winners grant before the others
+		err = s.db.Where("status = ? AND type = ? AND approval_status = ?", true, "single", models.ApprovalStatusApproved).
+			Order("priority DESC, id").Find(&strategies).Error
 		if err == nil {
 			logger.Info("Successfully loaded enabled single strategies", zap.Int("count", len(strategies)))
 			return strategies, nil
@@ -389,6 +402,31 @@ func (s *StrategyService) ExecStrategy(strategy *models.QuotaStrategy, users []m
 		return
 	}
 
+	// Topup strategies grant on low balance and record their own executions
+	if strategy.Type == "topup" {
+		s.execTopupStrategy(strategy, users)
+		return
+	}
+
+	// Shadow strategies only record would-be grants
+	if strategy.IsShadow() {
+		s.execShadowStrategy(strategy, users)
+		return
+	}
+
+	// Exclusion group members are loaded once per run
+	var exclusion *exclusionRun
+	if strategy.ExclusionGroup != "" {
+		run, err := s.loadExclusionRun(strategy)
+		if err != nil {
+			logger.Error("Failed to load exclusion group, skipping strategy",
+				zap.String("strategy", strategy.Name),
+				zap.Error(err))
+			return
+		}
+		exclusion = run
+	}
+
 	batchNumber := s.generateBatchNumber()
 
 	for _, user := range users {
@@ -422,11 +460,7 @@ func (s *StrategyService) ExecStrategy(strategy *models.QuotaStrategy, users []m
 		}
 
 		// Check condition
-		ctx := &condition.EvaluationContext{
-			QuotaQuerier:    s.quotaQuerier,
-			DatabaseQuerier: s.databaseQuerier,
-			ConfigQuerier:   s.configQuerier,
-		}
+		ctx := s.evaluationContext()
 		match, err := condition.CalcCondition(&user, strategy.Condition, ctx)
 		if err != nil {
 			logger.Error("Failed to calculate condition",
@@ -440,8 +474,46 @@ func (s *StrategyService) ExecStrategy(strategy *models.QuotaStrategy, users []m
 			continue
 		}
 
+		// Within an exclusion group only the highest priority matching grant is given
+		if exclusion != nil {
+			reason, err := s.exclusionBlocked(strategy, exclusion, &user, ctx)
+			if err != nil {
+				logger.Error("Failed to check exclusion group",
+					zap.String("user", user.ID),
+					zap.String("strategy", strategy.Name),
+					zap.Error(err))
+				// conservative: skip on error to avoid over-grant
+				continue
+			}
+			if reason != "" {
+				logger.Info("Skip user due to exclusion group",
+					zap.String("user", user.ID),
+					zap.String("strategy", strategy.Name),
+					zap.String("exclusion_group", strategy.ExclusionGroup),
+					zap.String("reason", reason))
+				continue
+			}
+		}
+
+		// Evaluate the per-user amount alongside the condition
+		amount, formula, err := s.resolveGrantAmount(strategy, &user, ctx)
+		if err != nil {
+			logger.Error("Failed to calculate amount",
+				zap.String("user", user.ID),
+				zap.String("strategy", strategy.Name),
+				zap.Error(err))
+			continue
+		}
+		if !amount.IsPositive() {
+			logger.Info("Skip user due to non-positive computed amount",
+				zap.String("user", user.ID),
+				zap.String("strategy", strategy.Name),
+				zap.Stringer("amount", amount))
+			continue
+		}
+
 		// Execute recharge
-		if err := s.executeRecharge(strategy, &user, batchNumber); err != nil {
+		if err := s.executeRecharge(strategy, &user, batchNumber, amount, formula); err != nil {
 			logger.Error("Failed to execute recharge",
 				zap.String("user", user.ID),
 				zap.String("strategy", strategy.Name),
@@ -485,7 +557,7 @@ func (s *StrategyService) hasExecuted(strategyID int, userID string) bool {
 }
 
 // executeRecharge executes recharge
-func (s *StrategyService) executeRecharge(strategy *models.QuotaStrategy, user *models.UserInfo, batchNumber string) error {
+func (s *StrategyService) executeRecharge(strategy *models.QuotaStrategy, user *models.UserInfo, batchNumber string, amount decimal.Decimal, formula *models.AmountFormulaDetail) error {
 	// Strategy should already be validated as enabled before reaching here
 	if !strategy.IsEnabled() {
 		return fmt.Errorf("strategy is disabled")
@@ -511,8 +583,19 @@ func (s *StrategyService) executeRecharge(strategy *models.QuotaStrategy, user *
 		return fmt.Errorf("failed to create execute record: %w", err)
 	}
 
-	// 2. Add quota using QuotaService
-	err := s.quotaService.AddQuotaForStrategy(user.ID, strategy.Amount, strategy.ID, strategy.Name)
+	// 2. Add quota using QuotaService; drip strategies create a grant plan instead,
+	// pool-funding strategies fund the department pool with the user's share
+	var err error
+	switch {
+	case strategy.FundPool != "":
+		err = s.quotaService.FundDepartmentPoolForStrategy(strategy.FundPool, amount, strategy.ID, strategy.Name)
+	case strategy.IsDrip():
+		err = s.executeDripRecharge(strategy, user, amount, formula)
+	case formula != nil:
+		err = s.quotaService.AddQuotaForStrategyWithFormula(user.ID, amount, strategy.ID, strategy.Name, formula)
+	default:
+		err = s.quotaService.AddQuotaForStrategy(user.ID, amount, strategy.ID, strategy.Name)
+	}
 	if err != nil {
 		// Update execution status to failed
 		s.db.Model(execute).Update("status", "failed")
@@ -527,7 +610,7 @@ func (s *StrategyService) executeRecharge(strategy *models.QuotaStrategy, user *
 	logger.Info("Recharge completed",
 		zap.String("user", user.ID),
 		zap.String("strategy", strategy.Name),
-		zap.Float64("amount", strategy.Amount),
+		zap.Stringer("amount", amount),
 		zap.String("model", strategy.Model),
 		zap.Time("expiry_date", expiryDate))
 
@@ -542,39 +625,87 @@ func (s *StrategyService) generateBatchNumber() string {
 
 // CreateStrategy creates a strategy and registers periodic ones to cron
 func (s *StrategyService) CreateStrategy(strategy *models.QuotaStrategy) error {
+	if err := s.createStrategy(s.db.DB, strategy); err != nil {
+		return err
+	}
+
+	// Reload the strategy from the database to get the default value
+	if err := s.db.First(strategy, strategy.ID).Error; err != nil {
+		return fmt.Errorf("failed to reload strategy: %w", err)
+	}
+
+	// Register to cron if it's an enabled periodic strategy
+	if strategy.Type == "periodic" && strategy.IsEnabled() {
+		if err := s.registerPeriodicStrategy(strategy); err != nil {
+			logger.Error("Failed to register periodic strategy to cron",
+				zap.String("strategy", strategy.Name),
+				zap.Error(err))
+			// Don't fail the creation, just log the error
+		}
+	}
+
+	return nil
+}
+
+// createStrategy validates a strategy and writes it with tx. Strategies above the approval
+// thresholds are stored as disabled drafts; cron registration is left to the caller.
+func (s *StrategyService) createStrategy(tx *gorm.DB, strategy *models.QuotaStrategy) error {
 	// Validate cron expression for periodic strategies before saving
 	if strategy.Type == "periodic" {
 		if strategy.PeriodicExpr == "" {
 			return fmt.Errorf("periodic expression cannot be empty for periodic strategy")
 		}
+		if err := ValidateStrategyTimezone(strategy.Timezone, strategy.PeriodicExpr); err != nil {
+			return err
+		}
 		// Test cron expression by trying to add it to a temporary cron instance
 		tempCron := cron.New(cron.WithSeconds())
-		_, err := tempCron.AddFunc(strategy.PeriodicExpr, func() {})
+		_, err := tempCron.AddFunc(cronSpecForStrategy(strategy), func() {})
 		if err != nil {
 			return fmt.Errorf("invalid cron expression '%s': %w", strategy.PeriodicExpr, err)
 		}
 	}
 
-	// Create strategy in database
-	if err := s.db.Create(strategy).Error; err != nil {
-		return fmt.Errorf("failed to create strategy: %w", err)
+	if err := ValidateTopupStrategy(strategy); err != nil {
+		return err
+	}
+	if err := ValidateDripSettings(strategy); err != nil {
+		return err
+	}
+	if err := ValidateAmountExpr(strategy.AmountExpr); err != nil {
+		return err
+	}
+	if err := ValidateExclusionGroup(strategy); err != nil {
+		return err
+	}
+	if err := ValidateExpiryPolicy(strategy); err != nil {
+		return err
+	}
+	if err := ValidateFundPool(strategy); err != nil {
+		return err
 	}
 
-	// Reload the strategy from the database to get the default value
-	if err := s.db.First(strategy, strategy.ID).Error; err != nil {
-		return fmt.Errorf("failed to reload strategy: %w", err)
+	// Strategies above the approval thresholds start as disabled drafts
+	required, err := s.requiresApproval(strategy)
+	if err != nil {
+		return err
+	}
+	strategy.ApprovalStatus = models.ApprovalStatusApproved
+	if required {
+		strategy.ApprovalStatus = models.ApprovalStatusDraft
 	}
 
-	// Register to cron if it's an enabled periodic strategy
-	if strategy.Type == "periodic" && strategy.IsEnabled() {
-		if err := s.registerPeriodicStrategy(strategy); err != nil {
-			logger.Error("Failed to register periodic strategy to cron",
-				zap.String("strategy", strategy.Name),
-				zap.Error(err))
-			// Don't fail the creation, just log the error
-		}
+	// Create strategy in database
+	if err := tx.Create(strategy).Error; err != nil {
+		return fmt.Errorf("failed to create strategy: %w", err)
 	}
 
+	// A false status is a zero value and gets replaced by the column default on create
+	if required {
+		if err := tx.Model(strategy).Update("status", false).Error; err != nil {
+			return fmt.Errorf("failed to disable draft strategy: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -607,8 +738,13 @@ func (s *StrategyService) GetDisabledStrategies() ([]models.QuotaStrategy, error
 
 // GetStrategy gets a single strategy
 func (s *StrategyService) GetStrategy(id int) (*models.QuotaStrategy, error) {
+	return getStrategy(s.db.DB, id)
+}
+
+// getStrategy loads a strategy by ID through db, which may be a transaction
+func getStrategy(db *gorm.DB, id int) (*models.QuotaStrategy, error) {
 	var strategy models.QuotaStrategy
-	if err := s.db.First(&strategy, id).Error; err != nil {
+	if err := db.First(&strategy, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, fmt.Errorf("strategy not found")
 		}
@@ -619,10 +755,61 @@ func (s *StrategyService) GetStrategy(id int) (*models.QuotaStrategy, error) {
 
 // UpdateStrategy updates a strategy and manages cron registration
 func (s *StrategyService) UpdateStrategy(id int, updates map[string]interface{}) error {
+	// Strategies above the approval thresholds cannot be enabled before approval
+	enable, _ := updates["status"].(bool)
+
+	var oldStrategy *models.QuotaStrategy
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var blocked bool
+		var err error
+		oldStrategy, blocked, err = s.updateStrategy(tx, id, updates)
+		if err != nil {
+			return err
+		}
+		if blocked && enable {
+			return NewApprovalRequiredError(fmt.Sprintf("strategy %s requires approval before it can be enabled", oldStrategy.Name))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Get updated strategy
+	newStrategy, err := s.GetStrategy(id)
+	if err != nil {
+		return fmt.Errorf("failed to get updated strategy: %w", err)
+	}
+
+	// Handle cron registration changes
+	if newStrategy.Type == "periodic" {
+		if newStrategy.IsEnabled() {
+			// Register or re-register to cron
+			if err := s.registerPeriodicStrategy(newStrategy); err != nil {
+				logger.Error("Failed to register updated periodic strategy to cron",
+					zap.String("strategy", newStrategy.Name),
+					zap.Error(err))
+			}
+		} else {
+			// Unregister from cron if disabled
+			s.unregisterPeriodicStrategy(newStrategy.ID)
+		}
+	} else if oldStrategy.Type == "periodic" {
+		// Strategy type changed from periodic to single, unregister
+		s.unregisterPeriodicStrategy(id)
+	}
+
+	return nil
+}
+
+// updateStrategy validates updates against the strategy and writes them with tx. It returns
+// the strategy as it was before the update, and whether the approval thresholds keep it
+// disabled; cron registration is left to the caller.
+func (s *StrategyService) updateStrategy(tx *gorm.DB, id int, updates map[string]interface{}) (*models.QuotaStrategy, bool, error) {
 	// Get current strategy
-	oldStrategy, err := s.GetStrategy(id)
+	oldStrategy, err := getStrategy(tx, id)
 	if err != nil {
-		return fmt.Errorf("failed to get strategy: %w", err)
+		return nil, false, fmt.Errorf("failed to get strategy: %w", err)
 	}
 
 	// Validate cron expression if being updated for periodic strategies
@@ -638,48 +825,115 @@ func (s *StrategyService) UpdateStrategy(id int, updates map[string]interface{})
 
 			if strategyType == "periodic" {
 				if periodicExprStr == "" {
-					return fmt.Errorf("periodic expression cannot be empty for periodic strategy")
+					return nil, false, fmt.Errorf("periodic expression cannot be empty for periodic strategy")
 				}
 				// Test cron expression by trying to add it to a temporary cron instance
 				tempCron := cron.New(cron.WithSeconds())
 				_, err := tempCron.AddFunc(periodicExprStr, func() {})
 				if err != nil {
-					return fmt.Errorf("invalid cron expression '%s': %w", periodicExprStr, err)
+					return nil, false, fmt.Errorf("invalid cron expression '%s': %w", periodicExprStr, err)
 				}
 			}
 		}
 	}
 
-	// Update strategy in database
-	if err := s.db.Model(&models.QuotaStrategy{}).Where("id = ?", id).Updates(updates).Error; err != nil {
-		return fmt.Errorf("failed to update strategy: %w", err)
+	// Validate the timezone against the resulting expression
+	if _, tzChanged := updates["timezone"]; tzChanged || updates["periodic_expr"] != nil {
+		candidate := *oldStrategy
+		if tz, ok := updates["timezone"].(string); ok {
+			candidate.Timezone = tz
+		}
+		if expr, ok := updates["periodic_expr"].(string); ok {
+			candidate.PeriodicExpr = expr
+		}
+		if err := ValidateStrategyTimezone(candidate.Timezone, candidate.PeriodicExpr); err != nil {
+			return nil, false, NewValidationFailedError(err.Error())
+		}
 	}
 
-	// Get updated strategy
-	newStrategy, err := s.GetStrategy(id)
+	// Validate the top-up settings of the resulting strategy
+	candidate := *oldStrategy
+	if newType, ok := updates["type"].(string); ok {
+		candidate.Type = newType
+	}
+	if threshold, ok := toDecimal(updates["topup_threshold"]); ok {
+		candidate.TopupThreshold = threshold
+	}
+	if period, ok := updates["topup_period"].(string); ok {
+		candidate.TopupPeriod = period
+	}
+	if maxPerPeriod, ok := updates["topup_max_per_period"].(int); ok {
+		candidate.TopupMaxPerPeriod = maxPerPeriod
+	}
+	if installments, ok := updates["drip_installments"].(int); ok {
+		candidate.DripInstallments = installments
+	}
+	if interval, ok := updates["drip_interval"].(string); ok {
+		candidate.DripInterval = interval
+	}
+	if group, ok := updates["exclusion_group"].(string); ok {
+		candidate.ExclusionGroup = group
+	}
+	if percent, ok := toFloat64(updates["rollover_percent"]); ok {
+		candidate.RolloverPercent = percent
+	}
+	if maxAmount, ok := toDecimal(updates["rollover_max_amount"]); ok {
+		candidate.RolloverMaxAmount = maxAmount
+	}
+	if months, ok := updates["rollover_months"].(int); ok {
+		candidate.RolloverMonths = months
+	}
+	if grace, ok := updates["grace_period"].(string); ok {
+		candidate.GracePeriod = grace
+	}
+	if model, ok := updates["model"].(string); ok {
+		candidate.Model = model
+	}
+	if pool, ok := updates["fund_pool"].(string); ok {
+		candidate.FundPool = pool
+	}
+	if err := ValidateTopupStrategy(&candidate); err != nil {
+		return nil, false, NewValidationFailedError(err.Error())
+	}
+	if err := ValidateExclusionGroup(&candidate); err != nil {
+		return nil, false, NewValidationFailedError(err.Error())
+	}
+	if err := ValidateDripSettings(&candidate); err != nil {
+		return nil, false, NewValidationFailedError(err.Error())
+	}
+	if err := ValidateExpiryPolicy(&candidate); err != nil {
+		return nil, false, NewValidationFailedError(err.Error())
+	}
+	if err := ValidateFundPool(&candidate); err != nil {
+		return nil, false, NewValidationFailedError(err.Error())
+	}
+	if expr, ok := updates["amount_expr"].(string); ok {
+		if err := ValidateAmountExpr(expr); err != nil {
+			return nil, false, NewValidationFailedError(err.Error())
+		}
+	}
+
+	blocked, err := s.enforceApprovalOnUpdate(oldStrategy, updates)
 	if err != nil {
-		return fmt.Errorf("failed to get updated strategy: %w", err)
+		return nil, false, err
 	}
 
-	// Handle cron registration changes
-	if newStrategy.Type == "periodic" {
-		if newStrategy.IsEnabled() {
-			// Register or re-register to cron
-			if err := s.registerPeriodicStrategy(newStrategy); err != nil {
-				logger.Error("Failed to register updated periodic strategy to cron",
-					zap.String("strategy", newStrategy.Name),
-					zap.Error(err))
-			}
-		} else {
-			// Unregister from cron if disabled
-			s.unregisterPeriodicStrategy(newStrategy.ID)
-		}
-	} else if oldStrategy.Type == "periodic" {
-		// Strategy type changed from periodic to single, unregister
-		s.unregisterPeriodicStrategy(id)
+	// Update strategy in database
+	if err := tx.Model(&models.QuotaStrategy{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to update strategy: %w", err)
 	}
 
-	return nil
+	// A change that sends a submitted or approved strategy back to draft revokes its approval
+	if status, ok := updates["approval_status"].(string); ok && status == models.ApprovalStatusDraft {
+		newStrategy, err := getStrategy(tx, id)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get updated strategy: %w", err)
+		}
+		if err := s.recordApprovalRevoked(tx, newStrategy); err != nil {
+			return nil, false, err
+		}
+	}
+	return oldStrategy, blocked, nil
 }
 
 // EnableStrategy enables a strategy and registers periodic ones to cron
@@ -701,18 +955,41 @@ func (s *StrategyService) DeleteStrategy(id int) error {
 
 	// Use transaction to ensure data consistency
 	return s.db.Transaction(func(tx *gorm.DB) error {
-		// First, delete all related execution records
-		if err := tx.Where("strategy_id = ?", id).Delete(&models.QuotaExecute{}).Error; err != nil {
-			return fmt.Errorf("failed to delete related execution records: %w", err)
-		}
+		return s.deleteStrategy(tx, id)
+	})
+}
 
-		// Then delete the strategy itself
-		if err := tx.Delete(&models.QuotaStrategy{}, id).Error; err != nil {
-			return fmt.Errorf("failed to delete strategy: %w", err)
-		}
+// deleteStrategy deletes a strategy and its execution, shadow, top-up and drip records with tx;
+// cron unregistration is left to the caller
+func (s *StrategyService) deleteStrategy(tx *gorm.DB, id int) error {
+	// First, delete all related execution records
+	if err := tx.Where("strategy_id = ?", id).Delete(&models.QuotaExecute{}).Error; err != nil {
+		return fmt.Errorf("failed to delete related execution records: %w", err)
+	}
 
-		return nil
-	})
+	if err := tx.Where("strategy_id = ?", id).Delete(&models.StrategyShadowResult{}).Error; err != nil {
+		return fmt.Errorf("failed to delete related shadow results: %w", err)
+	}
+
+	if err := tx.Where("strategy_id = ?", id).Delete(&models.TopupExecute{}).Error; err != nil {
+		return fmt.Errorf("failed to delete related topup records: %w", err)
+	}
+
+	// Outstanding drip installments are dropped with the strategy
+	planIDs := tx.Model(&models.DripPlan{}).Select("id").Where("strategy_id = ?", id)
+	if err := tx.Where("plan_id IN (?)", planIDs).Delete(&models.DripInstallment{}).Error; err != nil {
+		return fmt.Errorf("failed to delete related drip installments: %w", err)
+	}
+	if err := tx.Where("strategy_id = ?", id).Delete(&models.DripPlan{}).Error; err != nil {
+		return fmt.Errorf("failed to delete related drip plans: %w", err)
+	}
+
+	// Then delete the strategy itself
+	if err := tx.Delete(&models.QuotaStrategy{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete strategy: %w", err)
+	}
+
+	return nil
 }
 
 // GetStrategyExecuteRecords gets execution records for a strategy
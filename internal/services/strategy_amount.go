@@ -0,0 +1,102 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"quota-manager/internal/condition"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+
+	"gorm.io/gorm"
+)
+
+// QueryMonthlyUsedQuota implements condition.UsageQuerier with the recorded monthly usage
+func (q *StrategyDatabaseQuerier) QueryMonthlyUsedQuota(userID, yearMonth string) (float64, error) {
+	var usage models.MonthlyQuotaUsage
+	err := q.db.DB.Where("user_id = ? AND year_month = ?", userID, yearMonth).
+		Order("record_time DESC").
+		First(&usage).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, nil // No usage recorded for the month
+		}
+		return 0, fmt.Errorf("failed to query monthly usage: %w", err)
+	}
+	return usage.UsedQuota.Float64(), nil
+}
+
+// ValidateAmountExpr checks the syntax, variables and functions of an amount expression
+func ValidateAmountExpr(expr string) error {
+	if expr == "" {
+		return nil
+	}
+	if _, err := condition.ParseAmountExpr(expr); err != nil {
+		return fmt.Errorf("invalid amount expression: %w", err)
+	}
+	return nil
+}
+
+// evaluationContext returns the dependencies for condition and amount evaluation
+func (s *StrategyService) evaluationContext() *condition.EvaluationContext {
+	ctx := &condition.EvaluationContext{
+		QuotaQuerier:    s.quotaQuerier,
+		DatabaseQuerier: s.databaseQuerier,
+		ConfigQuerier:   s.configQuerier,
+	}
+	if usageQuerier, ok := s.databaseQuerier.(condition.UsageQuerier); ok {
+		ctx.UsageQuerier = usageQuerier
+	}
+	return ctx
+}
+
+// resolveGrantAmount returns the amount a user is granted by the strategy. Strategies
+// with an amount expression evaluate it per user, capped by the strategy amount when
+// that is positive; the returned formula detail records the inputs for the audit log.
+func (s *StrategyService) resolveGrantAmount(strategy *models.QuotaStrategy, user *models.UserInfo, ctx *condition.EvaluationContext) (decimal.Decimal, *models.AmountFormulaDetail, error) {
+	if strategy.AmountExpr == "" {
+		return strategy.Amount, nil, nil
+	}
+
+	computed, inputs, err := condition.CalcAmount(user, strategy.AmountExpr, ctx)
+	if err != nil {
+		return decimal.Zero, nil, fmt.Errorf("failed to evaluate amount expression: %w", err)
+	}
+
+	computedAmount := decimal.NewFromFloat(computed)
+	amount := computedAmount
+	if strategy.Amount.IsPositive() {
+		amount = decimal.Min(amount, strategy.Amount)
+	}
+
+	return amount, &models.AmountFormulaDetail{
+		Expression:     strategy.AmountExpr,
+		Inputs:         inputs,
+		ComputedAmount: computedAmount,
+		Cap:            strategy.Amount,
+		Amount:         amount,
+	}, nil
+}
+
+// marshalAmountFormula encodes a formula detail for storage, empty when there is none
+func marshalAmountFormula(formula *models.AmountFormulaDetail) string {
+	if formula == nil {
+		return ""
+	}
+	data, err := json.Marshal(formula)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// unmarshalAmountFormula decodes a stored formula detail, nil when there is none
+func unmarshalAmountFormula(data string) *models.AmountFormulaDetail {
+	if data == "" {
+		return nil
+	}
+	var formula models.AmountFormulaDetail
+	if err := json.Unmarshal([]byte(data), &formula); err != nil {
+		return nil
+	}
+	return &formula
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"fmt"
+	"quota-manager/internal/condition"
+	"quota-manager/internal/config"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+	"quota-manager/pkg/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// materialStrategyFields are the fields that change who gets how much quota.
+// Changing any of them on an approved strategy drops the approval.
+var materialStrategyFields = []string{"type", "amount", "amount_expr", "model", "periodic_expr", "condition", "max_exec_per_user", "shadow",
+	"topup_threshold", "topup_period", "topup_max_per_period", "drip_installments", "drip_interval", "exclusion_group", "priority",
+	"rollover_percent", "rollover_max_amount", "rollover_months", "grace_period", "fund_pool"}
+
+// approvalThresholds returns the configured amount and audience thresholds
+func approvalThresholds() (decimal.Decimal, int) {
+	cfg := config.GetGlobalConfig()
+	if cfg == nil {
+		return decimal.Zero, 0
+	}
+	return cfg.StrategyApproval.AmountThreshold, cfg.StrategyApproval.AudienceThreshold
+}
+
+// SimulateAudience counts the users the strategy condition currently matches
+func (s *StrategyService) SimulateAudience(strategy *models.QuotaStrategy) (int, error) {
+	users, err := s.loadUsers()
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := s.evaluationContext()
+
+	audience := 0
+	for i := range users {
+		// Users whose condition fails to evaluate are skipped, as ExecStrategy does
+		match, err := condition.CalcCondition(&users[i], strategy.Condition, ctx)
+		if err != nil {
+			continue
+		}
+		if match {
+			audience++
+		}
+	}
+	return audience, nil
+}
+
+// requiresApproval checks the strategy against the approval thresholds.
+// The audience is only simulated when the amount alone does not decide it.
+func (s *StrategyService) requiresApproval(strategy *models.QuotaStrategy) (bool, error) {
+	// Shadow strategies never grant quota
+	if strategy.IsShadow() {
+		return false, nil
+	}
+
+	amountThreshold, audienceThreshold := approvalThresholds()
+	if amountThreshold.IsPositive() {
+		// A formula without a positive cap can grant any amount
+		if strategy.AmountExpr != "" && !strategy.Amount.IsPositive() {
+			return true, nil
+		}
+		if strategy.Amount.GreaterThan(amountThreshold) {
+			return true, nil
+		}
+	}
+	if audienceThreshold <= 0 {
+		return false, nil
+	}
+
+	audience, err := s.SimulateAudience(strategy)
+	if err != nil {
+		return false, fmt.Errorf("failed to simulate audience: %w", err)
+	}
+	return audience > audienceThreshold, nil
+}
+
+// enforceApprovalOnUpdate rewrites updates so that a strategy above the thresholds
+// stays disabled until it has been approved. It reports whether the strategy is blocked
+// from being enabled; callers decide whether a requested enable is an error.
+func (s *StrategyService) enforceApprovalOnUpdate(old *models.QuotaStrategy, updates map[string]interface{}) (bool, error) {
+	// Approval status is only changed through the approval workflow
+	delete(updates, "approval_status")
+
+	candidate := *old
+	material := false
+	for _, field := range materialStrategyFields {
+		value, exists := updates[field]
+		if !exists {
+			continue
+		}
+		if fmt.Sprint(value) != fmt.Sprint(strategyFieldValue(old, field)) {
+			material = true
+		}
+		switch field {
+		case "amount":
+			if amount, ok := toDecimal(value); ok {
+				candidate.Amount = amount
+			}
+		case "amount_expr":
+			if expr, ok := value.(string); ok {
+				candidate.AmountExpr = expr
+			}
+		case "condition":
+			if cond, ok := value.(string); ok {
+				candidate.Condition = cond
+			}
+		case "shadow":
+			if shadow, ok := value.(bool); ok {
+				candidate.Shadow = shadow
+			}
+		}
+	}
+
+	if !material && old.IsApproved() {
+		return false, nil
+	}
+
+	required, err := s.requiresApproval(&candidate)
+	if err != nil {
+		return false, err
+	}
+	if !required {
+		if !old.IsApproved() {
+			updates["approval_status"] = models.ApprovalStatusApproved
+		}
+		return false, nil
+	}
+
+	if material && old.ApprovalStatus != models.ApprovalStatusDraft {
+		updates["approval_status"] = models.ApprovalStatusDraft
+	}
+	updates["status"] = false
+	return true, nil
+}
+
+// strategyFieldValue returns the current value of a material strategy field
+func strategyFieldValue(strategy *models.QuotaStrategy, field string) interface{} {
+	switch field {
+	case "type":
+		return strategy.Type
+	case "amount":
+		return strategy.Amount
+	case "amount_expr":
+		return strategy.AmountExpr
+	case "model":
+		return strategy.Model
+	case "periodic_expr":
+		return strategy.PeriodicExpr
+	case "condition":
+		return strategy.Condition
+	case "max_exec_per_user":
+		return strategy.MaxExecPerUser
+	case "shadow":
+		return strategy.Shadow
+	case "topup_threshold":
+		return strategy.TopupThreshold
+	case "topup_period":
+		return strategy.TopupPeriod
+	case "topup_max_per_period":
+		return strategy.TopupMaxPerPeriod
+	case "drip_installments":
+		return strategy.DripInstallments
+	case "drip_interval":
+		return strategy.DripInterval
+	case "exclusion_group":
+		return strategy.ExclusionGroup
+	case "priority":
+		return strategy.Priority
+	case "rollover_percent":
+		return strategy.RolloverPercent
+	case "rollover_max_amount":
+		return strategy.RolloverMaxAmount
+	case "rollover_months":
+		return strategy.RolloverMonths
+	case "grace_period":
+		return strategy.GracePeriod
+	case "fund_pool":
+		return strategy.FundPool
+	}
+	return nil
+}
+
+// toFloat64 converts numeric update values to float64
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case decimal.Decimal:
+		return v.Float64(), true
+	}
+	return 0, false
+}
+
+// toDecimal converts an amount from an updates map to a decimal
+func toDecimal(value interface{}) (decimal.Decimal, bool) {
+	if d, ok := value.(decimal.Decimal); ok {
+		return d, true
+	}
+	f, ok := toFloat64(value)
+	if !ok {
+		return decimal.Zero, false
+	}
+	return decimal.NewFromFloat(f), true
+}
+
+// SubmitStrategyForApproval moves a draft strategy to pending and records the simulated audience
+func (s *StrategyService) SubmitStrategyForApproval(id int, operator, comment string) (*models.StrategyApproval, error) {
+	strategy, err := s.GetStrategy(id)
+	if err != nil {
+		return nil, NewResourceNotFoundError("strategy", fmt.Sprintf("%d", id))
+	}
+	if strategy.ApprovalStatus != models.ApprovalStatusDraft {
+		return nil, NewConflictError(fmt.Sprintf("strategy %s is %s, only draft strategies can be submitted", strategy.Name, strategy.ApprovalStatus))
+	}
+
+	required, err := s.requiresApproval(strategy)
+	if err != nil {
+		return nil, err
+	}
+	if !required {
+		return nil, NewValidationFailedError(fmt.Sprintf("strategy %s is below the approval thresholds and does not need approval", strategy.Name))
+	}
+	audience, err := s.SimulateAudience(strategy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to simulate audience: %w", err)
+	}
+
+	record := &models.StrategyApproval{
+		StrategyID:   strategy.ID,
+		StrategyName: strategy.Name,
+		Action:       models.ApprovalActionSubmit,
+		Operator:     operator,
+		Amount:       strategy.Amount,
+		AudienceSize: audience,
+		Comment:      comment,
+	}
+	if err := s.transitionApproval(strategy, models.ApprovalStatusDraft, models.ApprovalStatusPending, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// ApproveStrategy approves a pending strategy. The approver must not be the admin who submitted it.
+func (s *StrategyService) ApproveStrategy(id int, approver, comment string) (*models.StrategyApproval, error) {
+	strategy, err := s.GetStrategy(id)
+	if err != nil {
+		return nil, NewResourceNotFoundError("strategy", fmt.Sprintf("%d", id))
+	}
+	if strategy.ApprovalStatus != models.ApprovalStatusPending {
+		return nil, NewConflictError(fmt.Sprintf("strategy %s is %s, only pending strategies can be approved", strategy.Name, strategy.ApprovalStatus))
+	}
+
+	submission, err := s.latestSubmission(strategy.ID)
+	if err != nil {
+		return nil, err
+	}
+	if submission.Operator == approver {
+		return nil, NewValidationFailedError("a strategy must be approved by a different admin than the one who submitted it")
+	}
+
+	record := &models.StrategyApproval{
+		StrategyID:   strategy.ID,
+		StrategyName: strategy.Name,
+		Action:       models.ApprovalActionApprove,
+		Operator:     approver,
+		Amount:       strategy.Amount,
+		AudienceSize: submission.AudienceSize,
+		Comment:      comment,
+	}
+	if err := s.transitionApproval(strategy, models.ApprovalStatusPending, models.ApprovalStatusApproved, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// RejectStrategy sends a pending strategy back to draft
+func (s *StrategyService) RejectStrategy(id int, operator, comment string) (*models.StrategyApproval, error) {
+	strategy, err := s.GetStrategy(id)
+	if err != nil {
+		return nil, NewResourceNotFoundError("strategy", fmt.Sprintf("%d", id))
+	}
+	if strategy.ApprovalStatus != models.ApprovalStatusPending {
+		return nil, NewConflictError(fmt.Sprintf("strategy %s is %s, only pending strategies can be rejected", strategy.Name, strategy.ApprovalStatus))
+	}
+
+	submission, err := s.latestSubmission(strategy.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.StrategyApproval{
+		StrategyID:   strategy.ID,
+		StrategyName: strategy.Name,
+		Action:       models.ApprovalActionReject,
+		Operator:     operator,
+		Amount:       strategy.Amount,
+		AudienceSize: submission.AudienceSize,
+		Comment:      comment,
+	}
+	if err := s.transitionApproval(strategy, models.ApprovalStatusPending, models.ApprovalStatusDraft, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// GetStrategyApprovals gets the approval audit trail of a strategy
+func (s *StrategyService) GetStrategyApprovals(strategyID int) ([]models.StrategyApproval, error) {
+	var records []models.StrategyApproval
+	if err := s.db.Where("strategy_id = ?", strategyID).
+		Order("create_time ASC, id ASC").
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to query strategy approvals: %w", err)
+	}
+	return records, nil
+}
+
+// GetStrategiesByApprovalStatus gets strategies in the given approval status
+func (s *StrategyService) GetStrategiesByApprovalStatus(approvalStatus string) ([]models.QuotaStrategy, error) {
+	var strategies []models.QuotaStrategy
+	if err := s.db.Where("approval_status = ?", approvalStatus).Find(&strategies).Error; err != nil {
+		return nil, fmt.Errorf("failed to query strategies by approval status: %w", err)
+	}
+	return strategies, nil
+}
+
+// latestSubmission returns the submission the current pending state is based on
+func (s *StrategyService) latestSubmission(strategyID int) (*models.StrategyApproval, error) {
+	var submission models.StrategyApproval
+	if err := s.db.Where("strategy_id = ? AND action = ?", strategyID, models.ApprovalActionSubmit).
+		Order("create_time DESC, id DESC").
+		First(&submission).Error; err != nil {
+		return nil, NewDatabaseError("query strategy submission", err)
+	}
+	return &submission, nil
+}
+
+// transitionApproval changes the approval status and writes the audit record atomically.
+// The status guard in the WHERE clause makes concurrent decisions on the same strategy fail.
+func (s *StrategyService) transitionApproval(strategy *models.QuotaStrategy, from, to string, record *models.StrategyApproval) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.QuotaStrategy{}).
+			Where("id = ? AND approval_status = ?", strategy.ID, from).
+			Update("approval_status", to)
+		if result.Error != nil {
+			return NewDatabaseError("update strategy approval status", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return NewConflictError(fmt.Sprintf("strategy %s approval status changed concurrently", strategy.Name))
+		}
+		if err := tx.Create(record).Error; err != nil {
+			return NewDatabaseError("record strategy approval", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Strategy approval status changed",
+		zap.String("strategy", strategy.Name),
+		zap.String("action", record.Action),
+		zap.String("operator", record.Operator),
+		zap.String("from", from),
+		zap.String("to", to),
+		zap.Int("audience_size", record.AudienceSize))
+	return nil
+}
+
+// recordApprovalRevoked writes a revoke entry with tx when a change sends a submitted or approved strategy back to draft
+func (s *StrategyService) recordApprovalRevoked(tx *gorm.DB, strategy *models.QuotaStrategy) error {
+	record := &models.StrategyApproval{
+		StrategyID:   strategy.ID,
+		StrategyName: strategy.Name,
+		Action:       models.ApprovalActionRevoke,
+		Operator:     "system",
+		Amount:       strategy.Amount,
+		Comment:      "grant changed after submission",
+	}
+	if err := tx.Create(record).Error; err != nil {
+		return fmt.Errorf("failed to record strategy approval revocation: %w", err)
+	}
+	return nil
+}
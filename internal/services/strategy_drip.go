@@ -0,0 +1,286 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+	"quota-manager/pkg/logger"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultDripReleaseInterval is the installment release schedule when none is configured
+	DefaultDripReleaseInterval = "0 */5 * * * *"
+	// MinDripInterval is the shortest allowed time between installments
+	MinDripInterval = time.Minute
+)
+
+// DripCancelResult summarizes cancelled installments
+type DripCancelResult struct {
+	CancelledPlans        int             `json:"cancelled_plans"`
+	CancelledInstallments int             `json:"cancelled_installments"`
+	CancelledAmount       decimal.Decimal `json:"cancelled_amount"`
+}
+
+// ValidateDripSettings checks the drip schedule of a strategy
+func ValidateDripSettings(strategy *models.QuotaStrategy) error {
+	if strategy.DripInstallments < 0 {
+		return fmt.Errorf("drip_installments must be >= 0")
+	}
+	if !strategy.IsDrip() {
+		return nil
+	}
+	if strategy.Type == "topup" {
+		return fmt.Errorf("drip grants are not supported for topup strategies")
+	}
+	if strategy.DripInterval == "" {
+		return fmt.Errorf("drip_interval is required when drip_installments is greater than 1")
+	}
+	interval, err := time.ParseDuration(strategy.DripInterval)
+	if err != nil {
+		return fmt.Errorf("invalid drip_interval '%s': %w", strategy.DripInterval, err)
+	}
+	if interval < MinDripInterval {
+		return fmt.Errorf("drip_interval must be at least %s", MinDripInterval)
+	}
+	return nil
+}
+
+// splitDripAmount splits total into n installments of whole cents; the last one takes the remainder
+func splitDripAmount(total decimal.Decimal, n int) []decimal.Decimal {
+	amounts := make([]decimal.Decimal, n)
+	each := total.DivTrunc(int64(n))
+	rest := total
+	for i := 0; i < n-1; i++ {
+		amounts[i] = each
+		rest = rest.Sub(each)
+	}
+	amounts[n-1] = rest
+	return amounts
+}
+
+// executeDripRecharge creates a grant plan for the user and releases the first installment right away
+func (s *StrategyService) executeDripRecharge(strategy *models.QuotaStrategy, user *models.UserInfo, amount decimal.Decimal, formula *models.AmountFormulaDetail) error {
+	interval, err := time.ParseDuration(strategy.DripInterval)
+	if err != nil {
+		return fmt.Errorf("invalid drip interval: %w", err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	plan := &models.DripPlan{
+		StrategyID:    strategy.ID,
+		StrategyName:  strategy.Name,
+		User:          user.ID,
+		TotalAmount:   amount,
+		Installments:  strategy.DripInstallments,
+		Interval:      strategy.DripInterval,
+		AmountFormula: marshalAmountFormula(formula),
+		Status:        models.DripPlanStatusActive,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(plan).Error; err != nil {
+			return fmt.Errorf("failed to create drip plan: %w", err)
+		}
+		for i, amount := range splitDripAmount(amount, strategy.DripInstallments) {
+			installment := &models.DripInstallment{
+				PlanID:      plan.ID,
+				Sequence:    i + 1,
+				Amount:      amount,
+				ReleaseTime: now.Add(time.Duration(i) * interval),
+				Status:      models.DripInstallmentPending,
+			}
+			if err := tx.Create(installment).Error; err != nil {
+				return fmt.Errorf("failed to create drip installment: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Drip plan created",
+		zap.String("user", user.ID),
+		zap.String("strategy", strategy.Name),
+		zap.Int("plan_id", plan.ID),
+		zap.Stringer("total_amount", plan.TotalAmount),
+		zap.Int("installments", plan.Installments))
+
+	// The first installment is due now; if it fails the release job retries it
+	var first models.DripInstallment
+	if err := s.db.Where("plan_id = ? AND sequence = ?", plan.ID, 1).First(&first).Error; err != nil {
+		return fmt.Errorf("failed to load first drip installment: %w", err)
+	}
+	if err := s.releaseDripInstallment(plan, &first); err != nil {
+		logger.Warn("Failed to release first drip installment, it will be retried",
+			zap.Int("plan_id", plan.ID),
+			zap.Error(err))
+	}
+	return nil
+}
+
+// ReleaseDueDripInstallments releases every pending installment whose release time has passed
+func (s *StrategyService) ReleaseDueDripInstallments() {
+	var due []models.DripInstallment
+	if err := s.db.Where("status = ? AND release_time <= ?", models.DripInstallmentPending, time.Now()).
+		Order("release_time, id").
+		Find(&due).Error; err != nil {
+		logger.Error("Failed to load due drip installments", zap.Error(err))
+		return
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	released := 0
+	for i := range due {
+		var plan models.DripPlan
+		if err := s.db.First(&plan, due[i].PlanID).Error; err != nil {
+			logger.Error("Failed to load drip plan",
+				zap.Int("plan_id", due[i].PlanID),
+				zap.Error(err))
+			continue
+		}
+		if plan.Status != models.DripPlanStatusActive {
+			continue
+		}
+		if err := s.releaseDripInstallment(&plan, &due[i]); err != nil {
+			logger.Error("Failed to release drip installment",
+				zap.Int("plan_id", plan.ID),
+				zap.Int("sequence", due[i].Sequence),
+				zap.Error(err))
+			continue
+		}
+		released++
+	}
+
+	logger.Info("Drip installments released",
+		zap.Int("due", len(due)),
+		zap.Int("released", released))
+}
+
+// errDripInstallmentTaken reports an installment released or cancelled by a concurrent run
+var errDripInstallmentTaken = errors.New("drip installment is no longer pending")
+
+// releaseDripInstallment grants a pending installment as a separate quota bucket. The
+// installment is marked released in the grant transaction, so a failure or crash at any
+// point leaves it either pending or released together with its quota.
+func (s *StrategyService) releaseDripInstallment(plan *models.DripPlan, installment *models.DripInstallment) error {
+	now := time.Now().Truncate(time.Second)
+	markReleased := func(tx *gorm.DB) error {
+		// Concurrent runs and cancels block on this row; only one sees it still pending
+		result := tx.Model(&models.DripInstallment{}).
+			Where("id = ? AND status = ?", installment.ID, models.DripInstallmentPending).
+			Updates(map[string]interface{}{
+				"status":        models.DripInstallmentReleased,
+				"released_time": now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to mark drip installment released: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errDripInstallmentTaken
+		}
+		if err := tx.Model(&models.DripPlan{}).Where("id = ?", plan.ID).
+			Update("released_amount", gorm.Expr("released_amount + ?", installment.Amount)).Error; err != nil {
+			return fmt.Errorf("failed to update drip plan: %w", err)
+		}
+
+		var outstanding int64
+		if err := tx.Model(&models.DripInstallment{}).
+			Where("plan_id = ? AND status = ?", plan.ID, models.DripInstallmentPending).
+			Count(&outstanding).Error; err != nil {
+			return fmt.Errorf("failed to count outstanding installments: %w", err)
+		}
+		if outstanding == 0 {
+			return tx.Model(&models.DripPlan{}).Where("id = ? AND status = ?", plan.ID, models.DripPlanStatusActive).
+				Update("status", models.DripPlanStatusCompleted).Error
+		}
+		return nil
+	}
+
+	err := s.quotaService.AddQuotaForDripInstallment(plan.User, installment.Amount, plan.StrategyID, plan.StrategyName,
+		installment.Sequence, plan.Installments, unmarshalAmountFormula(plan.AmountFormula), markReleased)
+	if errors.Is(err, errDripInstallmentTaken) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to release drip installment: %w", err)
+	}
+	return nil
+}
+
+// GetUserDripPlans gets a user's drip plans with their installments, optionally filtered by status
+func (s *StrategyService) GetUserDripPlans(userID, status string) ([]models.DripPlan, error) {
+	var plans []models.DripPlan
+	query := s.db.Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sequence")
+	}).Order("create_time DESC, id DESC").Find(&plans).Error; err != nil {
+		return nil, NewDatabaseError("query drip plans", err)
+	}
+	return plans, nil
+}
+
+// CancelUserDripPlans cancels the outstanding installments of a user's active drip plans.
+// A planID of 0 cancels all of them. Released installments are not taken back.
+func (s *StrategyService) CancelUserDripPlans(userID string, planID int) (*DripCancelResult, error) {
+	result := &DripCancelResult{}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var plans []models.DripPlan
+		query := tx.Where("user_id = ? AND status = ?", userID, models.DripPlanStatusActive)
+		if planID > 0 {
+			query = query.Where("id = ?", planID)
+		}
+		if err := query.Find(&plans).Error; err != nil {
+			return NewDatabaseError("query drip plans", err)
+		}
+		if planID > 0 && len(plans) == 0 {
+			return NewResourceNotFoundError("active drip plan", fmt.Sprintf("%d", planID))
+		}
+
+		for _, plan := range plans {
+			var pending []models.DripInstallment
+			if err := tx.Where("plan_id = ? AND status = ?", plan.ID, models.DripInstallmentPending).
+				Find(&pending).Error; err != nil {
+				return NewDatabaseError("query drip installments", err)
+			}
+			if err := tx.Model(&models.DripInstallment{}).
+				Where("plan_id = ? AND status = ?", plan.ID, models.DripInstallmentPending).
+				Update("status", models.DripInstallmentCancelled).Error; err != nil {
+				return NewDatabaseError("cancel drip installments", err)
+			}
+			if err := tx.Model(&models.DripPlan{}).Where("id = ?", plan.ID).
+				Update("status", models.DripPlanStatusCancelled).Error; err != nil {
+				return NewDatabaseError("cancel drip plan", err)
+			}
+
+			result.CancelledPlans++
+			result.CancelledInstallments += len(pending)
+			for _, installment := range pending {
+				result.CancelledAmount = result.CancelledAmount.Add(installment.Amount)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Drip plans cancelled",
+		zap.String("user", userID),
+		zap.Int("plans", result.CancelledPlans),
+		zap.Int("installments", result.CancelledInstallments),
+		zap.Stringer("amount", result.CancelledAmount))
+
+	return result, nil
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"fmt"
+	"quota-manager/internal/condition"
+	"quota-manager/internal/models"
+	"quota-manager/internal/utils"
+	"sort"
+	"time"
+)
+
+// ExclusionGroupSummary describes the strategies sharing an exclusion group
+type ExclusionGroupSummary struct {
+	Group     string                 `json:"group"`
+	Members   []ExclusionGroupMember `json:"members"` // highest priority first
+	Conflicts []string               `json:"conflicts,omitempty"`
+}
+
+// ExclusionGroupMember is one strategy of an exclusion group
+type ExclusionGroupMember struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Priority int    `json:"priority"`
+	Enabled  bool   `json:"enabled"`
+	Shadow   bool   `json:"shadow,omitempty"`
+}
+
+// exclusionRun holds the group state loaded once per ExecStrategy run
+type exclusionRun struct {
+	periodStart time.Time
+	runStart    time.Time
+	members     []models.QuotaStrategy // live members other than the executing strategy
+}
+
+// ValidateExclusionGroup checks the exclusion settings of a strategy
+func ValidateExclusionGroup(strategy *models.QuotaStrategy) error {
+	if strategy.ExclusionGroup == "" {
+		return nil
+	}
+	if len(strategy.ExclusionGroup) > 100 {
+		return fmt.Errorf("exclusion_group must be at most 100 characters")
+	}
+	if strategy.Type == "topup" {
+		return fmt.Errorf("exclusion groups are not supported for topup strategies")
+	}
+	return nil
+}
+
+// exclusionPeriodStart returns the start of the firing period within which a user receives
+// at most one grant per exclusion group. A periodic strategy's period starts at its previous
+// fire time: the run belongs to its last fire time at or before now, however late it started,
+// and the period reaches back to the fire before that. Single strategies, which run on every
+// scan, use the calendar month containing now.
+func exclusionPeriodStart(strategy *models.QuotaStrategy, now time.Time) time.Time {
+	if strategy.Type == "periodic" {
+		spec := cronSpecForStrategy(strategy)
+		current, err := utils.PrevCronTime(spec, now.Location(), now.Truncate(time.Second).Add(time.Second))
+		if err == nil && !current.IsZero() {
+			prev, err := utils.PrevCronTime(spec, now.Location(), current)
+			if err == nil && !prev.IsZero() {
+				return prev
+			}
+		}
+	}
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+}
+
+// loadExclusionRun loads the other live strategies of the strategy's exclusion group
+func (s *StrategyService) loadExclusionRun(strategy *models.QuotaStrategy) (*exclusionRun, error) {
+	now := time.Now().In(strategyCronLocation())
+	run := &exclusionRun{
+		periodStart: exclusionPeriodStart(strategy, now),
+		runStart:    now,
+	}
+	if err := s.db.Where("exclusion_group = ? AND id <> ? AND status = ? AND approval_status = ? AND shadow = ?",
+		strategy.ExclusionGroup, strategy.ID, true, models.ApprovalStatusApproved, false).
+		Find(&run.members).Error; err != nil {
+		return nil, fmt.Errorf("failed to load exclusion group %s: %w", strategy.ExclusionGroup, err)
+	}
+	return run, nil
+}
+
+// exclusionBlocked reports whether the user must not receive the strategy's grant
+// in this period, and why. A user is blocked when another strategy of the group with
+// the same or a higher priority already granted them in this period, or when a higher
+// priority strategy that has not fired yet in this period matches them. Repeated
+// grants of the strategy itself stay governed by its own type and limits.
+func (s *StrategyService) exclusionBlocked(strategy *models.QuotaStrategy, run *exclusionRun, user *models.UserInfo, ctx *condition.EvaluationContext) (string, error) {
+	if len(run.members) == 0 {
+		return "", nil
+	}
+
+	ids := make([]int, 0, len(run.members))
+	members := make(map[int]models.QuotaStrategy, len(run.members))
+	for _, member := range run.members {
+		ids = append(ids, member.ID)
+		members[member.ID] = member
+	}
+
+	var granted []int
+	if err := s.db.Model(&models.QuotaExecute{}).
+		Where("user_id = ? AND strategy_id IN ? AND status IN ? AND create_time >= ?",
+			user.ID, ids, []string{"processing", "completed"}, run.periodStart).
+		Distinct().Pluck("strategy_id", &granted).Error; err != nil {
+		return "", fmt.Errorf("failed to query exclusion group grants: %w", err)
+	}
+	for _, id := range granted {
+		if other := members[id]; other.Priority >= strategy.Priority {
+			return fmt.Sprintf("already granted by %s (priority %d) in this period", other.Name, other.Priority), nil
+		}
+	}
+
+	for i := range run.members {
+		member := &run.members[i]
+		if member.Priority <= strategy.Priority || s.firedInPeriod(member, run) || s.exhaustedForUser(member, user.ID) {
+			continue
+		}
+		match, err := condition.CalcCondition(user, member.Condition, ctx)
+		if err != nil || !match {
+			continue
+		}
+		return fmt.Sprintf("deferred to higher priority %s (priority %d)", member.Name, member.Priority), nil
+	}
+	return "", nil
+}
+
+// firedInPeriod reports whether a periodic strategy has already fired in this period,
+// before the current run. Single strategies run on every scan and never count as fired.
+func (s *StrategyService) firedInPeriod(strategy *models.QuotaStrategy, run *exclusionRun) bool {
+	if strategy.Type != "periodic" {
+		return false
+	}
+	times, err := utils.NextCronTimes(cronSpecForStrategy(strategy), strategyCronLocation(), run.periodStart.Add(-time.Second), 1)
+	if err != nil || len(times) == 0 {
+		return false
+	}
+	// Strategies firing in the same second as this run count as not yet fired
+	return times[0].Before(run.runStart.Truncate(time.Second))
+}
+
+// exhaustedForUser reports whether the strategy can no longer grant the user, so
+// lower priorities need not wait for it
+func (s *StrategyService) exhaustedForUser(strategy *models.QuotaStrategy, userID string) bool {
+	limit := strategy.MaxExecPerUser
+	if strategy.Type == "single" {
+		limit = 1
+	}
+	if limit <= 0 {
+		return false
+	}
+
+	var count int64
+	if err := s.db.Model(&models.QuotaExecute{}).
+		Where("strategy_id = ? AND user_id = ? AND status = ?", strategy.ID, userID, "completed").
+		Count(&count).Error; err != nil {
+		// conservative: keep deferring to avoid over-grant
+		return false
+	}
+	return count >= int64(limit)
+}
+
+// GetExclusionGroups summarizes every exclusion group and flags enabled live members
+// that share a priority, since their winner depends on which fires first
+func (s *StrategyService) GetExclusionGroups() ([]ExclusionGroupSummary, error) {
+	var strategies []models.QuotaStrategy
+	if err := s.db.Where("exclusion_group <> ''").Order("exclusion_group, priority DESC, id").
+		Find(&strategies).Error; err != nil {
+		return nil, fmt.Errorf("failed to load exclusion groups: %w", err)
+	}
+
+	var summaries []ExclusionGroupSummary
+	byGroup := make(map[string]int)
+	for _, strategy := range strategies {
+		idx, exists := byGroup[strategy.ExclusionGroup]
+		if !exists {
+			idx = len(summaries)
+			byGroup[strategy.ExclusionGroup] = idx
+			summaries = append(summaries, ExclusionGroupSummary{Group: strategy.ExclusionGroup})
+		}
+		summaries[idx].Members = append(summaries[idx].Members, ExclusionGroupMember{
+			ID:       strategy.ID,
+			Name:     strategy.Name,
+			Type:     strategy.Type,
+			Priority: strategy.Priority,
+			Enabled:  strategy.IsEnabled(),
+			Shadow:   strategy.IsShadow(),
+		})
+	}
+
+	for i := range summaries {
+		byPriority := make(map[int][]string)
+		for _, member := range summaries[i].Members {
+			if member.Enabled && !member.Shadow {
+				byPriority[member.Priority] = append(byPriority[member.Priority], member.Name)
+			}
+		}
+		var priorities []int
+		for priority, names := range byPriority {
+			if len(names) > 1 {
+				priorities = append(priorities, priority)
+			}
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+		for _, priority := range priorities {
+			summaries[i].Conflicts = append(summaries[i].Conflicts,
+				fmt.Sprintf("strategies %v share priority %d, the first to fire wins", byPriority[priority], priority))
+		}
+	}
+
+	return summaries, nil
+}
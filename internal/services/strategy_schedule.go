@@ -0,0 +1,104 @@
+package services
+
+import (
+	"fmt"
+	"quota-manager/internal/config"
+	"quota-manager/internal/models"
+	"quota-manager/internal/utils"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultCronPreviewCount is the number of fire times returned with a strategy
+	DefaultCronPreviewCount = 5
+	// MaxCronPreviewCount caps the standalone preview endpoint
+	MaxCronPreviewCount = 50
+)
+
+// CronPreview describes when a cron expression fires
+type CronPreview struct {
+	Expression    string      `json:"expression"`
+	Timezone      string      `json:"timezone"`
+	Description   string      `json:"description"`
+	NextFireTimes []time.Time `json:"next_fire_times"`
+}
+
+// strategyCronLocation returns the configured timezone used by the strategy cron
+func strategyCronLocation() *time.Location {
+	cfg := config.GetGlobalConfig()
+	if cfg == nil {
+		return time.Local
+	}
+	return utils.GetTimezone(cfg)
+}
+
+// cronSpecForStrategy returns the cron spec with the strategy's own timezone, if any
+func cronSpecForStrategy(strategy *models.QuotaStrategy) string {
+	if strategy.Timezone == "" {
+		return strategy.PeriodicExpr
+	}
+	return "CRON_TZ=" + strategy.Timezone + " " + strategy.PeriodicExpr
+}
+
+// ValidateStrategyTimezone checks the per-strategy timezone and rejects inline TZ prefixes,
+// which would conflict with the timezone field
+func ValidateStrategyTimezone(timezone, periodicExpr string) error {
+	if strings.HasPrefix(periodicExpr, "TZ=") || strings.HasPrefix(periodicExpr, "CRON_TZ=") {
+		return fmt.Errorf("periodic expression must not contain a timezone prefix, use the timezone field instead")
+	}
+	if timezone == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("invalid timezone '%s': %w", timezone, err)
+	}
+	return nil
+}
+
+// PreviewCron returns the description and next fire times of expr. An empty
+// timezone means the configured timezone.
+func PreviewCron(expr, timezone string, count int) (*CronPreview, error) {
+	if err := ValidateStrategyTimezone(timezone, expr); err != nil {
+		return nil, NewValidationFailedError(err.Error())
+	}
+	if count <= 0 {
+		count = DefaultCronPreviewCount
+	}
+	if count > MaxCronPreviewCount {
+		count = MaxCronPreviewCount
+	}
+
+	loc := strategyCronLocation()
+	if timezone != "" {
+		loc, _ = time.LoadLocation(timezone)
+	}
+
+	description, err := utils.DescribeCron(expr)
+	if err != nil {
+		return nil, NewValidationFailedError(err.Error())
+	}
+	times, err := utils.NextCronTimes(expr, loc, time.Now(), count)
+	if err != nil {
+		return nil, NewValidationFailedError(err.Error())
+	}
+
+	return &CronPreview{
+		Expression:    expr,
+		Timezone:      loc.String(),
+		Description:   description,
+		NextFireTimes: times,
+	}, nil
+}
+
+// PreviewStrategySchedule returns the schedule preview of a periodic strategy, or nil for other types
+func (s *StrategyService) PreviewStrategySchedule(strategy *models.QuotaStrategy) *CronPreview {
+	if strategy.Type != "periodic" || strategy.PeriodicExpr == "" {
+		return nil
+	}
+	preview, err := PreviewCron(strategy.PeriodicExpr, strategy.Timezone, DefaultCronPreviewCount)
+	if err != nil {
+		return nil
+	}
+	return preview
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"quota-manager/internal/condition"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/logger"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ShadowComparison compares a shadow strategy's would-be grants with a live strategy's actual grants
+type ShadowComparison struct {
+	ShadowStrategyID int                      `json:"shadow_strategy_id"`
+	LiveStrategyID   int                      `json:"live_strategy_id"`
+	StartTime        time.Time                `json:"start_time"`
+	EndTime          time.Time                `json:"end_time"`
+	Summary          ShadowComparisonSummary  `json:"summary"`
+	Users            []ShadowComparisonResult `json:"users"`
+}
+
+// ShadowComparisonSummary contains totals for a shadow comparison
+type ShadowComparisonSummary struct {
+	ShadowUsers     int     `json:"shadow_users"`
+	LiveUsers       int     `json:"live_users"`
+	BothUsers       int     `json:"both_users"`
+	ShadowOnlyUsers int     `json:"shadow_only_users"`
+	LiveOnlyUsers   int     `json:"live_only_users"`
+	ShadowAmount    float64 `json:"shadow_amount"`
+	LiveAmount      float64 `json:"live_amount"`
+}
+
+// ShadowComparisonResult is one user's line in a shadow comparison
+type ShadowComparisonResult struct {
+	UserID       string  `json:"user_id"`
+	ShadowAmount float64 `json:"shadow_amount"`
+	ShadowGrants int     `json:"shadow_grants"`
+	LiveAmount   float64 `json:"live_amount"`
+	LiveGrants   int     `json:"live_grants"`
+	Difference   float64 `json:"difference"` // shadow_amount - live_amount
+}
+
+// grantTotal is the per-user aggregate used by comparisons
+type grantTotal struct {
+	UserID string
+	Amount float64
+	Grants int
+}
+
+// execShadowStrategy evaluates a shadow strategy and records would-be grants.
+// It never creates quota or calls the AiGateway.
+func (s *StrategyService) execShadowStrategy(strategy *models.QuotaStrategy, users []models.UserInfo) {
+	batchNumber := s.generateBatchNumber()
+	recorded := 0
+
+	for _, user := range users {
+		// Mirror the live execution limits against the shadow results
+		if strategy.Type == "single" || (strategy.Type == "periodic" && strategy.MaxExecPerUser > 0) {
+			var count int64
+			if err := s.db.Model(&models.StrategyShadowResult{}).
+				Where("strategy_id = ? AND user_id = ?", strategy.ID, user.ID).
+				Count(&count).Error; err != nil {
+				logger.Error("Failed to count shadow results",
+					zap.Int("strategy_id", strategy.ID),
+					zap.String("user", user.ID),
+					zap.Error(err))
+				continue
+			}
+			if strategy.Type == "single" && count > 0 {
+				continue
+			}
+			if strategy.Type == "periodic" && count >= int64(strategy.MaxExecPerUser) {
+				continue
+			}
+		}
+
+		ctx := s.evaluationContext()
+		match, trace, err := condition.CalcConditionWithTrace(&user, strategy.Condition, ctx)
+		if err != nil {
+			logger.Error("Failed to calculate condition",
+				zap.String("user", user.ID),
+				zap.String("strategy", strategy.Name),
+				zap.Error(err))
+			continue
+		}
+		if !match {
+			continue
+		}
+
+		amount, formula, err := s.resolveGrantAmount(strategy, &user, ctx)
+		if err != nil {
+			logger.Error("Failed to calculate amount",
+				zap.String("user", user.ID),
+				zap.String("strategy", strategy.Name),
+				zap.Error(err))
+			continue
+		}
+		if formula != nil {
+			inputs, _ := json.Marshal(formula.Inputs)
+			trace = append(trace, fmt.Sprintf("amount %s %s => %s", formula.Expression, inputs, amount.StringFixed()))
+		}
+		if !amount.IsPositive() {
+			continue
+		}
+
+		traceJSON, _ := json.Marshal(trace)
+		result := &models.StrategyShadowResult{
+			StrategyID:     strategy.ID,
+			StrategyName:   strategy.Name,
+			UserID:         user.ID,
+			Amount:         amount,
+			BatchNumber:    batchNumber,
+			Condition:      strategy.Condition,
+			ConditionTrace: string(traceJSON),
+		}
+		if err := s.db.Create(result).Error; err != nil {
+			logger.Error("Failed to record shadow result",
+				zap.String("user", user.ID),
+				zap.String("strategy", strategy.Name),
+				zap.Error(err))
+			continue
+		}
+		recorded++
+	}
+
+	logger.Info("Shadow strategy run completed",
+		zap.String("strategy", strategy.Name),
+		zap.String("batch_number", batchNumber),
+		zap.Int("would_be_grants", recorded))
+}
+
+// GetShadowResults gets recorded would-be grants for a shadow strategy
+func (s *StrategyService) GetShadowResults(strategyID int, page, pageSize int) ([]models.StrategyShadowResult, int64, error) {
+	var results []models.StrategyShadowResult
+	var total int64
+
+	if err := s.db.Model(&models.StrategyShadowResult{}).Where("strategy_id = ?", strategyID).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count shadow results: %w", err)
+	}
+
+	offset := (page - 1) * pageSize
+	if err := s.db.Where("strategy_id = ?", strategyID).
+		Order("create_time DESC, id DESC").
+		Offset(offset).
+		Limit(pageSize).
+		Find(&results).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query shadow results: %w", err)
+	}
+
+	return results, total, nil
+}
+
+// CompareShadowStrategy compares a shadow strategy's would-be grants with the
+// RECHARGE audit records of a live strategy within [startTime, endTime)
+func (s *StrategyService) CompareShadowStrategy(shadowID, liveID int, startTime, endTime time.Time) (*ShadowComparison, error) {
+	shadow, err := s.GetStrategy(shadowID)
+	if err != nil {
+		return nil, NewResourceNotFoundError("strategy", fmt.Sprintf("%d", shadowID))
+	}
+	if !shadow.IsShadow() {
+		return nil, NewValidationFailedError(fmt.Sprintf("strategy %s is not a shadow strategy", shadow.Name))
+	}
+	live, err := s.GetStrategy(liveID)
+	if err != nil {
+		return nil, NewResourceNotFoundError("strategy", fmt.Sprintf("%d", liveID))
+	}
+	if live.IsShadow() {
+		return nil, NewValidationFailedError(fmt.Sprintf("strategy %s is a shadow strategy, compare against a live strategy", live.Name))
+	}
+
+	var shadowTotals []grantTotal
+	if err := s.db.Model(&models.StrategyShadowResult{}).
+		Select("user_id, SUM(amount) AS amount, COUNT(*) AS grants").
+		Where("strategy_id = ? AND create_time >= ? AND create_time < ?", shadowID, startTime, endTime).
+		Group("user_id").
+		Scan(&shadowTotals).Error; err != nil {
+		return nil, NewDatabaseError("aggregate shadow results", err)
+	}
+
+	var liveTotals []grantTotal
+	if err := s.db.Model(&models.QuotaAudit{}).
+		Select("user_id, SUM(amount) AS amount, COUNT(*) AS grants").
+		Where("strategy_id = ? AND operation = ? AND create_time >= ? AND create_time < ?",
+			liveID, models.OperationRecharge, startTime, endTime).
+		Group("user_id").
+		Scan(&liveTotals).Error; err != nil {
+		return nil, NewDatabaseError("aggregate live grants", err)
+	}
+
+	byUser := make(map[string]*ShadowComparisonResult)
+	lineFor := func(userID string) *ShadowComparisonResult {
+		line, exists := byUser[userID]
+		if !exists {
+			line = &ShadowComparisonResult{UserID: userID}
+			byUser[userID] = line
+		}
+		return line
+	}
+
+	comparison := &ShadowComparison{
+		ShadowStrategyID: shadowID,
+		LiveStrategyID:   liveID,
+		StartTime:        startTime,
+		EndTime:          endTime,
+	}
+	for _, total := range shadowTotals {
+		line := lineFor(total.UserID)
+		line.ShadowAmount = total.Amount
+		line.ShadowGrants = total.Grants
+		comparison.Summary.ShadowAmount += total.Amount
+	}
+	for _, total := range liveTotals {
+		line := lineFor(total.UserID)
+		line.LiveAmount = total.Amount
+		line.LiveGrants = total.Grants
+		comparison.Summary.LiveAmount += total.Amount
+	}
+
+	comparison.Users = make([]ShadowComparisonResult, 0, len(byUser))
+	for _, line := range byUser {
+		line.Difference = line.ShadowAmount - line.LiveAmount
+		switch {
+		case line.ShadowGrants > 0 && line.LiveGrants > 0:
+			comparison.Summary.BothUsers++
+		case line.ShadowGrants > 0:
+			comparison.Summary.ShadowOnlyUsers++
+		default:
+			comparison.Summary.LiveOnlyUsers++
+		}
+		comparison.Users = append(comparison.Users, *line)
+	}
+	comparison.Summary.ShadowUsers = len(shadowTotals)
+	comparison.Summary.LiveUsers = len(liveTotals)
+
+	sort.Slice(comparison.Users, func(i, j int) bool {
+		return comparison.Users[i].UserID < comparison.Users[j].UserID
+	})
+
+	return comparison, nil
+}
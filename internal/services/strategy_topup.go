@@ -0,0 +1,360 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"quota-manager/internal/condition"
+	"quota-manager/internal/config"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+	"quota-manager/pkg/logger"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultTopupSweepInterval is the balance sweep schedule when none is configured
+	DefaultTopupSweepInterval = "0 */15 * * * *"
+	// DefaultTopupSweepConcurrency bounds concurrent AiGateway balance queries during a sweep
+	DefaultTopupSweepConcurrency = 10
+)
+
+// ValidateTopupStrategy checks the top-up settings of a topup strategy
+func ValidateTopupStrategy(strategy *models.QuotaStrategy) error {
+	if strategy.Type != "topup" {
+		return nil
+	}
+	if !strategy.TopupThreshold.IsPositive() {
+		return fmt.Errorf("topup_threshold must be greater than 0 for topup strategy")
+	}
+	if strategy.TopupMaxPerPeriod < 0 {
+		return fmt.Errorf("topup_max_per_period must be >= 0")
+	}
+	if strategy.TopupMaxPerPeriod > 0 && strategy.TopupPeriod == "" {
+		return fmt.Errorf("topup_period is required when topup_max_per_period is set")
+	}
+	switch strategy.TopupPeriod {
+	case "", "day", "week", "month":
+	default:
+		return fmt.Errorf("invalid topup_period '%s', must be day, week or month", strategy.TopupPeriod)
+	}
+	return nil
+}
+
+// topupPeriodKey identifies the cap window containing t
+func topupPeriodKey(period string, t time.Time) string {
+	switch period {
+	case "day":
+		return t.Format("2006-01-02")
+	case "week":
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case "month":
+		return t.Format("2006-01")
+	default:
+		return "all"
+	}
+}
+
+// topupSweepConcurrency returns the configured sweep concurrency
+func topupSweepConcurrency() int {
+	if cfg := config.GetGlobalConfig(); cfg != nil && cfg.Scheduler.TopupSweepConcurrency > 0 {
+		return cfg.Scheduler.TopupSweepConcurrency
+	}
+	return DefaultTopupSweepConcurrency
+}
+
+// loadEnabledTopupStrategies loads enabled and approved topup strategies
+func (s *StrategyService) loadEnabledTopupStrategies() ([]models.QuotaStrategy, error) {
+	var strategies []models.QuotaStrategy
+	if err := s.db.Where("status = ? AND type = ? AND approval_status = ?", true, "topup", models.ApprovalStatusApproved).
+		Find(&strategies).Error; err != nil {
+		return nil, fmt.Errorf("failed to load topup strategies: %w", err)
+	}
+	return strategies, nil
+}
+
+// CheckTopupForUser runs the topup strategies for a user whose remaining balance
+// was just observed. It is registered as the QuotaService balance observer.
+func (s *StrategyService) CheckTopupForUser(userID string, remaining decimal.Decimal) {
+	strategies, err := s.loadEnabledTopupStrategies()
+	if err != nil {
+		logger.Error("Failed to load topup strategies", zap.Error(err))
+		return
+	}
+
+	// Nothing can trigger, avoid the user lookup
+	triggered := false
+	for _, strategy := range strategies {
+		if remaining.LessThan(strategy.TopupThreshold) {
+			triggered = true
+			break
+		}
+	}
+	if !triggered {
+		return
+	}
+
+	var user models.UserInfo
+	if err := s.db.AuthDB.Where("id = ?", userID).First(&user).Error; err != nil {
+		logger.Error("Failed to load user for topup check",
+			zap.String("user", userID),
+			zap.Error(err))
+		return
+	}
+
+	candidates := s.topupCandidates(strategies, &user)
+	s.applyTopups(candidates, &user, remaining)
+}
+
+// SweepTopupStrategies checks the balance of every user with a matching topup
+// strategy, so users who are not actively querying their quota are topped up too
+func (s *StrategyService) SweepTopupStrategies() {
+	strategies, err := s.loadEnabledTopupStrategies()
+	if err != nil {
+		logger.Error("Failed to load topup strategies", zap.Error(err))
+		return
+	}
+	if len(strategies) == 0 {
+		return
+	}
+
+	users, err := s.loadUsers()
+	if err != nil {
+		logger.Error("Failed to load users for topup sweep", zap.Error(err))
+		return
+	}
+
+	logger.Info("Running topup sweep",
+		zap.Int("strategy_count", len(strategies)),
+		zap.Int("user_count", len(users)))
+
+	s.sweepTopupUsers(strategies, users)
+}
+
+// execTopupStrategy runs a single topup strategy against the given users
+func (s *StrategyService) execTopupStrategy(strategy *models.QuotaStrategy, users []models.UserInfo) {
+	s.sweepTopupUsers([]models.QuotaStrategy{*strategy}, users)
+}
+
+// sweepTopupUsers evaluates conditions first and only queries the AiGateway balance
+// of users with at least one candidate strategy, with bounded concurrency
+func (s *StrategyService) sweepTopupUsers(strategies []models.QuotaStrategy, users []models.UserInfo) {
+	sem := make(chan struct{}, topupSweepConcurrency())
+	var wg sync.WaitGroup
+
+	for i := range users {
+		user := &users[i]
+		candidates := s.topupCandidates(strategies, user)
+		if len(candidates) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			remaining, err := s.quotaService.QueryRemainingQuota(user.ID)
+			if err != nil {
+				logger.Error("Failed to query remaining quota for topup",
+					zap.String("user", user.ID),
+					zap.Error(err))
+				return
+			}
+			s.applyTopups(candidates, user, remaining)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// topupCandidates returns the strategies whose period cap is not reached and whose condition matches the user
+func (s *StrategyService) topupCandidates(strategies []models.QuotaStrategy, user *models.UserInfo) []models.QuotaStrategy {
+	var candidates []models.QuotaStrategy
+	for i := range strategies {
+		strategy := &strategies[i]
+		if reached, err := s.topupCapReached(strategy, user.ID); err != nil || reached {
+			continue
+		}
+
+		ctx := s.evaluationContext()
+		match, err := condition.CalcCondition(user, strategy.Condition, ctx)
+		if err != nil {
+			logger.Error("Failed to calculate condition",
+				zap.String("user", user.ID),
+				zap.String("strategy", strategy.Name),
+				zap.Error(err))
+			continue
+		}
+		if match {
+			candidates = append(candidates, *strategy)
+		}
+	}
+	return candidates
+}
+
+// topupCapReached checks the per-user, per-period top-up cap
+func (s *StrategyService) topupCapReached(strategy *models.QuotaStrategy, userID string) (bool, error) {
+	if strategy.TopupMaxPerPeriod <= 0 {
+		return false, nil
+	}
+
+	var count int64
+	periodKey := topupPeriodKey(strategy.TopupPeriod, time.Now())
+	if err := s.db.Model(&models.TopupExecute{}).
+		Where("strategy_id = ? AND user_id = ? AND period_key = ? AND status IN ?",
+			strategy.ID, userID, periodKey, []string{"processing", "completed", "shadow"}).
+		Count(&count).Error; err != nil {
+		logger.Error("Failed to count topup executions",
+			zap.Int("strategy_id", strategy.ID),
+			zap.String("user", userID),
+			zap.Error(err))
+		// conservative: treat as reached to avoid over-grant
+		return true, err
+	}
+	return count >= int64(strategy.TopupMaxPerPeriod), nil
+}
+
+// applyTopups tops up the user for every candidate strategy whose threshold is above the balance
+func (s *StrategyService) applyTopups(candidates []models.QuotaStrategy, user *models.UserInfo, remaining decimal.Decimal) {
+	if len(candidates) == 0 {
+		return
+	}
+
+	for i := range candidates {
+		strategy := &candidates[i]
+		if remaining.GreaterThanOrEqual(strategy.TopupThreshold) {
+			continue
+		}
+		// Re-check, a concurrent check may have topped up already
+		if reached, err := s.topupCapReached(strategy, user.ID); err != nil || reached {
+			continue
+		}
+
+		amount, err := s.executeTopup(strategy, user, remaining)
+		if err != nil {
+			logger.Error("Failed to execute topup",
+				zap.String("user", user.ID),
+				zap.String("strategy", strategy.Name),
+				zap.Error(err))
+			continue
+		}
+		if !strategy.IsShadow() {
+			remaining = remaining.Add(amount)
+		}
+	}
+}
+
+// claimTopupSlot records a top-up under the next attempt number of its period. Concurrent
+// checks of the same user, in this process or another replica, compute the same number and
+// only one of them inserts it; it reports false for the others.
+func (s *StrategyService) claimTopupSlot(execute *models.TopupExecute) (bool, error) {
+	var attempts int64
+	if err := s.db.Model(&models.TopupExecute{}).
+		Where("strategy_id = ? AND user_id = ? AND period_key = ?", execute.StrategyID, execute.User, execute.PeriodKey).
+		Count(&attempts).Error; err != nil {
+		return false, fmt.Errorf("failed to count topup records: %w", err)
+	}
+	execute.Seq = int(attempts) + 1
+
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(execute)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to create topup record: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// executeTopup records and grants one top-up; shadow strategies only record it.
+// A top-up claimed concurrently by another check returns a zero amount.
+func (s *StrategyService) executeTopup(strategy *models.QuotaStrategy, user *models.UserInfo, remaining decimal.Decimal) (decimal.Decimal, error) {
+	amount, formula, err := s.resolveGrantAmount(strategy, user, s.evaluationContext())
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if !amount.IsPositive() {
+		return decimal.Zero, fmt.Errorf("computed amount %s is not positive", amount.StringFixed())
+	}
+
+	execute := &models.TopupExecute{
+		StrategyID:    strategy.ID,
+		User:          user.ID,
+		PeriodKey:     topupPeriodKey(strategy.TopupPeriod, time.Now()),
+		BalanceBefore: remaining,
+		Amount:        amount,
+		Status:        "processing",
+	}
+
+	if strategy.IsShadow() {
+		execute.Status = "shadow"
+	}
+	claimed, err := s.claimTopupSlot(execute)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if !claimed {
+		logger.Info("Topup already claimed by a concurrent check",
+			zap.String("user", user.ID),
+			zap.String("strategy", strategy.Name))
+		return decimal.Zero, nil
+	}
+
+	if strategy.IsShadow() {
+		trace, _ := json.Marshal([]string{fmt.Sprintf("remaining balance %s < threshold %s", remaining.StringFixed(), strategy.TopupThreshold.StringFixed())})
+		result := &models.StrategyShadowResult{
+			StrategyID:     strategy.ID,
+			StrategyName:   strategy.Name,
+			UserID:         user.ID,
+			Amount:         amount,
+			BatchNumber:    s.generateBatchNumber(),
+			Condition:      strategy.Condition,
+			ConditionTrace: string(trace),
+		}
+		if err := s.db.Create(result).Error; err != nil {
+			return decimal.Zero, fmt.Errorf("failed to record shadow result: %w", err)
+		}
+		return amount, nil
+	}
+
+	if err := s.quotaService.AddQuotaForTopup(user.ID, amount, strategy.ID, strategy.Name, formula); err != nil {
+		s.db.Model(execute).Update("status", "failed")
+		return decimal.Zero, fmt.Errorf("failed to top up quota: %w", err)
+	}
+
+	if err := s.db.Model(execute).Update("status", "completed").Error; err != nil {
+		logger.Error("Failed to update topup status", zap.Error(err))
+	}
+
+	logger.Info("Topup completed",
+		zap.String("user", user.ID),
+		zap.String("strategy", strategy.Name),
+		zap.Stringer("balance_before", remaining),
+		zap.Stringer("amount", amount))
+
+	return amount, nil
+}
+
+// GetTopupExecuteRecords gets top-up execution records of a strategy
+func (s *StrategyService) GetTopupExecuteRecords(strategyID int, page, pageSize int) ([]models.TopupExecute, int64, error) {
+	var records []models.TopupExecute
+	var total int64
+
+	if err := s.db.Model(&models.TopupExecute{}).Where("strategy_id = ?", strategyID).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count topup records: %w", err)
+	}
+
+	offset := (page - 1) * pageSize
+	if err := s.db.Where("strategy_id = ?", strategyID).
+		Order("create_time DESC, id DESC").
+		Offset(offset).
+		Limit(pageSize).
+		Find(&records).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query topup records: %w", err)
+	}
+
+	return records, total, nil
+}
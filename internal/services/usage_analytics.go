@@ -0,0 +1,402 @@
+package services
+
+import (
+	"fmt"
+	"quota-manager/internal/config"
+	"quota-manager/internal/models"
+	"quota-manager/internal/utils"
+	"quota-manager/pkg/decimal"
+	"quota-manager/pkg/logger"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultUsageSnapshotInterval is the daily usage snapshot schedule when none is configured
+	DefaultUsageSnapshotInterval = "0 30 0 * * *"
+	// DefaultUsageSnapshotConcurrency bounds concurrent AiGateway queries during a snapshot
+	DefaultUsageSnapshotConcurrency = 10
+	// DefaultUsageRangeDays is the date range of usage analytics when the caller sets none
+	DefaultUsageRangeDays = 30
+	// MaxUsageRangeDays bounds the date range of usage analytics
+	MaxUsageRangeDays = 366
+	// DefaultTopConsumersLimit is how many top consumers are listed when the caller sets no limit
+	DefaultTopConsumersLimit = 10
+	// MaxTopConsumersLimit bounds the top consumers listing
+	MaxTopConsumersLimit = 100
+	// DefaultUsageTrendMonths is how many months a trend covers when the caller sets none
+	DefaultUsageTrendMonths = 6
+	// MaxUsageTrendMonths bounds the months of a trend
+	MaxUsageTrendMonths = 24
+
+	usageDateLayout = "2006-01-02"
+	// usageLookupBatchSize bounds the IN lists of the department lookup
+	usageLookupBatchSize = 1000
+)
+
+// UsageSeries is a user's daily usage snapshots over a date range, oldest first
+type UsageSeries struct {
+	UserID     string                   `json:"user_id"`
+	StartDate  string                   `json:"start_date"`
+	EndDate    string                   `json:"end_date"`
+	TotalUsage decimal.Decimal          `json:"total_usage"`
+	Points     []models.DailyQuotaUsage `json:"points"`
+}
+
+// UsageConsumer is a user's usage over a date range
+type UsageConsumer struct {
+	UserID     string          `json:"user_id"`
+	Department string          `json:"department,omitempty"` // at the last snapshot of the range
+	Usage      decimal.Decimal `json:"usage"`
+}
+
+// DepartmentUsage aggregates the usage of a department's users over a date range
+type DepartmentUsage struct {
+	Department   string          `json:"department"`
+	Users        int64           `json:"users"`
+	Usage        decimal.Decimal `json:"usage"`
+	AverageUsage decimal.Decimal `json:"average_usage"` // per user
+}
+
+// MonthlyUsageTrend is the usage of one month compared with the month before
+type MonthlyUsageTrend struct {
+	YearMonth     string          `json:"year_month"`
+	Usage         decimal.Decimal `json:"usage"`
+	ActiveUsers   int64           `json:"active_users"` // users with usage in the month
+	Change        decimal.Decimal `json:"change"`
+	ChangePercent *float64        `json:"change_percent,omitempty"` // absent when the previous month had no usage
+}
+
+// usageSnapshotConcurrency returns the configured snapshot concurrency
+func usageSnapshotConcurrency() int {
+	if cfg := config.GetGlobalConfig(); cfg != nil && cfg.Scheduler.UsageSnapshotConcurrency > 0 {
+		return cfg.Scheduler.UsageSnapshotConcurrency
+	}
+	return DefaultUsageSnapshotConcurrency
+}
+
+// SnapshotDailyUsage records today's AiGateway total and used quota of every user with valid
+// quota or charged usage, with bounded concurrency. Running it again the same day refreshes
+// the day's snapshot.
+func (s *QuotaService) SnapshotDailyUsage() {
+	if !s.usageSnapshotMu.TryLock() {
+		logger.Warn("Previous usage snapshot still in progress, skipping run")
+		return
+	}
+	defer s.usageSnapshotMu.Unlock()
+
+	var userIDs []string
+	if err := s.db.DB.Raw("SELECT user_id FROM quota WHERE status = ? UNION SELECT user_id FROM quota_usage_cursor ORDER BY user_id",
+		models.StatusValid).Scan(&userIDs).Error; err != nil {
+		logger.Error("Failed to load users for usage snapshot", zap.Error(err))
+		return
+	}
+	departments, err := s.usageDepartments(userIDs)
+	if err != nil {
+		logger.Error("Failed to load departments for usage snapshot", zap.Error(err))
+		return
+	}
+	catalogRevision, err := currentCatalogRevision(s.db.DB)
+	if err != nil {
+		logger.Error("Failed to load model catalog revision for usage snapshot", zap.Error(err))
+		return
+	}
+
+	now := utils.NowInConfigTimezone(s.configManager.GetDirect())
+	date := now.Format(usageDateLayout)
+	logger.Info("Starting daily usage snapshot", zap.String("date", date), zap.Int("user_count", len(userIDs)))
+
+	sem := make(chan struct{}, usageSnapshotConcurrency())
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+	for _, userID := range userIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(userID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := s.snapshotUserUsage(userID, date, departments[userID], catalogRevision); err != nil {
+				logger.Error("Failed to snapshot user usage", zap.String("user_id", userID), zap.Error(err))
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			}
+		}(userID)
+	}
+	wg.Wait()
+
+	logger.Info("Daily usage snapshot completed",
+		zap.String("date", date),
+		zap.Int("user_count", len(userIDs)),
+		zap.Int("failed", failed))
+}
+
+// snapshotUserUsage records one user's counters of the day, with the usage added since the
+// user's previous snapshot. The total leaves the credit line out, like GET /quota.
+func (s *QuotaService) snapshotUserUsage(userID, date, department string, catalogRevision int) error {
+	queriedAt := time.Now().Truncate(time.Second)
+	totalQuota, err := s.aiGatewayClient.QueryQuotaValue(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get total quota: %w", err)
+	}
+	usedQuota, err := s.aiGatewayClient.QueryUsedQuotaValue(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get used quota: %w", err)
+	}
+
+	credit, err := s.loadCredit(s.db.DB, userID)
+	if err != nil {
+		return err
+	}
+
+	var previous models.DailyQuotaUsage
+	result := s.db.DB.Where("user_id = ? AND snapshot_date < ?", userID, date).
+		Order("snapshot_date DESC").Limit(1).Find(&previous)
+	if result.Error != nil {
+		return fmt.Errorf("failed to load previous snapshot: %w", result.Error)
+	}
+	usage := decimal.Zero
+	if result.RowsAffected > 0 {
+		// Expiry takes the used counter down, add those resets back so the day's usage
+		// before the expiry still counts
+		var resets decimal.Decimal
+		if err := s.db.DB.Model(&models.GatewayOutbox{}).
+			Where("user_id = ? AND model = '' AND mutation = ? AND source = ? AND status = ? AND delivered_time > ? AND delivered_time <= ?",
+				userID, models.OutboxMutationDeltaUsedQuota, models.OperationExpire, models.OutboxStatusDelivered,
+				previous.RecordTime, queriedAt).
+			Select("COALESCE(SUM(value), 0)").Scan(&resets).Error; err != nil {
+			return fmt.Errorf("failed to sum used quota resets: %w", err)
+		}
+		if adjusted := usedQuota.Sub(resets); adjusted.GreaterThan(previous.UsedQuota) {
+			usage = adjusted.Sub(previous.UsedQuota)
+		}
+	}
+
+	snapshot := &models.DailyQuotaUsage{
+		UserID:          userID,
+		SnapshotDate:    date,
+		TotalQuota:      totalQuota.Sub(credit.CreditLimit),
+		UsedQuota:       usedQuota,
+		Usage:           usage,
+		Department:      department,
+		CatalogRevision: catalogRevision,
+		RecordTime:      queriedAt,
+	}
+	if err := s.db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "snapshot_date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"total_quota", "used_quota", "usage", "department", "catalog_revision", "record_time"}),
+	}).Create(snapshot).Error; err != nil {
+		return fmt.Errorf("failed to save usage snapshot: %w", err)
+	}
+	return nil
+}
+
+// usageDepartments maps users to the comma-separated department levels of their employee record
+func (s *QuotaService) usageDepartments(userIDs []string) (map[string]string, error) {
+	departments := make(map[string]string, len(userIDs))
+	for start := 0; start < len(userIDs); start += usageLookupBatchSize {
+		end := start + usageLookupBatchSize
+		if end > len(userIDs) {
+			end = len(userIDs)
+		}
+
+		var users []models.UserInfo
+		if err := s.db.AuthDB.Select("id, employee_number").
+			Where("id IN ? AND employee_number <> ''", userIDs[start:end]).Find(&users).Error; err != nil {
+			return nil, fmt.Errorf("failed to query users: %w", err)
+		}
+		if len(users) == 0 {
+			continue
+		}
+		employeeNumbers := make([]string, len(users))
+		for i, user := range users {
+			employeeNumbers[i] = user.EmployeeNumber
+		}
+
+		var employees []models.EmployeeDepartment
+		if err := s.db.DB.Where("employee_number IN ?", employeeNumbers).Find(&employees).Error; err != nil {
+			return nil, fmt.Errorf("failed to query employee departments: %w", err)
+		}
+		byEmployee := make(map[string]string, len(employees))
+		for _, employee := range employees {
+			byEmployee[employee.EmployeeNumber] = employee.DeptFullLevelNames
+		}
+		for _, user := range users {
+			if department, ok := byEmployee[user.EmployeeNumber]; ok {
+				departments[user.ID] = department
+			}
+		}
+	}
+	return departments, nil
+}
+
+// resolveUsageDateRange checks a YYYY-MM-DD date range, defaulting to the last
+// DefaultUsageRangeDays days up to today in the configured timezone
+func (s *QuotaService) resolveUsageDateRange(startDate, endDate string) (string, string, error) {
+	end := utils.NowInConfigTimezone(s.configManager.GetDirect())
+	if endDate != "" {
+		parsed, err := time.Parse(usageDateLayout, endDate)
+		if err != nil {
+			return "", "", NewValidationFailedError("invalid end_date, expected YYYY-MM-DD")
+		}
+		end = parsed
+	}
+	start := end.AddDate(0, 0, -(DefaultUsageRangeDays - 1))
+	if startDate != "" {
+		parsed, err := time.Parse(usageDateLayout, startDate)
+		if err != nil {
+			return "", "", NewValidationFailedError("invalid start_date, expected YYYY-MM-DD")
+		}
+		start = parsed
+	}
+
+	startDate, endDate = start.Format(usageDateLayout), end.Format(usageDateLayout)
+	if startDate > endDate {
+		return "", "", NewValidationFailedError("start_date must not be after end_date")
+	}
+	first, _ := time.Parse(usageDateLayout, startDate)
+	last, _ := time.Parse(usageDateLayout, endDate)
+	if last.Sub(first) >= MaxUsageRangeDays*24*time.Hour {
+		return "", "", NewValidationFailedError(fmt.Sprintf("date range must not exceed %d days", MaxUsageRangeDays))
+	}
+	return startDate, endDate, nil
+}
+
+// GetUsageSeries lists a user's daily usage snapshots within a date range, oldest first
+func (s *QuotaService) GetUsageSeries(userID, startDate, endDate string) (*UsageSeries, error) {
+	startDate, endDate, err := s.resolveUsageDateRange(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	series := &UsageSeries{UserID: userID, StartDate: startDate, EndDate: endDate}
+	if err := s.db.DB.Where("user_id = ? AND snapshot_date BETWEEN ? AND ?", userID, startDate, endDate).
+		Order("snapshot_date ASC").Find(&series.Points).Error; err != nil {
+		return nil, NewDatabaseError("query usage snapshots", err)
+	}
+	if series.Points == nil {
+		series.Points = []models.DailyQuotaUsage{}
+	}
+	for _, point := range series.Points {
+		series.TotalUsage = series.TotalUsage.Add(point.Usage)
+	}
+	return series, nil
+}
+
+// GetTopConsumers lists the users with the most usage within a date range, optionally of one department
+func (s *QuotaService) GetTopConsumers(startDate, endDate, department string, limit int) ([]UsageConsumer, error) {
+	startDate, endDate, err := s.resolveUsageDateRange(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultTopConsumersLimit
+	}
+	if limit > MaxTopConsumersLimit {
+		limit = MaxTopConsumersLimit
+	}
+
+	query := s.db.DB.Model(&models.DailyQuotaUsage{}).
+		Where("snapshot_date BETWEEN ? AND ?", startDate, endDate)
+	if department != "" {
+		query = query.Where("department LIKE ?", "%"+department+"%")
+	}
+	var consumers []UsageConsumer
+	if err := query.
+		Select("user_id, (ARRAY_AGG(department ORDER BY snapshot_date DESC))[1] AS department, SUM(usage) AS usage").
+		Group("user_id").Having("SUM(usage) > 0").
+		Order("usage DESC, user_id").Limit(limit).Scan(&consumers).Error; err != nil {
+		return nil, NewDatabaseError("query top consumers", err)
+	}
+	if consumers == nil {
+		consumers = []UsageConsumer{}
+	}
+	return consumers, nil
+}
+
+// GetDepartmentUsage aggregates usage within a date range by department, at the given level
+// of the department path (1 = top level); users count in the department they had on each day
+func (s *QuotaService) GetDepartmentUsage(startDate, endDate string, level int) ([]DepartmentUsage, error) {
+	startDate, endDate, err := s.resolveUsageDateRange(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	if level <= 0 {
+		level = 1
+	}
+
+	var aggregates []DepartmentUsage
+	if err := s.db.DB.Model(&models.DailyQuotaUsage{}).
+		Select("SPLIT_PART(department, ',', ?) AS department, COUNT(DISTINCT user_id) AS users, COALESCE(SUM(usage), 0) AS usage", level).
+		Where("snapshot_date BETWEEN ? AND ? AND SPLIT_PART(department, ',', ?) <> ''", startDate, endDate, level).
+		Group(fmt.Sprintf("SPLIT_PART(department, ',', %d)", level)).
+		Order("usage DESC").Scan(&aggregates).Error; err != nil {
+		return nil, NewDatabaseError("aggregate department usage", err)
+	}
+	if aggregates == nil {
+		aggregates = []DepartmentUsage{}
+	}
+	for i := range aggregates {
+		if aggregates[i].Users > 0 {
+			aggregates[i].AverageUsage = aggregates[i].Usage.DivTrunc(aggregates[i].Users)
+		}
+	}
+	return aggregates, nil
+}
+
+// GetUsageTrends compares the usage of each of the last months with the month before,
+// optionally for one user or department, oldest month first
+func (s *QuotaService) GetUsageTrends(months int, userID, department string) ([]MonthlyUsageTrend, error) {
+	if months <= 0 {
+		months = DefaultUsageTrendMonths
+	}
+	if months > MaxUsageTrendMonths {
+		months = MaxUsageTrendMonths
+	}
+
+	// One month more than asked, as the baseline of the first month's change
+	now := utils.NowInConfigTimezone(s.configManager.GetDirect())
+	firstMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -months, 0)
+
+	query := s.db.DB.Model(&models.DailyQuotaUsage{}).
+		Where("snapshot_date >= ?", firstMonth.Format(usageDateLayout))
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if department != "" {
+		query = query.Where("department LIKE ?", "%"+department+"%")
+	}
+	var rows []MonthlyUsageTrend
+	if err := query.
+		Select("LEFT(snapshot_date, 7) AS year_month, COALESCE(SUM(usage), 0) AS usage, " +
+			"COUNT(DISTINCT CASE WHEN usage > 0 THEN user_id END) AS active_users").
+		Group("LEFT(snapshot_date, 7)").Scan(&rows).Error; err != nil {
+		return nil, NewDatabaseError("aggregate monthly usage", err)
+	}
+	byMonth := make(map[string]MonthlyUsageTrend, len(rows))
+	for _, row := range rows {
+		byMonth[row.YearMonth] = row
+	}
+
+	// Months without snapshots are reported with no usage
+	trends := make([]MonthlyUsageTrend, 0, months)
+	previous := byMonth[firstMonth.Format("2006-01")].Usage
+	for i := 1; i <= months; i++ {
+		yearMonth := firstMonth.AddDate(0, i, 0).Format("2006-01")
+		trend := byMonth[yearMonth]
+		trend.YearMonth = yearMonth
+		trend.Change = trend.Usage.Sub(previous)
+		if previous.IsPositive() {
+			percent := trend.Change.Float64() / previous.Float64() * 100
+			trend.ChangePercent = &percent
+		}
+		trends = append(trends, trend)
+		previous = trend.Usage
+	}
+	return trends, nil
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"quota-manager/pkg/decimal"
 	"strings"
 	"time"
 )
@@ -25,8 +26,9 @@ type VoucherData struct {
 
 // VoucherQuotaItem represents quota item in voucher
 type VoucherQuotaItem struct {
-	Amount     float64   `json:"amount"`
-	ExpiryDate time.Time `json:"expiry_date"`
+	Amount     decimal.Decimal `json:"amount"`
+	ExpiryDate time.Time       `json:"expiry_date"`
+	Model      string          `json:"model,omitempty"` // model pool, empty = any model
 }
 
 // VoucherService handles voucher code generation and validation
@@ -108,3 +110,9 @@ func (s *VoucherService) generateSignature(data []byte) []byte {
 	h.Write(data)
 	return h.Sum(nil)
 }
+
+// DeriveKey derives a key for another purpose from the signing key, so other signatures
+// never share a key with voucher codes
+func (s *VoucherService) DeriveKey(purpose string) []byte {
+	return s.generateSignature([]byte(purpose))
+}
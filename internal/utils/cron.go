@@ -0,0 +1,251 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// cronParser parses 6-field seconds-precision expressions, the same format the strategy cron uses
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+var monthNames = []string{"", "January", "February", "March", "April", "May", "June",
+	"July", "August", "September", "October", "November", "December"}
+
+var weekdayNames = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+
+var cronNameValues = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}
+
+// NextCronTimes returns the next n fire times of expr after from, expressed in loc
+func NextCronTimes(expr string, loc *time.Location, from time.Time, n int) ([]time.Time, error) {
+	schedule, err := cronParser.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression: %w", err)
+	}
+
+	times := make([]time.Time, 0, n)
+	next := from.In(loc)
+	for i := 0; i < n; i++ {
+		next = schedule.Next(next)
+		if next.IsZero() {
+			// The expression can never fire again (e.g. Feb 30)
+			break
+		}
+		times = append(times, next)
+	}
+	return times, nil
+}
+
+// prevCronLookbacks are the windows searched for the last fire time of an expression, shortest
+// first so frequent schedules are not stepped through over a long window
+var prevCronLookbacks = []time.Duration{time.Hour, 24 * time.Hour, 8 * 24 * time.Hour, 32 * 24 * time.Hour, 367 * 24 * time.Hour, 5 * 367 * 24 * time.Hour}
+
+// PrevCronTime returns the last fire time of expr strictly before before, expressed in loc.
+// The zero time is returned when expr did not fire within the last five years.
+func PrevCronTime(expr string, loc *time.Location, before time.Time) (time.Time, error) {
+	schedule, err := cronParser.Parse(expr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid cron expression: %w", err)
+	}
+
+	before = before.In(loc)
+	for _, lookback := range prevCronLookbacks {
+		var prev time.Time
+		for next := schedule.Next(before.Add(-lookback)); !next.IsZero() && next.Before(before); next = schedule.Next(next) {
+			prev = next
+		}
+		if !prev.IsZero() {
+			return prev, nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+// DescribeCron returns an English description of a 6-field cron expression,
+// e.g. "0 0 8 1 * *" -> "At 08:00:00, on day 1 of the month"
+func DescribeCron(expr string) (string, error) {
+	if _, err := cronParser.Parse(expr); err != nil {
+		return "", fmt.Errorf("invalid cron expression: %w", err)
+	}
+
+	spec := strings.TrimSpace(expr)
+	if strings.HasPrefix(spec, "TZ=") || strings.HasPrefix(spec, "CRON_TZ=") {
+		if i := strings.Index(spec, " "); i >= 0 {
+			spec = strings.TrimSpace(spec[i+1:])
+		}
+	}
+
+	if strings.HasPrefix(spec, "@") {
+		return describeCronDescriptor(spec), nil
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 6 {
+		return "", fmt.Errorf("expected 6 fields, got %d", len(fields))
+	}
+
+	parts := []string{describeCronTime(fields[0], fields[1], fields[2])}
+	if days := describeCronDays(fields[3], fields[5]); days != "" {
+		parts = append(parts, days)
+	}
+	if month := describeCronField(fields[4], "month", monthName); month != "" {
+		parts = append(parts, "in "+month)
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+func describeCronDescriptor(spec string) string {
+	switch spec {
+	case "@yearly", "@annually":
+		return "At 00:00:00, on day 1 of the month, in January"
+	case "@monthly":
+		return "At 00:00:00, on day 1 of the month"
+	case "@weekly":
+		return "At 00:00:00, on Sunday"
+	case "@daily", "@midnight":
+		return "At 00:00:00, every day"
+	case "@hourly":
+		return "Every hour"
+	}
+	if strings.HasPrefix(spec, "@every ") {
+		return "Every " + strings.TrimSpace(strings.TrimPrefix(spec, "@every "))
+	}
+	return spec
+}
+
+func describeCronTime(sec, min, hour string) string {
+	if isCronNumber(sec) && isCronNumber(min) && isCronNumber(hour) {
+		return fmt.Sprintf("At %02s:%02s:%02s", hour, min, sec)
+	}
+
+	switch {
+	case sec == "*" && min == "*" && hour == "*":
+		return "Every second"
+	case sec == "0" && min == "*" && hour == "*":
+		return "Every minute"
+	case sec == "0" && min == "0" && hour == "*":
+		return "Every hour"
+	case strings.HasPrefix(sec, "*/") && min == "*" && hour == "*":
+		return fmt.Sprintf("Every %s seconds", sec[2:])
+	case sec == "0" && strings.HasPrefix(min, "*/") && hour == "*":
+		return fmt.Sprintf("Every %s minutes", min[2:])
+	case sec == "0" && min == "0" && strings.HasPrefix(hour, "*/"):
+		return fmt.Sprintf("Every %s hours", hour[2:])
+	}
+
+	var parts []string
+	if sec != "0" {
+		parts = append(parts, describeCronField(sec, "second", nil))
+	}
+	parts = append(parts, describeCronField(min, "minute", nil))
+	switch {
+	case hour == "*":
+	case isCronNumber(hour):
+		parts = append(parts, "during hour "+hour)
+	default:
+		parts = append(parts, strings.Replace(describeCronField(hour, "hour", nil), "at hours ", "during hours ", 1))
+	}
+
+	description := strings.Join(parts, ", ")
+	return strings.ToUpper(description[:1]) + description[1:]
+}
+
+func describeCronDays(dom, dow string) string {
+	domAny := dom == "*" || dom == "?"
+	dowAny := dow == "*" || dow == "?"
+	days := strings.TrimPrefix(describeCronField(dom, "day", nil), "at ")
+
+	switch {
+	case domAny && dowAny:
+		return ""
+	case dowAny:
+		return "on " + days + " of the month"
+	case domAny:
+		return "on " + describeCronField(dow, "weekday", weekdayName)
+	default:
+		// Cron matches when either day field matches
+		return "on " + days + " of the month or on " + describeCronField(dow, "weekday", weekdayName)
+	}
+}
+
+// describeCronField describes a single field; name maps values to display names for months and weekdays
+func describeCronField(field, unit string, name func(string) string) string {
+	if field == "*" || field == "?" {
+		if unit == "second" || unit == "minute" {
+			return "every " + unit
+		}
+		return ""
+	}
+	named := name != nil
+	if !named {
+		name = func(v string) string { return v }
+	}
+
+	var items []string
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step, hasStep := strings.Cut(item, "/")
+		var desc string
+		switch {
+		case rangePart == "*":
+			desc = fmt.Sprintf("every %s %ss", step, unit)
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			desc = fmt.Sprintf("%s through %s", name(from), name(to))
+			if hasStep {
+				desc = fmt.Sprintf("every %s %ss from %s", step, unit, desc)
+			}
+		default:
+			desc = name(rangePart)
+			if hasStep {
+				desc = fmt.Sprintf("every %s %ss starting at %s", step, unit, desc)
+			}
+		}
+		items = append(items, desc)
+	}
+
+	joined := strings.Join(items, ", ")
+	if named {
+		return joined
+	}
+	if len(items) == 1 && isCronNumber(field) {
+		return fmt.Sprintf("at %s %s", unit, joined)
+	}
+	if strings.HasPrefix(joined, "every ") {
+		return joined
+	}
+	return fmt.Sprintf("at %ss %s", unit, joined)
+}
+
+func monthName(v string) string {
+	if n, ok := cronValue(v); ok && n >= 1 && n <= 12 {
+		return monthNames[n]
+	}
+	return v
+}
+
+func weekdayName(v string) string {
+	if n, ok := cronValue(v); ok && n >= 0 && n <= 7 {
+		return weekdayNames[n%7]
+	}
+	return v
+}
+
+func cronValue(v string) (int, bool) {
+	if n, err := strconv.Atoi(v); err == nil {
+		return n, true
+	}
+	n, ok := cronNameValues[strings.ToUpper(v)]
+	return n, ok
+}
+
+func isCronNumber(v string) bool {
+	_, err := strconv.Atoi(v)
+	return err == nil
+}
@@ -2,6 +2,8 @@ package validation
 
 import (
 	"fmt"
+	"quota-manager/pkg/decimal"
+	"reflect"
 	"strings"
 
 	"github.com/gin-gonic/gin/binding"
@@ -43,6 +45,17 @@ func registerCustomValidators() {
 	// Register custom validators for permission management
 	schemaValidator.RegisterValidation("employee_number", validateEmployeeNumber)
 	schemaValidator.RegisterValidation("department_name", validateDepartmentName)
+
+	// Validate fixed-point amounts by their numeric value, so gt/gte/lte tags apply to them
+	schemaValidator.RegisterCustomTypeFunc(decimalValue, decimal.Decimal{})
+}
+
+// decimalValue exposes a decimal amount to the validator as a float
+func decimalValue(field reflect.Value) interface{} {
+	if value, ok := field.Interface().(decimal.Decimal); ok {
+		return value.Float64()
+	}
+	return nil
 }
 
 // validateCron validates cron expression using our existing function
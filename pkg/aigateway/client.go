@@ -1,6 +1,7 @@
 package aigateway
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"quota-manager/internal/utils"
+	"quota-manager/pkg/decimal"
 )
 
 type Client struct {
@@ -31,8 +33,8 @@ type ResponseData struct {
 }
 
 type QuotaResponse struct {
-	Quota  float64 `json:"quota"`
-	UserID string  `json:"user_id"`
+	Quota  decimal.Decimal `json:"quota"`
+	UserID string          `json:"user_id"`
 }
 
 type StarProjectsResponse struct {
@@ -58,6 +60,26 @@ type ModelPermissionQueryResponse struct {
 	Models         []string `json:"models"`
 }
 
+// counterValues returns the form fields addressing a user's quota counter. The model
+// field is only sent for model pools, so "any model" requests stay unchanged.
+func counterValues(userID, model string) url.Values {
+	data := url.Values{}
+	data.Set("user_id", userID)
+	if model != "" {
+		data.Set("model", model)
+	}
+	return data
+}
+
+// counterQuery returns the query string addressing a user's quota counter
+func counterQuery(userID, model string) string {
+	query := "user_id=" + userID
+	if model != "" {
+		query += "&model=" + url.QueryEscape(model)
+	}
+	return query
+}
+
 func NewClient(baseURL, adminPath, authHeader, authValue string) *Client {
 	return &Client{
 		BaseURL:    baseURL,
@@ -71,20 +93,25 @@ func NewClient(baseURL, adminPath, authHeader, authValue string) *Client {
 }
 
 // RefreshQuota refreshes user quota with retry mechanism
-func (c *Client) RefreshQuota(userID string, quota float64) error {
+func (c *Client) RefreshQuota(userID string, quota decimal.Decimal) error {
+	return c.RefreshQuotaForModel(userID, "", quota)
+}
+
+// RefreshQuotaForModel refreshes the user's quota counter of a model pool, an empty
+// model addressing the "any model" pool
+func (c *Client) RefreshQuotaForModel(userID, model string, quota decimal.Decimal) error {
 	_, err := utils.WithRetry(context.Background(), func() (struct{}, error) {
-		return struct{}{}, c.refreshQuotaImpl(userID, quota)
+		return struct{}{}, c.refreshQuotaImpl(userID, model, quota)
 	})
 	return err
 }
 
 // refreshQuotaImpl implements the actual RefreshQuota logic
-func (c *Client) refreshQuotaImpl(userID string, quota float64) error {
+func (c *Client) refreshQuotaImpl(userID, model string, quota decimal.Decimal) error {
 	apiUrl := fmt.Sprintf("%s%s/refresh", c.BaseURL, c.AdminPath)
 
-	data := url.Values{}
-	data.Set("user_id", userID)
-	data.Set("quota", strconv.FormatFloat(quota, 'f', -1, 64))
+	data := counterValues(userID, model)
+	data.Set("quota", quota.String())
 
 	req, err := http.NewRequest("POST", apiUrl, strings.NewReader(data.Encode()))
 	if err != nil {
@@ -128,14 +155,19 @@ func (c *Client) refreshQuotaImpl(userID string, quota float64) error {
 
 // QueryQuota queries user quota with retry mechanism
 func (c *Client) QueryQuota(userID string) (*QuotaResponse, error) {
+	return c.QueryQuotaForModel(userID, "")
+}
+
+// QueryQuotaForModel queries the user's quota counter of a model pool with retry mechanism
+func (c *Client) QueryQuotaForModel(userID, model string) (*QuotaResponse, error) {
 	return utils.WithRetry(context.Background(), func() (*QuotaResponse, error) {
-		return c.queryQuotaImpl(userID)
+		return c.queryQuotaImpl(userID, model)
 	})
 }
 
 // queryQuotaImpl implements the actual QueryQuota logic
-func (c *Client) queryQuotaImpl(userID string) (*QuotaResponse, error) {
-	apiUrl := fmt.Sprintf("%s%s?user_id=%s", c.BaseURL, c.AdminPath, userID)
+func (c *Client) queryQuotaImpl(userID, model string) (*QuotaResponse, error) {
+	apiUrl := fmt.Sprintf("%s%s?%s", c.BaseURL, c.AdminPath, counterQuery(userID, model))
 
 	req, err := http.NewRequest("GET", apiUrl, nil)
 	if err != nil {
@@ -159,7 +191,7 @@ func (c *Client) queryQuotaImpl(userID string) (*QuotaResponse, error) {
 	}
 
 	var respData ResponseData
-	if err := json.Unmarshal(body, &respData); err != nil {
+	if err := unmarshalResponse(body, &respData); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
@@ -176,7 +208,7 @@ func (c *Client) queryQuotaImpl(userID string) (*QuotaResponse, error) {
 		return nil, fmt.Errorf("invalid response data format")
 	}
 
-	quota, ok := dataMap["quota"].(float64)
+	quota, ok := quotaValue(dataMap["quota"])
 	if !ok {
 		return nil, fmt.Errorf("invalid quota format in response")
 	}
@@ -188,20 +220,26 @@ func (c *Client) queryQuotaImpl(userID string) (*QuotaResponse, error) {
 }
 
 // DeltaQuota increases or decreases user quota with retry mechanism
-func (c *Client) DeltaQuota(userID string, value float64) error {
+func (c *Client) DeltaQuota(userID string, value decimal.Decimal) error {
 	_, err := utils.WithRetry(context.Background(), func() (struct{}, error) {
-		return struct{}{}, c.deltaQuotaImpl(userID, value)
+		return struct{}{}, c.deltaQuotaImpl(userID, "", value, "")
 	})
 	return err
 }
 
+// DeltaQuotaForModelWithKey changes the quota counter of a model pool in a single attempt,
+// carrying a dedup key in the Idempotency-Key header. The gateway does not honour the header
+// yet, so a retry after a timeout could apply the mutation twice; retries are left to the caller.
+func (c *Client) DeltaQuotaForModelWithKey(userID, model string, value decimal.Decimal, dedupKey string) error {
+	return c.deltaQuotaImpl(userID, model, value, dedupKey)
+}
+
 // deltaQuotaImpl implements the actual DeltaQuota logic
-func (c *Client) deltaQuotaImpl(userID string, value float64) error {
+func (c *Client) deltaQuotaImpl(userID, model string, value decimal.Decimal, dedupKey string) error {
 	apiUrl := fmt.Sprintf("%s%s/delta", c.BaseURL, c.AdminPath)
 
-	data := url.Values{}
-	data.Set("user_id", userID)
-	data.Set("value", strconv.FormatFloat(value, 'f', -1, 64))
+	data := counterValues(userID, model)
+	data.Set("value", value.String())
 
 	req, err := http.NewRequest("POST", apiUrl, strings.NewReader(data.Encode()))
 	if err != nil {
@@ -214,6 +252,9 @@ func (c *Client) deltaQuotaImpl(userID string, value float64) error {
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if dedupKey != "" {
+		req.Header.Set("Idempotency-Key", dedupKey)
+	}
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
@@ -243,18 +284,23 @@ func (c *Client) deltaQuotaImpl(userID string, value float64) error {
 }
 
 // QueryQuotaValue implements the QuotaQuerier interface with retry mechanism
-// Returns only the quota value as a float64
-func (c *Client) QueryQuotaValue(userID string) (float64, error) {
-	return utils.WithRetry(context.Background(), func() (float64, error) {
-		return c.queryQuotaValueImpl(userID)
+// Returns only the quota value
+func (c *Client) QueryQuotaValue(userID string) (decimal.Decimal, error) {
+	return c.QueryQuotaValueForModel(userID, "")
+}
+
+// QueryQuotaValueForModel returns the user's quota value of a model pool
+func (c *Client) QueryQuotaValueForModel(userID, model string) (decimal.Decimal, error) {
+	return utils.WithRetry(context.Background(), func() (decimal.Decimal, error) {
+		return c.queryQuotaValueImpl(userID, model)
 	})
 }
 
 // queryQuotaValueImpl implements the actual QueryQuotaValue logic
-func (c *Client) queryQuotaValueImpl(userID string) (float64, error) {
-	resp, err := c.QueryQuota(userID)
+func (c *Client) queryQuotaValueImpl(userID, model string) (decimal.Decimal, error) {
+	resp, err := c.QueryQuotaForModel(userID, model)
 	if err != nil {
-		return 0, err
+		return decimal.Zero, err
 	}
 	return resp.Quota, nil
 }
@@ -385,6 +431,59 @@ func (c *Client) setGithubStarProjectsImpl(employeeNumber string, starredProject
 	return nil
 }
 
+// SetModelCostMultipliers replaces the model cost catalog of AiGateway with retry mechanism.
+// The gateway weights each request's cost by its model's multiplier before adding it to
+// the used quota; models missing from the catalog cost 1x.
+func (c *Client) SetModelCostMultipliers(multipliers map[string]float64, revision int) error {
+	_, err := utils.WithRetry(context.Background(), func() (struct{}, error) {
+		return struct{}{}, c.setModelCostMultipliersImpl(multipliers, revision)
+	})
+	return err
+}
+
+// setModelCostMultipliersImpl implements the actual SetModelCostMultipliers logic
+func (c *Client) setModelCostMultipliersImpl(multipliers map[string]float64, revision int) error {
+	apiUrl := fmt.Sprintf("%s%s/model-cost/refresh", c.BaseURL, c.AdminPath)
+
+	multipliersJSON, err := json.Marshal(multipliers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal multipliers: %w", err)
+	}
+	data := url.Values{}
+	data.Set("multipliers", string(multipliersJSON))
+	data.Set("revision", strconv.Itoa(revision))
+
+	req, err := http.NewRequest("POST", apiUrl, strings.NewReader(data.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if c.AuthHeader != "" && c.AuthValue != "" {
+		req.Header.Set(c.AuthHeader, c.AuthValue)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var respData ResponseData
+	if err := json.Unmarshal(body, &respData); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if !respData.Success {
+		return &utils.HTTPError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("AI Gateway error: %s - %s", respData.Code, respData.Message)}
+	}
+	return nil
+}
+
 // SetUserPermission sets user permission in Higress with retry mechanism
 func (c *Client) SetUserPermission(employeeNumber string, models []string) error {
 	_, err := utils.WithRetry(context.Background(), func() (struct{}, error) {
@@ -589,20 +688,25 @@ func (c *Client) setUserQuotaCheckPermissionImpl(employeeNumber string, enabled
 }
 
 // QueryUsedQuotaValue queries user used quota value with retry mechanism
-// Returns only the used quota value as a float64
-func (c *Client) QueryUsedQuotaValue(userID string) (float64, error) {
-	return utils.WithRetry(context.Background(), func() (float64, error) {
-		return c.queryUsedQuotaValueImpl(userID)
+// Returns only the used quota value
+func (c *Client) QueryUsedQuotaValue(userID string) (decimal.Decimal, error) {
+	return c.QueryUsedQuotaValueForModel(userID, "")
+}
+
+// QueryUsedQuotaValueForModel returns the user's used quota value of a model pool
+func (c *Client) QueryUsedQuotaValueForModel(userID, model string) (decimal.Decimal, error) {
+	return utils.WithRetry(context.Background(), func() (decimal.Decimal, error) {
+		return c.queryUsedQuotaValueImpl(userID, model)
 	})
 }
 
 // queryUsedQuotaValueImpl implements the actual QueryUsedQuotaValue logic
-func (c *Client) queryUsedQuotaValueImpl(userID string) (float64, error) {
-	apiUrl := fmt.Sprintf("%s%s/used?user_id=%s", c.BaseURL, c.AdminPath, userID)
+func (c *Client) queryUsedQuotaValueImpl(userID, model string) (decimal.Decimal, error) {
+	apiUrl := fmt.Sprintf("%s%s/used?%s", c.BaseURL, c.AdminPath, counterQuery(userID, model))
 
 	req, err := http.NewRequest("GET", apiUrl, nil)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create request: %w", err)
+		return decimal.Zero, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set admin key header if configured
@@ -612,22 +716,22 @@ func (c *Client) queryUsedQuotaValueImpl(userID string) (float64, error) {
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return 0, fmt.Errorf("failed to execute request: %w", err)
+		return decimal.Zero, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read response: %w", err)
+		return decimal.Zero, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	var respData ResponseData
-	if err := json.Unmarshal(body, &respData); err != nil {
-		return 0, fmt.Errorf("failed to unmarshal response: %w", err)
+	if err := unmarshalResponse(body, &respData); err != nil {
+		return decimal.Zero, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
 	if !respData.Success {
-		return 0, &utils.HTTPError{
+		return decimal.Zero, &utils.HTTPError{
 			StatusCode: resp.StatusCode,
 			Message:    fmt.Sprintf("AI Gateway error: %s - %s", respData.Code, respData.Message),
 		}
@@ -636,32 +740,37 @@ func (c *Client) queryUsedQuotaValueImpl(userID string) (float64, error) {
 	// Parse the data field
 	dataMap, ok := respData.Data.(map[string]interface{})
 	if !ok {
-		return 0, fmt.Errorf("invalid response data format")
+		return decimal.Zero, fmt.Errorf("invalid response data format")
 	}
 
-	quota, ok := dataMap["quota"].(float64)
+	quota, ok := quotaValue(dataMap["quota"])
 	if !ok {
-		return 0, fmt.Errorf("invalid quota format in response")
+		return decimal.Zero, fmt.Errorf("invalid quota format in response")
 	}
 
 	return quota, nil
 }
 
 // DeltaUsedQuota increases or decreases user used quota with retry mechanism
-func (c *Client) DeltaUsedQuota(userID string, value float64) error {
+func (c *Client) DeltaUsedQuota(userID string, value decimal.Decimal) error {
 	_, err := utils.WithRetry(context.Background(), func() (struct{}, error) {
-		return struct{}{}, c.deltaUsedQuotaImpl(userID, value)
+		return struct{}{}, c.deltaUsedQuotaImpl(userID, "", value, "")
 	})
 	return err
 }
 
+// DeltaUsedQuotaForModelWithKey changes the used quota counter of a model pool in a single
+// attempt, like DeltaQuotaForModelWithKey
+func (c *Client) DeltaUsedQuotaForModelWithKey(userID, model string, value decimal.Decimal, dedupKey string) error {
+	return c.deltaUsedQuotaImpl(userID, model, value, dedupKey)
+}
+
 // deltaUsedQuotaImpl implements the actual DeltaUsedQuota logic
-func (c *Client) deltaUsedQuotaImpl(userID string, value float64) error {
+func (c *Client) deltaUsedQuotaImpl(userID, model string, value decimal.Decimal, dedupKey string) error {
 	apiUrl := fmt.Sprintf("%s%s/used/delta", c.BaseURL, c.AdminPath)
 
-	data := url.Values{}
-	data.Set("user_id", userID)
-	data.Set("value", strconv.FormatFloat(value, 'f', -1, 64))
+	data := counterValues(userID, model)
+	data.Set("value", value.String())
 
 	req, err := http.NewRequest("POST", apiUrl, strings.NewReader(data.Encode()))
 	if err != nil {
@@ -674,6 +783,9 @@ func (c *Client) deltaUsedQuotaImpl(userID string, value float64) error {
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if dedupKey != "" {
+		req.Header.Set("Idempotency-Key", dedupKey)
+	}
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
@@ -703,7 +815,7 @@ func (c *Client) deltaUsedQuotaImpl(userID string, value float64) error {
 }
 
 // RefreshUsedQuota refreshes user's used quota (sets an absolute value) with retry mechanism
-func (c *Client) RefreshUsedQuota(userID string, quota float64) error {
+func (c *Client) RefreshUsedQuota(userID string, quota decimal.Decimal) error {
 	_, err := utils.WithRetry(context.Background(), func() (struct{}, error) {
 		return struct{}{}, c.refreshUsedQuotaImpl(userID, quota)
 	})
@@ -711,12 +823,12 @@ func (c *Client) RefreshUsedQuota(userID string, quota float64) error {
 }
 
 // refreshUsedQuotaImpl implements the actual RefreshUsedQuota logic
-func (c *Client) refreshUsedQuotaImpl(userID string, quota float64) error {
+func (c *Client) refreshUsedQuotaImpl(userID string, quota decimal.Decimal) error {
 	apiUrl := fmt.Sprintf("%s%s/used/refresh", c.BaseURL, c.AdminPath)
 
 	data := url.Values{}
 	data.Set("user_id", userID)
-	data.Set("quota", strconv.FormatFloat(quota, 'f', -1, 64))
+	data.Set("quota", quota.String())
 
 	req, err := http.NewRequest("POST", apiUrl, strings.NewReader(data.Encode()))
 	if err != nil {
@@ -896,3 +1008,29 @@ func (c *Client) queryUserPermissionImpl(employeeNumber string) (*ModelPermissio
 	}
 	return &out, nil
 }
+
+// unmarshalResponse decodes a gateway response keeping numbers as json.Number, so quota
+// values are read without going through float64
+func unmarshalResponse(body []byte, respData *ResponseData) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	return decoder.Decode(respData)
+}
+
+// quotaValue reads a quota value from a decoded response field
+func quotaValue(value interface{}) (decimal.Decimal, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		if quota, err := decimal.NewFromString(v.String()); err == nil {
+			return quota, true
+		}
+		f, err := v.Float64() // exponent notation
+		if err != nil {
+			return decimal.Zero, false
+		}
+		return decimal.NewFromFloat(f), true
+	case float64:
+		return decimal.NewFromFloat(v), true
+	}
+	return decimal.Zero, false
+}
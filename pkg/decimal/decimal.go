@@ -0,0 +1,340 @@
+package decimal
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scale is the number of fractional digits kept for quota amounts, matching the
+// DECIMAL(10,2) columns of the ledger
+const Scale = 2
+
+const unitsPerOne = 100 // 10^Scale
+
+// Decimal is a fixed-point quota amount stored as an integer number of hundredths,
+// so sums and comparisons are exact
+type Decimal struct {
+	units int64
+}
+
+// Zero is the zero amount
+var Zero = Decimal{}
+
+// New returns a whole amount
+func New(value int64) Decimal {
+	return Decimal{units: value * unitsPerOne}
+}
+
+// NewFromFloat converts a float to the nearest amount, halves rounding away from zero.
+// NaN and infinities convert to zero.
+func NewFromFloat(value float64) Decimal {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return Zero
+	}
+	d, err := NewFromString(strconv.FormatFloat(value, 'f', -1, 64))
+	if err != nil {
+		return Zero
+	}
+	return d
+}
+
+// NewFromString parses a plain decimal number such as "12", "-0.5" or "3.14159",
+// rounding extra fractional digits half away from zero
+func NewFromString(value string) (Decimal, error) {
+	s := strings.TrimSpace(value)
+	negative := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+	intPart, fracPart := s, ""
+	if dot := strings.IndexByte(s, '.'); dot >= 0 {
+		intPart, fracPart = s[:dot], s[dot+1:]
+	}
+	if (intPart == "" && fracPart == "") || !isDigits(intPart) || !isDigits(fracPart) {
+		return Zero, fmt.Errorf("invalid decimal %q", value)
+	}
+
+	var units int64
+	for _, c := range intPart {
+		if units > (math.MaxInt64-9)/10/unitsPerOne {
+			return Zero, fmt.Errorf("decimal %q out of range", value)
+		}
+		units = units*10 + int64(c-'0')
+	}
+	units *= unitsPerOne
+
+	var frac int64
+	for i := 0; i < Scale; i++ {
+		frac *= 10
+		if i < len(fracPart) {
+			frac += int64(fracPart[i] - '0')
+		}
+	}
+	units += frac
+	if len(fracPart) > Scale && fracPart[Scale] >= '5' {
+		units++
+	}
+
+	if negative {
+		units = -units
+	}
+	return Decimal{units: units}, nil
+}
+
+// RequireFromString is like NewFromString but panics on invalid input, for constants
+func RequireFromString(value string) Decimal {
+	d, err := NewFromString(value)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Sum adds up amounts
+func Sum(values ...Decimal) Decimal {
+	var total Decimal
+	for _, v := range values {
+		total.units += v.units
+	}
+	return total
+}
+
+// Min returns the smaller amount
+func Min(a, b Decimal) Decimal {
+	if a.units < b.units {
+		return a
+	}
+	return b
+}
+
+// Max returns the larger amount
+func Max(a, b Decimal) Decimal {
+	if a.units > b.units {
+		return a
+	}
+	return b
+}
+
+// Add returns d + other
+func (d Decimal) Add(other Decimal) Decimal {
+	return Decimal{units: d.units + other.units}
+}
+
+// Sub returns d - other
+func (d Decimal) Sub(other Decimal) Decimal {
+	return Decimal{units: d.units - other.units}
+}
+
+// Neg returns -d
+func (d Decimal) Neg() Decimal {
+	return Decimal{units: -d.units}
+}
+
+// Abs returns the absolute value of d
+func (d Decimal) Abs() Decimal {
+	if d.units < 0 {
+		return d.Neg()
+	}
+	return d
+}
+
+// Mul scales d by a factor such as a multiplier or share, rounding half away from zero
+func (d Decimal) Mul(factor float64) Decimal {
+	return NewFromFloat(d.Float64() * factor)
+}
+
+// MulTrunc scales d by a factor, dropping fractions of a hundredth
+func (d Decimal) MulTrunc(factor float64) Decimal {
+	scaled := d.Mul(factor)
+	if exact := d.Float64() * factor; math.Abs(scaled.Float64()) > math.Abs(exact) {
+		if scaled.units > 0 {
+			scaled.units--
+		} else {
+			scaled.units++
+		}
+	}
+	return scaled
+}
+
+// DivTrunc divides d into n equal parts, dropping fractions of a hundredth
+func (d Decimal) DivTrunc(n int64) Decimal {
+	return Decimal{units: d.units / n}
+}
+
+// Cmp returns -1, 0 or +1 as d is less than, equal to or greater than other
+func (d Decimal) Cmp(other Decimal) int {
+	switch {
+	case d.units < other.units:
+		return -1
+	case d.units > other.units:
+		return 1
+	}
+	return 0
+}
+
+// Equal reports whether d == other
+func (d Decimal) Equal(other Decimal) bool { return d.units == other.units }
+
+// LessThan reports whether d < other
+func (d Decimal) LessThan(other Decimal) bool { return d.units < other.units }
+
+// LessThanOrEqual reports whether d <= other
+func (d Decimal) LessThanOrEqual(other Decimal) bool { return d.units <= other.units }
+
+// GreaterThan reports whether d > other
+func (d Decimal) GreaterThan(other Decimal) bool { return d.units > other.units }
+
+// GreaterThanOrEqual reports whether d >= other
+func (d Decimal) GreaterThanOrEqual(other Decimal) bool { return d.units >= other.units }
+
+// IsZero reports whether d == 0
+func (d Decimal) IsZero() bool { return d.units == 0 }
+
+// IsPositive reports whether d > 0
+func (d Decimal) IsPositive() bool { return d.units > 0 }
+
+// IsNegative reports whether d < 0
+func (d Decimal) IsNegative() bool { return d.units < 0 }
+
+// Float64 returns the nearest float, for formulas and display only
+func (d Decimal) Float64() float64 {
+	return float64(d.units) / unitsPerOne
+}
+
+// String formats d without trailing fractional zeros, e.g. "12", "12.5", "-0.25"
+func (d Decimal) String() string {
+	units := d.units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	intPart := strconv.FormatInt(units/unitsPerOne, 10)
+	frac := units % unitsPerOne
+	if frac == 0 {
+		return sign + intPart
+	}
+	fracPart := strings.TrimRight(fmt.Sprintf("%0*d", Scale, frac), "0")
+	return sign + intPart + "." + fracPart
+}
+
+// StringFixed formats d with exactly Scale fractional digits, e.g. "12.50"
+func (d Decimal) StringFixed() string {
+	units := d.units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, units/unitsPerOne, Scale, units%unitsPerOne)
+}
+
+// MarshalJSON encodes d as a JSON number
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number, a quoted number, or null as zero
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*d = Zero
+		return nil
+	}
+	s := string(bytes.Trim(data, `"`))
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid decimal %s: %w", data, err)
+		}
+		*d = NewFromFloat(f)
+		return nil
+	}
+	parsed, err := NewFromString(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// MarshalYAML encodes d as a plain YAML number
+func (d Decimal) MarshalYAML() (interface{}, error) {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: d.String()}, nil
+}
+
+// UnmarshalYAML accepts a YAML number, a quoted number, or null as zero
+func (d *Decimal) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return fmt.Errorf("invalid decimal at line %d: expected a number", value.Line)
+	}
+	if value.Tag == "!!null" {
+		*d = Zero
+		return nil
+	}
+	return d.scanString(value.Value)
+}
+
+// Scan implements sql.Scanner for NUMERIC, float and integer columns
+func (d *Decimal) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*d = Zero
+	case []byte:
+		return d.scanString(string(v))
+	case string:
+		return d.scanString(v)
+	case float64:
+		*d = NewFromFloat(v)
+	case float32:
+		*d = NewFromFloat(float64(v))
+	case int64:
+		*d = New(v)
+	default:
+		return fmt.Errorf("cannot scan %T into decimal", value)
+	}
+	return nil
+}
+
+func (d *Decimal) scanString(s string) error {
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid decimal %q: %w", s, err)
+		}
+		*d = NewFromFloat(f)
+		return nil
+	}
+	parsed, err := NewFromString(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Value implements driver.Valuer, sending d as an exact decimal string
+func (d Decimal) Value() (driver.Value, error) {
+	return d.StringFixed(), nil
+}
+
+// GormDataType makes auto-migration create the same DECIMAL(10,2) columns as init_db.sql
+func (Decimal) GormDataType() string {
+	return "decimal(10,2)"
+}
@@ -59,15 +59,50 @@ CREATE TABLE IF NOT EXISTS quota_strategy (
     title VARCHAR(255) NOT NULL,
     type VARCHAR(50) NOT NULL,
     amount DECIMAL(10,2) NOT NULL,
+    amount_expr TEXT,  -- per-user amount formula, amount caps it when > 0
     model VARCHAR(255),
     periodic_expr VARCHAR(255),
+    timezone VARCHAR(64),  -- IANA timezone for periodic_expr, empty = configured timezone
     condition TEXT,
     max_exec_per_user INTEGER NOT NULL DEFAULT 0,
+    topup_threshold DECIMAL(10,2) NOT NULL DEFAULT 0,  -- topup: grant when remaining balance drops below this
+    topup_period VARCHAR(10),  -- topup: day/week/month cap window
+    topup_max_per_period INTEGER NOT NULL DEFAULT 0,  -- topup: grants per user per period, 0=unlimited
+    drip_installments INTEGER NOT NULL DEFAULT 0,  -- drip: installments the amount is spread over, 0/1=grant at once
+    drip_interval VARCHAR(20),  -- drip: time between installments, e.g. 24h
+    exclusion_group VARCHAR(100),  -- users get one grant per group and calendar month
+    priority INTEGER NOT NULL DEFAULT 0,  -- highest matching priority wins within the exclusion group
+    rollover_percent DECIMAL(5,2) NOT NULL DEFAULT 0,  -- expiry: share of the unused amount carried into a new bucket
+    rollover_max_amount DECIMAL(10,2) NOT NULL DEFAULT 0,  -- expiry: cap on the carried amount, 0=no cap
+    rollover_months INTEGER NOT NULL DEFAULT 0,  -- expiry: months the rollover bucket stays valid, 0=1
+    grace_period VARCHAR(20),  -- expiry: time quota stays usable past its expiry date, e.g. 72h
+    fund_pool VARCHAR(500),  -- department pool funded with the amount of each matching user instead of the user
     status BOOLEAN DEFAULT true NOT NULL,  -- Status field: true=enabled, false=disabled
+    approval_status VARCHAR(20) DEFAULT 'approved' NOT NULL,  -- draft/pending/approved
+    shadow BOOLEAN DEFAULT false NOT NULL,  -- true=record would-be grants only
     create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
     update_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Existing deployments: strategies created before the approval workflow are treated as approved
+ALTER TABLE quota_strategy ADD COLUMN IF NOT EXISTS approval_status VARCHAR(20) DEFAULT 'approved' NOT NULL;
+ALTER TABLE quota_strategy ADD COLUMN IF NOT EXISTS shadow BOOLEAN DEFAULT false NOT NULL;
+ALTER TABLE quota_strategy ADD COLUMN IF NOT EXISTS timezone VARCHAR(64);
+ALTER TABLE quota_strategy ADD COLUMN IF NOT EXISTS topup_threshold DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE quota_strategy ADD COLUMN IF NOT EXISTS topup_period VARCHAR(10);
+ALTER TABLE quota_strategy ADD COLUMN IF NOT EXISTS topup_max_per_period INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE quota_strategy ADD COLUMN IF NOT EXISTS drip_installments INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE quota_strategy ADD COLUMN IF NOT EXISTS drip_interval VARCHAR(20);
+ALTER TABLE quota_strategy ADD COLUMN IF NOT EXISTS amount_expr TEXT;
+ALTER TABLE quota_strategy ADD COLUMN IF NOT EXISTS exclusion_group VARCHAR(100);
+ALTER TABLE quota_strategy ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE quota_strategy ADD COLUMN IF NOT EXISTS rollover_percent DECIMAL(5,2) NOT NULL DEFAULT 0;
+ALTER TABLE quota_strategy ADD COLUMN IF NOT EXISTS rollover_max_amount DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE quota_strategy ADD COLUMN IF NOT EXISTS rollover_months INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE quota_strategy ADD COLUMN IF NOT EXISTS grace_period VARCHAR(20);
+ALTER TABLE quota_strategy ADD COLUMN IF NOT EXISTS fund_pool VARCHAR(500);
+CREATE INDEX IF NOT EXISTS idx_quota_strategy_exclusion_group ON quota_strategy(exclusion_group);
+
 -- Quota execution status table
 CREATE TABLE IF NOT EXISTS quota_execute (
     id SERIAL PRIMARY KEY,
@@ -95,12 +130,23 @@ CREATE TABLE IF NOT EXISTS quota (
     id SERIAL PRIMARY KEY,
     user_id VARCHAR(255) NOT NULL,
     amount DECIMAL(10,2) NOT NULL,
+    consumed DECIMAL(10,2) NOT NULL DEFAULT 0,  -- usage charged to this bucket, FIFO by expiry
+    model VARCHAR(100) NOT NULL DEFAULT '',  -- model pool, empty = any model
+    strategy_id INTEGER,  -- originating strategy when it has its own expiry policy
+    rollover_from INTEGER,  -- expired bucket this one was rolled over from
     expiry_date TIMESTAMPTZ(0) NOT NULL,
     status VARCHAR(20) DEFAULT 'VALID' NOT NULL,
     create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
     update_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
 );
 
+ALTER TABLE quota ADD COLUMN IF NOT EXISTS consumed DECIMAL(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE quota ADD COLUMN IF NOT EXISTS strategy_id INTEGER;
+ALTER TABLE quota ADD COLUMN IF NOT EXISTS rollover_from INTEGER;
+ALTER TABLE quota ADD COLUMN IF NOT EXISTS model VARCHAR(100) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_quota_strategy_id ON quota(strategy_id);
+CREATE INDEX IF NOT EXISTS idx_quota_model ON quota(model);
+
 CREATE INDEX IF NOT EXISTS idx_quota_user_id ON quota(user_id);
 CREATE INDEX IF NOT EXISTS idx_quota_expiry_date ON quota(expiry_date);
 CREATE INDEX IF NOT EXISTS idx_quota_status ON quota(status);
@@ -111,6 +157,7 @@ CREATE TABLE IF NOT EXISTS quota_audit (
     user_id VARCHAR(255) NOT NULL,
     amount DECIMAL(10,2) NOT NULL,
     operation VARCHAR(50) NOT NULL,
+    model VARCHAR(100) NOT NULL DEFAULT '',  -- model pool, empty = any model
     voucher_code VARCHAR(1000),
     related_user VARCHAR(255),
     strategy_id INTEGER,
@@ -120,10 +167,41 @@ CREATE TABLE IF NOT EXISTS quota_audit (
     create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
 );
 
+ALTER TABLE quota_audit ADD COLUMN IF NOT EXISTS model VARCHAR(100) NOT NULL DEFAULT '';
+
 CREATE INDEX IF NOT EXISTS idx_quota_audit_user_id ON quota_audit(user_id);
 CREATE INDEX IF NOT EXISTS idx_quota_audit_operation ON quota_audit(operation);
 CREATE INDEX IF NOT EXISTS idx_quota_audit_strategy_name ON quota_audit(strategy_name);
 CREATE INDEX IF NOT EXISTS idx_quota_audit_create_time ON quota_audit(create_time);
+CREATE INDEX IF NOT EXISTS idx_quota_audit_strategy_id ON quota_audit(strategy_id);
+CREATE INDEX IF NOT EXISTS idx_quota_audit_related_user ON quota_audit(related_user);
+
+-- Tamper-evident hash chain: every record carries a keyed hash over its content and the
+-- hash of the user's previous record; records written before chaining keep empty hashes
+ALTER TABLE quota_audit ADD COLUMN IF NOT EXISTS prev_hash VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE quota_audit ADD COLUMN IF NOT EXISTS hash VARCHAR(64) NOT NULL DEFAULT '';
+
+-- Last record of each user's audit hash chain, locked to append to it
+CREATE TABLE IF NOT EXISTS quota_audit_chain_head (
+    user_id VARCHAR(255) PRIMARY KEY,
+    last_audit_id INTEGER NOT NULL DEFAULT 0,
+    last_hash VARCHAR(64) NOT NULL DEFAULT '',
+    length BIGINT NOT NULL DEFAULT 0,  -- chained records of the user
+    update_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Breaks found by audit hash chain verification, kept as first detected
+CREATE TABLE IF NOT EXISTS quota_audit_chain_break (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    audit_id INTEGER NOT NULL,
+    kind VARCHAR(30) NOT NULL,  -- hash_mismatch/link_mismatch/unsealed/head_mismatch
+    detail TEXT,
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_chain_break ON quota_audit_chain_break(user_id, audit_id, kind);
+CREATE INDEX IF NOT EXISTS idx_quota_audit_chain_break_kind ON quota_audit_chain_break(kind);
 
 -- Voucher redemption table
 CREATE TABLE IF NOT EXISTS voucher_redemption (
@@ -281,6 +359,10 @@ CREATE INDEX IF NOT EXISTS idx_monthly_quota_usage_user_id ON monthly_quota_usag
 CREATE INDEX IF NOT EXISTS idx_monthly_quota_usage_year_month ON monthly_quota_usage(year_month);
 CREATE INDEX IF NOT EXISTS idx_monthly_quota_usage_user_month ON monthly_quota_usage(user_id, year_month);
 
+-- Model cost catalog revisions in effect when the month started and ended
+ALTER TABLE monthly_quota_usage ADD COLUMN IF NOT EXISTS catalog_revision INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE monthly_quota_usage ADD COLUMN IF NOT EXISTS catalog_revision_from INTEGER NOT NULL DEFAULT 0;
+
 -- Add comments
 COMMENT ON TABLE monthly_quota_usage IS 'Monthly quota usage record table';
 COMMENT ON COLUMN monthly_quota_usage.user_id IS 'User ID';
@@ -288,3 +370,359 @@ COMMENT ON COLUMN monthly_quota_usage.year_month IS 'Year and month identifier,
 COMMENT ON COLUMN monthly_quota_usage.used_quota IS 'Used quota amount';
 COMMENT ON COLUMN monthly_quota_usage.record_time IS 'Record time';
 COMMENT ON COLUMN monthly_quota_usage.create_time IS 'Create time';
+
+-- Daily quota usage snapshot table
+CREATE TABLE IF NOT EXISTS daily_quota_usage (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    snapshot_date VARCHAR(10) NOT NULL,  -- Format: YYYY-MM-DD
+    total_quota DECIMAL(10,2) NOT NULL DEFAULT 0,
+    used_quota DECIMAL(10,2) NOT NULL DEFAULT 0,
+    usage DECIMAL(10,2) NOT NULL DEFAULT 0,
+    department TEXT NOT NULL DEFAULT '',
+    catalog_revision INTEGER NOT NULL DEFAULT 0,
+    record_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, snapshot_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_daily_quota_usage_snapshot_date ON daily_quota_usage(snapshot_date);
+
+COMMENT ON TABLE daily_quota_usage IS 'Daily quota usage snapshot table';
+COMMENT ON COLUMN daily_quota_usage.snapshot_date IS 'Snapshot date, format: YYYY-MM-DD';
+COMMENT ON COLUMN daily_quota_usage.used_quota IS 'AiGateway used quota at snapshot time';
+COMMENT ON COLUMN daily_quota_usage.usage IS 'Used quota since the previous snapshot';
+COMMENT ON COLUMN daily_quota_usage.department IS 'Department levels at snapshot time, comma separated';
+
+-- Strategy approval audit trail
+CREATE TABLE IF NOT EXISTS strategy_approval (
+    id SERIAL PRIMARY KEY,
+    strategy_id INTEGER NOT NULL,
+    strategy_name VARCHAR(100) NOT NULL,
+    action VARCHAR(20) NOT NULL,  -- 'submit', 'approve', 'reject', 'revoke'
+    operator VARCHAR(255) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    audience_size INTEGER NOT NULL DEFAULT 0,  -- simulated number of matching users
+    comment TEXT,
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_strategy_approval_strategy_id ON strategy_approval(strategy_id);
+CREATE INDEX IF NOT EXISTS idx_strategy_approval_create_time ON strategy_approval(create_time);
+CREATE INDEX IF NOT EXISTS idx_quota_strategy_approval_status ON quota_strategy(approval_status);
+
+-- Would-be grants recorded by shadow strategies
+CREATE TABLE IF NOT EXISTS strategy_shadow_result (
+    id SERIAL PRIMARY KEY,
+    strategy_id INTEGER NOT NULL,
+    strategy_name VARCHAR(100) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    batch_number VARCHAR(20) NOT NULL,  -- run identifier
+    condition TEXT,
+    condition_trace TEXT,  -- JSON array of evaluated function calls
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_strategy_shadow_result_strategy_id ON strategy_shadow_result(strategy_id);
+CREATE INDEX IF NOT EXISTS idx_strategy_shadow_result_user_id ON strategy_shadow_result(user_id);
+CREATE INDEX IF NOT EXISTS idx_strategy_shadow_result_batch_number ON strategy_shadow_result(batch_number);
+CREATE INDEX IF NOT EXISTS idx_strategy_shadow_result_create_time ON strategy_shadow_result(create_time);
+
+-- Low-balance top-up executions of topup strategies
+CREATE TABLE IF NOT EXISTS quota_topup_execute (
+    id SERIAL PRIMARY KEY,
+    strategy_id INTEGER NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    period_key VARCHAR(20) NOT NULL,  -- cap window, e.g. 2025-01-15, 2025-W03, 2025-01
+    balance_before DECIMAL(10,2) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    status VARCHAR(20) NOT NULL,  -- processing/completed/failed/shadow
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
+    update_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_quota_topup_execute_strategy_user_period ON quota_topup_execute(strategy_id, user_id, period_key);
+CREATE INDEX IF NOT EXISTS idx_quota_topup_execute_user_id ON quota_topup_execute(user_id);
+
+-- Attempt number within the period; the unique slot lets only one replica claim a top-up
+ALTER TABLE quota_topup_execute ADD COLUMN IF NOT EXISTS seq INTEGER NOT NULL DEFAULT 0;
+UPDATE quota_topup_execute t SET seq = numbered.seq
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY strategy_id, user_id, period_key ORDER BY id) AS seq
+    FROM quota_topup_execute
+) numbered
+WHERE t.id = numbered.id AND t.seq = 0;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_topup_execute_slot ON quota_topup_execute(strategy_id, user_id, period_key, seq);
+
+-- Drip grant plans: one strategy grant spread over several installments
+CREATE TABLE IF NOT EXISTS quota_drip_plan (
+    id SERIAL PRIMARY KEY,
+    strategy_id INTEGER NOT NULL,
+    strategy_name VARCHAR(100) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    total_amount DECIMAL(10,2) NOT NULL,
+    installments INTEGER NOT NULL,
+    interval VARCHAR(20) NOT NULL,
+    released_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    amount_formula TEXT,  -- JSON formula detail when the total came from an amount expression
+    status VARCHAR(20) NOT NULL,  -- active/completed/cancelled
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
+    update_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_quota_drip_plan_strategy_id ON quota_drip_plan(strategy_id);
+CREATE INDEX IF NOT EXISTS idx_quota_drip_plan_user_id ON quota_drip_plan(user_id);
+CREATE INDEX IF NOT EXISTS idx_quota_drip_plan_status ON quota_drip_plan(status);
+
+-- Scheduled installments of drip plans, each released as its own quota bucket
+CREATE TABLE IF NOT EXISTS quota_drip_installment (
+    id SERIAL PRIMARY KEY,
+    plan_id INTEGER NOT NULL,
+    sequence INTEGER NOT NULL,  -- 1-based
+    amount DECIMAL(10,2) NOT NULL,
+    release_time TIMESTAMPTZ(0) NOT NULL,
+    status VARCHAR(20) NOT NULL,  -- pending/released/cancelled
+    released_time TIMESTAMPTZ(0),
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
+    update_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_quota_drip_installment_plan_id ON quota_drip_installment(plan_id);
+CREATE INDEX IF NOT EXISTS idx_quota_drip_installment_status_release ON quota_drip_installment(status, release_time);
+
+-- Results of keyed transfer requests, replayed for client retries with the same Idempotency-Key
+CREATE TABLE IF NOT EXISTS quota_idempotency_key (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    endpoint VARCHAR(100) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,  -- sha256 of the request body
+    status VARCHAR(20) NOT NULL,  -- processing/completed
+    response_code INTEGER NOT NULL DEFAULT 0,
+    response_body TEXT,
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
+    update_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_user_endpoint_key ON quota_idempotency_key(user_id, endpoint, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_quota_idempotency_key_create_time ON quota_idempotency_key(create_time);
+
+-- AiGateway quota mutations written in the same transaction as the quota change,
+-- delivered after commit and retried by the outbox dispatcher
+CREATE TABLE IF NOT EXISTS gateway_outbox (
+    id SERIAL PRIMARY KEY,
+    dedup_key VARCHAR(255) UNIQUE NOT NULL,  -- sent as Idempotency-Key to the gateway
+    user_id VARCHAR(255) NOT NULL,
+    mutation VARCHAR(30) NOT NULL,  -- delta_quota/delta_used_quota
+    model VARCHAR(100) NOT NULL DEFAULT '',  -- model pool counter, empty = any model
+    value DECIMAL(10,2) NOT NULL,
+    source VARCHAR(50) NOT NULL,  -- audit operation that caused the mutation
+    status VARCHAR(20) NOT NULL,  -- pending/delivering/delivered/failed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_time TIMESTAMPTZ(0) NOT NULL,
+    delivered_time TIMESTAMPTZ(0),
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
+    update_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE gateway_outbox ADD COLUMN IF NOT EXISTS model VARCHAR(100) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_gateway_outbox_status_next ON gateway_outbox(status, next_attempt_time);
+CREATE INDEX IF NOT EXISTS idx_gateway_outbox_user_id ON gateway_outbox(user_id);
+
+-- Reconciliation runs comparing the quota ledger, the audit log and AiGateway balances
+CREATE TABLE IF NOT EXISTS reconciliation_report (
+    id SERIAL PRIMARY KEY,
+    mode VARCHAR(20) NOT NULL,  -- dry_run/fix
+    status VARCHAR(20) NOT NULL,  -- running/completed/failed
+    triggered_by VARCHAR(50),  -- schedule/api/scan
+    total_users INTEGER NOT NULL DEFAULT 0,
+    consistent_users INTEGER NOT NULL DEFAULT 0,
+    discrepancy_users INTEGER NOT NULL DEFAULT 0,
+    fixed_users INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    start_time TIMESTAMPTZ(0) NOT NULL,
+    end_time TIMESTAMPTZ(0),
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_report_status ON reconciliation_report(status);
+
+-- Users whose balances disagree in a reconciliation run
+CREATE TABLE IF NOT EXISTS reconciliation_item (
+    id SERIAL PRIMARY KEY,
+    report_id INTEGER NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    classification VARCHAR(50) NOT NULL,  -- ledger_audit_mismatch/gateway_total_mismatch/pending_delivery/gateway_overused/read_error
+    ledger_sum DECIMAL(10,2) NOT NULL,  -- SUM(quota.amount) of valid buckets
+    audit_net DECIMAL(10,2) NOT NULL,  -- SUM(quota_audit.amount)
+    gateway_total DECIMAL(10,2) NOT NULL,
+    gateway_used DECIMAL(10,2) NOT NULL,
+    pending_delta DECIMAL(10,2) NOT NULL,  -- undelivered outbox delta_quota mutations
+    action VARCHAR(30) NOT NULL,  -- none/refreshed/manual_review/fix_failed
+    detail TEXT,
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_item_report_id ON reconciliation_item(report_id);
+CREATE INDEX IF NOT EXISTS idx_reconciliation_item_user_id ON reconciliation_item(user_id);
+CREATE INDEX IF NOT EXISTS idx_reconciliation_item_classification ON reconciliation_item(classification);
+
+-- Usage charged to quota buckets, derived from AiGateway used quota deltas
+CREATE TABLE IF NOT EXISTS quota_consumption (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    quota_id INTEGER NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    used_quota DECIMAL(10,2) NOT NULL,  -- AiGateway used quota the usage was derived from
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_quota_consumption_user_id ON quota_consumption(user_id);
+CREATE INDEX IF NOT EXISTS idx_quota_consumption_quota_id ON quota_consumption(quota_id);
+CREATE INDEX IF NOT EXISTS idx_quota_consumption_create_time ON quota_consumption(create_time);
+
+-- AiGateway used quota already charged to each user's buckets, per model pool
+CREATE TABLE IF NOT EXISTS quota_usage_cursor (
+    user_id VARCHAR(255) NOT NULL,
+    model VARCHAR(100) NOT NULL DEFAULT '',  -- model pool, empty = any model
+    used_quota DECIMAL(10,2) NOT NULL DEFAULT 0,
+    overused DECIMAL(10,2) NOT NULL DEFAULT 0,  -- usage no valid bucket could absorb
+    update_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, model)
+);
+
+-- Cursors created before model pools are keyed by user only
+ALTER TABLE quota_usage_cursor ADD COLUMN IF NOT EXISTS model VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE quota_usage_cursor DROP CONSTRAINT IF EXISTS quota_usage_cursor_pkey;
+ALTER TABLE quota_usage_cursor ADD PRIMARY KEY (user_id, model);
+
+-- Upcoming quota expiries, raised once per bucket and warning window
+CREATE TABLE IF NOT EXISTS quota_expiry_notice (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    quota_id INTEGER NOT NULL,
+    days_before INTEGER NOT NULL,  -- warning window the notice was raised for
+    amount DECIMAL(10,2) NOT NULL,  -- remaining amount when the notice was raised
+    expiry_date TIMESTAMPTZ(0) NOT NULL,
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_expiry_notice_quota_days ON quota_expiry_notice(quota_id, days_before);
+CREATE INDEX IF NOT EXISTS idx_quota_expiry_notice_user_id ON quota_expiry_notice(user_id);
+CREATE INDEX IF NOT EXISTS idx_quota_expiry_notice_expiry_date ON quota_expiry_notice(expiry_date);
+
+-- Model cost catalog changes, one catalog revision each
+CREATE TABLE IF NOT EXISTS model_cost_multiplier (
+    id SERIAL PRIMARY KEY,
+    revision INTEGER NOT NULL UNIQUE,  -- catalog revision the change was made in
+    model VARCHAR(100) NOT NULL,
+    multiplier DECIMAL(10,4) NOT NULL,  -- quota charged per unit of the model's cost
+    removed BOOLEAN NOT NULL DEFAULT FALSE,  -- the model left the catalog, costing 1x again
+    operator VARCHAR(255),
+    reason VARCHAR(500),
+    pushed BOOLEAN NOT NULL DEFAULT FALSE,  -- the catalog at this revision reached AiGateway
+    effective_time TIMESTAMPTZ(0) NOT NULL,
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_model_cost_multiplier_model ON model_cost_multiplier(model);
+
+-- Quota held for long-running jobs until committed with the actual amount or released
+CREATE TABLE IF NOT EXISTS quota_reservation (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    model VARCHAR(100) NOT NULL DEFAULT '',  -- model pool, empty = any model
+    amount DECIMAL(10,2) NOT NULL,  -- held amount
+    actual_amount DECIMAL(10,2) NOT NULL DEFAULT 0,  -- amount used, set on commit
+    status VARCHAR(20) NOT NULL DEFAULT 'HELD',  -- HELD/COMMITTED/RELEASED/EXPIRED
+    reference VARCHAR(255),  -- caller's job reference
+    expires_at TIMESTAMPTZ(0) NOT NULL,
+    settle_time TIMESTAMPTZ(0),
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
+    update_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_quota_reservation_user_id ON quota_reservation(user_id);
+CREATE INDEX IF NOT EXISTS idx_reservation_status_expires ON quota_reservation(status, expires_at);
+
+-- Part of a reservation taken from one bucket, returned to it on settlement
+CREATE TABLE IF NOT EXISTS quota_reservation_hold (
+    id SERIAL PRIMARY KEY,
+    reservation_id INTEGER NOT NULL,
+    quota_id INTEGER NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    expiry_date TIMESTAMPTZ(0) NOT NULL,  -- bucket expiry, to recreate a bucket merged away meanwhile
+    strategy_id INTEGER,
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_quota_reservation_hold_reservation_id ON quota_reservation_hold(reservation_id);
+
+-- Credit limits of users and departments: how far below zero the available balance may go
+CREATE TABLE IF NOT EXISTS credit_limit_setting (
+    id SERIAL PRIMARY KEY,
+    target_type VARCHAR(20) NOT NULL,  -- 'user' or 'department'
+    target_identifier VARCHAR(500) NOT NULL,  -- user ID for user, department name for department
+    credit_limit DECIMAL(10,2) NOT NULL,
+    operator VARCHAR(255),
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
+    update_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_credit_limit_target ON credit_limit_setting(target_type, target_identifier);
+
+-- Credit line granted to AiGateway and outstanding debt of each user's "any model" pool
+CREATE TABLE IF NOT EXISTS quota_credit (
+    user_id VARCHAR(255) PRIMARY KEY,
+    credit_limit DECIMAL(10,2) NOT NULL DEFAULT 0,  -- limit added to the AiGateway total
+    debt DECIMAL(10,2) NOT NULL DEFAULT 0,  -- usage beyond the user's quota, paid from the next recharge
+    update_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Department quota pools: shared budgets members draw from once their personal balance is exhausted
+CREATE TABLE IF NOT EXISTS department_pool (
+    id SERIAL PRIMARY KEY,
+    department VARCHAR(500) NOT NULL UNIQUE,
+    balance DECIMAL(10,2) NOT NULL DEFAULT 0,
+    member_cap DECIMAL(10,2) NOT NULL DEFAULT 0,  -- max drawn per member and calendar month, 0=unlimited
+    draw_amount DECIMAL(10,2) NOT NULL DEFAULT 0,  -- quota moved per automatic draw, 0=on-demand draws only
+    operator VARCHAR(255),
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
+    update_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Every movement of a department pool's balance
+CREATE TABLE IF NOT EXISTS department_pool_ledger (
+    id SERIAL PRIMARY KEY,
+    pool_id INTEGER NOT NULL,
+    operation VARCHAR(20) NOT NULL,  -- POOL_FUND or POOL_DRAW
+    amount DECIMAL(10,2) NOT NULL,  -- positive for funding, negative for draws
+    balance_after DECIMAL(10,2) NOT NULL,
+    user_id VARCHAR(255),  -- drawing member
+    year_month VARCHAR(7),  -- member caps count draws per calendar month
+    strategy_id INTEGER,
+    strategy_name VARCHAR(100),
+    operator VARCHAR(255),
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_department_pool_ledger_pool_id ON department_pool_ledger(pool_id);
+CREATE INDEX IF NOT EXISTS idx_department_pool_ledger_user_id ON department_pool_ledger(user_id);
+CREATE INDEX IF NOT EXISTS idx_department_pool_ledger_year_month ON department_pool_ledger(year_month);
+
+-- Maximum balance overrides of users and departments; the global cap is configured in balance_cap
+CREATE TABLE IF NOT EXISTS balance_cap_setting (
+    id SERIAL PRIMARY KEY,
+    target_type VARCHAR(20) NOT NULL,  -- 'user' or 'department'
+    target_identifier VARCHAR(500) NOT NULL,  -- user ID for user, department name for department
+    max_balance DECIMAL(10,2) NOT NULL,  -- 0 = no cap
+    policy VARCHAR(20),  -- truncate or reject, empty = global policy
+    operator VARCHAR(255),
+    create_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
+    update_time TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_balance_cap_target ON balance_cap_setting(target_type, target_identifier);
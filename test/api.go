@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"quota-manager/pkg/decimal"
 	"strings"
 
 	"quota-manager/internal/config"
@@ -312,7 +313,7 @@ func testAPIGetStrategies(ctx *TestContext) TestResult {
 		Name:      "list-test-strategy",
 		Title:     "List Test Strategy",
 		Type:      "single",
-		Amount:    50,
+		Amount:    decimal.New(50),
 		Model:     "test-model",
 		Condition: "true()",
 		Status:    true,
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+)
+
+// chainedAuditIDs grants amounts to a user and returns the IDs of the chained audit records
+func chainedAuditIDs(ctx *TestContext, userID string, amounts ...int64) ([]int, error) {
+	for _, amount := range amounts {
+		if err := grantTestQuota(ctx, userID, amount); err != nil {
+			return nil, err
+		}
+	}
+	var ids []int
+	if err := ctx.DB.Model(&models.QuotaAudit{}).Where("user_id = ?", userID).Order("id ASC").Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// testAuditChainVerification tests that the audit hash chain detects edited, removed and
+// unsealed records, and that scheduled runs record each break once
+func testAuditChainVerification(ctx *TestContext) TestResult {
+	intact := "audit-chain-intact-user"
+	edited := "audit-chain-edited-user"
+	removed := "audit-chain-removed-user"
+
+	// Records written before chaining started are counted as unsealed, not as breaks
+	if err := ctx.DB.Create(&models.QuotaAudit{
+		UserID:     intact,
+		Amount:     decimal.New(5),
+		Operation:  models.OperationRecharge,
+		ExpiryDate: time.Now().Add(30 * 24 * time.Hour),
+		CreateTime: time.Now(),
+	}).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create legacy audit failed: %v", err)}
+	}
+	if _, err := chainedAuditIDs(ctx, intact, 10, 20); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Grant failed: %v", err)}
+	}
+	result, err := ctx.QuotaService.VerifyAuditChain(intact)
+	if err != nil || !result.Valid || result.Records != 3 || result.Chained != 2 || result.Unsealed != 1 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected a valid chain of 2 after 1 unsealed record, got %+v (%v)", result, err)}
+	}
+
+	// Editing a record breaks its hash
+	ids, err := chainedAuditIDs(ctx, edited, 10, 20, 30)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Grant failed: %v", err)}
+	}
+	if err := ctx.DB.Model(&models.QuotaAudit{}).Where("id = ?", ids[1]).Update("amount", decimal.New(200)).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Tamper audit failed: %v", err)}
+	}
+	result, err = ctx.QuotaService.VerifyAuditChain(edited)
+	if err != nil || result.Valid || len(result.Breaks) != 1 ||
+		result.Breaks[0].AuditID != ids[1] || result.Breaks[0].Kind != models.AuditChainHashMismatch {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected a hash mismatch at record %d, got %+v (%v)", ids[1], result, err)}
+	}
+
+	// Removing a record breaks the link of the next one
+	ids, err = chainedAuditIDs(ctx, removed, 10, 20, 30)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Grant failed: %v", err)}
+	}
+	if err := ctx.DB.Delete(&models.QuotaAudit{}, ids[1]).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Delete audit failed: %v", err)}
+	}
+	result, err = ctx.QuotaService.VerifyAuditChain(removed)
+	if err != nil || result.Valid || len(result.Breaks) != 2 || result.Breaks[0].AuditID != ids[2] ||
+		result.Breaks[0].Kind != models.AuditChainLinkMismatch || result.Breaks[1].Kind != models.AuditChainHeadMismatch {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected a link mismatch at record %d and a head count mismatch, got %+v (%v)", ids[2], result, err)}
+	}
+
+	// Scheduled runs record the breaks, a break found again is not recorded twice
+	ctx.QuotaService.RunScheduledAuditChainVerification()
+	ctx.QuotaService.RunScheduledAuditChainVerification()
+	for userID, expected := range map[string]int64{intact: 0, edited: 1, removed: 2} {
+		_, total, err := ctx.QuotaService.GetAuditChainBreaks(userID, 1, 10)
+		if err != nil || total != expected {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Expected %d recorded breaks for %s, got %d (%v)", expected, userID, total, err)}
+		}
+	}
+
+	return TestResult{Passed: true, Message: "Audit Chain Verification Test Succeeded"}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"quota-manager/internal/models"
+	"quota-manager/internal/services"
+	"quota-manager/pkg/decimal"
+)
+
+// testAuditSearchExport tests audit search filters, keyset pages, summaries and exports
+func testAuditSearchExport(ctx *TestContext) TestResult {
+	userID := "audit-search-test-user"
+	for _, amount := range []int64{10, 20, 30} {
+		if err := grantTestQuota(ctx, userID, amount); err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Grant failed: %v", err)}
+		}
+	}
+	if _, err := ctx.QuotaService.ReserveQuota(userID, &services.ReserveQuotaRequest{Amount: decimal.New(5)}); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Reserve failed: %v", err)}
+	}
+
+	if _, err := ctx.QuotaService.SearchQuotaAudit(&services.AuditSearchFilter{Operations: []string{"GIFT"}}, 0, 10); serviceErrorCode(err) != services.ErrorValidationFailed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unknown operation expected validation_failed, got %v", err)}
+	}
+
+	// Keyset pages walk the records newest first
+	filter := &services.AuditSearchFilter{UserID: userID}
+	first, err := ctx.QuotaService.SearchQuotaAudit(filter, 0, 3)
+	if err != nil || len(first.Records) != 3 || first.NextCursor == 0 || first.Records[0].Operation != models.OperationReserve {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected first page: %+v (%v)", first, err)}
+	}
+	second, err := ctx.QuotaService.SearchQuotaAudit(filter, first.NextCursor, 3)
+	if err != nil || len(second.Records) != 1 || second.NextCursor != 0 || !second.Records[0].Amount.Equal(decimal.New(10)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected last page: %+v (%v)", second, err)}
+	}
+
+	minAmount := decimal.New(15)
+	recharges, err := ctx.QuotaService.SearchQuotaAudit(&services.AuditSearchFilter{
+		UserID:     userID,
+		Operations: []string{models.OperationRecharge},
+		MinAmount:  &minAmount,
+	}, 0, 10)
+	if err != nil || len(recharges.Records) != 2 || !recharges.Records[0].Amount.Equal(decimal.New(30)) || !recharges.Records[1].Amount.Equal(decimal.New(20)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the recharges of 30 and 20, got %+v (%v)", recharges, err)}
+	}
+
+	summary, err := ctx.QuotaService.SummarizeQuotaAudit(filter)
+	if err != nil || summary.Count != 4 || !summary.TotalAmount.Equal(decimal.New(55)) ||
+		!summary.Credited.Equal(decimal.New(60)) || !summary.Debited.Equal(decimal.New(-5)) || len(summary.Operations) != 2 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected audit summary: %+v (%v)", summary, err)}
+	}
+
+	// Exports hold the same records
+	var csvOut bytes.Buffer
+	if err := ctx.QuotaService.ExportQuotaAuditCSV(filter, &csvOut); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("CSV export failed: %v", err)}
+	}
+	rows, err := csv.NewReader(&csvOut).ReadAll()
+	if err != nil || len(rows) != 5 || rows[0][0] != "id" || rows[1][2] != models.OperationReserve || rows[1][3] != "-5.00" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected CSV export: %v (%v)", rows, err)}
+	}
+
+	var ndjsonOut bytes.Buffer
+	if err := ctx.QuotaService.ExportQuotaAuditNDJSON(filter, &ndjsonOut); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("NDJSON export failed: %v", err)}
+	}
+	lines := strings.Split(strings.TrimSpace(ndjsonOut.String()), "\n")
+	if len(lines) != 4 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 4 NDJSON lines, got %d", len(lines))}
+	}
+	var last models.QuotaAudit
+	if err := json.Unmarshal([]byte(lines[3]), &last); err != nil || last.UserID != userID || !last.Amount.Equal(decimal.New(10)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected NDJSON record: %s (%v)", lines[3], err)}
+	}
+
+	return TestResult{Passed: true, Message: "Audit Search Export Test Succeeded"}
+}
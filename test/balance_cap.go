@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/pkg/decimal"
+
+	"quota-manager/internal/models"
+	"quota-manager/internal/services"
+)
+
+// testBalanceCapPolicies tests that grants are truncated or rejected at the user's maximum balance
+func testBalanceCapPolicies(ctx *TestContext) TestResult {
+	truncated := "balance-cap-truncate-user"
+	rejected := "balance-cap-reject-user"
+
+	if _, err := ctx.QuotaService.SetBalanceCap(models.TargetTypeUser, truncated, decimal.New(50), "drop", "admin"); serviceErrorCode(err) != services.ErrorValidationFailed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Invalid policy expected validation_failed, got %v", err)}
+	}
+	setting, err := ctx.QuotaService.SetBalanceCap(models.TargetTypeUser, truncated, decimal.New(50), services.BalanceCapPolicyTruncate, "admin")
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Set balance cap failed: %v", err)}
+	}
+	if _, err := ctx.QuotaService.SetBalanceCap(models.TargetTypeUser, rejected, decimal.New(25), services.BalanceCapPolicyReject, "admin"); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Set balance cap failed: %v", err)}
+	}
+
+	// Truncate grants what fits and records the excess
+	if err := grantTestQuota(ctx, truncated, 30); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Grant failed: %v", err)}
+	}
+	if err := grantTestQuota(ctx, truncated, 40); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Grant failed: %v", err)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(truncated); total != 50 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 50 at the cap, got %f", total)}
+	}
+	var audit models.QuotaAudit
+	if err := ctx.DB.Where("user_id = ? AND operation = ?", truncated, models.OperationRecharge).Order("id DESC").First(&audit).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Recharge audit not found: %v", err)}
+	}
+	details, err := audit.UnmarshalDetails()
+	if err != nil || details.BalanceCap == nil || !audit.Amount.Equal(decimal.New(20)) ||
+		!details.BalanceCap.TruncatedAmount.Equal(decimal.New(20)) || details.BalanceCap.Source != models.TargetTypeUser {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected a grant of 20 with 20 truncated, got %s (%v)", audit.Amount, err)}
+	}
+
+	// A user at the cap gets nothing
+	if err := grantTestQuota(ctx, truncated, 10); serviceErrorCode(err) != services.ErrorConflict {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Grant at the cap expected conflict, got %v", err)}
+	}
+
+	// Reject refuses grants that do not fit whole
+	if err := grantTestQuota(ctx, rejected, 20); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Grant failed: %v", err)}
+	}
+	if err := grantTestQuota(ctx, rejected, 10); serviceErrorCode(err) != services.ErrorConflict {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Grant past a reject cap expected conflict, got %v", err)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(rejected); total != 20 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 20 after the rejected grant, got %f", total)}
+	}
+
+	info, err := ctx.QuotaService.GetBalanceCapInfo(truncated)
+	if err != nil || info.Cap == nil || !info.Cap.MaxBalance.Equal(decimal.New(50)) || !info.Balance.Equal(decimal.New(50)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected balance cap info: %+v (%v)", info, err)}
+	}
+
+	// Without the user setting the global cap of config.yaml applies
+	if err := ctx.QuotaService.DeleteBalanceCap(setting.ID); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Delete balance cap failed: %v", err)}
+	}
+	if info, err = ctx.QuotaService.GetBalanceCapInfo(truncated); err != nil || (info.Cap != nil && info.Cap.Source != "global") {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the global cap after deleting the user setting, got %+v (%v)", info, err)}
+	}
+
+	return TestResult{Passed: true, Message: "Balance Cap Policies Test Succeeded"}
+}
@@ -22,7 +22,7 @@ import (
 // testClearData test clear data - unified data clearing for all test modules
 func testClearData(ctx *TestContext) TestResult {
 	// Clear quota-related tables from main database
-	quotaTables := []string{"voucher_redemption", "quota_audit", "quota", "quota_execute", "quota_strategy"}
+	quotaTables := []string{"voucher_redemption", "quota_audit", "quota_audit_chain_head", "quota_audit_chain_break", "daily_quota_usage", "quota_reservation_hold", "quota_reservation", "quota_consumption", "quota_usage_cursor", "quota_expiry_notice", "quota", "quota_execute", "strategy_approval", "strategy_shadow_result", "quota_topup_execute", "quota_drip_installment", "quota_drip_plan", "quota_idempotency_key", "gateway_outbox", "reconciliation_item", "reconciliation_report", "model_cost_multiplier", "credit_limit_setting", "quota_credit", "department_pool_ledger", "department_pool", "balance_cap_setting", "quota_strategy"}
 	for _, table := range quotaTables {
 		if err := ctx.DB.DB.Exec("DELETE FROM " + table).Error; err != nil {
 			return TestResult{Passed: false, Message: fmt.Sprintf("Clear table %s failed: %v", table, err)}
@@ -133,7 +133,7 @@ func setupTestEnvironment() (*TestContext, error) {
 	}
 
 	// Auto migrate - ensure all tables exist in test environment
-	if err := db.DB.AutoMigrate(&models.QuotaStrategy{}, &models.QuotaExecute{}, &models.Quota{}, &models.QuotaAudit{}, &models.VoucherRedemption{}, &models.MonthlyQuotaUsage{}); err != nil {
+	if err := db.DB.AutoMigrate(&models.QuotaStrategy{}, &models.QuotaExecute{}, &models.Quota{}, &models.QuotaAudit{}, &models.QuotaAuditChainHead{}, &models.QuotaAuditChainBreak{}, &models.VoucherRedemption{}, &models.MonthlyQuotaUsage{}, &models.DailyQuotaUsage{}, &models.StrategyApproval{}, &models.StrategyShadowResult{}, &models.TopupExecute{}, &models.DripPlan{}, &models.DripInstallment{}, &models.IdempotencyKey{}, &models.GatewayOutbox{}, &models.ReconciliationReport{}, &models.ReconciliationItem{}, &models.QuotaConsumption{}, &models.QuotaUsageCursor{}, &models.QuotaExpiryNotice{}, &models.ModelCostMultiplier{}, &models.QuotaReservation{}, &models.QuotaReservationHold{}, &models.CreditLimitSetting{}, &models.QuotaCredit{}, &models.DepartmentPool{}, &models.DepartmentPoolLedger{}, &models.BalanceCapSetting{}); err != nil {
 		return nil, fmt.Errorf("failed to migrate main tables: %w", err)
 	}
 
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"quota-manager/pkg/decimal"
 	"time"
 
 	"quota-manager/internal/models"
@@ -25,7 +26,7 @@ func testConcurrentOperations(ctx *TestContext) TestResult {
 	}
 
 	// Add initial quota for user1 (no need to set mock quota since AddQuotaForStrategy will handle AiGateway)
-	if err := ctx.QuotaService.AddQuotaForStrategy(user1.ID, 500, 0, "concurrent-test-strategy"); err != nil {
+	if err := ctx.QuotaService.AddQuotaForStrategy(user1.ID, decimal.New(500), 0, "concurrent-test-strategy"); err != nil {
 		return TestResult{Passed: false, Message: fmt.Sprintf("Add initial quota failed: %v", err)}
 	}
 
@@ -37,7 +38,7 @@ func testConcurrentOperations(ctx *TestContext) TestResult {
 	go func() {
 		<-startChan
 		for i := 0; i < 5; i++ {
-			ctx.Gateway.DeltaUsedQuota(user1.ID, 10)
+			ctx.Gateway.DeltaUsedQuota(user1.ID, decimal.New(10))
 		}
 		resultChan <- nil
 	}()
@@ -53,7 +54,7 @@ func testConcurrentOperations(ctx *TestContext) TestResult {
 			transferOutReq := &services.TransferOutRequest{
 				ReceiverID: user2.ID,
 				QuotaList: []services.TransferQuotaItem{
-					{Amount: 30, ExpiryDate: expiry},
+					{Amount: decimal.New(30), ExpiryDate: expiry},
 				},
 			}
 			_, err := ctx.QuotaService.TransferOut(&models.AuthUser{
@@ -67,7 +68,7 @@ func testConcurrentOperations(ctx *TestContext) TestResult {
 	go func() {
 		<-startChan
 		for i := 0; i < 2; i++ {
-			err := ctx.QuotaService.AddQuotaForStrategy(user1.ID, 25, 0, fmt.Sprintf("concurrent-strategy-%d", i))
+			err := ctx.QuotaService.AddQuotaForStrategy(user1.ID, decimal.New(25), 0, fmt.Sprintf("concurrent-strategy-%d", i))
 			resultChan <- err
 		}
 	}()
@@ -115,15 +116,15 @@ func testConcurrentOperations(ctx *TestContext) TestResult {
 	expectedUsed := 50.0   // 5 * 10
 	expectedRemaining := expectedTotal - expectedUsed
 
-	if finalQuotaInfo.TotalQuota != expectedTotal {
-		return TestResult{Passed: false, Message: fmt.Sprintf("Expected total quota %f, got %f", expectedTotal, finalQuotaInfo.TotalQuota)}
+	if finalQuotaInfo.TotalQuota.Float64() != expectedTotal {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected total quota %f, got %f", expectedTotal, finalQuotaInfo.TotalQuota.Float64())}
 	}
 
-	if finalQuotaInfo.UsedQuota != expectedUsed {
-		return TestResult{Passed: false, Message: fmt.Sprintf("Expected used quota %f, got %f", expectedUsed, finalQuotaInfo.UsedQuota)}
+	if finalQuotaInfo.UsedQuota.Float64() != expectedUsed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected used quota %f, got %f", expectedUsed, finalQuotaInfo.UsedQuota.Float64())}
 	}
 
-	actualRemaining := finalQuotaInfo.TotalQuota - finalQuotaInfo.UsedQuota
+	actualRemaining := finalQuotaInfo.TotalQuota.Sub(finalQuotaInfo.UsedQuota).Float64()
 	if actualRemaining != expectedRemaining {
 		return TestResult{Passed: false, Message: fmt.Sprintf("Expected remaining quota %f, got %f", expectedRemaining, actualRemaining)}
 	}
@@ -5,6 +5,7 @@ import (
 	"quota-manager/internal/condition"
 	"quota-manager/internal/config"
 	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
 	"time"
 )
 
@@ -72,7 +73,7 @@ func testBelongToWithEmployeeSync(ctx *TestContext) TestResult {
 		Name:      strategyNameChinese,
 		Title:     "Belong To Chinese Department Test",
 		Type:      "single",
-		Amount:    100,
+		Amount:    decimal.New(100),
 		Model:     "test-model",
 		Condition: `belong-to("技术部")`, // Chinese department name
 		Status:    true,
@@ -108,7 +109,7 @@ func testBelongToWithEmployeeSync(ctx *TestContext) TestResult {
 		Name:      strategyNameEnglish,
 		Title:     "Belong To English Department Test",
 		Type:      "single",
-		Amount:    100,
+		Amount:    decimal.New(100),
 		Model:     "test-model",
 		Condition: `belong-to("Tech_Group")`, // English department name
 		Status:    true,
@@ -158,7 +159,7 @@ func testBelongToFallbackToOriginal(ctx *TestContext) TestResult {
 		Name:      strategyName,
 		Title:     "Belong To Fallback Test",
 		Type:      "single",
-		Amount:    100,
+		Amount:    decimal.New(100),
 		Model:     "test-model",
 		Condition: `belong-to("TechCorp")`,
 		Status:    true,
@@ -207,7 +208,7 @@ func testBelongToWithNoEmployeeNumber(ctx *TestContext) TestResult {
 		Name:      strategyName,
 		Title:     "Belong To No Employee Number Test",
 		Type:      "single",
-		Amount:    100,
+		Amount:    decimal.New(100),
 		Model:     "test-model",
 		Condition: `belong-to("FallbackCorp")`,
 		Status:    true,
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"quota-manager/internal/models"
+	"quota-manager/internal/services"
+	"quota-manager/pkg/decimal"
+)
+
+// testDecimalRoundTrips tests that amounts keep their exact cents through parsing, JSON,
+// the database and the gateway
+func testDecimalRoundTrips(ctx *TestContext) TestResult {
+	// Parsing rounds to cents half away from zero, and sums are exact
+	cases := map[string]string{"12.345": "12.35", "-0.005": "-0.01", "0.004": "0.00", "7": "7.00", " 3.1 ": "3.10"}
+	for input, expected := range cases {
+		d, err := decimal.NewFromString(input)
+		if err != nil || d.StringFixed() != expected {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Parse %q expected %s, got %s (%v)", input, expected, d.StringFixed(), err)}
+		}
+	}
+	for _, invalid := range []string{"", "abc", "1.2.3", "--1"} {
+		if _, err := decimal.NewFromString(invalid); err == nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Parse %q expected an error", invalid)}
+		}
+	}
+	if sum := decimal.RequireFromString("0.1").Add(decimal.RequireFromString("0.2")); !sum.Equal(decimal.RequireFromString("0.3")) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("0.1 + 0.2 expected 0.3, got %s", sum)}
+	}
+
+	// JSON carries amounts as numbers and accepts quoted numbers and null
+	type payload struct {
+		Amount decimal.Decimal `json:"amount"`
+	}
+	encoded, err := json.Marshal(payload{Amount: decimal.RequireFromString("12.50")})
+	if err != nil || string(encoded) != `{"amount":12.5}` {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Marshal expected {\"amount\":12.5}, got %s (%v)", encoded, err)}
+	}
+	decodeCases := map[string]string{`{"amount":12.5}`: "12.50", `{"amount":"33.33"}`: "33.33", `{"amount":1e2}`: "100.00", `{"amount":null}`: "0.00"}
+	for input, expected := range decodeCases {
+		var decoded payload
+		if err := json.Unmarshal([]byte(input), &decoded); err != nil || decoded.Amount.StringFixed() != expected {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Unmarshal %s expected %s, got %s (%v)", input, expected, decoded.Amount.StringFixed(), err)}
+		}
+	}
+
+	// Declarative configuration documents decode amounts exactly too
+	state, err := services.ParseDesiredState([]byte("strategies:\n  - name: decimal-yaml-test\n    title: Decimal YAML Test\n" +
+		"    type: single\n    amount: 12.34\n    rollover_max_amount: \"7.5\"\n    condition: \"false()\"\n"))
+	if err != nil || len(state.Strategies) != 1 || !state.Strategies[0].Amount.Equal(decimal.RequireFromString("12.34")) ||
+		!state.Strategies[0].RolloverMaxAmount.Equal(decimal.RequireFromString("7.50")) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Desired state expected amounts 12.34 and 7.50, got %+v (%v)", state, err)}
+	}
+
+	// The database keeps cents exactly, in rows and in sums
+	userID := "decimal-round-trip-test-user"
+	expiry := time.Now().Truncate(time.Second).Add(30 * 24 * time.Hour)
+	for i := 0; i < 3; i++ {
+		if err := ctx.DB.Create(&models.Quota{
+			UserID:     userID,
+			Amount:     decimal.RequireFromString("33.33"),
+			ExpiryDate: expiry.Add(time.Duration(i) * time.Hour),
+			Status:     models.StatusValid,
+		}).Error; err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Create quota failed: %v", err)}
+		}
+	}
+	var stored models.Quota
+	if err := ctx.DB.Where("user_id = ?", userID).First(&stored).Error; err != nil || stored.Amount.StringFixed() != "33.33" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 33.33 read back, got %s (%v)", stored.Amount.StringFixed(), err)}
+	}
+	var total decimal.Decimal
+	if err := ctx.DB.Model(&models.Quota{}).Where("user_id = ?", userID).
+		Select("COALESCE(SUM(amount), 0)").Scan(&total).Error; err != nil || total.StringFixed() != "99.99" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected a sum of 99.99, got %s (%v)", total.StringFixed(), err)}
+	}
+
+	// Grants reach the gateway and the audit log with their cents
+	grantUser := "decimal-grant-test-user"
+	if err := ctx.QuotaService.AddQuotaForStrategy(grantUser, decimal.RequireFromString("12.34"), 0, ""); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Grant failed: %v", err)}
+	}
+	if gatewayTotal := ctx.MockQuotaStore.GetQuota(grantUser); decimal.NewFromFloat(gatewayTotal).StringFixed() != "12.34" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 12.34, got %f", gatewayTotal)}
+	}
+	var audit models.QuotaAudit
+	if err := ctx.DB.Where("user_id = ?", grantUser).First(&audit).Error; err != nil || audit.Amount.StringFixed() != "12.34" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected an audit of 12.34, got %s (%v)", audit.Amount.StringFixed(), err)}
+	}
+	details, err := audit.UnmarshalDetails()
+	if err != nil || details.Summary.TotalAmount.StringFixed() != "12.34" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected audit details of 12.34, got %+v (%v)", details, err)}
+	}
+
+	return TestResult{Passed: true, Message: "Decimal Round Trips Test Succeeded"}
+}
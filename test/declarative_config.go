@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/internal/config"
+	"quota-manager/internal/models"
+	"quota-manager/internal/services"
+	"quota-manager/pkg/decimal"
+)
+
+// newTestDeclarativeConfigService creates a declarative config service backed by the test database
+func newTestDeclarativeConfigService(ctx *TestContext) *services.DeclarativeConfigService {
+	aiGatewayConfig := &config.AiGatewayConfig{
+		Host:       "localhost",
+		Port:       8080,
+		AdminPath:  "/model-permission",
+		AuthHeader: "x-admin-key",
+		AuthValue:  "test-key",
+	}
+	employeeSyncConfig := &config.EmployeeSyncConfig{Enabled: false}
+
+	permissionService := services.NewPermissionService(ctx.DB, aiGatewayConfig, employeeSyncConfig, ctx.Gateway)
+	starCheckPermissionService := services.NewStarCheckPermissionService(ctx.DB, aiGatewayConfig, employeeSyncConfig, ctx.Gateway)
+	quotaCheckPermissionService := services.NewQuotaCheckPermissionService(ctx.DB, aiGatewayConfig, employeeSyncConfig, ctx.Gateway)
+	return services.NewDeclarativeConfigService(ctx.DB, ctx.StrategyService, permissionService, starCheckPermissionService, quotaCheckPermissionService)
+}
+
+// testDeclarativeConfigPlanApply tests export, plan and apply of the declarative configuration
+func testDeclarativeConfigPlanApply(ctx *TestContext) TestResult {
+	svc := newTestDeclarativeConfigService(ctx)
+
+	// An exported state planned against the same database must be a no-op
+	state, err := svc.Export()
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Export failed: %v", err)}
+	}
+	plan, err := svc.Plan(state)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Plan of exported state failed: %v", err)}
+	}
+	if plan.HasChanges() {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Plan of exported state expected no changes, got %+v", plan.Summary)}
+	}
+
+	// Adding a strategy plans and applies exactly one create
+	state.Strategies = append(state.Strategies, services.DesiredStrategy{
+		Name:      "declarative-plan-test",
+		Title:     "Declarative Plan Test",
+		Type:      "single",
+		Amount:    decimal.New(25),
+		Condition: "false()",
+	})
+	plan, err = svc.Apply(state)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Apply create failed: %v", err)}
+	}
+	if plan.Summary != (services.PlanSummary{Create: 1}) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Apply create expected 1 create, got %+v", plan.Summary)}
+	}
+
+	var strategy models.QuotaStrategy
+	if err := ctx.DB.Where("name = ?", "declarative-plan-test").First(&strategy).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Applied strategy not found: %v", err)}
+	}
+	if !strategy.IsEnabled() || strategy.Amount.Float64() != 25 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Applied strategy expected enabled with amount 25, got status=%v amount=%s", strategy.Status, strategy.Amount)}
+	}
+
+	// Changing the amount plans a single update of that field
+	state.Strategies[len(state.Strategies)-1].Amount = decimal.New(30)
+	plan, err = svc.Plan(state)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Plan update failed: %v", err)}
+	}
+	if plan.Summary != (services.PlanSummary{Update: 1}) || len(plan.Changes[0].Fields) != 1 || plan.Changes[0].Fields[0] != "amount" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Plan update expected 1 update of amount, got %+v", plan.Changes)}
+	}
+	if _, err := svc.Apply(state); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Apply update failed: %v", err)}
+	}
+	if err := ctx.DB.First(&strategy, strategy.ID).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Reload strategy failed: %v", err)}
+	}
+	if strategy.Amount.Float64() != 30 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Updated strategy expected amount 30, got %s", strategy.Amount)}
+	}
+
+	// Dropping the strategy from the state deletes it
+	state.Strategies = state.Strategies[:len(state.Strategies)-1]
+	plan, err = svc.Apply(state)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Apply delete failed: %v", err)}
+	}
+	if plan.Summary != (services.PlanSummary{Delete: 1}) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Apply delete expected 1 delete, got %+v", plan.Summary)}
+	}
+	var count int64
+	ctx.DB.Model(&models.QuotaStrategy{}).Where("name = ?", "declarative-plan-test").Count(&count)
+	if count != 0 {
+		return TestResult{Passed: false, Message: "Deleted strategy still exists"}
+	}
+
+	return TestResult{Passed: true, Message: "Declarative Config Plan/Apply Test Succeeded"}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/pkg/decimal"
+	"time"
+
+	"quota-manager/internal/models"
+	"quota-manager/internal/services"
+)
+
+// testDepartmentPoolDraws tests that members draw from the pool of a parent department once
+// their personal balance is exhausted, bounded by the pool balance and the member cap
+func testDepartmentPoolDraws(ctx *TestContext) TestResult {
+	user := createTestUser("user_pool_member", "Pool Member User", 0)
+	if err := ctx.DB.AuthDB.Create(user).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create user failed: %v", err)}
+	}
+	if err := ctx.DB.DB.Create(&models.EmployeeDepartment{
+		EmployeeNumber:     user.EmployeeNumber,
+		Username:           user.Name,
+		DeptFullLevelNames: "PoolTestCompany,PoolTestRnD",
+	}).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create employee department failed: %v", err)}
+	}
+
+	// The member's own department has no pool, the parent's pool is inherited
+	pool, err := ctx.QuotaService.SaveDepartmentPool("PoolTestCompany", decimal.New(30), decimal.New(20), "admin")
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Save department pool failed: %v", err)}
+	}
+	if pool, err = ctx.QuotaService.FundDepartmentPool(pool.ID, decimal.New(50), "admin"); err != nil || !pool.Balance.Equal(decimal.New(50)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Fund department pool failed: %+v (%v)", pool, err)}
+	}
+
+	if _, err := createTestQuotaWithExpiry(ctx, user.ID, 10, time.Now().Add(30*24*time.Hour)); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create quota failed: %v", err)}
+	}
+	ctx.MockQuotaStore.SetQuota(user.ID, 10)
+
+	// Members with personal quota left cannot draw
+	if _, err := ctx.QuotaService.DrawFromDepartmentPool(user.ID, decimal.Zero); serviceErrorCode(err) != services.ErrorConflict {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Draw with personal quota left expected conflict, got %v", err)}
+	}
+
+	// Once it is used up, the pool's draw amount is moved into a personal bucket
+	ctx.MockQuotaStore.SetUsed(user.ID, 10)
+	result, err := ctx.QuotaService.DrawFromDepartmentPool(user.ID, decimal.Zero)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Draw failed: %v", err)}
+	}
+	if result.Department != "PoolTestCompany" || !result.Amount.Equal(decimal.New(20)) || !result.BalanceLeft.Equal(decimal.New(30)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected draw result: %+v", result)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(user.ID); total != 30 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 30 after the draw, got %f", total)}
+	}
+
+	// The member cap of 30 leaves 10 of the next draw
+	ctx.MockQuotaStore.SetUsed(user.ID, 30)
+	if result, err = ctx.QuotaService.DrawFromDepartmentPool(user.ID, decimal.Zero); err != nil || !result.Amount.Equal(decimal.New(10)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected a draw cut to 10 by the member cap, got %+v (%v)", result, err)}
+	}
+	ctx.MockQuotaStore.SetUsed(user.ID, 40)
+	if _, err := ctx.QuotaService.DrawFromDepartmentPool(user.ID, decimal.Zero); serviceErrorCode(err) != services.ErrorConflict {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Draw past the member cap expected conflict, got %v", err)}
+	}
+
+	memberPools, err := ctx.QuotaService.GetMemberPools(user.ID)
+	if err != nil || len(memberPools) != 1 || !memberPools[0].MonthDrawn.Equal(decimal.New(30)) ||
+		memberPools[0].CapLeft == nil || !memberPools[0].CapLeft.IsZero() {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected member pools: %+v (%v)", memberPools, err)}
+	}
+	usage, err := ctx.QuotaService.GetDepartmentPoolUsage(pool.ID, "")
+	if err != nil || !usage.Funded.Equal(decimal.New(50)) || !usage.Drawn.Equal(decimal.New(30)) || !usage.Pool.Balance.Equal(decimal.New(20)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected pool usage: %+v (%v)", usage, err)}
+	}
+
+	var draws int64
+	ctx.DB.Model(&models.QuotaAudit{}).Where("user_id = ? AND operation = ?", user.ID, models.OperationPoolDraw).Count(&draws)
+	if draws != 2 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 2 POOL_DRAW audits, got %d", draws)}
+	}
+
+	return TestResult{Passed: true, Message: "Department Pool Draws Test Succeeded"}
+}
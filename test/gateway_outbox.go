@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"quota-manager/pkg/decimal"
+	"sync/atomic"
+	"time"
+
+	"quota-manager/internal/config"
+	"quota-manager/internal/models"
+	"quota-manager/internal/services"
+	"quota-manager/pkg/aigateway"
+)
+
+// newFailingServices creates quota and strategy services whose AiGateway calls all fail
+func newFailingServices(ctx *TestContext) (*services.QuotaService, *services.StrategyService) {
+	failGateway := aigateway.NewClient(ctx.FailServer.URL, "/v1/chat/completions/quota", "x-admin-key", "12345678")
+	configManager := config.NewManager(&config.Config{})
+	failQuotaService := services.NewQuotaService(ctx.DB, configManager, failGateway, ctx.VoucherService)
+	failStrategyService := services.NewStrategyService(ctx.DB, failGateway, failQuotaService, &config.EmployeeSyncConfig{Enabled: false})
+	return failQuotaService, failStrategyService
+}
+
+// userDeltaCalls returns the gateway delta calls made for a user
+func userDeltaCalls(ctx *TestContext, userID string) []MockQuotaStoreDeltaCall {
+	var calls []MockQuotaStoreDeltaCall
+	for _, call := range ctx.MockQuotaStore.GetDeltaCalls() {
+		if call.EmployeeNumber == userID {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// testGatewayOutboxDelivery tests that grants commit with a queued gateway mutation that is
+// delivered once, after the gateway recovers
+func testGatewayOutboxDelivery(ctx *TestContext) TestResult {
+	user := createTestUser("user_outbox_delivery", "Outbox Delivery User", 0)
+	if err := ctx.DB.AuthDB.Create(user).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create user failed: %v", err)}
+	}
+
+	strategy := &models.QuotaStrategy{
+		Name:      "outbox-delivery-test",
+		Title:     "Outbox Delivery Test",
+		Type:      "single",
+		Amount:    decimal.New(40),
+		Condition: "true()",
+		Status:    true,
+	}
+	if err := ctx.StrategyService.CreateStrategy(strategy); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create strategy failed: %v", err)}
+	}
+
+	// The grant commits even though the gateway is down, its mutation stays queued
+	_, failStrategyService := newFailingServices(ctx)
+	failStrategyService.ExecStrategy(strategy, []models.UserInfo{*user})
+
+	var entry models.GatewayOutbox
+	if err := ctx.DB.Where("user_id = ?", user.ID).First(&entry).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Outbox entry not found: %v", err)}
+	}
+	if entry.Status != models.OutboxStatusPending || entry.Attempts != 1 || entry.LastError == "" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected a pending entry after 1 failed attempt, got %s/%d/%q", entry.Status, entry.Attempts, entry.LastError)}
+	}
+	if entry.Mutation != models.OutboxMutationDeltaQuota || !entry.Value.Equal(decimal.New(40)) || entry.Source != models.OperationRecharge {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected outbox entry: %+v", entry)}
+	}
+	var audit models.QuotaAudit
+	if err := ctx.DB.Where("user_id = ? AND operation = ?", user.ID, models.OperationRecharge).First(&audit).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Recharge audit not found: %v", err)}
+	}
+	if expectedKey := fmt.Sprintf("audit:%d:%s", audit.ID, models.OutboxMutationDeltaQuota); entry.DedupKey != expectedKey {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected dedup key %s, got %s", expectedKey, entry.DedupKey)}
+	}
+
+	// The quota sync leaves pools with undelivered mutations alone, a refresh would be doubled by the delivery
+	if err := ctx.QuotaService.SyncQuotasWithAiGateway(); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Quota sync failed: %v", err)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(user.ID); total != 0 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Quota sync refreshed a pool with an undelivered mutation to %f", total)}
+	}
+
+	// Once due, the dispatcher delivers the entry with its dedup key
+	if err := ctx.DB.Model(&models.GatewayOutbox{}).Where("id = ?", entry.ID).
+		Update("next_attempt_time", time.Now().Add(-time.Second)).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Make entry due failed: %v", err)}
+	}
+	ctx.QuotaService.DispatchGatewayOutbox()
+	// A second run must not deliver the entry again
+	ctx.QuotaService.DispatchGatewayOutbox()
+
+	if err := ctx.DB.First(&entry, entry.ID).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Reload outbox entry failed: %v", err)}
+	}
+	if entry.Status != models.OutboxStatusDelivered || entry.Attempts != 2 || entry.DeliveredTime == nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the entry delivered on attempt 2, got %s/%d", entry.Status, entry.Attempts)}
+	}
+	calls := userDeltaCalls(ctx, user.ID)
+	if len(calls) != 1 || calls[0].Delta != 40 || calls[0].DedupKey != entry.DedupKey {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected one delta call of 40 keyed %s, got %+v", entry.DedupKey, calls)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(user.ID); total != 40 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 40, got %f", total)}
+	}
+
+	return TestResult{Passed: true, Message: "Gateway Outbox Delivery Test Succeeded"}
+}
+
+// testGatewayOutboxStuckEntry tests that entries left delivering past their lease are marked
+// failed instead of being redelivered, and are only sent again on an explicit retry
+func testGatewayOutboxStuckEntry(ctx *TestContext) TestResult {
+	userID := "outbox-stuck-test-user"
+	entry := &models.GatewayOutbox{
+		DedupKey:        "outbox-stuck-test:delta_quota",
+		UserID:          userID,
+		Mutation:        models.OutboxMutationDeltaQuota,
+		Value:           decimal.New(15),
+		Source:          models.OperationRecharge,
+		Status:          models.OutboxStatusDelivering,
+		Attempts:        0,
+		NextAttemptTime: time.Now().Add(-time.Minute), // lease of a crashed dispatcher
+	}
+	if err := ctx.DB.Create(entry).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create outbox entry failed: %v", err)}
+	}
+
+	summary, err := ctx.QuotaService.GetGatewayOutboxSummary()
+	if err != nil || summary.Stuck < 1 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the entry counted as stuck, got %+v (%v)", summary, err)}
+	}
+
+	ctx.QuotaService.DispatchGatewayOutbox()
+
+	if err := ctx.DB.First(entry, entry.ID).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Reload outbox entry failed: %v", err)}
+	}
+	if entry.Status != models.OutboxStatusFailed || entry.LastError == "" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the stuck entry marked failed, got %s/%q", entry.Status, entry.LastError)}
+	}
+	if calls := userDeltaCalls(ctx, userID); len(calls) != 0 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Stuck entry was redelivered by the dispatcher: %+v", calls)}
+	}
+
+	// Delivered entries cannot be retried, failed ones are sent once more
+	retried, err := ctx.QuotaService.RetryGatewayOutboxEntry(entry.ID)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Retry failed: %v", err)}
+	}
+	if retried.Status != models.OutboxStatusDelivered {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the retried entry delivered, got %s", retried.Status)}
+	}
+	if _, err := ctx.QuotaService.RetryGatewayOutboxEntry(entry.ID); serviceErrorCode(err) != services.ErrorConflict {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Retry of a delivered entry expected conflict, got %v", err)}
+	}
+	if calls := userDeltaCalls(ctx, userID); len(calls) != 1 || calls[0].Delta != 15 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected one delta call of 15, got %+v", calls)}
+	}
+
+	return TestResult{Passed: true, Message: "Gateway Outbox Stuck Entry Test Succeeded"}
+}
+
+// testGatewayOutboxSingleAttempt tests that a delivery is sent to the gateway once even when
+// the gateway errors after applying it, as the gateway does not deduplicate Idempotency-Key
+// replays; the entry is left to the dispatcher's backoff instead of an in-client retry
+func testGatewayOutboxSingleAttempt(ctx *TestContext) TestResult {
+	userID := "outbox-single-attempt-test-user"
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("user_id") == userID {
+			atomic.AddInt32(&requests, 1)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(`{"code":"ai-gateway.error","message":"upstream timeout","success":false}`))
+	}))
+	defer server.Close()
+
+	gateway := aigateway.NewClient(server.URL, "/v1/chat/completions/quota", "x-admin-key", "12345678")
+	quotaService := services.NewQuotaService(ctx.DB, config.NewManager(&config.Config{}), gateway, ctx.VoucherService)
+
+	entry := &models.GatewayOutbox{
+		DedupKey:        "outbox-single-attempt-test:delta_quota",
+		UserID:          userID,
+		Mutation:        models.OutboxMutationDeltaQuota,
+		Value:           decimal.New(25),
+		Source:          models.OperationRecharge,
+		Status:          models.OutboxStatusFailed,
+		NextAttemptTime: time.Now(),
+	}
+	if err := ctx.DB.Create(entry).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create outbox entry failed: %v", err)}
+	}
+
+	retried, err := quotaService.RetryGatewayOutboxEntry(entry.ID)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Retry failed: %v", err)}
+	}
+	if count := atomic.LoadInt32(&requests); count != 1 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected one gateway request per delivery, got %d", count)}
+	}
+	if retried.Status != models.OutboxStatusPending || retried.Attempts != 1 || retried.LastError == "" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the entry pending after 1 attempt, got %s/%d/%q", retried.Status, retried.Attempts, retried.LastError)}
+	}
+
+	return TestResult{Passed: true, Message: "Gateway Outbox Single Attempt Test Succeeded"}
+}
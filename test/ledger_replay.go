@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"quota-manager/internal/models"
+	"quota-manager/internal/services"
+	"quota-manager/pkg/decimal"
+)
+
+// testLedgerReplayApply tests that a ledger replay rebuilds quota rows from the audit log,
+// reporting only in dry-run mode, and that applying it audits each change, voids rows the
+// log does not account for and queues the net change for AiGateway
+func testLedgerReplayApply(ctx *TestContext) TestResult {
+	userID := "ledger-replay-test-user"
+	if err := grantTestQuota(ctx, userID, 30); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Grant failed: %v", err)}
+	}
+	var granted models.Quota
+	if err := ctx.DB.Where("user_id = ?", userID).First(&granted).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Granted bucket not found: %v", err)}
+	}
+
+	// A row edited behind the ledger's back and a row written without an audit record
+	if err := ctx.DB.Model(&models.Quota{}).Where("id = ?", granted.ID).Update("amount", decimal.New(25)).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Edit quota failed: %v", err)}
+	}
+	stray, err := createTestQuotaWithExpiry(ctx, userID, 10, time.Now().Add(45*24*time.Hour))
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create quota failed: %v", err)}
+	}
+	ctx.MockQuotaStore.SetQuota(userID, 40)
+
+	if _, err := ctx.QuotaService.ReplayQuotaLedger(userID, "fix"); serviceErrorCode(err) != services.ErrorValidationFailed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unknown mode expected validation_failed, got %v", err)}
+	}
+
+	// Dry run reports the update and the void without writing them
+	report, err := ctx.QuotaService.ReplayQuotaLedger(userID, services.ReplayModeDryRun)
+	if err != nil || report.ChangedUsers != 1 || len(report.Users) != 1 || len(report.Users[0].Changes) != 2 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 2 changes in the dry run, got %+v (%v)", report, err)}
+	}
+	changes := report.Users[0].Changes
+	if changes[0].Action != services.ReplayActionUpdate || changes[0].QuotaID != granted.ID || !changes[0].ReplayedAmount.Equal(decimal.New(30)) ||
+		changes[1].Action != services.ReplayActionDelete || changes[1].QuotaID != stray.ID {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected dry run changes: %+v", changes)}
+	}
+	var replayAudits int64
+	ctx.DB.Model(&models.QuotaAudit{}).Where("user_id = ? AND operation = ?", userID, models.OperationLedgerReplay).Count(&replayAudits)
+	if replayAudits != 0 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Dry run wrote %d LEDGER_REPLAY audits", replayAudits)}
+	}
+
+	// Apply rewrites the rows, voiding the unaccounted one instead of deleting it
+	if report, err = ctx.QuotaService.ReplayQuotaLedger(userID, services.ReplayModeApply); err != nil || report.ChangedUsers != 1 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Apply failed: %+v (%v)", report, err)}
+	}
+	if err := ctx.DB.First(&granted, granted.ID).Error; err != nil || !granted.Amount.Equal(decimal.New(30)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the edited row back at 30, got %s (%v)", granted.Amount, err)}
+	}
+	if err := ctx.DB.First(stray, stray.ID).Error; err != nil || stray.Status != models.StatusVoided {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the stray row voided, got %s (%v)", stray.Status, err)}
+	}
+	ctx.DB.Model(&models.QuotaAudit{}).Where("user_id = ? AND operation = ?", userID, models.OperationLedgerReplay).Count(&replayAudits)
+	if replayAudits != 2 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 2 LEDGER_REPLAY audits, got %d", replayAudits)}
+	}
+
+	// The net change of +5 and -10 reaches the gateway through the outbox
+	var entry models.GatewayOutbox
+	if err := ctx.DB.Where("user_id = ? AND source = ?", userID, models.OperationLedgerReplay).First(&entry).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Ledger replay outbox entry not found: %v", err)}
+	}
+	if !entry.Value.Equal(decimal.New(-5)) || entry.Status != models.OutboxStatusDelivered {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected a delivered delta of -5, got %s/%s", entry.Value, entry.Status)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(userID); total != 35 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 35 after the replay, got %f", total)}
+	}
+
+	// The replayed rows match the log, including its LEDGER_REPLAY records, and the chain holds
+	if report, err = ctx.QuotaService.ReplayQuotaLedger(userID, services.ReplayModeDryRun); err != nil || report.ChangedUsers != 0 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected no changes after apply, got %+v (%v)", report, err)}
+	}
+	if verification, err := ctx.QuotaService.VerifyAuditChain(userID); err != nil || !verification.Valid {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Audit chain broken by the replay: %+v (%v)", verification, err)}
+	}
+	balance, err := ctx.QuotaService.GetBalanceAt(userID, time.Now().Add(time.Second))
+	if err != nil || !balance.Balance.Equal(decimal.New(30)) || len(balance.Buckets) != 1 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected a replayed balance of 30, got %+v (%v)", balance, err)}
+	}
+
+	return TestResult{Passed: true, Message: "Ledger Replay Apply Test Succeeded"}
+}
@@ -258,6 +258,39 @@ func runAllTests(ctx *TestContext) []TestResult {
 		{"Non-existent User and Department Quota Check Test", testNonExistentUserAndDepartmentQuotaCheck},
 		{"Quota Check Employee Data Integrity Test", testQuotaCheckEmployeeDataIntegrity},
 		{"Quota Check Employee Sync Test", testQuotaCheckEmployeeSync},
+
+		// Ledger and strategy feature tests
+		{"Declarative Config Plan/Apply Test", testDeclarativeConfigPlanApply},
+		{"Strategy Approval Gating Test", testStrategyApprovalGating},
+		{"Shadow Strategy Test", testShadowStrategy},
+		{"Cron Schedule Preview Test", testCronSchedulePreview},
+		{"Topup Strategy Test", testTopupStrategy},
+		{"Drip Strategy Test", testDripStrategy},
+		{"Amount Formula Strategy Test", testAmountFormulaStrategy},
+		{"Exclusion Group Priority Test", testExclusionGroupPriority},
+		{"Exclusion Group Periodic Period Test", testExclusionGroupPeriodicPeriod},
+		{"Concurrent Transfer Out Locking Test", testConcurrentTransferOutLocking},
+		{"Transfer Idempotency Keys Test", testTransferIdempotencyKeys},
+		{"Gateway Outbox Delivery Test", testGatewayOutboxDelivery},
+		{"Gateway Outbox Stuck Entry Test", testGatewayOutboxStuckEntry},
+		{"Gateway Outbox Single Attempt Test", testGatewayOutboxSingleAttempt},
+		{"Reconciliation Report Test", testReconciliationReport},
+		{"FIFO Quota Consumption", testFIFOQuotaConsumption},
+		{"Expiry Warnings", testExpiryWarnings},
+		{"Expiry Sweep", testExpirySweep},
+		{"Rollover And Grace Policies", testRolloverAndGracePolicies},
+		{"Model Quota Pools", testModelQuotaPools},
+		{"Model Cost Multipliers", testModelCostMultipliers},
+		{"Quota Reservation Hold Commit", testQuotaReservationHoldCommit},
+		{"Quota Reservation Release", testQuotaReservationRelease},
+		{"Credit Limit", testCreditLimit},
+		{"Department Pool Draws", testDepartmentPoolDraws},
+		{"Balance Cap Policies", testBalanceCapPolicies},
+		{"Decimal Round Trips", testDecimalRoundTrips},
+		{"Audit Search Export", testAuditSearchExport},
+		{"Audit Chain Verification", testAuditChainVerification},
+		{"Ledger Replay Apply", testLedgerReplayApply},
+		{"Usage Snapshot Analytics", testUsageSnapshotAnalytics},
 	}
 
 	for _, tc := range testCases {
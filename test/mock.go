@@ -43,13 +43,17 @@ type QuotaCheckCall struct {
 // MockQuotaStoreDeltaCall represents a delta call for testing
 type MockQuotaStoreDeltaCall struct {
 	EmployeeNumber string
+	Model          string
 	Delta          float64
+	DedupKey       string
 }
 
 // MockQuotaStoreUsedDeltaCall represents a used delta call for testing
 type MockQuotaStoreUsedDeltaCall struct {
 	EmployeeNumber string
+	Model          string
 	Delta          float64
+	DedupKey       string
 }
 
 // MockQuotaStore mock quota storage
@@ -67,9 +71,20 @@ type MockQuotaStore struct {
 	CallCount            int                           // Track call count for SyncQuota
 	deltaCalls           []MockQuotaStoreDeltaCall     // Track delta calls
 	usedDeltaCalls       []MockQuotaStoreUsedDeltaCall // Track used delta calls
+	modelCosts           map[string]float64            // Model cost catalog pushed by quota-manager
+	modelCostRevision    int                           // Revision of the pushed model cost catalog
 	mock.Mock                                          // For testify/mock functionality
 }
 
+// mockQuotaKey returns the store key of a user's quota pool; the default pool
+// (empty model) keeps the plain user ID so existing tests are unaffected
+func mockQuotaKey(userID, model string) string {
+	if model == "" {
+		return userID
+	}
+	return userID + "/" + model
+}
+
 func (m *MockQuotaStore) GetQuota(consumer string) float64 {
 	if quota, exists := m.data[consumer]; exists {
 		return quota
@@ -267,6 +282,17 @@ func (m *MockQuotaStore) ClearUsedDeltaCalls() {
 	m.usedDeltaCalls = []MockQuotaStoreUsedDeltaCall{}
 }
 
+// SetModelCosts stores a pushed model cost catalog
+func (m *MockQuotaStore) SetModelCosts(multipliers map[string]float64, revision int) {
+	m.modelCosts = multipliers
+	m.modelCostRevision = revision
+}
+
+// GetModelCosts returns the last pushed model cost catalog and its revision
+func (m *MockQuotaStore) GetModelCosts() (map[string]float64, int) {
+	return m.modelCosts, m.modelCostRevision
+}
+
 // ClearAllCalls 清除所有调用记录
 func (m *MockQuotaStore) ClearAllCalls() {
 	m.CallCount = 0
@@ -286,6 +312,8 @@ func (m *MockQuotaStore) ClearData() {
 	m.permissionData = make(map[string][]string)
 	m.starCheckData = make(map[string]bool)
 	m.quotaCheckData = make(map[string]bool)
+	m.modelCosts = make(map[string]float64)
+	m.modelCostRevision = 0
 }
 
 var mockStore = &MockQuotaStore{
@@ -301,6 +329,7 @@ var mockStore = &MockQuotaStore{
 	quotaCheckCalls:      []QuotaCheckCall{},
 	deltaCalls:           []MockQuotaStoreDeltaCall{},
 	usedDeltaCalls:       []MockQuotaStoreUsedDeltaCall{},
+	modelCosts:           make(map[string]float64),
 }
 
 // createMockServer create mock server
@@ -339,6 +368,13 @@ func createMockServer(shouldFail bool) *httptest.Server {
 					return
 				}
 
+				var total float64
+				if _, err := fmt.Sscanf(quota, "%f", &total); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "quota must be numeric"})
+					return
+				}
+				mockStore.SetQuota(mockQuotaKey(userID, c.PostForm("model")), total)
+
 				c.JSON(http.StatusOK, gin.H{"message": "success"})
 			})
 
@@ -362,7 +398,7 @@ func createMockServer(shouldFail bool) *httptest.Server {
 					return
 				}
 
-				quota := mockStore.GetQuota(userID)
+				quota := mockStore.GetQuota(mockQuotaKey(userID, c.Query("model")))
 
 				c.JSON(http.StatusOK, gin.H{
 					"code":    "ai-gateway.queryquota",
@@ -409,13 +445,19 @@ func createMockServer(shouldFail bool) *httptest.Server {
 					return
 				}
 
+				// Like the real gateway, the Idempotency-Key is recorded but not deduplicated
+				model := c.PostForm("model")
+				dedupKey := c.GetHeader("Idempotency-Key")
+
 				// Directly update the data instead of calling SyncQuota mock method
-				mockStore.DeltaQuota(userID, delta)
+				mockStore.DeltaQuota(mockQuotaKey(userID, model), delta)
 
 				// Track the delta call manually
 				call := MockQuotaStoreDeltaCall{
 					EmployeeNumber: userID,
+					Model:          model,
 					Delta:          delta,
+					DedupKey:       dedupKey,
 				}
 				mockStore.deltaCalls = append(mockStore.deltaCalls, call)
 
@@ -450,7 +492,7 @@ func createMockServer(shouldFail bool) *httptest.Server {
 					return
 				}
 
-				used := mockStore.GetUsed(userID)
+				used := mockStore.GetUsed(mockQuotaKey(userID, c.Query("model")))
 
 				c.JSON(http.StatusOK, gin.H{
 					"code":    "ai-gateway.queryquota",
@@ -497,13 +539,19 @@ func createMockServer(shouldFail bool) *httptest.Server {
 					return
 				}
 
+				// Like the real gateway, the Idempotency-Key is recorded but not deduplicated
+				model := c.PostForm("model")
+				dedupKey := c.GetHeader("Idempotency-Key")
+
 				// Directly update the data instead of calling SyncQuota mock method
-				mockStore.DeltaUsed(userID, delta)
+				mockStore.DeltaUsed(mockQuotaKey(userID, model), delta)
 
 				// Track the used delta call manually
 				call := MockQuotaStoreUsedDeltaCall{
 					EmployeeNumber: userID,
+					Model:          model,
 					Delta:          delta,
+					DedupKey:       dedupKey,
 				}
 				mockStore.usedDeltaCalls = append(mockStore.usedDeltaCalls, call)
 
@@ -518,6 +566,46 @@ func createMockServer(shouldFail bool) *httptest.Server {
 				})
 			})
 
+			quota.POST("/model-cost/refresh", func(c *gin.Context) {
+				if shouldFail {
+					c.JSON(http.StatusServiceUnavailable, gin.H{
+						"code":    "ai-gateway.error",
+						"message": "redis error: connection failed",
+						"success": false,
+					})
+					return
+				}
+
+				var multipliers map[string]float64
+				var revision int
+				if err := json.Unmarshal([]byte(c.PostForm("multipliers")), &multipliers); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"code":    "ai-gateway.invalid_params",
+						"message": "multipliers must be a JSON object",
+						"success": false,
+					})
+					return
+				}
+				if _, err := fmt.Sscanf(c.PostForm("revision"), "%d", &revision); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"code":    "ai-gateway.invalid_params",
+						"message": "revision must be numeric",
+						"success": false,
+					})
+					return
+				}
+
+				mockStore.SetModelCosts(multipliers, revision)
+				fmt.Printf("[MOCK SERVER] POST /model-cost/refresh called - Revision: %d, Models: %d\n",
+					revision, len(multipliers))
+
+				c.JSON(http.StatusOK, gin.H{
+					"code":    "ai-gateway.modelcost",
+					"message": "model cost refresh successful",
+					"success": true,
+				})
+			})
+
 			// GitHub star related APIs
 			quota.GET("/star", func(c *gin.Context) {
 				if shouldFail {
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+
+	"quota-manager/internal/config"
+	"quota-manager/internal/services"
+)
+
+// catalogMultiplier returns a model's multiplier in a catalog, 0 when it is not listed
+func catalogMultiplier(catalog *services.ModelCatalog, model string) float64 {
+	for _, entry := range catalog.Models {
+		if entry.Model == model {
+			return entry.Multiplier
+		}
+	}
+	return 0
+}
+
+// testModelCostMultipliers tests catalog revisions, multiplier precision and the push to AiGateway
+func testModelCostMultipliers(ctx *TestContext) TestResult {
+	quotaService, err := newConfiguredQuotaService(ctx, func(cfg *config.Config) {
+		cfg.AiGateway.ModelCostPush = true
+	})
+	if err != nil {
+		return TestResult{Passed: false, Message: err.Error()}
+	}
+
+	if _, err := quotaService.SetModelCostMultiplier("model-cost-test-a", 0, "admin", "invalid"); serviceErrorCode(err) != services.ErrorValidationFailed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Multiplier 0 expected validation_failed, got %v", err)}
+	}
+
+	// Multipliers are kept at 4 decimals, setting the same value again is a no-op
+	first, err := quotaService.SetModelCostMultiplier("model-cost-test-a", 1.23456, "admin", "initial price")
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Set multiplier failed: %v", err)}
+	}
+	if first.Multiplier != 1.2346 || !first.Pushed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected a pushed multiplier of 1.2346, got %+v", first)}
+	}
+	again, err := quotaService.SetModelCostMultiplier("model-cost-test-a", 1.23459, "admin", "same price")
+	if err != nil || again.Revision != first.Revision {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Same multiplier expected revision %d, got %+v (%v)", first.Revision, again, err)}
+	}
+
+	if _, err := quotaService.SetModelCostMultiplier("model-cost-test-b", 0.5, "admin", "cheap model"); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Set multiplier failed: %v", err)}
+	}
+	removed, err := quotaService.RemoveModelCostMultiplier("model-cost-test-a", "admin", "back to 1x")
+	if err != nil || removed.Revision != first.Revision+2 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Remove expected revision %d, got %+v (%v)", first.Revision+2, removed, err)}
+	}
+	if _, err := quotaService.RemoveModelCostMultiplier("model-cost-test-a", "admin", "again"); serviceErrorCode(err) != services.ErrorResourceNotFound {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Removing a removed model expected resource_not_found, got %v", err)}
+	}
+
+	// Each revision of the catalog stays readable
+	current, err := quotaService.GetModelCatalog(0)
+	if err != nil || current.Revision != removed.Revision || catalogMultiplier(current, "model-cost-test-a") != 0 || catalogMultiplier(current, "model-cost-test-b") != 0.5 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected current catalog: %+v (%v)", current, err)}
+	}
+	past, err := quotaService.GetModelCatalog(first.Revision)
+	if err != nil || catalogMultiplier(past, "model-cost-test-a") != 1.2346 || catalogMultiplier(past, "model-cost-test-b") != 0 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected catalog at revision %d: %+v (%v)", first.Revision, past, err)}
+	}
+
+	// AiGateway holds the latest catalog
+	multipliers, revision := ctx.MockQuotaStore.GetModelCosts()
+	if revision != removed.Revision || multipliers["model-cost-test-b"] != 0.5 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the gateway at revision %d, got %d: %v", removed.Revision, revision, multipliers)}
+	}
+	if _, listed := multipliers["model-cost-test-a"]; listed {
+		return TestResult{Passed: false, Message: "Removed model still pushed to the gateway"}
+	}
+
+	// A forced push resends the catalog, and is refused while pushes are disabled
+	ctx.MockQuotaStore.SetModelCosts(nil, 0)
+	if err := quotaService.PushModelCatalogNow(); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Forced push failed: %v", err)}
+	}
+	if _, revision := ctx.MockQuotaStore.GetModelCosts(); revision != removed.Revision {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Forced push expected revision %d, got %d", removed.Revision, revision)}
+	}
+	if err := ctx.QuotaService.PushModelCatalogNow(); serviceErrorCode(err) != services.ErrorValidationFailed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Push with push disabled expected validation_failed, got %v", err)}
+	}
+
+	return TestResult{Passed: true, Message: "Model Cost Multipliers Test Succeeded"}
+}
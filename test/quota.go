@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"quota-manager/pkg/decimal"
 	"time"
 
 	"quota-manager/internal/models"
@@ -21,8 +22,8 @@ func testQuotaExpiry(ctx *TestContext) TestResult {
 	validDate := time.Now().Truncate(time.Second).Add(30 * 24 * time.Hour)
 
 	quotas := []*models.Quota{
-		{UserID: user.ID, Amount: 50, ExpiryDate: expiredDate, Status: models.StatusValid},
-		{UserID: user.ID, Amount: 100, ExpiryDate: validDate, Status: models.StatusValid},
+		{UserID: user.ID, Amount: decimal.New(50), ExpiryDate: expiredDate, Status: models.StatusValid},
+		{UserID: user.ID, Amount: decimal.New(100), ExpiryDate: validDate, Status: models.StatusValid},
 	}
 
 	for _, quota := range quotas {
@@ -71,7 +72,7 @@ func testQuotaAuditRecords(ctx *TestContext) TestResult {
 	}
 
 	// Add quota using strategy execution
-	if err := ctx.QuotaService.AddQuotaForStrategy(user.ID, 50, 0, "test-strategy"); err != nil {
+	if err := ctx.QuotaService.AddQuotaForStrategy(user.ID, decimal.New(50), 0, "test-strategy"); err != nil {
 		return TestResult{Passed: false, Message: fmt.Sprintf("Add quota for strategy failed: %v", err)}
 	}
 
@@ -90,7 +91,7 @@ func testQuotaAuditRecords(ctx *TestContext) TestResult {
 	}
 
 	record := records[0]
-	if record.Amount != 50 || record.Operation != models.OperationRecharge {
+	if record.Amount.Float64() != 50 || record.Operation != models.OperationRecharge {
 		return TestResult{Passed: false, Message: "Audit record data mismatch"}
 	}
 
@@ -120,7 +121,7 @@ func testStrategyWithExpiryDate(ctx *TestContext) TestResult {
 		Name:      "expiry-date-test",
 		Title:     "Expiry Date Test",
 		Type:      "single",
-		Amount:    75,
+		Amount:    decimal.New(75),
 		Model:     "test-model",
 		Condition: "true()",
 		Status:    true,
@@ -139,8 +140,8 @@ func testStrategyWithExpiryDate(ctx *TestContext) TestResult {
 		return TestResult{Passed: false, Message: fmt.Sprintf("Failed to get quota: %v", err)}
 	}
 
-	if quota.Amount != 75 {
-		return TestResult{Passed: false, Message: fmt.Sprintf("Expected quota amount 75, got %g", quota.Amount)}
+	if quota.Amount.Float64() != 75 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected quota amount 75, got %g", quota.Amount.Float64())}
 	}
 
 	if quota.Status != models.StatusValid {
@@ -186,7 +187,7 @@ func testMultipleOperationsAccuracy(ctx *TestContext) TestResult {
 	mockStore.SetQuota(user2.ID, 0)
 
 	// 1. Add initial quota via strategy for user1
-	if err := ctx.QuotaService.AddQuotaForStrategy(user1.ID, 100, 0, "initial-strategy"); err != nil {
+	if err := ctx.QuotaService.AddQuotaForStrategy(user1.ID, decimal.New(100), 0, "initial-strategy"); err != nil {
 		return TestResult{Passed: false, Message: fmt.Sprintf("Add initial quota failed: %v", err)}
 	}
 
@@ -202,7 +203,7 @@ func testMultipleOperationsAccuracy(ctx *TestContext) TestResult {
 	transferOutReq := &services.TransferOutRequest{
 		ReceiverID: user2.ID,
 		QuotaList: []services.TransferQuotaItem{
-			{Amount: 30, ExpiryDate: transferExpiryDate},
+			{Amount: decimal.New(30), ExpiryDate: transferExpiryDate},
 		},
 	}
 	transferOutResp, err := ctx.QuotaService.TransferOut(&models.AuthUser{
@@ -224,11 +225,11 @@ func testMultipleOperationsAccuracy(ctx *TestContext) TestResult {
 	}
 
 	// 4. Consume some quota for user1 and user2
-	ctx.Gateway.DeltaUsedQuota(user1.ID, 20)
-	ctx.Gateway.DeltaUsedQuota(user2.ID, 10)
+	ctx.Gateway.DeltaUsedQuota(user1.ID, decimal.New(20))
+	ctx.Gateway.DeltaUsedQuota(user2.ID, decimal.New(10))
 
 	// 5. Add more quota via strategy for user1
-	if err := ctx.QuotaService.AddQuotaForStrategy(user1.ID, 50, 0, "additional-strategy"); err != nil {
+	if err := ctx.QuotaService.AddQuotaForStrategy(user1.ID, decimal.New(50), 0, "additional-strategy"); err != nil {
 		return TestResult{Passed: false, Message: fmt.Sprintf("Add additional quota failed: %v", err)}
 	}
 
@@ -253,11 +254,11 @@ func testMultipleOperationsAccuracy(ctx *TestContext) TestResult {
 
 	expectedTotalUser1 := 120.0 // 100 initial + 50 additional - 30 transferred out
 	expectedUsedUser1 := 20.0
-	if quotaInfo1.TotalQuota != expectedTotalUser1 {
-		return TestResult{Passed: false, Message: fmt.Sprintf("User1 total quota incorrect: expected %f, got %f", expectedTotalUser1, quotaInfo1.TotalQuota)}
+	if quotaInfo1.TotalQuota.Float64() != expectedTotalUser1 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("User1 total quota incorrect: expected %f, got %f", expectedTotalUser1, quotaInfo1.TotalQuota.Float64())}
 	}
-	if quotaInfo1.UsedQuota != expectedUsedUser1 {
-		return TestResult{Passed: false, Message: fmt.Sprintf("User1 used quota incorrect: expected %f, got %f", expectedUsedUser1, quotaInfo1.UsedQuota)}
+	if quotaInfo1.UsedQuota.Float64() != expectedUsedUser1 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("User1 used quota incorrect: expected %f, got %f", expectedUsedUser1, quotaInfo1.UsedQuota.Float64())}
 	}
 
 	// Verify user2 quota calculations
@@ -268,11 +269,11 @@ func testMultipleOperationsAccuracy(ctx *TestContext) TestResult {
 
 	expectedTotalUser2 := 30.0 // 30 transferred in
 	expectedUsedUser2 := 10.0
-	if quotaInfo2.TotalQuota != expectedTotalUser2 {
-		return TestResult{Passed: false, Message: fmt.Sprintf("User2 total quota incorrect: expected %f, got %f", expectedTotalUser2, quotaInfo2.TotalQuota)}
+	if quotaInfo2.TotalQuota.Float64() != expectedTotalUser2 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("User2 total quota incorrect: expected %f, got %f", expectedTotalUser2, quotaInfo2.TotalQuota.Float64())}
 	}
-	if quotaInfo2.UsedQuota != expectedUsedUser2 {
-		return TestResult{Passed: false, Message: fmt.Sprintf("User2 used quota incorrect: expected %f, got %f", expectedUsedUser2, quotaInfo2.UsedQuota)}
+	if quotaInfo2.UsedQuota.Float64() != expectedUsedUser2 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("User2 used quota incorrect: expected %f, got %f", expectedUsedUser2, quotaInfo2.UsedQuota.Float64())}
 	}
 
 	// Verify audit records count
@@ -313,7 +314,7 @@ func testUserQuotaConsumptionOrder(ctx *TestContext) TestResult {
 	earlyExpiry := now.AddDate(0, 0, 10)
 	if err := ctx.DB.Create(&models.Quota{
 		UserID:     user.ID,
-		Amount:     100,
+		Amount:     decimal.New(100),
 		ExpiryDate: earlyExpiry,
 		Status:     models.StatusValid,
 	}).Error; err != nil {
@@ -324,7 +325,7 @@ func testUserQuotaConsumptionOrder(ctx *TestContext) TestResult {
 	midExpiry := now.AddDate(0, 0, 30)
 	if err := ctx.DB.Create(&models.Quota{
 		UserID:     user.ID,
-		Amount:     100,
+		Amount:     decimal.New(100),
 		ExpiryDate: midExpiry,
 		Status:     models.StatusValid,
 	}).Error; err != nil {
@@ -335,7 +336,7 @@ func testUserQuotaConsumptionOrder(ctx *TestContext) TestResult {
 	lateExpiry := now.AddDate(0, 0, 60)
 	if err := ctx.DB.Create(&models.Quota{
 		UserID:     user.ID,
-		Amount:     100,
+		Amount:     decimal.New(100),
 		ExpiryDate: lateExpiry,
 		Status:     models.StatusValid,
 	}).Error; err != nil {
@@ -344,7 +345,7 @@ func testUserQuotaConsumptionOrder(ctx *TestContext) TestResult {
 
 	// Consume 150 quota (should consume from earliest expiring quotas first)
 	// This should consume: 100 from early + 50 from mid, leaving 50 from mid + 100 from late
-	ctx.Gateway.DeltaUsedQuota(user.ID, 150)
+	ctx.Gateway.DeltaUsedQuota(user.ID, decimal.New(150))
 
 	// Get user quota to verify consumption order
 	quotaInfo, err := ctx.QuotaService.GetUserQuota(user.ID)
@@ -363,26 +364,26 @@ func testUserQuotaConsumptionOrder(ctx *TestContext) TestResult {
 	}
 
 	// Verify remaining amounts - first item (mid expiry) should have 50 remaining
-	if quotaInfo.QuotaList[0].Amount != 50 {
-		return TestResult{Passed: false, Message: fmt.Sprintf("Expected first item to have 50 remaining, got %g", quotaInfo.QuotaList[0].Amount)}
+	if quotaInfo.QuotaList[0].Amount.Float64() != 50 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected first item to have 50 remaining, got %g", quotaInfo.QuotaList[0].Amount.Float64())}
 	}
 
 	// Second item (late expiry) should have 100 remaining
-	if quotaInfo.QuotaList[1].Amount != 100 {
-		return TestResult{Passed: false, Message: fmt.Sprintf("Expected second item to have 100 remaining, got %g", quotaInfo.QuotaList[1].Amount)}
+	if quotaInfo.QuotaList[1].Amount.Float64() != 100 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected second item to have 100 remaining, got %g", quotaInfo.QuotaList[1].Amount.Float64())}
 	}
 
 	// Verify total remaining quota (calculate from quota list)
-	expectedRemaining := quotaInfo.TotalQuota - quotaInfo.UsedQuota // Should be 300 - 150 = 150
-	actualRemaining := quotaInfo.TotalQuota - quotaInfo.UsedQuota
-	if actualRemaining != expectedRemaining {
-		return TestResult{Passed: false, Message: fmt.Sprintf("Expected remaining quota %g, got %g", expectedRemaining, actualRemaining)}
+	expectedRemaining := quotaInfo.TotalQuota.Sub(quotaInfo.UsedQuota) // Should be 300 - 150 = 150
+	actualRemaining := quotaInfo.TotalQuota.Sub(quotaInfo.UsedQuota)
+	if !actualRemaining.Equal(expectedRemaining) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected remaining quota %g, got %g", expectedRemaining.Float64(), actualRemaining.Float64())}
 	}
 
 	// Verify used quota
 	expectedUsed := 150.0
-	if quotaInfo.UsedQuota != expectedUsed {
-		return TestResult{Passed: false, Message: fmt.Sprintf("Expected used quota %f, got %f", expectedUsed, quotaInfo.UsedQuota)}
+	if quotaInfo.UsedQuota.Float64() != expectedUsed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected used quota %f, got %f", expectedUsed, quotaInfo.UsedQuota.Float64())}
 	}
 
 	return TestResult{Passed: true, Message: "User quota consumption order test succeeded"}
@@ -408,7 +409,7 @@ func testStrategyExpiryDateCoverage(ctx *TestContext) TestResult {
 
 	// Test case 1: Strategy execution - always set to end of current month
 	// Add quota for user1 (should expire at end of current month)
-	if err := ctx.QuotaService.AddQuotaForStrategy(user1.ID, 100, 0, "test-strategy-1"); err != nil {
+	if err := ctx.QuotaService.AddQuotaForStrategy(user1.ID, decimal.New(100), 0, "test-strategy-1"); err != nil {
 		return TestResult{Passed: false, Message: fmt.Sprintf("Add quota for user1 failed: %v", err)}
 	}
 
@@ -435,7 +436,7 @@ func testStrategyExpiryDateCoverage(ctx *TestContext) TestResult {
 
 	// Test case 2: Strategy execution when <30 days remaining in current month
 	// This is simulated by the automatic logic in AddQuotaForStrategy
-	if err := ctx.QuotaService.AddQuotaForStrategy(user2.ID, 100, 0, "test-strategy-2"); err != nil {
+	if err := ctx.QuotaService.AddQuotaForStrategy(user2.ID, decimal.New(100), 0, "test-strategy-2"); err != nil {
 		return TestResult{Passed: false, Message: fmt.Sprintf("Add quota for user2 failed: %v", err)}
 	}
 
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/pkg/decimal"
+	"sync"
+	"time"
+
+	"quota-manager/internal/models"
+)
+
+// testFIFOQuotaConsumption tests that gateway usage is charged to the earliest expiring bucket
+// first, persisted, and not charged twice by concurrent or repeated syncs
+func testFIFOQuotaConsumption(ctx *TestContext) TestResult {
+	userID := "fifo-consumption-test-user"
+	early, err := createTestQuotaWithExpiry(ctx, userID, 30, time.Now().Add(10*24*time.Hour))
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create early quota failed: %v", err)}
+	}
+	late, err := createTestQuotaWithExpiry(ctx, userID, 50, time.Now().Add(60*24*time.Hour))
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create late quota failed: %v", err)}
+	}
+	ctx.MockQuotaStore.SetQuota(userID, 80)
+
+	// 40 used: the early bucket is used up, the rest goes to the later one. Two syncs at
+	// once charge it only once.
+	ctx.MockQuotaStore.SetUsed(userID, 40)
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = ctx.QuotaService.SyncUserConsumption(userID)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Sync consumption failed: %v", err)}
+		}
+	}
+	// Nothing new was used, a second sync charges nothing
+	if err := ctx.QuotaService.SyncUserConsumption(userID); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Repeated sync failed: %v", err)}
+	}
+
+	expected := map[int]decimal.Decimal{early.ID: decimal.New(30), late.ID: decimal.New(10)}
+	for quotaID, consumed := range expected {
+		var quota models.Quota
+		if err := ctx.DB.First(&quota, quotaID).Error; err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Reload quota failed: %v", err)}
+		}
+		if !quota.Consumed.Equal(consumed) {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Quota %d expected consumed %s, got %s", quotaID, consumed, quota.Consumed)}
+		}
+	}
+
+	var consumptions []models.QuotaConsumption
+	if err := ctx.DB.Where("user_id = ?", userID).Order("id ASC").Find(&consumptions).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Get consumption records failed: %v", err)}
+	}
+	if len(consumptions) != 2 || consumptions[0].QuotaID != early.ID || consumptions[1].QuotaID != late.ID ||
+		!consumptions[1].Amount.Equal(decimal.New(10)) || !consumptions[1].UsedQuota.Equal(decimal.New(40)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected consumption records: %+v", consumptions)}
+	}
+
+	// Usage beyond the valid quota is recorded as overused on the cursor
+	ctx.MockQuotaStore.SetUsed(userID, 90)
+	if err := ctx.QuotaService.SyncUserConsumption(userID); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Sync consumption failed: %v", err)}
+	}
+	var cursor models.QuotaUsageCursor
+	if err := ctx.DB.Where("user_id = ? AND model = ?", userID, "").First(&cursor).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Usage cursor not found: %v", err)}
+	}
+	if !cursor.UsedQuota.Equal(decimal.New(90)) || !cursor.Overused.Equal(decimal.New(10)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected cursor at 90 with 10 overused, got %s/%s", cursor.UsedQuota, cursor.Overused)}
+	}
+
+	// A counter going back outside the outbox leaves the cursor where it is, so only usage
+	// past the cursor is charged afterwards
+	ctx.MockQuotaStore.SetUsed(userID, 70)
+	if err := ctx.QuotaService.SyncUserConsumption(userID); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Sync consumption failed: %v", err)}
+	}
+	ctx.MockQuotaStore.SetUsed(userID, 95)
+	if err := ctx.QuotaService.SyncUserConsumption(userID); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Sync consumption failed: %v", err)}
+	}
+	if err := ctx.DB.Where("user_id = ? AND model = ?", userID, "").First(&cursor).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Usage cursor not found: %v", err)}
+	}
+	if !cursor.UsedQuota.Equal(decimal.New(95)) || !cursor.Overused.Equal(decimal.New(15)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected cursor at 95 with 15 overused, got %s/%s", cursor.UsedQuota, cursor.Overused)}
+	}
+
+	return TestResult{Passed: true, Message: "FIFO Quota Consumption Test Succeeded"}
+}
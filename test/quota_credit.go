@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/pkg/decimal"
+	"time"
+
+	"quota-manager/internal/models"
+	"quota-manager/internal/services"
+)
+
+// testCreditLimit tests that usage beyond the user's buckets is drawn on credit as debt,
+// and that the debt is paid from the next recharge
+func testCreditLimit(ctx *TestContext) TestResult {
+	userID := "credit-limit-test-user"
+	if _, err := createTestQuotaWithExpiry(ctx, userID, 10, time.Now().Add(30*24*time.Hour)); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create quota failed: %v", err)}
+	}
+	ctx.MockQuotaStore.SetQuota(userID, 10)
+
+	if _, err := ctx.QuotaService.SetUserCreditLimit(userID, decimal.New(-5), "admin"); serviceErrorCode(err) != services.ErrorValidationFailed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Negative credit limit expected validation_failed, got %v", err)}
+	}
+
+	// The credit line is added to the gateway total
+	info, err := ctx.QuotaService.SetUserCreditLimit(userID, decimal.New(20), "admin")
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Set credit limit failed: %v", err)}
+	}
+	if !info.CreditLimit.Equal(decimal.New(20)) || !info.GrantedLimit.Equal(decimal.New(20)) || info.Source != models.TargetTypeUser {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected credit info: %+v", info)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(userID); total != 30 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 30 with the credit line, got %f", total)}
+	}
+
+	// Using 25 exhausts the bucket and draws 15 on credit
+	ctx.MockQuotaStore.SetUsed(userID, 25)
+	quota, err := ctx.QuotaService.GetUserQuota(userID)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Get user quota failed: %v", err)}
+	}
+	if !quota.TotalQuota.Equal(decimal.New(10)) || !quota.CreditLimit.Equal(decimal.New(20)) || !quota.OutstandingDebt.Equal(decimal.New(15)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected total 10, credit 20 and debt 15, got %s/%s/%s", quota.TotalQuota, quota.CreditLimit, quota.OutstandingDebt)}
+	}
+	var cursor models.QuotaUsageCursor
+	if err := ctx.DB.Where("user_id = ? AND model = ?", userID, "").First(&cursor).Error; err != nil || !cursor.Overused.IsZero() {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Usage on credit was recorded as overused: %+v (%v)", cursor, err)}
+	}
+
+	// The next recharge pays the debt first
+	if err := grantTestQuota(ctx, userID, 40); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Grant failed: %v", err)}
+	}
+	if info, err = ctx.QuotaService.GetCreditInfo(userID); err != nil || !info.OutstandingDebt.IsZero() {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the debt settled, got %+v (%v)", info, err)}
+	}
+	var recharged models.Quota
+	if err := ctx.DB.Where("user_id = ? AND amount = ?", userID, decimal.New(40)).First(&recharged).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Recharged bucket not found: %v", err)}
+	}
+	if !recharged.Consumed.Equal(decimal.New(15)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 15 of the recharge consumed by the debt, got %s", recharged.Consumed)}
+	}
+
+	var operations []string
+	ctx.DB.Model(&models.QuotaAudit{}).Where("user_id = ?", userID).Order("id ASC").Pluck("operation", &operations)
+	expected := []string{models.OperationCreditLimit, models.OperationCreditDraw, models.OperationRecharge, models.OperationCreditSettle}
+	if fmt.Sprint(operations) != fmt.Sprint(expected) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected audits %v, got %v", expected, operations)}
+	}
+
+	// Removing the limit takes the credit line out of the gateway total again
+	if info, err = ctx.QuotaService.SetUserCreditLimit(userID, decimal.Zero, "admin"); err != nil || !info.GrantedLimit.IsZero() {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Remove credit limit failed: %+v (%v)", info, err)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(userID); total != 50 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 50 without the credit line, got %f", total)}
+	}
+
+	return TestResult{Passed: true, Message: "Credit Limit Test Succeeded"}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
 	"time"
 
 	"github.com/stretchr/testify/mock"
@@ -60,7 +61,7 @@ func createTestQuotaWithExpiry(ctx *TestContext, userID string, amount float64,
 func createTestQuota(ctx *TestContext, userID string, amount float64, status string, expiryTime time.Time) (*models.Quota, error) {
 	quota := &models.Quota{
 		UserID:     userID,
-		Amount:     amount,
+		Amount:     decimal.NewFromFloat(amount),
 		Status:     status,
 		ExpiryDate: expiryTime,
 		CreateTime: time.Now(),
@@ -164,8 +165,8 @@ func verifyUserQuotaRecordsIntegrity(ctx *TestContext, userID string, expectedRe
 		if quota.Status != expected.Status {
 			return fmt.Errorf("quota record %d: expected status %s, got %s", i, expected.Status, quota.Status)
 		}
-		if math.Abs(quota.Amount-expected.Amount) > 0.0001 {
-			return fmt.Errorf("quota record %d: expected amount %f, got %f", i, expected.Amount, quota.Amount)
+		if math.Abs(quota.Amount.Float64()-expected.Amount) > 0.0001 {
+			return fmt.Errorf("quota record %d: expected amount %f, got %f", i, expected.Amount, quota.Amount.Float64())
 		}
 
 		// 使用秒级精度比较时间，避免数据库存储时纳秒精度截断导致的问题
@@ -305,8 +306,8 @@ func verifyQuotaExpiryAuditExists(ctx *TestContext, userID string, expectedAmoun
 		return err
 	}
 
-	if math.Abs(auditRecord.Amount-expectedAmount) > 0.0001 {
-		return fmt.Errorf("audit record amount mismatch: expected %f, got %f", expectedAmount, auditRecord.Amount)
+	if math.Abs(auditRecord.Amount.Float64()-expectedAmount) > 0.0001 {
+		return fmt.Errorf("audit record amount mismatch: expected %f, got %f", expectedAmount, auditRecord.Amount.Float64())
 	}
 
 	if auditRecord.Operation != "EXPIRE" {
@@ -335,7 +336,7 @@ func verifyNoUnexpectedAuditRecords(ctx *TestContext, userID string, excludedOpe
 		}
 
 		if !excluded {
-			return fmt.Errorf("found unexpected audit record: operation=%s, amount=%f", record.Operation, record.Amount)
+			return fmt.Errorf("found unexpected audit record: operation=%s, amount=%f", record.Operation, record.Amount.Float64())
 		}
 	}
 	return nil
@@ -461,8 +462,8 @@ func verifyQuotaExpiryAudit(ctx *TestContext, quotaID string, expectedOperation
 		return err
 	}
 
-	if math.Abs(auditRecord.Amount-expectedAmount) > 0.0001 {
-		return fmt.Errorf("audit record amount mismatch: expected %f, got %f", expectedAmount, auditRecord.Amount)
+	if math.Abs(auditRecord.Amount.Float64()-expectedAmount) > 0.0001 {
+		return fmt.Errorf("audit record amount mismatch: expected %f, got %f", expectedAmount, auditRecord.Amount.Float64())
 	}
 
 	if auditRecord.Operation != expectedOperation {
@@ -498,6 +499,9 @@ func cleanupUserQuotaData(ctx *TestContext, userID string) error {
 	if err := ctx.DB.Where("user_id = ?", userID).Delete(&models.QuotaAudit{}).Error; err != nil {
 		return err
 	}
+	if err := ctx.DB.Where("user_id = ?", userID).Delete(&models.QuotaAuditChainHead{}).Error; err != nil {
+		return err
+	}
 
 	// 删除月度配额使用记录
 	if err := ctx.DB.Where("user_id = ?", userID).Delete(&models.MonthlyQuotaUsage{}).Error; err != nil {
@@ -562,8 +566,8 @@ func verifyMonthlyQuotaUsageRecord(ctx *TestContext, userID string, expectedYear
 	}
 
 	// 验证已使用配额金额
-	if math.Abs(monthlyQuotaUsage.UsedQuota-expectedUsedQuota) > 0.0001 {
-		return fmt.Errorf("monthly quota usage amount mismatch for user %s: expected %f, got %f", userID, expectedUsedQuota, monthlyQuotaUsage.UsedQuota)
+	if math.Abs(monthlyQuotaUsage.UsedQuota.Float64()-expectedUsedQuota) > 0.0001 {
+		return fmt.Errorf("monthly quota usage amount mismatch for user %s: expected %f, got %f", userID, expectedUsedQuota, monthlyQuotaUsage.UsedQuota.Float64())
 	}
 
 	// 验证记录时间不为空
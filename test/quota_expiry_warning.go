@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/pkg/decimal"
+	"time"
+
+	"quota-manager/internal/models"
+)
+
+// testExpiryWarnings tests that buckets entering a warning window get one notice for the
+// narrowest window only, and that repeated runs do not raise it again
+func testExpiryWarnings(ctx *TestContext) TestResult {
+	userID := "expiry-warning-test-user"
+	soon, err := createTestQuotaWithExpiry(ctx, userID, 10, time.Now().Add(12*time.Hour))
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create quota failed: %v", err)}
+	}
+	week, err := createTestQuotaWithExpiry(ctx, userID, 20, time.Now().Add(5*24*time.Hour))
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create quota failed: %v", err)}
+	}
+	if _, err := createTestQuotaWithExpiry(ctx, userID, 30, time.Now().Add(20*24*time.Hour)); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create quota failed: %v", err)}
+	}
+	ctx.MockQuotaStore.SetQuota(userID, 60)
+
+	for i := 0; i < 2; i++ {
+		if err := ctx.QuotaService.RecordExpiryWarnings([]int{7, 1}); err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Record expiry warnings failed: %v", err)}
+		}
+	}
+
+	var notices []models.QuotaExpiryNotice
+	if err := ctx.DB.Where("user_id = ?", userID).Order("expiry_date ASC").Find(&notices).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Get expiry notices failed: %v", err)}
+	}
+	if len(notices) != 2 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 2 expiry notices, got %d", len(notices))}
+	}
+	if notices[0].QuotaID != soon.ID || notices[0].DaysBefore != 1 || !notices[0].Amount.Equal(decimal.New(10)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected a 1 day notice for the bucket expiring today, got %+v", notices[0])}
+	}
+	if notices[1].QuotaID != week.ID || notices[1].DaysBefore != 7 || !notices[1].Amount.Equal(decimal.New(20)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected a 7 day notice for the bucket expiring this week, got %+v", notices[1])}
+	}
+
+	items, total, err := ctx.QuotaService.GetExpiringQuotas(userID, 1, 10)
+	if err != nil || total != 2 || len(items) != 2 || items[0].DaysBefore != 1 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected expiring quota list: %+v (total %d, %v)", items, total, err)}
+	}
+
+	return TestResult{Passed: true, Message: "Expiry Warnings Test Succeeded"}
+}
+
+// testExpirySweep tests that the expiry sweep lapses due buckets after charging usage to them
+func testExpirySweep(ctx *TestContext) TestResult {
+	userID := "expiry-sweep-test-user"
+	due, err := createTestQuotaWithExpiry(ctx, userID, 10, time.Now().Add(-time.Minute))
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create quota failed: %v", err)}
+	}
+	if _, err := createTestQuotaWithExpiry(ctx, userID, 20, time.Now().Add(30*24*time.Hour)); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create quota failed: %v", err)}
+	}
+	ctx.MockQuotaStore.SetQuota(userID, 30)
+	ctx.MockQuotaStore.SetUsed(userID, 5)
+
+	if err := ctx.QuotaService.ExpireDueQuotas(); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expiry sweep failed: %v", err)}
+	}
+
+	var quota models.Quota
+	if err := ctx.DB.First(&quota, due.ID).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Reload quota failed: %v", err)}
+	}
+	if quota.Status != models.StatusExpired || !quota.Consumed.Equal(decimal.New(5)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the due bucket expired with 5 consumed, got %s/%s", quota.Status, quota.Consumed)}
+	}
+	// The usage was charged to the lapsing bucket, the later bucket stays whole
+	if total, used := ctx.MockQuotaStore.GetQuota(userID), ctx.MockQuotaStore.GetUsed(userID); total != 20 || used != 0 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 20 and used 0, got %f/%f", total, used)}
+	}
+
+	return TestResult{Passed: true, Message: "Expiry Sweep Test Succeeded"}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/pkg/decimal"
+
+	"quota-manager/internal/config"
+	"quota-manager/internal/models"
+	"quota-manager/internal/services"
+)
+
+// newConfiguredQuotaService creates a quota service on the mock gateway whose config is
+// config.yaml changed by update
+func newConfiguredQuotaService(ctx *TestContext, update func(cfg *config.Config)) (*services.QuotaService, error) {
+	cfg, err := config.LoadConfig("config.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	update(cfg)
+	return services.NewQuotaService(ctx.DB, config.NewManager(cfg), ctx.Gateway, ctx.VoucherService), nil
+}
+
+// testModelQuotaPools tests that grants for pooled models keep a balance of their own,
+// with their own gateway counters, next to the "any model" pool
+func testModelQuotaPools(ctx *TestContext) TestResult {
+	quotaService, err := newConfiguredQuotaService(ctx, func(cfg *config.Config) {
+		cfg.ModelQuota.Pools = map[string][]string{"premium": {"gpt-4*"}}
+	})
+	if err != nil {
+		return TestResult{Passed: false, Message: err.Error()}
+	}
+	if pool := quotaService.ModelPool("gpt-4o"); pool != "premium" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gpt-4o in the premium pool, got %q", pool)}
+	}
+	if pool := quotaService.ModelPool("qwen-max"); pool != "" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected qwen-max in the any model pool, got %q", pool)}
+	}
+
+	strategy := &models.QuotaStrategy{
+		Name:      "model-pool-test",
+		Title:     "Model Pool Test",
+		Type:      "single",
+		Amount:    decimal.New(25),
+		Model:     "gpt-4o",
+		Condition: "true()",
+	}
+	if err := ctx.StrategyService.CreateStrategy(strategy); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create strategy failed: %v", err)}
+	}
+
+	userID := "model-pool-test-user"
+	premiumKey := mockQuotaKey(userID, "premium")
+	if err := quotaService.AddQuotaForStrategy(userID, decimal.New(25), strategy.ID, strategy.Name); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Premium grant failed: %v", err)}
+	}
+	if err := quotaService.AddQuotaForStrategy(userID, decimal.New(10), 0, ""); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Any model grant failed: %v", err)}
+	}
+	if premium, anyModel := ctx.MockQuotaStore.GetQuota(premiumKey), ctx.MockQuotaStore.GetQuota(userID); premium != 25 || anyModel != 10 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway pools premium=25 and any=10, got %f/%f", premium, anyModel)}
+	}
+	var bucket models.Quota
+	if err := ctx.DB.Where("user_id = ? AND model = ?", userID, "premium").First(&bucket).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Premium bucket not found: %v", err)}
+	}
+
+	// Usage of the premium pool is charged to its own buckets only
+	ctx.MockQuotaStore.SetUsed(premiumKey, 5)
+	info, err := quotaService.GetUserQuota(userID)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Get user quota failed: %v", err)}
+	}
+	if !info.TotalQuota.Equal(decimal.New(10)) || !info.UsedQuota.IsZero() {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected any model pool 10 with nothing used, got %s/%s", info.TotalQuota, info.UsedQuota)}
+	}
+	if len(info.Models) != 1 || info.Models[0].Model != "premium" || !info.Models[0].UsedQuota.Equal(decimal.New(5)) ||
+		len(info.Models[0].QuotaList) != 1 || !info.Models[0].QuotaList[0].Amount.Equal(decimal.New(20)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected premium pool balance: %+v", info.Models)}
+	}
+
+	// Transfers of a model outside every pool are rejected rather than drawn from the any model pool
+	giver := &models.AuthUser{ID: userID, Name: "Model Pool Test User"}
+	_, err = quotaService.TransferOut(giver, &services.TransferOutRequest{
+		ReceiverID: "model-pool-test-receiver",
+		QuotaList:  []services.TransferQuotaItem{{Amount: decimal.New(5), ExpiryDate: bucket.ExpiryDate, Model: "unpooled-model"}},
+	})
+	if err == nil {
+		return TestResult{Passed: false, Message: "Transfer of a model outside every pool succeeded"}
+	}
+	// Transfers of a pooled model draw from its pool
+	if _, err := quotaService.TransferOut(giver, &services.TransferOutRequest{
+		ReceiverID: "model-pool-test-receiver",
+		QuotaList:  []services.TransferQuotaItem{{Amount: decimal.New(5), ExpiryDate: bucket.ExpiryDate, Model: "gpt-4o"}},
+	}); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Premium transfer failed: %v", err)}
+	}
+	if premium := ctx.MockQuotaStore.GetQuota(premiumKey); premium != 20 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected premium gateway total 20 after the transfer, got %f", premium)}
+	}
+
+	return TestResult{Passed: true, Message: "Model Quota Pools Test Succeeded"}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/pkg/decimal"
+	"time"
+
+	"quota-manager/internal/models"
+	"quota-manager/internal/services"
+)
+
+// bucketAmounts reloads buckets and returns their amount and consumed quota
+func bucketAmounts(ctx *TestContext, quotas ...*models.Quota) ([]models.Quota, error) {
+	reloaded := make([]models.Quota, len(quotas))
+	for i, quota := range quotas {
+		if err := ctx.DB.First(&reloaded[i], quota.ID).Error; err != nil {
+			return nil, err
+		}
+	}
+	return reloaded, nil
+}
+
+// testQuotaReservationHoldCommit tests that a reservation holds quota out of the buckets and
+// the gateway total, and that committing it returns the hold and charges the actual amount
+func testQuotaReservationHoldCommit(ctx *TestContext) TestResult {
+	userID := "reservation-commit-test-user"
+	early, err := createTestQuotaWithExpiry(ctx, userID, 30, time.Now().Add(10*24*time.Hour))
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create quota failed: %v", err)}
+	}
+	late, err := createTestQuotaWithExpiry(ctx, userID, 50, time.Now().Add(60*24*time.Hour))
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create quota failed: %v", err)}
+	}
+	ctx.MockQuotaStore.SetQuota(userID, 80)
+
+	// The hold is taken earliest expiry first
+	reservation, err := ctx.QuotaService.ReserveQuota(userID, &services.ReserveQuotaRequest{Amount: decimal.New(40), Reference: "job-1"})
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Reserve failed: %v", err)}
+	}
+	if reservation.Status != models.ReservationStatusHeld {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected a held reservation, got %s", reservation.Status)}
+	}
+	buckets, err := bucketAmounts(ctx, early, late)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Reload quotas failed: %v", err)}
+	}
+	if !buckets[0].Amount.IsZero() || !buckets[1].Amount.Equal(decimal.New(40)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected buckets 0/40 while held, got %s/%s", buckets[0].Amount, buckets[1].Amount)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(userID); total != 40 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 40 while held, got %f", total)}
+	}
+
+	// Held quota cannot be reserved again
+	if _, err := ctx.QuotaService.ReserveQuota(userID, &services.ReserveQuotaRequest{Amount: decimal.New(50)}); serviceErrorCode(err) != services.ErrorValidationFailed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Reserving held quota expected validation_failed, got %v", err)}
+	}
+	if _, err := ctx.QuotaService.CommitReservation(userID, reservation.ID, decimal.New(41)); serviceErrorCode(err) != services.ErrorValidationFailed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Committing more than held expected validation_failed, got %v", err)}
+	}
+
+	// Commit returns the hold and charges the 25 actually used
+	committed, err := ctx.QuotaService.CommitReservation(userID, reservation.ID, decimal.New(25))
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Commit failed: %v", err)}
+	}
+	if committed.Status != models.ReservationStatusCommitted || !committed.ActualAmount.Equal(decimal.New(25)) || committed.SettleTime == nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected committed reservation: %+v", committed)}
+	}
+	buckets, err = bucketAmounts(ctx, early, late)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Reload quotas failed: %v", err)}
+	}
+	if !buckets[0].Amount.Equal(decimal.New(30)) || !buckets[0].Consumed.Equal(decimal.New(25)) ||
+		!buckets[1].Amount.Equal(decimal.New(50)) || !buckets[1].Consumed.IsZero() {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected buckets 30 (25 consumed) and 50, got %+v", buckets)}
+	}
+	if total, used := ctx.MockQuotaStore.GetQuota(userID), ctx.MockQuotaStore.GetUsed(userID); total != 80 || used != 25 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 80 and used 25, got %f/%f", total, used)}
+	}
+
+	// A sync afterwards must not charge the committed usage a second time
+	if err := ctx.QuotaService.SyncUserConsumption(userID); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Sync consumption failed: %v", err)}
+	}
+	if buckets, err = bucketAmounts(ctx, early); err != nil || !buckets[0].Consumed.Equal(decimal.New(25)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Committed usage charged again: %+v (%v)", buckets, err)}
+	}
+
+	if _, err := ctx.QuotaService.CommitReservation(userID, reservation.ID, decimal.New(25)); serviceErrorCode(err) != services.ErrorConflict {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Second commit expected conflict, got %v", err)}
+	}
+
+	var operations []string
+	ctx.DB.Model(&models.QuotaAudit{}).Where("user_id = ?", userID).Order("id ASC").Pluck("operation", &operations)
+	if len(operations) != 2 || operations[0] != models.OperationReserve || operations[1] != models.OperationReserveCommit {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected RESERVE and RESERVE_COMMIT audits, got %v", operations)}
+	}
+
+	return TestResult{Passed: true, Message: "Quota Reservation Hold Commit Test Succeeded"}
+}
+
+// testQuotaReservationRelease tests that released and timed out reservations give the whole hold back
+func testQuotaReservationRelease(ctx *TestContext) TestResult {
+	userID := "reservation-release-test-user"
+	quota, err := createTestQuotaWithExpiry(ctx, userID, 20, time.Now().Add(30*24*time.Hour))
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create quota failed: %v", err)}
+	}
+	ctx.MockQuotaStore.SetQuota(userID, 20)
+
+	released, err := ctx.QuotaService.ReserveQuota(userID, &services.ReserveQuotaRequest{Amount: decimal.New(15)})
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Reserve failed: %v", err)}
+	}
+	if released, err = ctx.QuotaService.ReleaseReservation(userID, released.ID); err != nil || released.Status != models.ReservationStatusReleased {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Release failed: %+v (%v)", released, err)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(userID); total != 20 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 20 after release, got %f", total)}
+	}
+
+	// Reservations past their TTL are released by the scheduler
+	timedOut, err := ctx.QuotaService.ReserveQuota(userID, &services.ReserveQuotaRequest{Amount: decimal.New(5), TTLSeconds: 60})
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Reserve failed: %v", err)}
+	}
+	if err := ctx.DB.Model(&models.QuotaReservation{}).Where("id = ?", timedOut.ID).
+		Update("expires_at", time.Now().Add(-time.Second)).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expire reservation failed: %v", err)}
+	}
+	ctx.QuotaService.ReleaseExpiredReservations()
+	if timedOut, err = ctx.QuotaService.GetReservation(userID, timedOut.ID); err != nil || timedOut.Status != models.ReservationStatusExpired {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the reservation expired by its TTL, got %+v (%v)", timedOut, err)}
+	}
+
+	buckets, err := bucketAmounts(ctx, quota)
+	if err != nil || !buckets[0].Amount.Equal(decimal.New(20)) || !buckets[0].Consumed.IsZero() {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the bucket whole again, got %+v (%v)", buckets, err)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(userID); total != 20 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 20 after the TTL release, got %f", total)}
+	}
+	if _, total, err := ctx.QuotaService.GetReservations(userID, models.ReservationStatusHeld, 1, 10); err != nil || total != 0 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected no held reservations left, got %d (%v)", total, err)}
+	}
+
+	return TestResult{Passed: true, Message: "Quota Reservation Release Test Succeeded"}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/pkg/decimal"
+	"time"
+
+	"quota-manager/internal/models"
+)
+
+// testRolloverAndGracePolicies tests strategy expiry policies: unused quota rolled over up to
+// the cap, buckets kept valid through their grace period, and rollover buckets left out of merges
+func testRolloverAndGracePolicies(ctx *TestContext) TestResult {
+	rolloverStrategy := &models.QuotaStrategy{
+		Name:              "rollover-policy-test",
+		Title:             "Rollover Policy Test",
+		Type:              "single",
+		Amount:            decimal.New(40),
+		Condition:         "true()",
+		RolloverPercent:   50,
+		RolloverMaxAmount: decimal.New(15),
+		RolloverMonths:    1,
+	}
+	graceStrategy := &models.QuotaStrategy{
+		Name:        "grace-policy-test",
+		Title:       "Grace Policy Test",
+		Type:        "single",
+		Amount:      decimal.New(10),
+		Condition:   "true()",
+		GracePeriod: "48h",
+	}
+	for _, strategy := range []*models.QuotaStrategy{rolloverStrategy, graceStrategy} {
+		if err := ctx.StrategyService.CreateStrategy(strategy); err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Create strategy %s failed: %v", strategy.Name, err)}
+		}
+	}
+
+	rolloverUser := "rollover-policy-test-user"
+	graceUser := "grace-policy-test-user"
+	expired := time.Now().Truncate(time.Second).Add(-time.Hour)
+	lapsing := &models.Quota{UserID: rolloverUser, Amount: decimal.New(40), StrategyID: &rolloverStrategy.ID, ExpiryDate: expired, Status: models.StatusValid}
+	inGrace := &models.Quota{UserID: graceUser, Amount: decimal.New(10), StrategyID: &graceStrategy.ID, ExpiryDate: expired, Status: models.StatusValid}
+	for _, quota := range []*models.Quota{lapsing, inGrace} {
+		if err := ctx.DB.Create(quota).Error; err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Create quota failed: %v", err)}
+		}
+	}
+	ctx.MockQuotaStore.SetQuota(rolloverUser, 40)
+	ctx.MockQuotaStore.SetQuota(graceUser, 10)
+
+	if err := ctx.QuotaService.ExpireDueQuotas(); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expiry sweep failed: %v", err)}
+	}
+
+	// Half of the unused 40 is 20, capped to 15
+	var rollover models.Quota
+	if err := ctx.DB.Where("rollover_from = ?", lapsing.ID).First(&rollover).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Rollover bucket not found: %v", err)}
+	}
+	if !rollover.Amount.Equal(decimal.New(15)) || rollover.Status != models.StatusValid || !rollover.ExpiryDate.After(time.Now()) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected rollover bucket: %+v", rollover)}
+	}
+	var audit models.QuotaAudit
+	if err := ctx.DB.Where("user_id = ? AND operation = ?", rolloverUser, models.OperationRollover).First(&audit).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Rollover audit not found: %v", err)}
+	}
+	details, err := audit.UnmarshalDetails()
+	if err != nil || details.Rollover == nil || details.Rollover.QuotaID != lapsing.ID || !details.Rollover.UnusedAmount.Equal(decimal.New(40)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Rollover audit does not link the expired bucket: %v", err)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(rolloverUser); total != 15 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 15 after rollover, got %f", total)}
+	}
+
+	// The grace period keeps the bucket usable past its expiry date
+	if err := ctx.DB.First(inGrace, inGrace.ID).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Reload quota failed: %v", err)}
+	}
+	if inGrace.Status != models.StatusValid {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Bucket in its grace period was expired: %s", inGrace.Status)}
+	}
+
+	// Plain buckets sharing the rollover's expiry are merged, the rollover bucket is not
+	for i := 0; i < 2; i++ {
+		if err := ctx.DB.Create(&models.Quota{
+			UserID:     rolloverUser,
+			Amount:     decimal.New(5),
+			StrategyID: &rolloverStrategy.ID,
+			ExpiryDate: rollover.ExpiryDate,
+			Status:     models.StatusValid,
+		}).Error; err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Create quota failed: %v", err)}
+		}
+	}
+	if err := ctx.QuotaService.MergeQuotaRecords(); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Merge quota records failed: %v", err)}
+	}
+	var valid []models.Quota
+	if err := ctx.DB.Where("user_id = ? AND status = ?", rolloverUser, models.StatusValid).Order("id ASC").Find(&valid).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Get valid quotas failed: %v", err)}
+	}
+	if len(valid) != 2 || valid[0].ID != rollover.ID || !valid[0].Amount.Equal(decimal.New(15)) || !valid[1].Amount.Equal(decimal.New(10)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the rollover bucket kept and the plain buckets merged to 10, got %+v", valid)}
+	}
+
+	return TestResult{Passed: true, Message: "Rollover And Grace Policies Test Succeeded"}
+}
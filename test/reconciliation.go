@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/pkg/decimal"
+	"time"
+
+	"quota-manager/internal/models"
+)
+
+// grantTestQuota grants quota the way a strategy does, with its audit record and gateway update
+func grantTestQuota(ctx *TestContext, userID string, amount int64) error {
+	return ctx.QuotaService.AddQuotaForStrategy(userID, decimal.New(amount), 0, "")
+}
+
+// reconciliationItemOf returns the item of a user in a report, nil when the user was consistent
+func reconciliationItemOf(ctx *TestContext, reportID int, userID string) (*models.ReconciliationItem, error) {
+	var items []models.ReconciliationItem
+	if err := ctx.DB.Where("report_id = ? AND user_id = ?", reportID, userID).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return &items[0], nil
+}
+
+// testReconciliationReport tests the classification of ledger, audit and gateway discrepancies and the fix mode
+func testReconciliationReport(ctx *TestContext) TestResult {
+	consistent := "reconcile-consistent-user"
+	gatewayOff := "reconcile-gateway-mismatch-user"
+	ledgerOff := "reconcile-ledger-mismatch-user"
+
+	if err := grantTestQuota(ctx, consistent, 30); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Grant failed: %v", err)}
+	}
+	if err := grantTestQuota(ctx, gatewayOff, 20); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Grant failed: %v", err)}
+	}
+	ctx.MockQuotaStore.SetQuota(gatewayOff, 25)
+	// A quota row written without its audit record
+	if _, err := createTestQuota(ctx, ledgerOff, 10, models.StatusValid, time.Now().Add(30*24*time.Hour)); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create quota failed: %v", err)}
+	}
+	ctx.MockQuotaStore.SetQuota(ledgerOff, 10)
+
+	// Dry run only reports
+	ctx.QuotaService.RunScheduledReconciliation()
+	reports, _, err := ctx.QuotaService.GetReconciliationReports(1, 1)
+	if err != nil || len(reports) != 1 || reports[0].Status != models.ReconcileStatusCompleted {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected a completed dry-run report, got %+v (%v)", reports, err)}
+	}
+	dryRun := reports[0]
+
+	item, err := reconciliationItemOf(ctx, dryRun.ID, consistent)
+	if err != nil || item != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Consistent user expected no item, got %+v (%v)", item, err)}
+	}
+	item, err = reconciliationItemOf(ctx, dryRun.ID, gatewayOff)
+	if err != nil || item == nil || item.Classification != models.ReconcileGatewayTotalMismatch || item.Action != models.ReconcileActionNone {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected an unfixed gateway_total_mismatch item, got %+v (%v)", item, err)}
+	}
+	item, err = reconciliationItemOf(ctx, dryRun.ID, ledgerOff)
+	if err != nil || item == nil || item.Classification != models.ReconcileLedgerAuditMismatch || item.Action != models.ReconcileActionManualReview {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected a ledger_audit_mismatch item for review, got %+v (%v)", item, err)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(gatewayOff); total != 25 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Dry run changed the gateway total to %f", total)}
+	}
+
+	// Fix mode refreshes the gateway total from the ledger
+	fix, err := ctx.QuotaService.StartReconciliation(models.ReconcileModeFix, "api")
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Start fix reconciliation failed: %v", err)}
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for fix.Status == models.ReconcileStatusRunning && time.Now().Before(deadline) {
+		time.Sleep(200 * time.Millisecond)
+		if fix, err = ctx.QuotaService.GetReconciliationReport(fix.ID); err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Get report failed: %v", err)}
+		}
+	}
+	if fix.Status != models.ReconcileStatusCompleted {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Fix reconciliation did not complete: %s", fix.Status)}
+	}
+	item, err = reconciliationItemOf(ctx, fix.ID, gatewayOff)
+	if err != nil || item == nil || item.Action != models.ReconcileActionRefreshed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the gateway mismatch refreshed, got %+v (%v)", item, err)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(gatewayOff); total != 20 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total refreshed to 20, got %f", total)}
+	}
+
+	return TestResult{Passed: true, Message: "Reconciliation Report Test Succeeded"}
+}
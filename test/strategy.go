@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"net/url"
+	"quota-manager/pkg/decimal"
 	"strconv"
 	"time"
 
@@ -25,7 +26,7 @@ func testSingleTypeStrategy(ctx *TestContext) TestResult {
 		Name:      "single-type-test",
 		Title:     "Single Recharge Test",
 		Type:      "single",
-		Amount:    70,
+		Amount:    decimal.New(70),
 		Model:     "test-model",
 		Condition: "true()", // Always true condition, all users match
 		Status:    true,
@@ -77,7 +78,7 @@ func testPeriodicTypeStrategy(ctx *TestContext) TestResult {
 		Name:         "periodic-type-test",
 		Title:        "Periodic Recharge Test",
 		Type:         "periodic",
-		Amount:       75,
+		Amount:       decimal.New(75),
 		Model:        "test-model",
 		PeriodicExpr: "0 * * * * *", // Execute every minute
 		Condition:    "true()",      // Always true condition, all users match
@@ -136,7 +137,7 @@ func testStrategyStatusControl(ctx *TestContext) TestResult {
 		Name:      "status-control-test",
 		Title:     "Status Control Test",
 		Type:      "single",
-		Amount:    80,
+		Amount:    decimal.New(80),
 		Model:     "test-model",
 		Condition: "true()", // Always true condition
 	}
@@ -240,7 +241,7 @@ func testAiGatewayFailure(ctx *TestContext) TestResult {
 		Name:      "gateway-failure-test",
 		Title:     "Gateway Failure Test",
 		Type:      "single",
-		Amount:    85,
+		Amount:    decimal.New(85),
 		Model:     "test-model",
 		Condition: "true()", // Always true condition
 		Status:    true,
@@ -253,16 +254,21 @@ func testAiGatewayFailure(ctx *TestContext) TestResult {
 	users := []models.UserInfo{*user}
 	failStrategyService.ExecStrategy(strategy, users)
 
-	// Check execution record exists but status is failed
+	// The grant commits, the gateway update stays queued in the outbox for a retry
 	var execute models.QuotaExecute
-	err = ctx.DB.Where("strategy_id = ? AND user_id = ? AND status = 'failed'", strategy.ID, user.ID).First(&execute).Error
+	err = ctx.DB.Where("strategy_id = ? AND user_id = ? AND status = 'completed'", strategy.ID, user.ID).First(&execute).Error
 
 	if err != nil {
 		return TestResult{Passed: false, Message: fmt.Sprintf("Execution record not found: %v", err)}
 	}
 
-	if execute.Status != "failed" {
-		return TestResult{Passed: false, Message: fmt.Sprintf("Expected status failed, actual status %s", execute.Status)}
+	var entry models.GatewayOutbox
+	if err := ctx.DB.Where("user_id = ? AND status = ?", user.ID, models.OutboxStatusPending).First(&entry).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Pending outbox entry not found: %v", err)}
+	}
+
+	if entry.Attempts != 1 || entry.LastError == "" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 1 failed delivery attempt, actual %d (%s)", entry.Attempts, entry.LastError)}
 	}
 
 	return TestResult{Passed: true, Message: "Gateway Failure Test Succeeded"}
@@ -284,7 +290,7 @@ func testBatchUserProcessing(ctx *TestContext) TestResult {
 		Name:      "batch-processing-test",
 		Title:     "Batch Processing Test",
 		Type:      "single",
-		Amount:    90,
+		Amount:    decimal.New(90),
 		Model:     "test-model",
 		Condition: `is-vip(2)`, // VIP level >=2 users
 		Status:    true,
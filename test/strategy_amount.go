@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+)
+
+// testAmountFormulaStrategy tests per-user amount formulas, their cap and the recorded formula inputs
+func testAmountFormulaStrategy(ctx *TestContext) TestResult {
+	// An unknown variable is rejected on creation
+	invalid := &models.QuotaStrategy{
+		Name:       "amount-formula-invalid-test",
+		Title:      "Amount Formula Invalid Test",
+		Type:       "single",
+		AmountExpr: "10 * unknown_variable",
+		Condition:  "true()",
+		Status:     true,
+	}
+	if err := ctx.StrategyService.CreateStrategy(invalid); err == nil {
+		return TestResult{Passed: false, Message: "Strategy with an unknown formula variable was created"}
+	}
+
+	users := []*models.UserInfo{
+		createTestUser("user_formula_vip0", "Formula VIP0 User", 0),
+		createTestUser("user_formula_vip1", "Formula VIP1 User", 1),
+		createTestUser("user_formula_vip5", "Formula VIP5 User", 5),
+	}
+	var userList []models.UserInfo
+	for _, user := range users {
+		if err := ctx.DB.AuthDB.Create(user).Error; err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Create user failed: %v", err)}
+		}
+		userList = append(userList, *user)
+	}
+
+	strategy := &models.QuotaStrategy{
+		Name:       "amount-formula-test",
+		Title:      "Amount Formula Test",
+		Type:       "single",
+		Amount:     decimal.New(30), // caps the computed amount
+		AmountExpr: "10 * vip + 5",
+		Condition:  "true()",
+		Status:     true,
+	}
+	if err := ctx.StrategyService.CreateStrategy(strategy); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create strategy failed: %v", err)}
+	}
+	ctx.StrategyService.ExecStrategy(strategy, userList)
+
+	expected := []float64{5, 15, 30}
+	for i, user := range users {
+		if total := ctx.MockQuotaStore.GetQuota(user.ID); total != expected[i] {
+			return TestResult{Passed: false, Message: fmt.Sprintf("User with vip %d expected %.2f, got %f", user.VIP, expected[i], total)}
+		}
+	}
+
+	// The capped grant records the formula, its inputs and the cap
+	var audit models.QuotaAudit
+	if err := ctx.DB.Where("user_id = ? AND strategy_id = ?", users[2].ID, strategy.ID).First(&audit).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Audit record not found: %v", err)}
+	}
+	details, err := audit.UnmarshalDetails()
+	if err != nil || details.AmountFormula == nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Audit record has no amount formula: %v", err)}
+	}
+	formula := details.AmountFormula
+	if !formula.ComputedAmount.Equal(decimal.New(55)) || !formula.Cap.Equal(decimal.New(30)) || !formula.Amount.Equal(decimal.New(30)) || formula.Inputs["vip"] != float64(5) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected amount formula detail: %+v", formula)}
+	}
+
+	return TestResult{Passed: true, Message: "Amount Formula Strategy Test Succeeded"}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/internal/config"
+	"quota-manager/internal/models"
+	"quota-manager/internal/services"
+	"quota-manager/pkg/decimal"
+)
+
+// setTestGlobalConfig installs a global config loaded from config.yaml with the given overrides.
+// The returned function removes it again.
+func setTestGlobalConfig(update func(cfg *config.Config)) (func(), error) {
+	cfg, err := config.LoadConfig("config.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	update(cfg)
+	config.SetGlobalConfig(config.NewManager(cfg))
+	return func() { config.SetGlobalConfig(nil) }, nil
+}
+
+// serviceErrorCode returns the code of a service error, or "" for any other error
+func serviceErrorCode(err error) string {
+	if serviceErr, ok := err.(*services.ServiceError); ok {
+		return serviceErr.Code
+	}
+	return ""
+}
+
+// testStrategyApprovalGating tests that strategies above the approval thresholds stay disabled until approved
+func testStrategyApprovalGating(ctx *TestContext) TestResult {
+	restore, err := setTestGlobalConfig(func(cfg *config.Config) {
+		cfg.StrategyApproval.AmountThreshold = decimal.New(100)
+		cfg.StrategyApproval.AudienceThreshold = 0
+	})
+	if err != nil {
+		return TestResult{Passed: false, Message: err.Error()}
+	}
+	defer restore()
+
+	// Strategies below the threshold are approved on creation
+	small := &models.QuotaStrategy{
+		Name:      "approval-below-threshold-test",
+		Title:     "Approval Below Threshold Test",
+		Type:      "single",
+		Amount:    decimal.New(50),
+		Condition: "false()",
+		Status:    true,
+	}
+	if err := ctx.StrategyService.CreateStrategy(small); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create small strategy failed: %v", err)}
+	}
+	if !small.IsApproved() || !small.IsEnabled() {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Small strategy expected approved and enabled, got %s/%v", small.ApprovalStatus, small.Status)}
+	}
+
+	// Strategies above the threshold start as disabled drafts
+	large := &models.QuotaStrategy{
+		Name:      "approval-above-threshold-test",
+		Title:     "Approval Above Threshold Test",
+		Type:      "single",
+		Amount:    decimal.New(500),
+		Condition: "false()",
+		Status:    true,
+	}
+	if err := ctx.StrategyService.CreateStrategy(large); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create large strategy failed: %v", err)}
+	}
+	if large.ApprovalStatus != models.ApprovalStatusDraft || large.IsEnabled() {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Large strategy expected disabled draft, got %s/%v", large.ApprovalStatus, large.Status)}
+	}
+
+	// Enabling before approval is refused
+	if err := ctx.StrategyService.EnableStrategy(large.ID); serviceErrorCode(err) != services.ErrorApprovalRequired {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Enable before approval expected approval_required, got %v", err)}
+	}
+
+	// The submitter cannot approve their own submission
+	if _, err := ctx.StrategyService.SubmitStrategyForApproval(large.ID, "admin-a", "quarterly grant"); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Submit failed: %v", err)}
+	}
+	if _, err := ctx.StrategyService.ApproveStrategy(large.ID, "admin-a", ""); serviceErrorCode(err) != services.ErrorValidationFailed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Self approval expected validation_failed, got %v", err)}
+	}
+	if _, err := ctx.StrategyService.ApproveStrategy(large.ID, "admin-b", "ok"); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Approve failed: %v", err)}
+	}
+	if err := ctx.StrategyService.EnableStrategy(large.ID); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Enable after approval failed: %v", err)}
+	}
+
+	// Raising the amount of an approved strategy drops the approval and disables it
+	if err := ctx.StrategyService.UpdateStrategy(large.ID, map[string]interface{}{"amount": 600.0}); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Update amount failed: %v", err)}
+	}
+	updated, err := ctx.StrategyService.GetStrategy(large.ID)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Get strategy failed: %v", err)}
+	}
+	if updated.ApprovalStatus != models.ApprovalStatusDraft || updated.IsEnabled() {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Changed strategy expected disabled draft, got %s/%v", updated.ApprovalStatus, updated.Status)}
+	}
+
+	approvals, err := ctx.StrategyService.GetStrategyApprovals(large.ID)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Get approvals failed: %v", err)}
+	}
+	var actions []string
+	for _, approval := range approvals {
+		actions = append(actions, approval.Action)
+	}
+	expected := []string{models.ApprovalActionSubmit, models.ApprovalActionApprove, models.ApprovalActionRevoke}
+	if fmt.Sprint(actions) != fmt.Sprint(expected) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Approval trail expected %v, got %v", expected, actions)}
+	}
+
+	// Strategies below the thresholds cannot be submitted
+	if _, err := ctx.StrategyService.SubmitStrategyForApproval(small.ID, "admin-a", ""); serviceErrorCode(err) != services.ErrorValidationFailed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Submitting a small strategy expected validation_failed, got %v", err)}
+	}
+
+	// A formula without a cap can grant any amount, so it needs approval and can be submitted
+	formula := &models.QuotaStrategy{
+		Name:       "approval-uncapped-formula-test",
+		Title:      "Approval Uncapped Formula Test",
+		Type:       "single",
+		AmountExpr: "50 * vip",
+		Condition:  "false()",
+		Status:     true,
+	}
+	if err := ctx.StrategyService.CreateStrategy(formula); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create formula strategy failed: %v", err)}
+	}
+	if formula.ApprovalStatus != models.ApprovalStatusDraft || formula.IsEnabled() {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Uncapped formula strategy expected disabled draft, got %s/%v", formula.ApprovalStatus, formula.Status)}
+	}
+	if _, err := ctx.StrategyService.SubmitStrategyForApproval(formula.ID, "admin-a", "vip grant"); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Submit uncapped formula strategy failed: %v", err)}
+	}
+	if _, err := ctx.StrategyService.ApproveStrategy(formula.ID, "admin-b", "ok"); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Approve uncapped formula strategy failed: %v", err)}
+	}
+	if err := ctx.StrategyService.EnableStrategy(formula.ID); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Enable uncapped formula strategy failed: %v", err)}
+	}
+
+	return TestResult{Passed: true, Message: "Strategy Approval Gating Test Succeeded"}
+}
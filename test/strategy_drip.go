@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+	"time"
+)
+
+// testDripStrategy tests that drip strategies release their amount in installments and can be cancelled
+func testDripStrategy(ctx *TestContext) TestResult {
+	user := createTestUser("user_drip_test", "Drip Test User", 0)
+	if err := ctx.DB.AuthDB.Create(user).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create user failed: %v", err)}
+	}
+
+	strategy := &models.QuotaStrategy{
+		Name:             "drip-strategy-test",
+		Title:            "Drip Strategy Test",
+		Type:             "single",
+		Amount:           decimal.New(100),
+		Condition:        "true()",
+		Status:           true,
+		DripInstallments: 3,
+		DripInterval:     "24h",
+	}
+	if err := ctx.StrategyService.CreateStrategy(strategy); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create strategy failed: %v", err)}
+	}
+
+	// The first installment is released with the plan
+	ctx.StrategyService.ExecStrategy(strategy, []models.UserInfo{*user})
+
+	plans, err := ctx.StrategyService.GetUserDripPlans(user.ID, "")
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Get drip plans failed: %v", err)}
+	}
+	if len(plans) != 1 || len(plans[0].Items) != 3 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 1 plan with 3 installments, got %d plans", len(plans))}
+	}
+	plan := plans[0]
+	// 100 split into whole cents, the last installment takes the remainder
+	expectedAmounts := []string{"33.33", "33.33", "33.34"}
+	for i, item := range plan.Items {
+		if item.Amount.StringFixed() != expectedAmounts[i] {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Installment %d expected %s, got %s", i+1, expectedAmounts[i], item.Amount.StringFixed())}
+		}
+	}
+	if plan.Items[0].Status != models.DripInstallmentReleased || plan.Items[1].Status != models.DripInstallmentPending {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected first installment released and second pending, got %s/%s", plan.Items[0].Status, plan.Items[1].Status)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(user.ID); total != 33.33 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 33.33 after first installment, got %f", total)}
+	}
+
+	// Bring the second installment due and release it
+	if err := ctx.DB.Model(&models.DripInstallment{}).Where("id = ?", plan.Items[1].ID).
+		Update("release_time", time.Now().Add(-time.Minute)).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Move installment failed: %v", err)}
+	}
+	ctx.StrategyService.ReleaseDueDripInstallments()
+	if total := ctx.MockQuotaStore.GetQuota(user.ID); total != 66.66 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 66.66 after second installment, got %f", total)}
+	}
+
+	// Cancelling takes back only the outstanding installment
+	result, err := ctx.StrategyService.CancelUserDripPlans(user.ID, plan.ID)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Cancel drip plan failed: %v", err)}
+	}
+	if result.CancelledInstallments != 1 || result.CancelledAmount.StringFixed() != "33.34" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 1 cancelled installment of 33.34, got %d/%s", result.CancelledInstallments, result.CancelledAmount.StringFixed())}
+	}
+	var cancelled models.DripPlan
+	if err := ctx.DB.First(&cancelled, plan.ID).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Reload plan failed: %v", err)}
+	}
+	if cancelled.Status != models.DripPlanStatusCancelled || cancelled.ReleasedAmount.StringFixed() != "66.66" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected cancelled plan with 66.66 released, got %s/%s", cancelled.Status, cancelled.ReleasedAmount.StringFixed())}
+	}
+
+	return TestResult{Passed: true, Message: "Drip Strategy Test Succeeded"}
+}
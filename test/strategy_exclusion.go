@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+	"time"
+)
+
+// testExclusionGroupPriority tests that a user gets at most one grant per exclusion group, by priority
+func testExclusionGroupPriority(ctx *TestContext) TestResult {
+	user := createTestUser("user_exclusion_test", "Exclusion Test User", 0)
+	if err := ctx.DB.AuthDB.Create(user).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create user failed: %v", err)}
+	}
+
+	high := &models.QuotaStrategy{
+		Name:           "exclusion-high-priority-test",
+		Title:          "Exclusion High Priority Test",
+		Type:           "single",
+		Amount:         decimal.New(50),
+		Condition:      "true()",
+		Status:         true,
+		ExclusionGroup: "exclusion-priority-test-group",
+		Priority:       10,
+	}
+	low := &models.QuotaStrategy{
+		Name:           "exclusion-low-priority-test",
+		Title:          "Exclusion Low Priority Test",
+		Type:           "single",
+		Amount:         decimal.New(20),
+		Condition:      "true()",
+		Status:         true,
+		ExclusionGroup: "exclusion-priority-test-group",
+		Priority:       1,
+	}
+	for _, strategy := range []*models.QuotaStrategy{high, low} {
+		if err := ctx.StrategyService.CreateStrategy(strategy); err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Create strategy %s failed: %v", strategy.Name, err)}
+		}
+	}
+
+	users := []models.UserInfo{*user}
+
+	// The low priority strategy defers to the matching high priority one that has not granted yet
+	ctx.StrategyService.ExecStrategy(low, users)
+	if total := ctx.MockQuotaStore.GetQuota(user.ID); total != 0 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Low priority strategy granted %f before the high priority one", total)}
+	}
+
+	ctx.StrategyService.ExecStrategy(high, users)
+	// Once the high priority strategy granted, the low priority one stays blocked for the period
+	ctx.StrategyService.ExecStrategy(low, users)
+	if total := ctx.MockQuotaStore.GetQuota(user.ID); total != 50 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected only the high priority grant of 50, got %f", total)}
+	}
+
+	var lowExecutes int64
+	ctx.DB.Model(&models.QuotaExecute{}).Where("strategy_id = ? AND user_id = ? AND status = ?", low.ID, user.ID, "completed").Count(&lowExecutes)
+	if lowExecutes != 0 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Low priority strategy completed %d executions", lowExecutes)}
+	}
+
+	groups, err := ctx.StrategyService.GetExclusionGroups()
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Get exclusion groups failed: %v", err)}
+	}
+	for _, group := range groups {
+		if group.Group != "exclusion-priority-test-group" {
+			continue
+		}
+		if len(group.Members) != 2 || group.Members[0].ID != high.ID || len(group.Conflicts) != 0 {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected exclusion group summary: %+v", group)}
+		}
+		return TestResult{Passed: true, Message: "Exclusion Group Priority Test Succeeded"}
+	}
+	return TestResult{Passed: false, Message: "Exclusion group missing from the summary"}
+}
+
+// testExclusionGroupPeriodicPeriod tests that a periodic strategy checks the group's grants
+// since its own previous fire time: a daily strategy is blocked by a grant of the last day,
+// not by one from earlier in the month
+func testExclusionGroupPeriodicPeriod(ctx *TestContext) TestResult {
+	high := &models.QuotaStrategy{
+		Name:           "exclusion-period-high-test",
+		Title:          "Exclusion Period High Test",
+		Type:           "single",
+		Amount:         decimal.New(50),
+		Condition:      "false()",
+		Status:         true,
+		ExclusionGroup: "exclusion-period-test-group",
+		Priority:       10,
+	}
+	daily := &models.QuotaStrategy{
+		Name:           "exclusion-period-daily-test",
+		Title:          "Exclusion Period Daily Test",
+		Type:           "periodic",
+		PeriodicExpr:   "0 0 0 * * *",
+		Amount:         decimal.New(20),
+		Condition:      "true()",
+		Status:         true,
+		ExclusionGroup: "exclusion-period-test-group",
+		Priority:       1,
+	}
+	for _, strategy := range []*models.QuotaStrategy{high, daily} {
+		if err := ctx.StrategyService.CreateStrategy(strategy); err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Create strategy %s failed: %v", strategy.Name, err)}
+		}
+	}
+
+	// One user was granted by the group three days ago, the other a minute ago
+	grantedAt := map[string]time.Duration{"user_exclusion_period_old": 72 * time.Hour, "user_exclusion_period_recent": time.Minute}
+	users := make(map[string]*models.UserInfo, len(grantedAt))
+	for name, age := range grantedAt {
+		user := createTestUser(name, name, 0)
+		if err := ctx.DB.AuthDB.Create(user).Error; err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Create user failed: %v", err)}
+		}
+		if err := ctx.DB.Create(&models.QuotaExecute{
+			StrategyID:  high.ID,
+			User:        user.ID,
+			BatchNumber: "exclusion-period-test",
+			Status:      "completed",
+			ExpiryDate:  time.Now().AddDate(0, 1, 0),
+			CreateTime:  time.Now().Add(-age),
+		}).Error; err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Create execute record failed: %v", err)}
+		}
+		users[name] = user
+	}
+
+	ctx.StrategyService.ExecStrategy(daily, []models.UserInfo{*users["user_exclusion_period_old"], *users["user_exclusion_period_recent"]})
+	if total := ctx.MockQuotaStore.GetQuota(users["user_exclusion_period_old"].ID); total != 20 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("A grant before the previous daily fire blocked the daily strategy, got %f", total)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(users["user_exclusion_period_recent"].ID); total != 0 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("A grant within the daily period did not block the daily strategy, got %f", total)}
+	}
+
+	return TestResult{Passed: true, Message: "Exclusion Group Periodic Period Test Succeeded"}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/internal/services"
+	"time"
+)
+
+// testCronSchedulePreview tests the description and next fire times of periodic strategies
+func testCronSchedulePreview(ctx *TestContext) TestResult {
+	preview, err := services.PreviewCron("0 0 9 * * 1", "Asia/Shanghai", 3)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Preview failed: %v", err)}
+	}
+	if preview.Description != "At 09:00:00, on Monday" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected description: %q", preview.Description)}
+	}
+	if preview.Timezone != "Asia/Shanghai" || len(preview.NextFireTimes) != 3 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 3 fire times in Asia/Shanghai, got %d in %s", len(preview.NextFireTimes), preview.Timezone)}
+	}
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	for i, fireTime := range preview.NextFireTimes {
+		local := fireTime.In(loc)
+		if local.Weekday() != time.Monday || local.Hour() != 9 || local.Minute() != 0 {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Fire time %d is not Monday 09:00 in Asia/Shanghai: %s", i, local)}
+		}
+		if i > 0 && local.Sub(preview.NextFireTimes[i-1]) != 7*24*time.Hour {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Fire times %d and %d are not a week apart", i-1, i)}
+		}
+	}
+
+	// Counts are capped and invalid input is a validation error
+	preview, err = services.PreviewCron("0 * * * * *", "", 1000)
+	if err != nil || len(preview.NextFireTimes) != services.MaxCronPreviewCount {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected %d capped fire times, got %v", services.MaxCronPreviewCount, err)}
+	}
+	if _, err := services.PreviewCron("not a cron", "", 3); serviceErrorCode(err) != services.ErrorValidationFailed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Invalid expression expected validation_failed, got %v", err)}
+	}
+	if _, err := services.PreviewCron("CRON_TZ=UTC 0 0 9 * * 1", "", 3); serviceErrorCode(err) != services.ErrorValidationFailed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Inline timezone expected validation_failed, got %v", err)}
+	}
+
+	// Only periodic strategies have a schedule
+	periodic := &models.QuotaStrategy{Type: "periodic", PeriodicExpr: "0 30 8 1 * *"}
+	if schedule := ctx.StrategyService.PreviewStrategySchedule(periodic); schedule == nil || schedule.Description != "At 08:30:00, on day 1 of the month" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected periodic strategy schedule: %+v", schedule)}
+	}
+	if schedule := ctx.StrategyService.PreviewStrategySchedule(&models.QuotaStrategy{Type: "single"}); schedule != nil {
+		return TestResult{Passed: false, Message: "Single strategy expected no schedule"}
+	}
+
+	return TestResult{Passed: true, Message: "Cron Schedule Preview Test Succeeded"}
+}
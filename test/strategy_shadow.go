@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+)
+
+// testShadowStrategy tests that shadow strategies record would-be grants without applying them
+func testShadowStrategy(ctx *TestContext) TestResult {
+	user := createTestUser("user_shadow_test", "Shadow Test User", 0)
+	if err := ctx.DB.AuthDB.Create(user).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create user failed: %v", err)}
+	}
+
+	strategy := &models.QuotaStrategy{
+		Name:      "shadow-strategy-test",
+		Title:     "Shadow Strategy Test",
+		Type:      "single",
+		Amount:    decimal.New(40),
+		Condition: "true()",
+		Status:    true,
+		Shadow:    true,
+	}
+	if err := ctx.StrategyService.CreateStrategy(strategy); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create strategy failed: %v", err)}
+	}
+
+	users := []models.UserInfo{*user}
+	ctx.StrategyService.ExecStrategy(strategy, users)
+	// A single shadow strategy records one would-be grant per user, like a live one
+	ctx.StrategyService.ExecStrategy(strategy, users)
+
+	var results []models.StrategyShadowResult
+	if err := ctx.DB.Where("strategy_id = ? AND user_id = ?", strategy.ID, user.ID).Find(&results).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Query shadow results failed: %v", err)}
+	}
+	if len(results) != 1 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 1 shadow result, got %d", len(results))}
+	}
+	if !results[0].Amount.Equal(decimal.New(40)) || results[0].ConditionTrace == "" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Shadow result expected amount 40 with a trace, got %s/%q", results[0].Amount, results[0].ConditionTrace)}
+	}
+
+	// Nothing is granted: no executions, quota rows, audits or gateway calls
+	var executeCount, quotaCount, auditCount int64
+	ctx.DB.Model(&models.QuotaExecute{}).Where("strategy_id = ?", strategy.ID).Count(&executeCount)
+	ctx.DB.Model(&models.Quota{}).Where("user_id = ?", user.ID).Count(&quotaCount)
+	ctx.DB.Model(&models.QuotaAudit{}).Where("user_id = ?", user.ID).Count(&auditCount)
+	if executeCount != 0 || quotaCount != 0 || auditCount != 0 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Shadow strategy granted quota: executes=%d quotas=%d audits=%d", executeCount, quotaCount, auditCount)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(user.ID); total != 0 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Shadow strategy changed gateway quota to %f", total)}
+	}
+
+	return TestResult{Passed: true, Message: "Shadow Strategy Test Succeeded"}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"quota-manager/internal/models"
+	"quota-manager/pkg/decimal"
+)
+
+// testTopupStrategy tests that topup strategies grant only below the threshold and within the period cap
+func testTopupStrategy(ctx *TestContext) TestResult {
+	lowUser := createTestUser("user_topup_low", "Topup Low Balance User", 0)
+	highUser := createTestUser("user_topup_high", "Topup High Balance User", 0)
+	for _, user := range []*models.UserInfo{lowUser, highUser} {
+		if err := ctx.DB.AuthDB.Create(user).Error; err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Create user failed: %v", err)}
+		}
+	}
+
+	// Remaining balance is total - used on the gateway: 2 for the low user, 50 for the high user
+	ctx.MockQuotaStore.SetQuota(lowUser.ID, 10)
+	ctx.MockQuotaStore.SetUsed(lowUser.ID, 8)
+	ctx.MockQuotaStore.SetQuota(highUser.ID, 60)
+	ctx.MockQuotaStore.SetUsed(highUser.ID, 10)
+
+	strategy := &models.QuotaStrategy{
+		Name:              "topup-strategy-test",
+		Title:             "Topup Strategy Test",
+		Type:              "topup",
+		Amount:            decimal.New(20),
+		Condition:         "true()",
+		Status:            true,
+		TopupThreshold:    decimal.New(5),
+		TopupPeriod:       "day",
+		TopupMaxPerPeriod: 1,
+	}
+	if err := ctx.StrategyService.CreateStrategy(strategy); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create strategy failed: %v", err)}
+	}
+
+	users := []models.UserInfo{*lowUser, *highUser}
+	ctx.StrategyService.ExecStrategy(strategy, users)
+
+	var executes []models.TopupExecute
+	if err := ctx.DB.Where("strategy_id = ?", strategy.ID).Find(&executes).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Query topup records failed: %v", err)}
+	}
+	if len(executes) != 1 || executes[0].User != lowUser.ID || executes[0].Status != "completed" {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected one completed topup of the low balance user, got %+v", executes)}
+	}
+	if !executes[0].BalanceBefore.Equal(decimal.New(2)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected balance before 2, got %s", executes[0].BalanceBefore)}
+	}
+	if total := ctx.MockQuotaStore.GetQuota(lowUser.ID); total != 30 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected gateway total 30 after topup, got %f", total)}
+	}
+	if err := verifyStrategyNameInAudit(ctx, lowUser.ID, strategy.Name, models.OperationTopup); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Topup audit verification failed: %v", err)}
+	}
+
+	// The per-day cap stops a second topup even when the balance drops again
+	ctx.MockQuotaStore.SetUsed(lowUser.ID, 29)
+	ctx.StrategyService.ExecStrategy(strategy, users)
+
+	var count int64
+	ctx.DB.Model(&models.TopupExecute{}).Where("strategy_id = ?", strategy.ID).Count(&count)
+	if count != 1 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected the daily cap to keep 1 topup, got %d", count)}
+	}
+
+	return TestResult{Passed: true, Message: "Topup Strategy Test Succeeded"}
+}
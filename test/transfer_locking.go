@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"quota-manager/pkg/decimal"
+	"sync"
+	"time"
+
+	"quota-manager/internal/models"
+	"quota-manager/internal/services"
+)
+
+// testConcurrentTransferOutLocking tests that concurrent transfers cannot spend the same quota twice
+func testConcurrentTransferOutLocking(ctx *TestContext) TestResult {
+	giver := createTestUser("user_transfer_lock_giver", "Transfer Lock Giver", 0)
+	receiver := createTestUser("user_transfer_lock_receiver", "Transfer Lock Receiver", 0)
+	for _, user := range []*models.UserInfo{giver, receiver} {
+		if err := ctx.DB.AuthDB.Create(user).Error; err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Create user failed: %v", err)}
+		}
+	}
+
+	expiryDate := time.Now().Truncate(time.Second).Add(30 * 24 * time.Hour)
+	if err := ctx.DB.Create(&models.Quota{
+		UserID:     giver.ID,
+		Amount:     decimal.New(100),
+		ExpiryDate: expiryDate,
+		Status:     models.StatusValid,
+	}).Error; err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Create initial quota failed: %v", err)}
+	}
+	ctx.MockQuotaStore.SetQuota(giver.ID, 100)
+
+	giverAuth := &models.AuthUser{ID: giver.ID, Name: giver.Name, Github: giver.GithubName, Phone: giver.Phone}
+	req := &services.TransferOutRequest{
+		ReceiverID: receiver.ID,
+		QuotaList:  []services.TransferQuotaItem{{Amount: decimal.New(60), ExpiryDate: expiryDate}},
+	}
+
+	// Two transfers of 60 out of 100: the bucket lock lets only one of them through
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = ctx.QuotaService.TransferOut(giverAuth, req)
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected exactly 1 of 2 concurrent transfers to succeed, got %d (%v)", succeeded, errs)}
+	}
+
+	var remaining decimal.Decimal
+	ctx.DB.Model(&models.Quota{}).Where("user_id = ? AND status = ?", giver.ID, models.StatusValid).
+		Select("COALESCE(SUM(amount), 0)").Scan(&remaining)
+	if !remaining.Equal(decimal.New(40)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 40 left after one transfer, got %s", remaining)}
+	}
+
+	return TestResult{Passed: true, Message: "Concurrent Transfer Out Locking Test Succeeded"}
+}
+
+// testTransferIdempotencyKeys tests claiming, replaying and conflicting idempotency keys
+func testTransferIdempotencyKeys(ctx *TestContext) TestResult {
+	userID := "idempotency-test-user"
+	endpoint := "/quota-manager/api/v1/quota/transfer-out"
+	hash := services.HashIdempotentRequest([]byte(`{"receiver_id":"r1"}`))
+
+	record, replay, err := ctx.QuotaService.BeginIdempotentRequest(userID, endpoint, "key-1", hash)
+	if err != nil || replay {
+		return TestResult{Passed: false, Message: fmt.Sprintf("First claim expected a new record, got replay=%v err=%v", replay, err)}
+	}
+
+	// A retry while the first request runs is a conflict
+	if _, _, err := ctx.QuotaService.BeginIdempotentRequest(userID, endpoint, "key-1", hash); serviceErrorCode(err) != services.ErrorConflict {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Retry during processing expected conflict, got %v", err)}
+	}
+
+	if err := ctx.QuotaService.CompleteIdempotentRequest(record, http.StatusOK, []byte(`{"voucher_code":"abc"}`)); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Complete failed: %v", err)}
+	}
+
+	// A completed key replays the stored response
+	stored, replay, err := ctx.QuotaService.BeginIdempotentRequest(userID, endpoint, "key-1", hash)
+	if err != nil || !replay || stored.ResponseCode != http.StatusOK || stored.ResponseBody != `{"voucher_code":"abc"}` {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Completed key expected a replay, got replay=%v err=%v", replay, err)}
+	}
+
+	// Reusing the key for a different request is a conflict
+	otherHash := services.HashIdempotentRequest([]byte(`{"receiver_id":"r2"}`))
+	if _, _, err := ctx.QuotaService.BeginIdempotentRequest(userID, endpoint, "key-1", otherHash); serviceErrorCode(err) != services.ErrorConflict {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Different request expected conflict, got %v", err)}
+	}
+
+	// A released claim can be taken again
+	released, _, err := ctx.QuotaService.BeginIdempotentRequest(userID, endpoint, "key-2", hash)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Claim key-2 failed: %v", err)}
+	}
+	if err := ctx.QuotaService.ReleaseIdempotentRequest(released); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Release failed: %v", err)}
+	}
+	if _, replay, err := ctx.QuotaService.BeginIdempotentRequest(userID, endpoint, "key-2", hash); err != nil || replay {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Released key expected a new claim, got replay=%v err=%v", replay, err)}
+	}
+
+	return TestResult{Passed: true, Message: "Transfer Idempotency Keys Test Succeeded"}
+}
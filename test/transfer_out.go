@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"quota-manager/pkg/decimal"
 	"strings"
 	"time"
 
@@ -19,8 +20,8 @@ func testVoucherGenerationAndValidation(ctx *TestContext) TestResult {
 		GiverGithub: "zhangsan",
 		ReceiverID:  "receiver456",
 		QuotaList: []services.VoucherQuotaItem{
-			{Amount: 10, ExpiryDate: time.Now().Truncate(time.Second).Add(30 * 24 * time.Hour)},
-			{Amount: 20, ExpiryDate: time.Now().Truncate(time.Second).Add(60 * 24 * time.Hour)},
+			{Amount: decimal.New(10), ExpiryDate: time.Now().Truncate(time.Second).Add(30 * 24 * time.Hour)},
+			{Amount: decimal.New(20), ExpiryDate: time.Now().Truncate(time.Second).Add(60 * 24 * time.Hour)},
 		},
 	}
 
@@ -69,7 +70,7 @@ func testQuotaTransferOut(ctx *TestContext) TestResult {
 	expiryDate := time.Now().Truncate(time.Second).Add(30 * 24 * time.Hour)
 	quota := &models.Quota{
 		UserID:     giver.ID,
-		Amount:     100,
+		Amount:     decimal.New(100),
 		ExpiryDate: expiryDate,
 		Status:     models.StatusValid,
 	}
@@ -90,7 +91,7 @@ func testQuotaTransferOut(ctx *TestContext) TestResult {
 	transferReq := &services.TransferOutRequest{
 		ReceiverID: "receiver_user",
 		QuotaList: []services.TransferQuotaItem{
-			{Amount: 30, ExpiryDate: expiryDate},
+			{Amount: decimal.New(30), ExpiryDate: expiryDate},
 		},
 	}
 
@@ -110,8 +111,8 @@ func testQuotaTransferOut(ctx *TestContext) TestResult {
 		return TestResult{Passed: false, Message: fmt.Sprintf("Failed to get updated quota: %v", err)}
 	}
 
-	if updatedQuota.Amount != 70 {
-		return TestResult{Passed: false, Message: fmt.Sprintf("Expected remaining quota 70, got %g", updatedQuota.Amount)}
+	if updatedQuota.Amount.Float64() != 70 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected remaining quota 70, got %g", updatedQuota.Amount.Float64())}
 	}
 
 	// Verify audit record
@@ -120,8 +121,8 @@ func testQuotaTransferOut(ctx *TestContext) TestResult {
 		return TestResult{Passed: false, Message: fmt.Sprintf("Failed to get audit record: %v", err)}
 	}
 
-	if auditRecord.Amount != -30 {
-		return TestResult{Passed: false, Message: fmt.Sprintf("Expected audit amount -30, got %g", auditRecord.Amount)}
+	if auditRecord.Amount.Float64() != -30 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected audit amount -30, got %g", auditRecord.Amount.Float64())}
 	}
 
 	// Verify no strategy name in transfer out audit record
@@ -154,7 +155,7 @@ func testTransferOutInsufficientAvailable(ctx *TestContext) TestResult {
 	earlyExpiry := now.AddDate(0, 0, 10)
 	if err := ctx.DB.Create(&models.Quota{
 		UserID:     user1.ID,
-		Amount:     100,
+		Amount:     decimal.New(100),
 		ExpiryDate: earlyExpiry,
 		Status:     models.StatusValid,
 	}).Error; err != nil {
@@ -165,7 +166,7 @@ func testTransferOutInsufficientAvailable(ctx *TestContext) TestResult {
 	lateExpiry := now.AddDate(0, 0, 30)
 	if err := ctx.DB.Create(&models.Quota{
 		UserID:     user1.ID,
-		Amount:     100,
+		Amount:     decimal.New(100),
 		ExpiryDate: lateExpiry,
 		Status:     models.StatusValid,
 	}).Error; err != nil {
@@ -174,13 +175,13 @@ func testTransferOutInsufficientAvailable(ctx *TestContext) TestResult {
 
 	// Consume 120 quota (should consume all 100 from early + 20 from late)
 	// This leaves 80 available in late-expiry quota
-	ctx.Gateway.DeltaUsedQuota(user1.ID, 120)
+	ctx.Gateway.DeltaUsedQuota(user1.ID, decimal.New(120))
 
 	// Try to transfer 90 quota with early expiry date (should fail - only has 0 available with early expiry)
 	transferOutReq := &services.TransferOutRequest{
 		ReceiverID: user2.ID,
 		QuotaList: []services.TransferQuotaItem{
-			{Amount: 90, ExpiryDate: earlyExpiry},
+			{Amount: decimal.New(90), ExpiryDate: earlyExpiry},
 		},
 	}
 	_, err := ctx.QuotaService.TransferOut(&models.AuthUser{
@@ -200,7 +201,7 @@ func testTransferOutInsufficientAvailable(ctx *TestContext) TestResult {
 	transferOutReq2 := &services.TransferOutRequest{
 		ReceiverID: user2.ID,
 		QuotaList: []services.TransferQuotaItem{
-			{Amount: 80, ExpiryDate: lateExpiry},
+			{Amount: decimal.New(80), ExpiryDate: lateExpiry},
 		},
 	}
 	_, err = ctx.QuotaService.TransferOut(&models.AuthUser{
@@ -218,16 +219,16 @@ func testTransferOutInsufficientAvailable(ctx *TestContext) TestResult {
 	}
 
 	// Should have 0 remaining quota (all consumed or transferred)
-	actualRemaining1 := quotaInfo1.TotalQuota - quotaInfo1.UsedQuota
-	if actualRemaining1 != 0 {
-		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 0 remaining quota, got %g", actualRemaining1)}
+	actualRemaining1 := quotaInfo1.TotalQuota.Sub(quotaInfo1.UsedQuota)
+	if !actualRemaining1.IsZero() {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 0 remaining quota, got %g", actualRemaining1.Float64())}
 	}
 
 	// Try to transfer 1 more quota (should fail - no remaining quota)
 	transferOutReq3 := &services.TransferOutRequest{
 		ReceiverID: user2.ID,
 		QuotaList: []services.TransferQuotaItem{
-			{Amount: 1, ExpiryDate: lateExpiry},
+			{Amount: decimal.New(1), ExpiryDate: lateExpiry},
 		},
 	}
 	_, err = ctx.QuotaService.TransferOut(&models.AuthUser{
@@ -266,7 +267,7 @@ func testTransferEarliestExpiryDate(ctx *TestContext) TestResult {
 	// Add quotas in non-chronological order to test ordering
 	if err := ctx.DB.Create(&models.Quota{
 		UserID:     user1.ID,
-		Amount:     50,
+		Amount:     decimal.New(50),
 		ExpiryDate: expiry2, // Middle expiry
 		Status:     models.StatusValid,
 	}).Error; err != nil {
@@ -275,7 +276,7 @@ func testTransferEarliestExpiryDate(ctx *TestContext) TestResult {
 
 	if err := ctx.DB.Create(&models.Quota{
 		UserID:     user1.ID,
-		Amount:     100,
+		Amount:     decimal.New(100),
 		ExpiryDate: expiry1, // Earliest expiry
 		Status:     models.StatusValid,
 	}).Error; err != nil {
@@ -284,7 +285,7 @@ func testTransferEarliestExpiryDate(ctx *TestContext) TestResult {
 
 	if err := ctx.DB.Create(&models.Quota{
 		UserID:     user1.ID,
-		Amount:     150,
+		Amount:     decimal.New(150),
 		ExpiryDate: expiry3, // Latest expiry
 		Status:     models.StatusValid,
 	}).Error; err != nil {
@@ -295,9 +296,9 @@ func testTransferEarliestExpiryDate(ctx *TestContext) TestResult {
 	transferOutReq := &services.TransferOutRequest{
 		ReceiverID: user2.ID,
 		QuotaList: []services.TransferQuotaItem{
-			{Amount: 50, ExpiryDate: expiry2}, // Middle expiry
-			{Amount: 80, ExpiryDate: expiry1}, // Earliest expiry
-			{Amount: 70, ExpiryDate: expiry3}, // Latest expiry
+			{Amount: decimal.New(50), ExpiryDate: expiry2}, // Middle expiry
+			{Amount: decimal.New(80), ExpiryDate: expiry1}, // Earliest expiry
+			{Amount: decimal.New(70), ExpiryDate: expiry3}, // Latest expiry
 		},
 	}
 
@@ -364,7 +365,7 @@ func testTransferEarliestExpiryDate(ctx *TestContext) TestResult {
 	mockStore.DeltaQuota(user1.ID, 200) // Add 200 more to mock store
 	if err := ctx.DB.Create(&models.Quota{
 		UserID:     user1.ID,
-		Amount:     200,
+		Amount:     decimal.New(200),
 		ExpiryDate: expiry2, // Middle expiry
 		Status:     models.StatusValid,
 	}).Error; err != nil {
@@ -375,8 +376,8 @@ func testTransferEarliestExpiryDate(ctx *TestContext) TestResult {
 	transferOutReq2 := &services.TransferOutRequest{
 		ReceiverID: user2.ID,
 		QuotaList: []services.TransferQuotaItem{
-			{Amount: 30, ExpiryDate: expiry3}, // Latest expiry
-			{Amount: 40, ExpiryDate: expiry2}, // Middle expiry
+			{Amount: decimal.New(30), ExpiryDate: expiry3}, // Latest expiry
+			{Amount: decimal.New(40), ExpiryDate: expiry2}, // Middle expiry
 		},
 	}
 
@@ -419,7 +420,7 @@ func testTransferOutEmptyReceiverID(ctx *TestContext) TestResult {
 	expiryDate := time.Now().Truncate(time.Second).Add(30 * 24 * time.Hour)
 	quota := &models.Quota{
 		UserID:     user.ID,
-		Amount:     100,
+		Amount:     decimal.New(100),
 		ExpiryDate: expiryDate,
 		Status:     models.StatusValid,
 	}
@@ -443,7 +444,7 @@ func testTransferOutEmptyReceiverID(ctx *TestContext) TestResult {
 	transferReq := &services.TransferOutRequest{
 		ReceiverID: "", // Empty receiver_id
 		QuotaList: []services.TransferQuotaItem{
-			{Amount: 30, ExpiryDate: expiryDate},
+			{Amount: decimal.New(30), ExpiryDate: expiryDate},
 		},
 	}
 
@@ -464,8 +465,8 @@ func testTransferOutEmptyReceiverID(ctx *TestContext) TestResult {
 		return TestResult{Passed: false, Message: fmt.Sprintf("Failed to get quota: %v", err)}
 	}
 
-	if unchangedQuota.Amount != 100 {
-		return TestResult{Passed: false, Message: fmt.Sprintf("Expected quota to remain unchanged at 100, got %g", unchangedQuota.Amount)}
+	if unchangedQuota.Amount.Float64() != 100 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected quota to remain unchanged at 100, got %g", unchangedQuota.Amount.Float64())}
 	}
 
 	// Verify no audit record was created for the failed transfer
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"quota-manager/internal/config"
+	"quota-manager/internal/models"
+	"quota-manager/internal/services"
+	"quota-manager/internal/utils"
+	"quota-manager/pkg/decimal"
+)
+
+// testUsageSnapshotAnalytics tests that the daily snapshot records each user's gateway counters
+// with the usage since the previous snapshot, and that the series, top consumers, department
+// aggregates and monthly trends read those snapshots
+func testUsageSnapshotAnalytics(ctx *TestContext) TestResult {
+	cfg, err := config.LoadConfig("config.yaml")
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Failed to load config: %v", err)}
+	}
+	now := utils.NowInConfigTimezone(cfg)
+	today := now.Format("2006-01-02")
+	lastMonth := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, now.Location())
+
+	departments := map[string]string{"usage_alice": "UsageTestCo,Platform", "usage_bob": "UsageTestCo,Data"}
+	userIDs := make(map[string]string, len(departments))
+	for _, name := range []string{"usage_alice", "usage_bob"} {
+		user := createTestUser(name, name, 0)
+		if err := ctx.DB.AuthDB.Create(user).Error; err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Create user failed: %v", err)}
+		}
+		if err := ctx.DB.DB.Create(&models.EmployeeDepartment{
+			EmployeeNumber:     user.EmployeeNumber,
+			Username:           name,
+			DeptFullLevelNames: departments[name],
+		}).Error; err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Create employee department failed: %v", err)}
+		}
+		userIDs[name] = user.ID
+	}
+	alice, bob := userIDs["usage_alice"], userIDs["usage_bob"]
+
+	// Last month's snapshots are the baseline of today's usage
+	baselines := []models.DailyQuotaUsage{
+		{UserID: alice, UsedQuota: decimal.New(5), Usage: decimal.New(10), Department: departments["usage_alice"]},
+		{UserID: bob, Department: departments["usage_bob"]},
+	}
+	for i := range baselines {
+		baselines[i].SnapshotDate = lastMonth.Format("2006-01-02")
+		baselines[i].RecordTime = lastMonth
+		if err := ctx.DB.Create(&baselines[i]).Error; err != nil {
+			return TestResult{Passed: false, Message: fmt.Sprintf("Create snapshot failed: %v", err)}
+		}
+	}
+	if err := grantTestQuota(ctx, alice, 100); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Grant failed: %v", err)}
+	}
+	if err := grantTestQuota(ctx, bob, 50); err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Grant failed: %v", err)}
+	}
+	ctx.MockQuotaStore.SetUsed(alice, 20)
+	ctx.MockQuotaStore.SetUsed(bob, 15)
+
+	// A second run the same day refreshes the day's snapshot instead of adding one
+	ctx.QuotaService.SnapshotDailyUsage()
+	ctx.QuotaService.SnapshotDailyUsage()
+	var snapshots []models.DailyQuotaUsage
+	if err := ctx.DB.Where("user_id = ? AND snapshot_date = ?", alice, today).Find(&snapshots).Error; err != nil || len(snapshots) != 1 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected one snapshot of today, got %d (%v)", len(snapshots), err)}
+	}
+	snapshot := snapshots[0]
+	if !snapshot.TotalQuota.Equal(decimal.New(100)) || !snapshot.UsedQuota.Equal(decimal.New(20)) ||
+		!snapshot.Usage.Equal(decimal.New(15)) || snapshot.Department != departments["usage_alice"] {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected total 100, used 20, usage 15 in Platform, got %s/%s/%s in %s",
+			snapshot.TotalQuota, snapshot.UsedQuota, snapshot.Usage, snapshot.Department)}
+	}
+
+	series, err := ctx.QuotaService.GetUsageSeries(alice, lastMonth.Format("2006-01-02"), today)
+	if err != nil || len(series.Points) != 2 || series.Points[1].SnapshotDate != today || !series.TotalUsage.Equal(decimal.New(25)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 2 points with usage 25, got %+v (%v)", series, err)}
+	}
+	if _, err := ctx.QuotaService.GetUsageSeries(alice, today, lastMonth.Format("2006-01-02")); serviceErrorCode(err) != services.ErrorValidationFailed {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Reversed range expected validation_failed, got %v", err)}
+	}
+
+	consumers, err := ctx.QuotaService.GetTopConsumers(lastMonth.Format("2006-01-02"), today, "UsageTestCo", 10)
+	if err != nil || len(consumers) != 2 || consumers[0].UserID != alice || !consumers[0].Usage.Equal(decimal.New(25)) ||
+		consumers[1].UserID != bob || !consumers[1].Usage.Equal(decimal.New(15)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected alice 25 then bob 15, got %+v (%v)", consumers, err)}
+	}
+
+	// Departments aggregate at the requested level of the path
+	topLevel, err := ctx.QuotaService.GetDepartmentUsage(lastMonth.Format("2006-01-02"), today, 1)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Department usage failed: %v", err)}
+	}
+	found := false
+	for _, aggregate := range topLevel {
+		if aggregate.Department == "UsageTestCo" {
+			found = aggregate.Users == 2 && aggregate.Usage.Equal(decimal.New(40)) && aggregate.AverageUsage.Equal(decimal.New(20))
+		}
+	}
+	if !found {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected UsageTestCo with 2 users and usage 40, got %+v", topLevel)}
+	}
+	secondLevel, err := ctx.QuotaService.GetDepartmentUsage(lastMonth.Format("2006-01-02"), today, 2)
+	if err != nil {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Department usage failed: %v", err)}
+	}
+	usages := make(map[string]decimal.Decimal)
+	for _, aggregate := range secondLevel {
+		usages[aggregate.Department] = aggregate.Usage
+	}
+	if !usages["Platform"].Equal(decimal.New(25)) || !usages["Data"].Equal(decimal.New(15)) {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected Platform 25 and Data 15, got %+v", secondLevel)}
+	}
+
+	// This month's 15 against last month's 10
+	trends, err := ctx.QuotaService.GetUsageTrends(2, alice, "")
+	if err != nil || len(trends) != 2 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Expected 2 months of trends, got %+v (%v)", trends, err)}
+	}
+	current := trends[1]
+	if trends[0].YearMonth != lastMonth.Format("2006-01") || !trends[0].Usage.Equal(decimal.New(10)) ||
+		current.YearMonth != now.Format("2006-01") || !current.Usage.Equal(decimal.New(15)) || current.ActiveUsers != 1 ||
+		!current.Change.Equal(decimal.New(5)) || current.ChangePercent == nil || *current.ChangePercent != 50 {
+		return TestResult{Passed: false, Message: fmt.Sprintf("Unexpected trends: %+v", trends)}
+	}
+
+	return TestResult{Passed: true, Message: "Usage Snapshot Analytics Test Succeeded"}
+}